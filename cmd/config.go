@@ -27,6 +27,24 @@ monitor:
   namespace: "default"
   labels: ""
   interval: "5m"
+  watch_logs: ""     # 跟随日志的 Pod 选择器，命中 panic / OOM 等严重日志时立即诊断 (注解 kubehealer.io/watch-logs=true 始终生效)
+  max_log_streams: 20 # 同时跟随的日志流上限
+logs:
+  patterns_dir: ""   # 自定义日志模式目录 (*.yaml / *.json 语言包)
+  json_format: auto  # JSON 日志字段约定: auto, zap, logrus, bunyan, structlog
+  tail_lines: 50     # 每个容器最多获取的日志行数 (0 表示不限制)
+  since: 0s          # 只获取最近一段时间的日志，例如 10m (0 表示不限制)
+  limit_bytes: 1048576 # 每个容器最多读取的字节数 (0 表示不限制)
+  timeout: 15s       # 单次日志请求的超时
+  match_context: 2   # 每处日志命中附带的上下文行数 (前后各 N 行)
+  # json_fields:     # 覆盖单个字段名 (按顺序取第一个存在的字段)
+  #   level: ["lvl"]
+  #   message: ["message"]
+  #   error: ["err"]
+  #   stacktrace: ["trace"]
+redact:
+  enabled: true      # 生成报告前脱敏令牌、密码、连接串
+  patterns: []       # 额外的脱敏正则，例如 'X-Api-Key: (?P<secret>\S+)'
 `
 		err := os.WriteFile(configPath, []byte(content), 0644)
 		if err != nil {
@@ -4,7 +4,10 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"strings"
+	"syscall"
 	"time"
 
 	"context"
@@ -18,20 +21,39 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
-// 定义变量存储输出格式
-var outputFormat string
+// 定义变量存储输出格式与目标命名空间
+var (
+	outputFormat       string
+	diagnoseNamespace  string
+	diagnoseDeep       bool
+	diagnoseDeepWindow time.Duration
+)
 
 // diagnoseCmd 代表 diagnose 命令
 var diagnoseCmd = &cobra.Command{
-	Use:   "diagnose [pod-name]",
-	Short: "诊断指定的 Pod",
-	Args:  cobra.ExactArgs(1),
+	Use:   "diagnose [pod-name | <kind>/<name>]",
+	Short: "诊断指定的 Pod 或工作负载",
+	Long: `诊断指定的资源对象。不带类型前缀时默认为 Pod。
+支持的类型: pod, service, deployment, replicaset, statefulset, job, pdb
+
+示例:
+  kubehealer diagnose crash-pod
+  kubehealer diagnose service/web -n shop
+  kubehealer diagnose deployment/web -n shop
+  kubehealer diagnose statefulset/db -n shop
+  kubehealer diagnose pdb/web-pdb -n shop
+  kubehealer diagnose api-5f7c9 --deep --deep-window 1h`,
+	Args: cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
-		podName := args[0]
+		kind, name, err := parseDiagnoseTarget(args[0])
+		if err != nil {
+			logrus.Errorf("❌ 错误: %v\n", err)
+			os.Exit(1)
+		}
 
 		// 只有在默认模式下才打印这行，否则会污染 Markdown 输出
 		if outputFormat == "" || outputFormat == "table" {
-			logrus.Infof("🔍 正在诊断 Pod: %s ...\n\n", podName)
+			logrus.Infof("🔍 正在诊断 %s: %s ...\n\n", kind, name)
 		}
 
 		// 初始化客户端
@@ -41,53 +63,73 @@ var diagnoseCmd = &cobra.Command{
 			os.Exit(1)
 		}
 
-		// 获取 Pod
-		pod, err := client.Clientset.CoreV1().Pods("default").Get(context.TODO(), podName, metav1.GetOptions{})
-		if err != nil {
-			logrus.Errorf("❌ 错误: 无法找到 Pod %s - %v\n", podName, err)
-			os.Exit(1)
-		}
+		// Ctrl+C 时取消正在进行的请求 (例如卡住的日志读取)，而不是一直等下去
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
 
 		// 调用分析器
-		analyzer := diagnosis.NewAnalyzer(client.Clientset)
-		result := analyzer.AnalyzePod(pod)
-
-		// 3. 根据参数选择输出
-		switch outputFormat {
-		case "md", "markdown":
-			md := report.GenerateMarkdown(result)
-			fmt.Println(md)
-		case "json":
-			// MarshalIndent 生成带缩进的 JSON
-			jsonData, err := json.MarshalIndent(result, "", "  ")
+		analyzer := withLogConfig(diagnosis.NewAnalyzer(client.Clientset).WithMetrics(client.Metrics)).WithContext(ctx)
+		if diagnoseDeep {
+			analyzer.WithDeepLogs(diagnoseDeepWindow)
+		}
+
+		switch kind {
+		case "Service":
+			svc, err := client.Clientset.CoreV1().Services(diagnoseNamespace).Get(ctx, name, metav1.GetOptions{})
 			if err != nil {
-				logrus.Errorf("❌ JSON 序列化失败: %v\n", err)
+				logrus.Errorf("❌ 错误: 无法找到 Service %s - %v\n", name, err)
 				os.Exit(1)
 			}
-			fmt.Println(string(jsonData))
-		case "html":
-			// 自动归档到 reports 目录
-			reportDir := "reports"
-			if _, err := os.Stat(reportDir); os.IsNotExist(err) {
-				_ = os.Mkdir(reportDir, 0755) // 创建目录
+			writeWorkloadReport(analyzer.AnalyzeService(svc))
+
+		case "Deployment":
+			dep, err := client.Clientset.AppsV1().Deployments(diagnoseNamespace).Get(ctx, name, metav1.GetOptions{})
+			if err != nil {
+				logrus.Errorf("❌ 错误: 无法找到 Deployment %s - %v\n", name, err)
+				os.Exit(1)
 			}
-			// 动态文件名
-			timestamp := time.Now().Format("20060102_150405")
-			// 格式: reports/pod-name_report_timestamp.html
-			fileName := fmt.Sprintf("%s_report_%s.html", podName, timestamp)
-			fullPath := filepath.Join(reportDir, fileName)
+			writeWorkloadReport(analyzer.AnalyzeDeployment(dep))
 
-			err := report.GenerateHTML(result, fullPath)
+		case "ReplicaSet":
+			rs, err := client.Clientset.AppsV1().ReplicaSets(diagnoseNamespace).Get(ctx, name, metav1.GetOptions{})
 			if err != nil {
-				logrus.Errorf("❌ 生成 HTML 失败: %v\n", err)
+				logrus.Errorf("❌ 错误: 无法找到 ReplicaSet %s - %v\n", name, err)
 				os.Exit(1)
 			}
-			// 获取绝对路径，方便用户点击
-			absPath, _ := filepath.Abs(fullPath)
-			logrus.Infof("✅ 诊断报告已归档: %s\n", absPath)
+			writeWorkloadReport(analyzer.AnalyzeReplicaSet(rs))
+
+		case "StatefulSet":
+			sts, err := client.Clientset.AppsV1().StatefulSets(diagnoseNamespace).Get(ctx, name, metav1.GetOptions{})
+			if err != nil {
+				logrus.Errorf("❌ 错误: 无法找到 StatefulSet %s - %v\n", name, err)
+				os.Exit(1)
+			}
+			writeWorkloadReport(analyzer.AnalyzeStatefulSet(sts))
+
+		case "Job":
+			job, err := client.Clientset.BatchV1().Jobs(diagnoseNamespace).Get(ctx, name, metav1.GetOptions{})
+			if err != nil {
+				logrus.Errorf("❌ 错误: 无法找到 Job %s - %v\n", name, err)
+				os.Exit(1)
+			}
+			writeWorkloadReport(analyzer.AnalyzeJob(job))
+
+		case "PodDisruptionBudget":
+			pdb, err := client.Clientset.PolicyV1().PodDisruptionBudgets(diagnoseNamespace).Get(ctx, name, metav1.GetOptions{})
+			if err != nil {
+				logrus.Errorf("❌ 错误: 无法找到 PodDisruptionBudget %s - %v\n", name, err)
+				os.Exit(1)
+			}
+			writeWorkloadReport(analyzer.AnalyzePDB(pdb))
 
 		default:
-			report.PrintTable(result)
+			// 获取 Pod
+			pod, err := client.Clientset.CoreV1().Pods(diagnoseNamespace).Get(ctx, name, metav1.GetOptions{})
+			if err != nil {
+				logrus.Errorf("❌ 错误: 无法找到 Pod %s - %v\n", name, err)
+				os.Exit(1)
+			}
+			writePodReport(analyzer.AnalyzePod(pod))
 		}
 
 		// 打印 PID 和程序退出标记
@@ -98,9 +140,109 @@ var diagnoseCmd = &cobra.Command{
 	},
 }
 
+// parseDiagnoseTarget 解析 "kind/name" 形式的诊断目标，不带前缀时视为 Pod
+func parseDiagnoseTarget(target string) (kind, name string, err error) {
+	prefix, rest, found := strings.Cut(target, "/")
+	if !found {
+		return "Pod", target, nil
+	}
+	if rest == "" {
+		return "", "", fmt.Errorf("缺少资源名称: %s", target)
+	}
+
+	switch strings.ToLower(prefix) {
+	case "po", "pod", "pods":
+		return "Pod", rest, nil
+	case "svc", "service", "services":
+		return "Service", rest, nil
+	case "deploy", "deployment", "deployments":
+		return "Deployment", rest, nil
+	case "rs", "replicaset", "replicasets":
+		return "ReplicaSet", rest, nil
+	case "sts", "statefulset", "statefulsets":
+		return "StatefulSet", rest, nil
+	case "job", "jobs":
+		return "Job", rest, nil
+	case "pdb", "poddisruptionbudget", "poddisruptionbudgets":
+		return "PodDisruptionBudget", rest, nil
+	default:
+		return "", "", fmt.Errorf("不支持的资源类型: %s", prefix)
+	}
+}
+
+// writePodReport 根据 --output 参数输出 Pod 诊断结果 (输出前先脱敏)
+func writePodReport(result diagnosis.DiagnosisResult) {
+	result.Redact(newRedactor())
+	switch outputFormat {
+	case "md", "markdown":
+		fmt.Println(report.GenerateMarkdown(result))
+	case "json":
+		printJSON(result)
+	case "html":
+		saveHTMLReport(result.PodName, func(path string) error {
+			return report.GenerateHTML(result, path)
+		})
+	default:
+		report.PrintTable(result)
+	}
+}
+
+// writeWorkloadReport 根据 --output 参数输出工作负载级诊断结果 (输出前先脱敏)
+func writeWorkloadReport(result diagnosis.WorkloadResult) {
+	result.Redact(newRedactor())
+	switch outputFormat {
+	case "md", "markdown":
+		fmt.Println(report.GenerateWorkloadMarkdown(result))
+	case "json":
+		printJSON(result)
+	case "html":
+		baseName := fmt.Sprintf("%s-%s", strings.ToLower(result.Kind), result.Name)
+		saveHTMLReport(baseName, func(path string) error {
+			return report.GenerateWorkloadHTML(result, path)
+		})
+	default:
+		report.PrintWorkloadTable(result)
+	}
+}
+
+// printJSON 以带缩进的 JSON 打印任意结果
+func printJSON(v interface{}) {
+	// MarshalIndent 生成带缩进的 JSON
+	jsonData, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		logrus.Errorf("❌ JSON 序列化失败: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Println(string(jsonData))
+}
+
+// saveHTMLReport 将 HTML 报告自动归档到 reports 目录
+func saveHTMLReport(baseName string, render func(path string) error) {
+	reportDir := "reports"
+	if _, err := os.Stat(reportDir); os.IsNotExist(err) {
+		_ = os.Mkdir(reportDir, 0755) // 创建目录
+	}
+	// 动态文件名
+	timestamp := time.Now().Format("20060102_150405")
+	// 格式: reports/name_report_timestamp.html
+	fileName := fmt.Sprintf("%s_report_%s.html", baseName, timestamp)
+	fullPath := filepath.Join(reportDir, fileName)
+
+	if err := render(fullPath); err != nil {
+		logrus.Errorf("❌ 生成 HTML 失败: %v\n", err)
+		os.Exit(1)
+	}
+	// 获取绝对路径，方便用户点击
+	absPath, _ := filepath.Abs(fullPath)
+	logrus.Infof("✅ 诊断报告已归档: %s\n", absPath)
+}
+
 func init() {
 	rootCmd.AddCommand(diagnoseCmd)
 
 	// 绑定参数 --output 或 -o
-	diagnoseCmd.Flags().StringVarP(&outputFormat, "output", "o", "", "输出格式 (table, md, json)")
+	diagnoseCmd.Flags().StringVarP(&outputFormat, "output", "o", "", "输出格式 (table, md, json, html)")
+	diagnoseCmd.Flags().StringVarP(&diagnoseNamespace, "namespace", "n", "default", "目标资源所在的 Namespace")
+	diagnoseCmd.Flags().BoolVar(&diagnoseDeep, "deep", false, "深度模式: 正常运行的容器也分析日志，统计各错误模式的趋势并标出近期突增")
+	diagnoseCmd.Flags().DurationVar(&diagnoseDeepWindow, "deep-window", diagnosis.DefaultDeepLogWindow, "深度模式分析的时间窗口")
 }
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"sync"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"github.com/swfoodt/kubehealer/pkg/diagnosis"
+)
+
+var (
+	logPatternLib  *diagnosis.PatternLibrary
+	logPatternOnce sync.Once
+)
+
+// withLogConfig 按全局参数与配置文件设置分析器的日志分析方式
+func withLogConfig(analyzer *diagnosis.Analyzer) *diagnosis.Analyzer {
+	return analyzer.WithLogPatterns(logPatterns()).
+		WithJSONLogFields(jsonLogFields()).
+		WithLogCollection(logCollectOptions()).
+		WithLogMatchContext(viper.GetInt("logs.match_context"))
+}
+
+// logCollectOptions 返回日志抓取限制 (logs.tail_lines / since / limit_bytes / timeout)
+func logCollectOptions() diagnosis.LogCollectOptions {
+	return diagnosis.LogCollectOptions{
+		TailLines:    viper.GetInt64("logs.tail_lines"),
+		SinceSeconds: int64(viper.GetDuration("logs.since").Seconds()),
+		LimitBytes:   viper.GetInt64("logs.limit_bytes"),
+		Timeout:      viper.GetDuration("logs.timeout"),
+	}
+}
+
+// logPatterns 返回日志模式库 (内置语言包 + logs.patterns_dir 下的自定义语言包)
+// 只加载一次，monitor 模式下每次诊断复用；加载失败时退回内置模式库
+func logPatterns() *diagnosis.PatternLibrary {
+	logPatternOnce.Do(func() {
+		dir := viper.GetString("logs.patterns_dir")
+		if dir == "" {
+			return
+		}
+		lib, err := diagnosis.LoadPatternLibrary(dir)
+		if err != nil {
+			logrus.Warnf("⚠️ 无法加载日志模式目录 %s，使用内置模式: %v", dir, err)
+			return
+		}
+		logPatternLib = lib
+	})
+	return logPatternLib
+}
+
+// jsonLogFields 返回 JSON 日志的字段约定: logs.json_format 指定预设，logs.json_fields 覆盖单个字段
+func jsonLogFields() diagnosis.JSONLogFields {
+	format := viper.GetString("logs.json_format")
+	fields, err := diagnosis.JSONLogPreset(format)
+	if err != nil {
+		logrus.Warnf("⚠️ %v，使用自动识别", err)
+		fields = diagnosis.DefaultJSONLogFields()
+	}
+
+	var override diagnosis.JSONLogFields
+	if err := viper.UnmarshalKey("logs.json_fields", &override); err != nil {
+		logrus.Warnf("⚠️ 配置项 logs.json_fields 无效: %v", err)
+	}
+	return fields.Merge(override)
+}
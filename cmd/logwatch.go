@@ -0,0 +1,199 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/swfoodt/kubehealer/pkg/diagnosis"
+	"github.com/swfoodt/kubehealer/pkg/k8s"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// logWatchAnnotation Pod 上带有该注解 (值为 "true") 时跟随其日志
+const logWatchAnnotation = "kubehealer.io/watch-logs"
+
+// 断线重连的退避时间
+const (
+	logWatchMinBackoff = time.Second
+	logWatchMaxBackoff = 30 * time.Second
+)
+
+// logWatcher 跟随选中 Pod 的容器日志，命中严重模式时回调 onAlert
+// 每个容器一个日志流，同时打开的日志流数量不超过 maxStreams
+type logWatcher struct {
+	ctx      context.Context
+	client   *k8s.Client
+	selector labels.Selector // 为 nil 时只看注解
+	slots    chan struct{}   // 信号量，限制并发的日志流
+	onAlert  func(pod *corev1.Pod, alert diagnosis.LogAlert)
+
+	mu      sync.Mutex
+	streams map[string]*logStream // namespace/pod/container -> 日志流
+}
+
+// logStream 是一个正在跟随的日志流
+type logStream struct {
+	cancel context.CancelFunc
+}
+
+// newLogWatcher 创建日志跟随器，selector 为空时只跟随带注解的 Pod
+func newLogWatcher(ctx context.Context, client *k8s.Client, selector string, maxStreams int, onAlert func(*corev1.Pod, diagnosis.LogAlert)) (*logWatcher, error) {
+	w := &logWatcher{
+		ctx:     ctx,
+		client:  client,
+		onAlert: onAlert,
+		streams: map[string]*logStream{},
+	}
+	if selector != "" {
+		sel, err := labels.Parse(selector)
+		if err != nil {
+			return nil, fmt.Errorf("无效的日志跟随选择器 %q: %w", selector, err)
+		}
+		w.selector = sel
+	}
+	if maxStreams <= 0 {
+		maxStreams = 1
+	}
+	w.slots = make(chan struct{}, maxStreams)
+	return w, nil
+}
+
+// selected 判断 Pod 是否选择了日志跟随 (标签选择器或注解)
+func (w *logWatcher) selected(pod *corev1.Pod) bool {
+	if pod.Annotations[logWatchAnnotation] == "true" {
+		return true
+	}
+	return w.selector != nil && w.selector.Matches(labels.Set(pod.Labels))
+}
+
+// Sync 按 Pod 的最新状态打开 / 关闭日志流: 选中且未结束的 Pod 的每个容器跟随日志，其余关闭
+// 容器重启时不关闭日志流，由重连接上新的容器实例
+func (w *logWatcher) Sync(pod *corev1.Pod) {
+	watched := map[string]bool{}
+	if w.selected(pod) && pod.DeletionTimestamp == nil &&
+		pod.Status.Phase != corev1.PodSucceeded && pod.Status.Phase != corev1.PodFailed {
+		for _, cs := range pod.Status.ContainerStatuses {
+			watched[cs.Name] = true
+		}
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	prefix := pod.Namespace + "/" + pod.Name + "/"
+	w.closeLocked(prefix, watched)
+	for name := range watched {
+		key := prefix + name
+		if _, ok := w.streams[key]; ok {
+			continue
+		}
+		// 不阻塞 Informer 的回调: 没有空闲名额时跳过，下次 Pod 更新或全量同步时再试
+		select {
+		case w.slots <- struct{}{}:
+		default:
+			logrus.Warnf("⚠️ [%s] 日志流已达上限 (%d)，暂不跟随容器 %s 的日志\n", pod.Name, cap(w.slots), name)
+			continue
+		}
+		ctx, cancel := context.WithCancel(w.ctx)
+		s := &logStream{cancel: cancel}
+		w.streams[key] = s
+		go w.follow(ctx, key, s, pod.DeepCopy(), name)
+	}
+}
+
+// Stop 关闭 Pod 的所有日志流 (Pod 被删除时调用)
+func (w *logWatcher) Stop(pod *corev1.Pod) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.closeLocked(pod.Namespace+"/"+pod.Name+"/", nil)
+}
+
+// closeLocked 关闭 prefix 下不在 keep 中的日志流，调用方需持有 w.mu
+func (w *logWatcher) closeLocked(prefix string, keep map[string]bool) {
+	for key, s := range w.streams {
+		if strings.HasPrefix(key, prefix) && !keep[strings.TrimPrefix(key, prefix)] {
+			s.cancel()
+			delete(w.streams, key)
+		}
+	}
+}
+
+// follow 跟随一个容器的日志直到 ctx 被取消，断线后按指数退避重连
+func (w *logWatcher) follow(ctx context.Context, key string, self *logStream, pod *corev1.Pod, container string) {
+	defer func() {
+		<-w.slots
+		w.mu.Lock()
+		// 同一个容器可能已经开了新的日志流，只清理自己
+		if w.streams[key] == self {
+			delete(w.streams, key)
+		}
+		self.cancel()
+		w.mu.Unlock()
+	}()
+
+	logrus.Infof("👀 [%s] 开始跟随容器 %s 的日志\n", pod.Name, container)
+	detector := diagnosis.NewCriticalLogDetector(nil)
+	// 只看开始跟随之后的日志，重连时从最后一条已处理日志的时间戳继续 (包括断线期间与容器重启后新实例的日志)
+	last := time.Now()
+	backoff := logWatchMinBackoff
+	for ctx.Err() == nil {
+		since := metav1.NewTime(last)
+		opts := &corev1.PodLogOptions{Container: container, Follow: true, Timestamps: true, SinceTime: &since}
+		read, err := w.stream(ctx, pod, container, opts, detector, &last)
+		if ctx.Err() != nil {
+			break
+		}
+		if read > 0 {
+			backoff = logWatchMinBackoff
+		}
+		if err != nil {
+			logrus.Debugf("[%s] 容器 %s 日志流断开: %v，%s 后重连", pod.Name, container, err, backoff)
+		}
+
+		select {
+		case <-ctx.Done():
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		if backoff > logWatchMaxBackoff {
+			backoff = logWatchMaxBackoff
+		}
+	}
+	logrus.Debugf("[%s] 停止跟随容器 %s 的日志", pod.Name, container)
+}
+
+// stream 读取一次日志流，返回处理的行数；last 为最后一条已处理日志的时间戳，读取时随之更新
+// SinceTime 只精确到秒，重连后同一秒内已处理过的行会再次出现，按时间戳跳过以免重复告警
+func (w *logWatcher) stream(ctx context.Context, pod *corev1.Pod, container string, opts *corev1.PodLogOptions, detector *diagnosis.CriticalLogDetector, last *time.Time) (int, error) {
+	rc, err := w.client.Clientset.CoreV1().Pods(pod.Namespace).GetLogs(pod.Name, opts).Stream(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer rc.Close()
+
+	read := 0
+	scanner := bufio.NewScanner(rc)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1<<20)
+	for scanner.Scan() {
+		ts, line, ok := diagnosis.SplitLogTimestamp(scanner.Text())
+		if ok {
+			if !ts.After(*last) {
+				continue
+			}
+			*last = ts
+		}
+		read++
+		if alert := detector.Feed(line, time.Now()); alert != nil {
+			alert.Container = container
+			logrus.Warnf("🔥 [%s] 容器 %s 日志命中严重模式: %s\n", pod.Name, container, alert.Pattern)
+			w.onAlert(pod, *alert)
+		}
+	}
+	return read, scanner.Err()
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	_ "net/http/pprof"
@@ -25,9 +26,11 @@ import (
 
 // 定义过滤参数变量
 var (
-	monitorNamespace string
-	monitorLabels    string
-	monitorInterval  time.Duration
+	monitorNamespace  string
+	monitorLabels     string
+	monitorInterval   time.Duration
+	monitorWatchLogs  string
+	monitorLogStreams int
 )
 
 var monitorCmd = &cobra.Command{
@@ -53,6 +56,9 @@ var monitorCmd = &cobra.Command{
 		logrus.Infof("   - 监听 Namespace: %s\n", ns)
 		logrus.Infof("   - 监听 Labels: %s\n", labels)
 		logrus.Infof("   - 同步间隔: %s\n", interval)
+		watchLogs := viper.GetString("monitor.watch_logs")
+		maxLogStreams := viper.GetInt("monitor.max_log_streams")
+		logrus.Infof("   - 跟随日志: %s (或注解 %s=true)，最多 %d 个日志流\n", watchLogs, logWatchAnnotation, maxLogStreams)
 
 		// 初始化客户端
 		client, err := k8s.NewClient()
@@ -89,6 +95,23 @@ var monitorCmd = &cobra.Command{
 			)
 		}
 
+		// 退出时取消所有正在进行的诊断 (例如卡住的日志读取)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		// 跟随选中 Pod 的日志，命中严重模式时立即诊断，不等容器崩溃
+		watcher, err := newLogWatcher(ctx, client, watchLogs, maxLogStreams, func(pod *corev1.Pod, alert diagnosis.LogAlert) {
+			// 日志流打开时的 Pod 快照可能已经过时，诊断前取最新状态
+			if latest, err := client.Clientset.CoreV1().Pods(pod.Namespace).Get(ctx, pod.Name, metav1.GetOptions{}); err == nil {
+				pod = latest
+			}
+			go triggerDiagnosis(ctx, pod, client, &alert)
+		})
+		if err != nil {
+			logrus.Errorf("❌ %v\n", err)
+			os.Exit(1)
+		}
+
 		// 获取 Pod 的 Informer
 		podInformer := factory.Core().V1().Pods().Informer()
 
@@ -97,14 +120,16 @@ var monitorCmd = &cobra.Command{
 			AddFunc: func(obj interface{}) {
 				pod := obj.(*corev1.Pod)
 				logrus.Infof("[➕ Added] %s/%s (Status: %s)\n", pod.Namespace, pod.Name, pod.Status.Phase)
+				watcher.Sync(pod)
 
 				if pod.Status.Phase != corev1.PodRunning && pod.Status.Phase != corev1.PodSucceeded {
-					go triggerDiagnosis(pod, client)
+					go triggerDiagnosis(ctx, pod, client, nil)
 				}
 			},
 			UpdateFunc: func(oldObj, newObj interface{}) {
 				oldPod := oldObj.(*corev1.Pod)
 				newPod := newObj.(*corev1.Pod)
+				watcher.Sync(newPod)
 
 				// 【修复】安全地获取重启次数
 				// 如果 Pod 处于 Pending 状态，ContainerStatuses 可能是空的，直接访问 [0] 会 panic
@@ -130,7 +155,7 @@ var monitorCmd = &cobra.Command{
 				// 如果变成了非 Running 状态，或者重启次数增加了
 				isCrashLoop := newRestarts > oldRestarts
 				if newPod.Status.Phase != corev1.PodRunning || isCrashLoop {
-					go triggerDiagnosis(newPod, client)
+					go triggerDiagnosis(ctx, newPod, client, nil)
 				}
 			},
 			DeleteFunc: func(obj interface{}) {
@@ -146,6 +171,7 @@ var monitorCmd = &cobra.Command{
 					}
 				}
 				logrus.Errorf("[❌ Deleted] %s/%s\n", pod.Namespace, pod.Name)
+				watcher.Stop(pod)
 			},
 		})
 
@@ -166,34 +192,61 @@ var monitorCmd = &cobra.Command{
 		signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
 		<-sigCh
 		logrus.Info("\n👋 收到停止信号，正在退出...")
+		cancel()
 	},
 }
 
-// 去重缓存 (PodUID -> 上次诊断时间)
+// 去重缓存 (冷却键 -> 上次诊断时间)
 // 使用 sync.Map 保证并发安全
 var diagnosisCooldown sync.Map
 
+// cooldownKey 返回诊断的去重键
+// 状态触发的诊断按 Pod 去重；实时日志告警按 Pod + 容器 + 模式单独去重，
+// 不会因为刚做过一次状态诊断而丢掉 panic / OOM 等告警
+func cooldownKey(pod *corev1.Pod, alert *diagnosis.LogAlert) string {
+	if alert == nil {
+		return string(pod.UID)
+	}
+	return fmt.Sprintf("%s/log/%s/%s", pod.UID, alert.Container, alert.Pattern)
+}
+
 // triggerDiagnosis 触发一次诊断并生成报告
-func triggerDiagnosis(pod *corev1.Pod, client *k8s.Client) {
+// alert 不为 nil 时表示由实时日志触发，命中的日志行会附在报告中
+func triggerDiagnosis(ctx context.Context, pod *corev1.Pod, client *k8s.Client, alert *diagnosis.LogAlert) {
 	// 去重检查
 	// 冷却时间设置为 1 分钟
 	const cooldownPeriod = 1 * time.Minute
 
 	// 获取上次诊断时间
-	if lastTime, loaded := diagnosisCooldown.Load(pod.UID); loaded {
+	key := cooldownKey(pod, alert)
+	if lastTime, loaded := diagnosisCooldown.Load(key); loaded {
 		if time.Since(lastTime.(time.Time)) < cooldownPeriod {
 			// 如果还在冷却期内，直接跳过
-			logrus.Infof("⏳ [%s] 处于冷却期，跳过重复诊断\n", pod.Name)
+			if alert != nil {
+				logrus.Warnf("⏳ [%s] 容器 %s 的日志告警 %s 处于冷却期，丢弃本次告警 (最后一行: %s)\n",
+					pod.Name, alert.Container, alert.Pattern, alert.Lines[len(alert.Lines)-1])
+			} else {
+				logrus.Infof("⏳ [%s] 处于冷却期，跳过重复诊断\n", pod.Name)
+			}
 			return
 		}
 	}
 
 	// 记录本次诊断时间 (相当于更新缓存)
-	diagnosisCooldown.Store(pod.UID, time.Now())
+	diagnosisCooldown.Store(key, time.Now())
 
 	// 初始化分析器 (以下逻辑保持不变)
-	analyzer := diagnosis.NewAnalyzer(client.Clientset)
+	analyzer := withLogConfig(diagnosis.NewAnalyzer(client.Clientset).WithMetrics(client.Metrics)).WithContext(ctx)
 	result := analyzer.AnalyzePod(pod)
+	if ctx.Err() != nil {
+		// 正在退出，诊断结果不完整，不再生成报告
+		return
+	}
+	if alert != nil {
+		result.AttachLogAlert(*alert)
+	}
+	// 报告目录是共享的，落盘前先脱敏
+	result.Redact(newRedactor())
 
 	// 生成报告
 	reportDir := "reports"
@@ -225,5 +278,10 @@ func init() {
 	// 绑定 Viper (让 Viper 知道这些 Flag 的存在)
 	viper.BindPFlag("monitor.namespace", monitorCmd.Flags().Lookup("namespace"))
 	viper.BindPFlag("monitor.labels", monitorCmd.Flags().Lookup("label-selector"))
+	monitorCmd.Flags().StringVar(&monitorWatchLogs, "watch-logs", "", "跟随日志的 Pod 的 Label Selector，命中 panic / OOM 等严重日志时立即诊断 (带注解 "+logWatchAnnotation+"=true 的 Pod 始终跟随)")
+	monitorCmd.Flags().IntVar(&monitorLogStreams, "max-log-streams", 20, "同时跟随的日志流上限 (每个容器一个)")
+
 	viper.BindPFlag("monitor.interval", monitorCmd.Flags().Lookup("interval"))
+	viper.BindPFlag("monitor.watch_logs", monitorCmd.Flags().Lookup("watch-logs"))
+	viper.BindPFlag("monitor.max_log_streams", monitorCmd.Flags().Lookup("max-log-streams"))
 }
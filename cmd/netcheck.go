@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/swfoodt/kubehealer/pkg/diagnosis"
+	"github.com/swfoodt/kubehealer/pkg/k8s"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// netcheck 参数
+var (
+	netcheckNamespace string
+	netcheckPort      int32
+	netcheckProtocol  string
+	netcheckOutput    string
+)
+
+var netcheckCmd = &cobra.Command{
+	Use:   "netcheck [source-pod] [target-pod]",
+	Short: "分析 NetworkPolicy，判断 Pod A 能否访问 Pod B 的端口",
+	Long: `列出选中两个 Pod 的 NetworkPolicy，计算有效的入站/出站放行规则，
+并判断源 Pod 能否访问目标 Pod 的指定端口。Pod 可以写成 namespace/name 的形式。
+
+示例:
+  kubehealer netcheck frontend-7d9c api-5f6b --port 8080
+  kubehealer netcheck shop/frontend-7d9c payment/api-5f6b -p 443`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		client, err := k8s.NewClient()
+		if err != nil {
+			logrus.Errorf("❌ 错误: 无法连接集群 - %v\n", err)
+			os.Exit(1)
+		}
+
+		src, err := getPodRef(client, args[0])
+		if err != nil {
+			logrus.Errorf("❌ 错误: %v\n", err)
+			os.Exit(1)
+		}
+		dst, err := getPodRef(client, args[1])
+		if err != nil {
+			logrus.Errorf("❌ 错误: %v\n", err)
+			os.Exit(1)
+		}
+
+		analyzer := diagnosis.NewAnalyzer(client.Clientset)
+		result, err := analyzer.CheckReachability(src, dst, netcheckPort, corev1.Protocol(strings.ToUpper(netcheckProtocol)))
+		if err != nil {
+			logrus.Errorf("❌ 分析失败: %v\n", err)
+			os.Exit(1)
+		}
+
+		if netcheckOutput == "json" {
+			printJSON(result)
+			return
+		}
+		printReachability(src, dst, result)
+	},
+}
+
+// getPodRef 根据 "[namespace/]name" 获取 Pod
+func getPodRef(client *k8s.Client, ref string) (*corev1.Pod, error) {
+	ns, name := netcheckNamespace, ref
+	if before, after, found := strings.Cut(ref, "/"); found {
+		ns, name = before, after
+	}
+	pod, err := client.Clientset.CoreV1().Pods(ns).Get(context.TODO(), name, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("无法找到 Pod %s/%s - %v", ns, name, err)
+	}
+	return pod, nil
+}
+
+// printReachability 以文本形式打印可达性分析结果
+func printReachability(src, dst *corev1.Pod, result diagnosis.ReachabilityResult) {
+	fmt.Println()
+	fmt.Printf("🌐 %s/%s  ->  %s/%s : %s/%d\n\n", src.Namespace, src.Name, dst.Namespace, dst.Name,
+		strings.ToUpper(netcheckProtocol), netcheckPort)
+
+	printEffectivePolicy("📤 源 Pod 出站 (Egress)", result.Source.Policies, result.Source.EgressIsolated, result.Source.Egress)
+	printEffectivePolicy("📥 目标 Pod 入站 (Ingress)", result.Destination.Policies, result.Destination.IngressIsolated, result.Destination.Ingress)
+
+	fmt.Println("🔍 判定:")
+	fmt.Printf("  %s Egress:  %s\n", verdictIcon(result.EgressAllowed), result.EgressReason)
+	fmt.Printf("  %s Ingress: %s\n", verdictIcon(result.IngressAllowed), result.IngressReason)
+	fmt.Println()
+	if result.Allowed {
+		fmt.Println("✅ 结论: NetworkPolicy 允许该流量")
+	} else {
+		fmt.Println("🛑 结论: 流量被 NetworkPolicy 拦截")
+		fmt.Println("   💡 请在上方被隔离的一侧增加对应的放行规则 (from/to + ports)")
+	}
+	fmt.Println()
+}
+
+func printEffectivePolicy(title string, policies []string, isolated bool, rules []diagnosis.PolicyAllow) {
+	fmt.Println(title + ":")
+	if len(policies) == 0 {
+		fmt.Println("  没有 NetworkPolicy 选中该 Pod")
+		fmt.Println()
+		return
+	}
+	fmt.Printf("  选中的策略: %s\n", strings.Join(policies, ", "))
+	if !isolated {
+		fmt.Println("  该方向未被隔离 (全部放行)")
+		fmt.Println()
+		return
+	}
+	if len(rules) == 0 {
+		fmt.Println("  该方向已隔离，且没有任何放行规则 (全部拒绝)")
+	}
+	for _, r := range rules {
+		fmt.Printf("  - [%s] %s on %s\n", r.Policy, strings.Join(r.Peers, " | "), strings.Join(r.Ports, ", "))
+	}
+	fmt.Println()
+}
+
+func verdictIcon(allowed bool) string {
+	if allowed {
+		return "✅"
+	}
+	return "🛑"
+}
+
+func init() {
+	rootCmd.AddCommand(netcheckCmd)
+
+	netcheckCmd.Flags().StringVarP(&netcheckNamespace, "namespace", "n", "default", "Pod 默认所在的 Namespace")
+	netcheckCmd.Flags().Int32VarP(&netcheckPort, "port", "p", 80, "目标端口")
+	netcheckCmd.Flags().StringVar(&netcheckProtocol, "protocol", "TCP", "协议 (TCP, UDP, SCTP)")
+	netcheckCmd.Flags().StringVarP(&netcheckOutput, "output", "o", "", "输出格式 (text, json)")
+}
@@ -0,0 +1,21 @@
+package main
+
+import (
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"github.com/swfoodt/kubehealer/pkg/redact"
+)
+
+// newRedactor 按 redact.enabled / redact.patterns 创建脱敏器，关闭脱敏时返回 nil
+// 自定义正则无效时只使用内置规则，不会因此跳过脱敏
+func newRedactor() *redact.Redactor {
+	if !viper.GetBool("redact.enabled") {
+		return nil
+	}
+	r, err := redact.New(viper.GetStringSlice("redact.patterns")...)
+	if err != nil {
+		logrus.Warnf("⚠️ %v，仅使用内置脱敏规则", err)
+		r, _ = redact.New()
+	}
+	return r
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"os"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/swfoodt/kubehealer/pkg/diagnosis"
+	"github.com/swfoodt/kubehealer/pkg/k8s"
+	"github.com/swfoodt/kubehealer/pkg/report"
+)
+
+// resources 参数
+var (
+	resourcesNamespace string
+	resourcesOutput    string
+)
+
+var resourcesCmd = &cobra.Command{
+	Use:   "resources",
+	Short: "对比 requests 与实际使用量，找出资源浪费与不足的工作负载",
+	Long: `统计命名空间下每个工作负载所有运行中 Pod 的 requests 总量，
+与 metrics-server 观测到的使用量对比，按浪费 / 不足的影响排序，并为每个容器生成建议的 resources 配置。
+
+示例:
+  kubehealer resources -n shop
+  kubehealer resources -n shop -o csv > shop-resources.csv`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		client, err := k8s.NewClient()
+		if err != nil {
+			logrus.Errorf("❌ 错误: 无法连接集群 - %v\n", err)
+			os.Exit(1)
+		}
+
+		analyzer := diagnosis.NewAnalyzer(client.Clientset).WithMetrics(client.Metrics)
+		result, err := analyzer.AnalyzeResources(resourcesNamespace)
+		if err != nil {
+			logrus.Errorf("❌ 分析失败: %v\n", err)
+			os.Exit(1)
+		}
+
+		switch resourcesOutput {
+		case "json":
+			printJSON(result)
+		case "csv":
+			if err := report.WriteResourceCSV(os.Stdout, result); err != nil {
+				logrus.Errorf("❌ 输出 CSV 失败: %v\n", err)
+				os.Exit(1)
+			}
+		default:
+			report.PrintResourceTable(result)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(resourcesCmd)
+
+	resourcesCmd.Flags().StringVarP(&resourcesNamespace, "namespace", "n", "default", "要分析的 Namespace")
+	resourcesCmd.Flags().StringVarP(&resourcesOutput, "output", "o", "", "输出格式 (table, json, csv)")
+}
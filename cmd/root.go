@@ -6,6 +6,7 @@ import (
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+	"github.com/swfoodt/kubehealer/pkg/diagnosis"
 	"github.com/swfoodt/kubehealer/pkg/util"
 )
 
@@ -37,6 +38,24 @@ func init() {
 	// 全局参数: --config
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "配置文件 (默认为 $HOME/.kubehealer.yaml)")
 	rootCmd.PersistentFlags().BoolVar(&debug, "debug", false, "开启调试模式 (显示详细日志)")
+	rootCmd.PersistentFlags().String("log-patterns", "", "额外的日志模式目录 (*.yaml / *.json 语言包)，同名模式覆盖内置模式")
+	viper.BindPFlag("logs.patterns_dir", rootCmd.PersistentFlags().Lookup("log-patterns"))
+	rootCmd.PersistentFlags().String("log-format", "auto", "JSON 日志的字段约定: auto, zap, logrus, bunyan, structlog")
+	viper.BindPFlag("logs.json_format", rootCmd.PersistentFlags().Lookup("log-format"))
+	rootCmd.PersistentFlags().Int64("log-tail", diagnosis.DefaultLogTailLines, "每个容器最多获取的日志行数 (0 表示不限制)")
+	viper.BindPFlag("logs.tail_lines", rootCmd.PersistentFlags().Lookup("log-tail"))
+	rootCmd.PersistentFlags().Duration("log-since", 0, "只获取最近一段时间的日志，例如 10m (0 表示不限制)")
+	viper.BindPFlag("logs.since", rootCmd.PersistentFlags().Lookup("log-since"))
+	rootCmd.PersistentFlags().Int64("log-limit-bytes", diagnosis.DefaultLogLimitBytes, "每个容器最多读取的日志字节数 (0 表示不限制)")
+	viper.BindPFlag("logs.limit_bytes", rootCmd.PersistentFlags().Lookup("log-limit-bytes"))
+	rootCmd.PersistentFlags().Duration("log-timeout", diagnosis.DefaultLogTimeout, "单次日志请求的超时，超时后使用已读到的部分")
+	viper.BindPFlag("logs.timeout", rootCmd.PersistentFlags().Lookup("log-timeout"))
+	rootCmd.PersistentFlags().Int("log-context", diagnosis.DefaultLogMatchContext, "报告中每处日志命中附带的上下文行数 (前后各 N 行)")
+	viper.BindPFlag("logs.match_context", rootCmd.PersistentFlags().Lookup("log-context"))
+	rootCmd.PersistentFlags().Bool("redact", true, "生成报告前脱敏日志、事件与报错中的令牌、密码、连接串")
+	viper.BindPFlag("redact.enabled", rootCmd.PersistentFlags().Lookup("redact"))
+	rootCmd.PersistentFlags().StringArray("redact-pattern", nil, "额外的脱敏正则 (可重复，支持名为 secret 的分组)")
+	viper.BindPFlag("redact.patterns", rootCmd.PersistentFlags().Lookup("redact-pattern"))
 }
 
 // initConfig 读取配置文件和环境变量
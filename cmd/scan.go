@@ -0,0 +1,109 @@
+package main
+
+import (
+	"os"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/swfoodt/kubehealer/pkg/diagnosis"
+	"github.com/swfoodt/kubehealer/pkg/k8s"
+)
+
+// scan 参数
+var (
+	scanNamespace     string
+	scanPullThreshold time.Duration
+)
+
+// scanCmd 是命名空间级扫描的父命令
+var scanCmd = &cobra.Command{
+	Use:   "scan",
+	Short: "对整个 Namespace 进行专项扫描",
+	Long: `对整个 Namespace 进行专项扫描，找出影响多个工作负载的配置问题。
+
+示例:
+  kubehealer scan pdb -n shop
+  kubehealer scan images -n shop
+  kubehealer scan pulls -n shop --threshold 1m`,
+}
+
+var scanPDBCmd = &cobra.Command{
+	Use:   "pdb",
+	Short: "扫描阻止驱逐 (节点 drain) 的 PodDisruptionBudget",
+	Long: `计算 Namespace 下每个 PDB 当前允许的中断数，
+并找出永久阻止驱逐的 PDB (例如 minAvailable 等于副本数，或选中的 Pod 全部不健康)。`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		client, err := k8s.NewClient()
+		if err != nil {
+			logrus.Errorf("❌ 错误: 无法连接集群 - %v\n", err)
+			os.Exit(1)
+		}
+
+		analyzer := diagnosis.NewAnalyzer(client.Clientset)
+		result, err := analyzer.ScanPDBs(scanNamespace)
+		if err != nil {
+			logrus.Errorf("❌ 扫描失败: %v\n", err)
+			os.Exit(1)
+		}
+		writeWorkloadReport(result)
+	},
+}
+
+var scanImagesCmd = &cobra.Command{
+	Use:   "images",
+	Short: "扫描同一控制器的副本是否运行了不同的镜像摘要",
+	Long: `对比 Namespace 下每个控制器所有副本的 imageID，找出因可变 tag 运行不同镜像内容的工作负载，
+列出每个摘要运行在哪些节点上，并标记使用 :latest / 未指定 tag 且 imagePullPolicy 为 IfNotPresent 的容器。`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		client, err := k8s.NewClient()
+		if err != nil {
+			logrus.Errorf("❌ 错误: 无法连接集群 - %v\n", err)
+			os.Exit(1)
+		}
+
+		analyzer := diagnosis.NewAnalyzer(client.Clientset)
+		result, err := analyzer.ScanImages(scanNamespace)
+		if err != nil {
+			logrus.Errorf("❌ 扫描失败: %v\n", err)
+			os.Exit(1)
+		}
+		writeWorkloadReport(result)
+	},
+}
+
+var scanPullsCmd = &cobra.Command{
+	Use:   "pulls",
+	Short: "统计镜像拉取耗时，找出需要精简或预拉取的镜像",
+	Long: `解析 Namespace 下 Pod 的 Pulling / Pulled 事件，读取 kubelet 上报的拉取耗时与镜像大小，
+按镜像和节点聚合，并标记超过阈值的慢拉取。注意事件默认只保留 1 小时。`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		client, err := k8s.NewClient()
+		if err != nil {
+			logrus.Errorf("❌ 错误: 无法连接集群 - %v\n", err)
+			os.Exit(1)
+		}
+
+		analyzer := diagnosis.NewAnalyzer(client.Clientset)
+		result, err := analyzer.ScanImagePulls(scanNamespace, scanPullThreshold)
+		if err != nil {
+			logrus.Errorf("❌ 扫描失败: %v\n", err)
+			os.Exit(1)
+		}
+		writeWorkloadReport(result)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(scanCmd)
+	scanCmd.AddCommand(scanPDBCmd)
+	scanCmd.AddCommand(scanImagesCmd)
+	scanCmd.AddCommand(scanPullsCmd)
+
+	scanCmd.PersistentFlags().StringVarP(&scanNamespace, "namespace", "n", "default", "扫描的 Namespace")
+	scanCmd.PersistentFlags().StringVarP(&outputFormat, "output", "o", "", "输出格式 (table, md, json, html)")
+	scanPullsCmd.Flags().DurationVar(&scanPullThreshold, "threshold", diagnosis.DefaultSlowPullThreshold, "慢拉取阈值 (例如 30s, 1m)")
+}
@@ -0,0 +1,232 @@
+package diagnosis
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// -----------------------------------------------------------
+// 准入拒绝分析: 控制器 FailedCreate 事件
+// 当 PodSecurity / ResourceQuota / LimitRange / Webhook 拒绝创建 Pod 时，
+// 集群里根本没有 Pod 可以诊断，唯一的线索是控制器上的 FailedCreate 事件
+// -----------------------------------------------------------
+
+// 准入拒绝的分类
+const (
+	AdmissionPodSecurity   = "PodSecurity"
+	AdmissionResourceQuota = "ResourceQuota"
+	AdmissionLimitRange    = "LimitRange"
+	AdmissionWebhook       = "Webhook"
+	AdmissionUnknown       = "Unknown"
+)
+
+// AdmissionFailure 是对一条 FailedCreate 事件的结构化解析结果
+type AdmissionFailure struct {
+	Category string   `json:"category"` // PodSecurity / ResourceQuota / LimitRange / Webhook / Unknown
+	Source   string   `json:"source"`   // 拒绝来源，例如 "restricted:latest"、配额名称、Webhook 名称
+	Fields   []string `json:"fields"`   // 需要修改的具体字段
+	Message  string   `json:"message"`  // 原始事件消息
+}
+
+var (
+	// violates PodSecurity "restricted:latest": ...
+	podSecurityRe = regexp.MustCompile(`violates PodSecurity "([^"]+)": (.*)`)
+	// 每条违规都是 "<检查项> (<详情>)"，多条之间以 ", " 分隔，例如
+	// runAsNonRoot != true (pod or container "app" must set securityContext.runAsNonRoot=true), hostPort (container "app" uses hostPort 80)
+	podSecurityFieldRe = regexp.MustCompile(`(?:^|, )([^(),]+?) \(([^()]*)\)`)
+	// 详情中已经写明了要改的字段: must (not) set securityContext.xxx
+	podSecurityMustSetRe = regexp.MustCompile(`must (?:not )?set `)
+
+	// exceeded quota: compute, requested: limits.memory=2Gi, used: limits.memory=7Gi, limited: limits.memory=8Gi
+	quotaExceededRe = regexp.MustCompile(`exceeded quota: ([^,]+), requested: (.+), used: (.+), limited: (.+)`)
+	// failed quota: compute: must specify limits.cpu for: app; limits.memory for: app
+	quotaMustSpecifyRe = regexp.MustCompile(`failed quota: ([^:]+): must specify (.+)`)
+
+	// maximum memory usage per Container is 1Gi, but limit is 2Gi
+	limitRangeRe = regexp.MustCompile(`(maximum|minimum) (\S+) usage per (Container|Pod|PersistentVolumeClaim) is ([^,\]]+), but (limit|request) is ([^,\]]+)`)
+	// cpu max limit to request ratio per Container is 2, but provided ratio is 4.000000
+	limitRatioRe = regexp.MustCompile(`(\S+) max limit to request ratio per (Container|Pod) is ([^,\]]+), but provided ratio is ([^,\]]+)`)
+
+	// admission webhook "validate.kyverno.svc" denied the request: ...
+	webhookRe = regexp.MustCompile(`admission webhook "([^"]+)" denied the request:?\s*(.*)`)
+)
+
+// ClassifyFailedCreate 将 FailedCreate 事件消息归类，并提取需要修改的字段
+func ClassifyFailedCreate(message string) AdmissionFailure {
+	failure := AdmissionFailure{
+		Category: AdmissionUnknown,
+		Fields:   []string{},
+		Message:  message,
+	}
+
+	// 1. PodSecurity 准入
+	if m := podSecurityRe.FindStringSubmatch(message); m != nil {
+		failure.Category = AdmissionPodSecurity
+		failure.Source = m[1]
+		for _, f := range podSecurityFieldRe.FindAllStringSubmatch(m[2], -1) {
+			failure.Fields = append(failure.Fields, podSecurityField(strings.TrimSpace(f[1]), strings.TrimSpace(f[2])))
+		}
+		return failure
+	}
+
+	// 2. ResourceQuota
+	if m := quotaExceededRe.FindStringSubmatch(message); m != nil {
+		failure.Category = AdmissionResourceQuota
+		failure.Source = strings.TrimSpace(m[1])
+		requested := splitQuotaList(m[2])
+		used := splitQuotaList(m[3])
+		limited := splitQuotaList(m[4])
+		for resource, req := range requested {
+			failure.Fields = append(failure.Fields,
+				fmt.Sprintf("%s: 申请 %s, 已用 %s, 上限 %s", resource, req, used[resource], limited[resource]))
+		}
+		sort.Strings(failure.Fields)
+		return failure
+	}
+	if m := quotaMustSpecifyRe.FindStringSubmatch(message); m != nil {
+		failure.Category = AdmissionResourceQuota
+		failure.Source = strings.TrimSpace(m[1])
+		for _, part := range strings.Split(m[2], ";") {
+			if part = strings.TrimSpace(part); part != "" {
+				failure.Fields = append(failure.Fields, "必须设置 "+part)
+			}
+		}
+		return failure
+	}
+
+	// 3. LimitRange
+	if ms := limitRangeRe.FindAllStringSubmatch(message, -1); ms != nil {
+		failure.Category = AdmissionLimitRange
+		for _, m := range ms {
+			field := "resources.limits." + m[2]
+			if m[5] == "request" {
+				field = "resources.requests." + m[2]
+			}
+			failure.Fields = append(failure.Fields,
+				fmt.Sprintf("%s = %s, 不满足每个 %s 的%s值 %s", field, strings.TrimSpace(m[6]), m[3], minMaxLabel(m[1]), strings.TrimSpace(m[4])))
+		}
+		return failure
+	}
+	if ms := limitRatioRe.FindAllStringSubmatch(message, -1); ms != nil {
+		failure.Category = AdmissionLimitRange
+		for _, m := range ms {
+			failure.Fields = append(failure.Fields,
+				fmt.Sprintf("resources.limits.%s / resources.requests.%s = %s, 超过每个 %s 允许的最大比例 %s", m[1], m[1], strings.TrimSpace(m[4]), m[2], strings.TrimSpace(m[3])))
+		}
+		return failure
+	}
+
+	// 4. Webhook 拒绝
+	if m := webhookRe.FindStringSubmatch(message); m != nil {
+		failure.Category = AdmissionWebhook
+		failure.Source = m[1]
+		if reason := strings.TrimSpace(m[2]); reason != "" {
+			failure.Fields = append(failure.Fields, reason)
+		}
+		return failure
+	}
+
+	return failure
+}
+
+// podSecurityCheckFields 详情中没有写明字段的 PodSecurity 检查项 (多为 baseline 级别) 对应需要修改的字段
+var podSecurityCheckFields = map[string]string{
+	"host namespaces":             "spec.hostNetwork / spec.hostPID / spec.hostIPC",
+	"hostPath volumes":            "spec.volumes[].hostPath",
+	"hostPort":                    "spec.containers[].ports[].hostPort",
+	"restricted volume types":     "spec.volumes[] (只允许 configMap、secret、emptyDir、persistentVolumeClaim 等类型)",
+	"non-default capabilities":    "securityContext.capabilities.add",
+	"forbidden sysctls":           "spec.securityContext.sysctls",
+	"procMount":                   "securityContext.procMount",
+	"seLinuxOptions":              "securityContext.seLinuxOptions",
+	"forbidden AppArmor profiles": "metadata.annotations[container.apparmor.security.beta.kubernetes.io/*] / securityContext.appArmorProfile",
+	"hostProcess":                 "securityContext.windowsOptions.hostProcess",
+}
+
+// podSecurityField 将一条 PodSecurity 违规转换为需要修改的字段
+// 详情中已有 "must set xxx" 时直接使用详情，否则按检查项补上对应的字段
+func podSecurityField(check, detail string) string {
+	if podSecurityMustSetRe.MatchString(detail) {
+		return detail
+	}
+	if field, ok := podSecurityCheckFields[check]; ok {
+		return fmt.Sprintf("%s: %s", field, detail)
+	}
+	return fmt.Sprintf("%s: %s", check, detail)
+}
+
+// AdmissionIssue 将解析结果转换为报告中的 Issue
+func AdmissionIssue(f AdmissionFailure) Issue {
+	issue := Issue{
+		Type:     "Error",
+		RawError: f.Message,
+	}
+
+	switch f.Category {
+	case AdmissionPodSecurity:
+		issue.Title = fmt.Sprintf("Pod 被 PodSecurity 准入拒绝 (%s)", f.Source)
+		issue.Suggestion = "请按以下要求修改 Pod 模板的 securityContext: " + joinFields(f.Fields)
+	case AdmissionResourceQuota:
+		issue.Title = fmt.Sprintf("Pod 创建超出 ResourceQuota (%s)", f.Source)
+		issue.Suggestion = "请降低 Pod 模板的资源申请或调大命名空间配额: " + joinFields(f.Fields)
+	case AdmissionLimitRange:
+		issue.Title = "Pod 资源配置违反 LimitRange"
+		issue.Suggestion = "请将 Pod 模板中的以下字段调整到 LimitRange 允许的范围内: " + joinFields(f.Fields)
+	case AdmissionWebhook:
+		issue.Title = fmt.Sprintf("Pod 被准入 Webhook 拒绝 (%s)", f.Source)
+		issue.Suggestion = "请根据 Webhook 返回的原因修改 Pod 模板，或联系策略维护者: " + joinFields(f.Fields)
+	default:
+		issue.Title = "控制器无法创建 Pod (FailedCreate)"
+		issue.Suggestion = "请根据原始报错检查 Pod 模板、ServiceAccount 及命名空间策略"
+	}
+	return issue
+}
+
+// GetAdmissionIssues 读取控制器 (ReplicaSet / StatefulSet / Job) 上的 FailedCreate 事件并归类
+// 相同消息的事件只报告一次
+func (a *Analyzer) GetAdmissionIssues(namespace, name string, uid types.UID) []Issue {
+	events, err := a.listObjectEvents(namespace, name, uid)
+	if err != nil {
+		return nil
+	}
+
+	var issues []Issue
+	seen := map[string]bool{}
+	for _, e := range events {
+		if e.Reason != "FailedCreate" || seen[e.Message] {
+			continue
+		}
+		seen[e.Message] = true
+		issues = append(issues, AdmissionIssue(ClassifyFailedCreate(e.Message)))
+	}
+	return issues
+}
+
+// splitQuotaList 将 "limits.memory=2Gi,requests.cpu=1" 解析为 map
+func splitQuotaList(s string) map[string]string {
+	result := map[string]string{}
+	for _, part := range strings.Split(s, ",") {
+		if k, v, ok := strings.Cut(strings.TrimSpace(part), "="); ok {
+			result[k] = v
+		}
+	}
+	return result
+}
+
+func minMaxLabel(s string) string {
+	if s == "maximum" {
+		return "最大"
+	}
+	return "最小"
+}
+
+func joinFields(fields []string) string {
+	if len(fields) == 0 {
+		return "(未能从消息中提取具体字段，请查看原始报错)"
+	}
+	return strings.Join(fields, "; ")
+}
@@ -0,0 +1,140 @@
+package diagnosis
+
+import (
+	"strings"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestClassifyFailedCreate(t *testing.T) {
+	tests := []struct {
+		name         string
+		message      string
+		wantCategory string
+		wantSource   string
+		wantField    string // 期望某个字段中包含的片段
+		wantFields   int    // 期望的字段数量，0 表示不检查
+	}{
+		{
+			name:         "PodSecurity",
+			message:      `Error creating: pods "web-5d8f-abcde" is forbidden: violates PodSecurity "restricted:latest": allowPrivilegeEscalation != false (container "app" must set securityContext.allowPrivilegeEscalation=false), runAsNonRoot != true (pod or container "app" must set securityContext.runAsNonRoot=true)`,
+			wantCategory: AdmissionPodSecurity,
+			wantSource:   "restricted:latest",
+			wantField:    "securityContext.runAsNonRoot=true",
+			wantFields:   2,
+		},
+		{
+			name:         "PodSecurity baseline: host namespaces",
+			message:      `Error creating: pods "web-5d8f-abcde" is forbidden: violates PodSecurity "baseline:latest": host namespaces (hostNetwork=true), hostPath volumes (volume "data"), hostPort (container "app" uses hostPort 80)`,
+			wantCategory: AdmissionPodSecurity,
+			wantSource:   "baseline:latest",
+			wantField:    "spec.hostNetwork / spec.hostPID / spec.hostIPC: hostNetwork=true",
+			wantFields:   3,
+		},
+		{
+			name:         "PodSecurity baseline: hostPath volumes",
+			message:      `Error creating: pods "web-5d8f-abcde" is forbidden: violates PodSecurity "baseline:latest": hostPath volumes (volume "data")`,
+			wantCategory: AdmissionPodSecurity,
+			wantSource:   "baseline:latest",
+			wantField:    `spec.volumes[].hostPath: volume "data"`,
+			wantFields:   1,
+		},
+		{
+			name:         "PodSecurity baseline: hostPort",
+			message:      `Error creating: pods "web-5d8f-abcde" is forbidden: violates PodSecurity "baseline:latest": hostPort (container "app" uses hostPort 80)`,
+			wantCategory: AdmissionPodSecurity,
+			wantSource:   "baseline:latest",
+			wantField:    `spec.containers[].ports[].hostPort: container "app" uses hostPort 80`,
+			wantFields:   1,
+		},
+		{
+			name:         "PodSecurity restricted: volume types",
+			message:      `Error creating: pods "web-5d8f-abcde" is forbidden: violates PodSecurity "restricted:latest": restricted volume types (volume "data" uses restricted volume type "hostPath"), runAsNonRoot != true (pod or container "app" must set securityContext.runAsNonRoot=true)`,
+			wantCategory: AdmissionPodSecurity,
+			wantSource:   "restricted:latest",
+			wantField:    `spec.volumes[] (只允许 configMap、secret、emptyDir、persistentVolumeClaim 等类型): volume "data" uses restricted volume type "hostPath"`,
+			wantFields:   2,
+		},
+		{
+			name:         "ResourceQuota exceeded",
+			message:      `Error creating: pods "web-5d8f-abcde" is forbidden: exceeded quota: compute-resources, requested: limits.memory=2Gi, used: limits.memory=7Gi, limited: limits.memory=8Gi`,
+			wantCategory: AdmissionResourceQuota,
+			wantSource:   "compute-resources",
+			wantField:    "limits.memory: 申请 2Gi, 已用 7Gi, 上限 8Gi",
+		},
+		{
+			name:         "ResourceQuota must specify",
+			message:      `Error creating: pods "web-5d8f-abcde" is forbidden: failed quota: compute-resources: must specify limits.cpu for: app; limits.memory for: app`,
+			wantCategory: AdmissionResourceQuota,
+			wantSource:   "compute-resources",
+			wantField:    "必须设置 limits.cpu for: app",
+		},
+		{
+			name:         "LimitRange",
+			message:      `Error creating: pods "web-5d8f-abcde" is forbidden: [maximum memory usage per Container is 1Gi, but limit is 2Gi, minimum cpu usage per Container is 100m, but request is 50m]`,
+			wantCategory: AdmissionLimitRange,
+			wantField:    "resources.requests.cpu = 50m",
+		},
+		{
+			name:         "Webhook",
+			message:      `Error creating: admission webhook "validate.kyverno.svc-fail" denied the request: policy require-labels: label 'team' is required`,
+			wantCategory: AdmissionWebhook,
+			wantSource:   "validate.kyverno.svc-fail",
+			wantField:    "label 'team' is required",
+		},
+		{
+			name:         "Unknown",
+			message:      `Error creating: pods "web" is forbidden: error looking up service account default/web: serviceaccount "web" not found`,
+			wantCategory: AdmissionUnknown,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ClassifyFailedCreate(tt.message)
+			if got.Category != tt.wantCategory {
+				t.Errorf("Category = %v, want %v", got.Category, tt.wantCategory)
+			}
+			if got.Source != tt.wantSource {
+				t.Errorf("Source = %q, want %q", got.Source, tt.wantSource)
+			}
+			if tt.wantField != "" && !strings.Contains(strings.Join(got.Fields, "\n"), tt.wantField) {
+				t.Errorf("Fields = %v, want to contain %q", got.Fields, tt.wantField)
+			}
+			if tt.wantFields > 0 && len(got.Fields) != tt.wantFields {
+				t.Errorf("Fields = %v, want %d fields", got.Fields, tt.wantFields)
+			}
+		})
+	}
+}
+
+func TestAnalyzer_AnalyzeReplicaSet_FailedCreate(t *testing.T) {
+	replicas := int32(2)
+	rs := &appsv1.ReplicaSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "web-5d8f", Namespace: "default", UID: "rs-uid"},
+		Spec: appsv1.ReplicaSetSpec{
+			Replicas: &replicas,
+			Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}},
+		},
+	}
+	event := &corev1.Event{
+		ObjectMeta:     metav1.ObjectMeta{Name: "web-5d8f.1", Namespace: "default"},
+		InvolvedObject: corev1.ObjectReference{Kind: "ReplicaSet", Name: "web-5d8f", Namespace: "default", UID: "rs-uid"},
+		Reason:         "FailedCreate",
+		Type:           "Warning",
+		Message:        `Error creating: pods "web-5d8f-x" is forbidden: violates PodSecurity "baseline:latest": privileged (container "app" must not set securityContext.privileged=true)`,
+	}
+
+	fakeClient := newEventSelectorClientset(rs, event)
+	result := NewAnalyzer(fakeClient).AnalyzeReplicaSet(rs)
+
+	if !hasIssue(result.Issues, "Pod 被 PodSecurity 准入拒绝 (baseline:latest)") {
+		t.Fatalf("expected PodSecurity issue, got %+v", result.Issues)
+	}
+	if !strings.Contains(result.Issues[0].Suggestion, "must not set securityContext.privileged=true") {
+		t.Errorf("suggestion should name the offending field, got %q", result.Issues[0].Suggestion)
+	}
+}
@@ -9,14 +9,19 @@ import (
 
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes"
+	metricsclient "k8s.io/metrics/pkg/client/clientset/versioned"
 )
 
 // Analyzer 负责编排整个 Pod 的诊断流程。
 // 它依赖 RuleEngine 进行具体的规则匹配，并聚合所有诊断结果。
 type Analyzer struct {
-	client kubernetes.Interface
-	engine *RuleEngine // 诊断引擎
+	client  kubernetes.Interface
+	engine  *RuleEngine             // 诊断引擎
+	metrics metricsclient.Interface // metrics.k8s.io 客户端 (可选，为 nil 时不采集使用量)
+	logs    LogAnalysisConfig       // 日志分析配置 (模式库、JSON 字段约定、抓取限制)
+	ctx     context.Context         // 诊断过程中 API 请求使用的上下文，取消后正在进行的日志读取会立即返回
 }
 
 // NewAnalyzer 初始化一个新的诊断分析器。
@@ -25,9 +30,19 @@ func NewAnalyzer(client kubernetes.Interface) *Analyzer {
 	return &Analyzer{
 		client: client,
 		engine: NewRuleEngine(), // 初始化诊断引擎
+		logs:   DefaultLogAnalysisConfig(),
+		ctx:    context.Background(),
 	}
 }
 
+// WithContext 设置诊断使用的上下文 (例如收到 Ctrl+C 时取消)
+func (a *Analyzer) WithContext(ctx context.Context) *Analyzer {
+	if ctx != nil {
+		a.ctx = ctx
+	}
+	return a
+}
+
 // AnalyzePod 对指定的 Pod 进行全方位的健康检查。
 // 包括：基础状态、容器详情、事件流、日志分析以及规则引擎匹配。
 func (a *Analyzer) AnalyzePod(pod *corev1.Pod) DiagnosisResult {
@@ -40,6 +55,9 @@ func (a *Analyzer) AnalyzePod(pod *corev1.Pod) DiagnosisResult {
 		Containers:   []ContainerDiagnosis{},
 		Events:       a.GetPodEvents(pod), // 获取事件列表
 	}
+	// 规则上下文只收集一次，供所有容器共享
+	ruleCtx := a.newRuleContext(pod)
+
 	// 遍历容器进行诊断
 	for _, cs := range pod.Status.ContainerStatuses {
 		// 寻找对应的 Container Spec
@@ -52,7 +70,7 @@ func (a *Analyzer) AnalyzePod(pod *corev1.Pod) DiagnosisResult {
 		}
 
 		// 获取单容器诊断结果
-		containerDiag := a.GetContainerDiagnosis(pod, cs, targetContainer)
+		containerDiag := a.diagnoseContainer(ruleCtx, pod, cs, targetContainer)
 		result.Containers = append(result.Containers, containerDiag)
 	}
 
@@ -60,18 +78,71 @@ func (a *Analyzer) AnalyzePod(pod *corev1.Pod) DiagnosisResult {
 	if len(pod.Status.ContainerStatuses) == 0 && pod.Status.Phase == corev1.PodPending {
 		// 构造虚拟状态触发检查,构造一个空的 dummy 状态，为了触发 PendingRule
 		dummyStatus := corev1.ContainerStatus{Name: "n/a"}
-		containerDiag := a.GetContainerDiagnosis(pod, dummyStatus, nil)
+		containerDiag := a.diagnoseContainer(ruleCtx, pod, dummyStatus, nil)
 		// 如果真的发现了问题（比如 PendingRule 命中了），才加进去
 		if len(containerDiag.Issues) > 0 {
 			result.Containers = append(result.Containers, containerDiag)
 		}
 	}
 
+	// 如果发现了问题，回答 "新版本改了什么"：与上一健康版本的模板做对比
+	if hasContainerIssues(result) {
+		result.TemplateDiff = a.GetTemplateDiff(pod)
+	}
+
+	// 镜像拉取缓慢会直接拖慢 Pod 启动
+	pulls := ParseImagePulls(ruleCtx.Events)
+	for i := range pulls {
+		if pulls[i].Node == "" {
+			pulls[i].Node = pod.Spec.NodeName
+		}
+	}
+	result.Issues = append(result.Issues, SlowImagePullIssues(pulls, DefaultSlowPullThreshold)...)
+
+	// 检查所属工作负载的 HPA：Pod 不健康可能只是因为 HPA 已顶到上限或读不到指标
+	hpa, hpaIssues := a.GetHPAStatus(pod)
+	result.HPA = hpa
+	result.Issues = append(result.Issues, hpaIssues...)
+
+	// 检查选中该 Pod 的 PDB：节点 drain 卡住时往往是 PDB 在阻止驱逐
+	pdbs, pdbIssues := a.GetPodPDBs(pod)
+	result.PDBs = pdbs
+	result.Issues = append(result.Issues, pdbIssues...)
+
 	return result
 }
 
+// hasContainerIssues 判断诊断结果中是否有任意容器发现了问题
+func hasContainerIssues(result DiagnosisResult) bool {
+	for _, c := range result.Containers {
+		if len(c.Issues) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// newRuleContext 收集规则需要的集群上下文 (Pod 事件等)
+func (a *Analyzer) newRuleContext(pod *corev1.Pod) *RuleContext {
+	events, _ := a.listObjectEvents(pod.Namespace, pod.Name, pod.UID)
+	ctx := &RuleContext{Events: events, Metrics: a.GetPodMetrics(pod)}
+
+	if pod.Spec.NodeName != "" {
+		if node, err := a.client.CoreV1().Nodes().Get(a.ctx, pod.Spec.NodeName, metav1.GetOptions{}); err == nil {
+			ctx.Node = node
+		}
+		ctx.NodeEvents, _ = a.listNodeEvents(pod.Spec.NodeName)
+	}
+	return ctx
+}
+
 // GetContainerDiagnosis 返回 ContainerDiagnosis 结构体
 func (a *Analyzer) GetContainerDiagnosis(pod *corev1.Pod, cs corev1.ContainerStatus, containerSpec *corev1.Container) ContainerDiagnosis {
+	return a.diagnoseContainer(a.newRuleContext(pod), pod, cs, containerSpec)
+}
+
+// diagnoseContainer 使用已收集的规则上下文诊断单个容器
+func (a *Analyzer) diagnoseContainer(ruleCtx *RuleContext, pod *corev1.Pod, cs corev1.ContainerStatus, containerSpec *corev1.Container) ContainerDiagnosis {
 	diag := ContainerDiagnosis{
 		Name:   cs.Name,
 		Ready:  cs.Ready,
@@ -96,14 +167,16 @@ func (a *Analyzer) GetContainerDiagnosis(pod *corev1.Pod, cs corev1.ContainerSta
 	if containerSpec != nil {
 		diag.ResourceInfo = a.GetResourceInfo(*containerSpec)
 	}
+	// 填充资源使用量 (metrics-server 可用时)
+	diag.Usage = BuildResourceUsage(ContainerUsage(ruleCtx.Metrics, cs.Name), containerSpec)
 
 	// ----------------------------------------------------
 	// 规则引擎介入
 	// ----------------------------------------------------
-	ruleResult := a.engine.Run(pod, containerSpec, cs)
+	ruleResult := a.engine.RunWithContext(ruleCtx, pod, containerSpec, cs)
 	if ruleResult != nil {
 		issueType := "Warning"
-		if ruleResult.Title == "内存溢出 (OOMKilled)" {
+		if ruleResult.Title == "内存溢出 (OOMKilled)" || ruleResult.Title == systemOOMTitle {
 			issueType = "Error"
 		}
 
@@ -130,22 +203,84 @@ func (a *Analyzer) GetContainerDiagnosis(pod *corev1.Pod, cs corev1.ContainerSta
 	// 日志分析 (Day 27)
 	// ----------------------------------------------------
 	// 只有当容器不正常 (非 Running) 或者有重启记录时，才去抓日志
-	// 避免抓取正常运行的日志浪费资源
-	if cs.State.Running == nil || cs.RestartCount > 0 {
-		logResult := AnalyzeContainerLogs(a.client, pod, cs.Name)
+	// 避免抓取正常运行的日志浪费资源；深度模式下所有容器都分析
+	crashed := cs.State.Running == nil || cs.RestartCount > 0
+	deep := a.logs.DeepWindow > 0
+
+	// 深度模式: 当前运行在时间窗口内的错误率趋势
+	var trendLines []string
+	if deep && cs.State.Running != nil {
+		if trend, lines, err := AnalyzeLogTrend(a.ctx, a.client, pod, cs.Name, a.logs, a.logs.DeepWindow); err == nil {
+			diag.LogTrend = trend
+			trendLines = lines
+		}
+	}
+
+	if crashed || deep {
+		var logResult LogAnalysisResult
+		if !crashed && diag.LogTrend != nil {
+			// 正常运行的容器复用趋势抓取的日志，不再单独抓取
+			logResult = recentLogResult(trendLines, diag.LogTrend, a.logs)
+		} else {
+			logResult = AnalyzeContainerLogsWith(a.ctx, a.client, pod, cs.Name, a.logs)
+		}
 		diag.Logs = logResult.Logs
-		diag.LogKeywords = logResult.MatchedKeyords
+		diag.LogKeywords = logResult.MatchedKeywords
+		diag.LogMatches = logResult.Matches
+		diag.StackTraces = logResult.StackTraces
+		diag.LogTemplates = logResult.Templates
+		diag.JSONLogs = NotableJSONEntries(logResult.JSONEntries)
+		diag.LogTruncation = logResult.Truncation
+		diag.RunComparison = logResult.Comparison
 
 		// 如果日志里发现了严重错误，也可以生成一个 Issue
-		if len(logResult.MatchedKeyords) > 0 {
-			diag.Issues = append(diag.Issues, Issue{
+		// (正常运行的容器偶尔出现错误日志很常见，深度模式下由错误率趋势判断)
+		if len(logResult.MatchedKeywords) > 0 && crashed {
+			issue := Issue{
 				Type:       "Error",
-				Title:      fmt.Sprintf("日志中发现错误特征: %s", strings.Join(logResult.MatchedKeyords, ", ")),
+				Title:      fmt.Sprintf("日志中发现错误特征: %s", strings.Join(logResult.MatchedKeywords, ", ")),
 				Suggestion: "请查看下方详细日志定位代码问题",
+			}
+			// 有堆栈时直接给出异常类型与应用代码中的抛出位置
+			if len(logResult.StackTraces) > 0 {
+				trace := logResult.StackTraces[len(logResult.StackTraces)-1]
+				issue.RawError = trace.Summary()
+				if trace.TopFrame != "" {
+					issue.Suggestion = fmt.Sprintf("异常抛出位置: %s，请结合下方完整堆栈定位代码问题", trace.TopFrame)
+				}
+			}
+			diag.Issues = append(diag.Issues, issue)
+		}
+
+		// 依赖故障: 目标是集群内 Service 时顺带检查它的 Endpoint
+		if len(logResult.Dependencies) > 0 {
+			a.checkDependencyServices(pod, logResult.Dependencies)
+			diag.Dependencies = logResult.Dependencies
+			for _, dep := range logResult.Dependencies {
+				if issue := dep.Issue(crashed); issue != nil {
+					diag.Issues = append(diag.Issues, *issue)
+				}
+			}
+		}
+
+		// 当前运行停在上次崩溃的位置，很可能会以同样的方式再次崩溃
+		if cmp := logResult.Comparison; cmp != nil && cmp.Progress == RunProgressAtCrashPoint {
+			diag.Issues = append(diag.Issues, Issue{
+				Type:       "Warning",
+				Title:      "当前运行停在上次崩溃前的位置",
+				RawError:   cmp.CrashPoint,
+				Suggestion: "两次运行在同一处停止，请对照下方两次运行的日志排查这一步依赖的资源或外部服务",
 			})
 		}
 	}
 
+	// 最近一段突增的错误模式
+	if trend := diag.LogTrend; trend != nil {
+		for _, p := range trend.Spikes() {
+			diag.Issues = append(diag.Issues, p.SpikeIssue(trend.BucketSize))
+		}
+	}
+
 	return diag
 }
 
@@ -179,22 +314,19 @@ func (a *Analyzer) GetResourceInfo(container corev1.Container) string {
 
 // GetPodEvents 返回字符串切片
 func (a *Analyzer) GetPodEvents(pod *corev1.Pod) []string {
-	var result []string
-
-	// 使用 FieldSelector 过滤出涉及该 Pod 的事件
-	// involvedObject.uid = Pod UID (更精确，防止同名冲突)
-	selector := fmt.Sprintf("involvedObject.name=%s,involvedObject.namespace=%s,involvedObject.uid=%s",
-		pod.Name, pod.Namespace, pod.UID)
+	return a.getObjectEvents(pod.Namespace, pod.Name, pod.UID)
+}
 
-	events, err := a.client.CoreV1().Events(pod.Namespace).List(context.TODO(), metav1.ListOptions{
-		FieldSelector: selector,
-	})
+// getObjectEvents 获取任意对象 (Pod / Service / Deployment ...) 最近的事件，格式化为字符串切片
+func (a *Analyzer) getObjectEvents(namespace, name string, uid types.UID) []string {
+	var result []string
 
+	events, err := a.listObjectEvents(namespace, name, uid)
 	if err != nil {
 		return []string{fmt.Sprintf("❌ 获取事件失败: %v", err)}
 	}
 
-	if len(events.Items) == 0 {
+	if len(events) == 0 {
 		return []string{}
 	}
 
@@ -202,23 +334,8 @@ func (a *Analyzer) GetPodEvents(pod *corev1.Pod) []string {
 	var recentEvents []corev1.Event
 	oneHourAgo := time.Now().Add(-1 * time.Hour)
 
-	// 辅助函数: 获取事件发生的最佳时间 (解决 [未知] 问题)
-	getEventTime := func(e corev1.Event) time.Time {
-		if !e.LastTimestamp.IsZero() {
-			return e.LastTimestamp.Time
-		}
-		if !e.EventTime.IsZero() {
-			return e.EventTime.Time
-		}
-		// 如果都没有，尝试 FirstTimestamp
-		if !e.FirstTimestamp.IsZero() {
-			return e.FirstTimestamp.Time
-		}
-		return time.Time{} // 真的一无所有
-	}
-
-	for _, e := range events.Items {
-		t := getEventTime(e)
+	for _, e := range events {
+		t := EventTime(e)
 		// 只要时间有效，且在1小时内，就保留
 		if !t.IsZero() && t.After(oneHourAgo) {
 			recentEvents = append(recentEvents, e)
@@ -227,8 +344,8 @@ func (a *Analyzer) GetPodEvents(pod *corev1.Pod) []string {
 
 	// 3. 按时间排序 (使用 recentEvents 而不是 events.Items)
 	sort.Slice(recentEvents, func(i, j int) bool {
-		t1 := getEventTime(recentEvents[i])
-		t2 := getEventTime(recentEvents[j])
+		t1 := EventTime(recentEvents[i])
+		t2 := EventTime(recentEvents[j])
 		return t1.Before(t2)
 	})
 
@@ -242,7 +359,7 @@ func (a *Analyzer) GetPodEvents(pod *corev1.Pod) []string {
 		e := recentEvents[i] // ✅ 这里使用 recentEvents
 
 		// 获取用于展示的时间
-		t := getEventTime(e)
+		t := EventTime(e)
 		age := TranslateTimestamp(t) // 确保 TranslateTimestamp 能处理 time.Time
 
 		icon := "🔹"
@@ -258,3 +375,31 @@ func (a *Analyzer) GetPodEvents(pod *corev1.Pod) []string {
 
 	return result
 }
+
+// listObjectEvents 获取涉及指定对象的原始事件列表
+func (a *Analyzer) listObjectEvents(namespace, name string, uid types.UID) ([]corev1.Event, error) {
+	// 使用 FieldSelector 过滤出涉及该对象的事件
+	// involvedObject.uid = 对象 UID (更精确，防止同名冲突)
+	selector := fmt.Sprintf("involvedObject.name=%s,involvedObject.namespace=%s,involvedObject.uid=%s",
+		name, namespace, uid)
+
+	events, err := a.client.CoreV1().Events(namespace).List(a.ctx, metav1.ListOptions{
+		FieldSelector: selector,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return events.Items, nil
+}
+
+// listNodeEvents 获取节点的原始事件列表
+// kubelet 上报的节点事件没有固定的命名空间，UID 也是节点名，因此按 kind + name 在所有命名空间中查找
+func (a *Analyzer) listNodeEvents(nodeName string) ([]corev1.Event, error) {
+	events, err := a.client.CoreV1().Events("").List(a.ctx, metav1.ListOptions{
+		FieldSelector: fmt.Sprintf("involvedObject.kind=Node,involvedObject.name=%s", nodeName),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return events.Items, nil
+}
@@ -2,12 +2,47 @@ package diagnosis
 
 import (
 	"testing"
+	"time"
 
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes/fake" // 关键：引入 fake 包
+	k8stesting "k8s.io/client-go/testing"
 )
 
+// newEventSelectorClientset 创建按 FieldSelector 过滤事件的 fake client
+// (fake client 默认忽略 FieldSelector，无法验证诊断时发出的查询条件)
+func newEventSelectorClientset(objects ...runtime.Object) *fake.Clientset {
+	client := fake.NewSimpleClientset(objects...)
+	client.PrependReactor("list", "events", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		list := action.(k8stesting.ListAction)
+		selector := list.GetListRestrictions().Fields
+		if selector == nil || selector.Empty() {
+			return false, nil, nil
+		}
+		obj, err := client.Tracker().List(corev1.SchemeGroupVersion.WithResource("events"), corev1.SchemeGroupVersion.WithKind("Event"), list.GetNamespace())
+		if err != nil {
+			return true, nil, err
+		}
+		filtered := &corev1.EventList{}
+		for _, e := range obj.(*corev1.EventList).Items {
+			if selector.Matches(fields.Set{
+				"involvedObject.kind":      e.InvolvedObject.Kind,
+				"involvedObject.name":      e.InvolvedObject.Name,
+				"involvedObject.namespace": e.InvolvedObject.Namespace,
+				"involvedObject.uid":       string(e.InvolvedObject.UID),
+			}) {
+				filtered.Items = append(filtered.Items, e)
+			}
+		}
+		return true, filtered, nil
+	})
+	return client
+}
+
 func TestAnalyzer_AnalyzePod(t *testing.T) {
 	// 1. 准备假数据 (Mock Pod)
 	pod := &corev1.Pod{
@@ -61,3 +96,95 @@ func TestAnalyzer_AnalyzePod(t *testing.T) {
 		t.Error("AnalyzePod failed to detect OOMKilled via Mock client")
 	}
 }
+
+func TestAnalyzer_AnalyzePod_GracePeriodKill(t *testing.T) {
+	finished := time.Now().Add(-time.Minute)
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default", UID: "pod-uid"},
+		Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "app"}}},
+		Status: corev1.PodStatus{
+			Phase: corev1.PodRunning,
+			ContainerStatuses: []corev1.ContainerStatus{{
+				Name:  "app",
+				State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "CrashLoopBackOff"}},
+				LastTerminationState: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{
+					Reason: "Error", ExitCode: 137, FinishedAt: metav1.NewTime(finished),
+				}},
+			}},
+		},
+	}
+	// 未设置 terminationGracePeriodSeconds，默认 30s
+	killing := &corev1.Event{
+		ObjectMeta: metav1.ObjectMeta{Name: "web.kill", Namespace: "default"},
+		InvolvedObject: corev1.ObjectReference{
+			Kind: "Pod", Name: "web", Namespace: "default", UID: "pod-uid", FieldPath: "spec.containers{app}",
+		},
+		Reason:        "Killing",
+		Message:       "Stopping container app",
+		LastTimestamp: metav1.NewTime(finished.Add(-30 * time.Second)),
+	}
+
+	result := NewAnalyzer(newEventSelectorClientset(pod, killing)).AnalyzePod(pod)
+	issues := result.Containers[0].Issues
+	if len(issues) == 0 || issues[0].Title != "优雅终止超时 (SIGTERM 后被 SIGKILL)" {
+		t.Errorf("expected grace-period kill instead of a generic crash, got %+v", issues)
+	}
+}
+
+func TestAnalyzer_AnalyzePod_SystemOOM(t *testing.T) {
+	finished := time.Now().Add(-time.Minute)
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "shop", UID: "pod-uid"},
+		Spec:       corev1.PodSpec{NodeName: "node-1", Containers: []corev1.Container{{Name: "app"}}},
+		Status: corev1.PodStatus{
+			Phase: corev1.PodRunning,
+			ContainerStatuses: []corev1.ContainerStatus{{
+				Name:  "app",
+				State: corev1.ContainerState{Running: &corev1.ContainerStateRunning{}},
+				LastTerminationState: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{
+					Reason: "Error", ExitCode: 137, FinishedAt: metav1.NewTime(finished),
+				}},
+			}},
+		},
+	}
+	node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-1"}}
+	// 节点事件由 kubelet 上报在 default 命名空间，与 Pod 不在同一个命名空间
+	oom := &corev1.Event{
+		ObjectMeta:     metav1.ObjectMeta{Name: "node-1.oom", Namespace: "default"},
+		InvolvedObject: corev1.ObjectReference{Kind: "Node", Name: "node-1", UID: "node-1"},
+		Reason:         "SystemOOM",
+		Message:        "System OOM encountered, victim process: app, pid: 42",
+		LastTimestamp:  metav1.NewTime(finished),
+	}
+
+	result := NewAnalyzer(newEventSelectorClientset(pod, node, oom)).AnalyzePod(pod)
+	issues := result.Containers[0].Issues
+	if len(issues) == 0 || issues[0].Title != systemOOMTitle || issues[0].Type != "Error" {
+		t.Errorf("expected system OOM error, got %+v", issues)
+	}
+}
+
+func TestAnalyzer_ListObjectEvents(t *testing.T) {
+	event := func(name, objName string, uid string) *corev1.Event {
+		return &corev1.Event{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			InvolvedObject: corev1.ObjectReference{
+				Kind: "ReplicaSet", Name: objName, Namespace: "default", UID: types.UID(uid),
+			},
+			Reason: "FailedCreate",
+		}
+	}
+	client := newEventSelectorClientset(
+		event("current", "web-5d8f", "uid-new"),
+		event("same-name-old-object", "web-5d8f", "uid-old"),
+		event("other", "api-7c9d", "uid-api"),
+	)
+
+	events, err := NewAnalyzer(client).listObjectEvents("default", "web-5d8f", "uid-new")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0].Name != "current" {
+		t.Errorf("events = %+v, want only the event of the current object", events)
+	}
+}
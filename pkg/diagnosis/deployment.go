@@ -0,0 +1,247 @@
+package diagnosis
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// maxRepresentativePods 是滚动更新诊断中深入分析的失败 Pod 数量上限
+// 同一 ReplicaSet 的 Pod 通常因为同一个原因失败，分析一两个就足够说明问题
+const maxRepresentativePods = 2
+
+// revisionAnnotation 是 Deployment 控制器记录版本号的注解
+const revisionAnnotation = "deployment.kubernetes.io/revision"
+
+// AnalyzeDeployment 诊断 Deployment 的滚动更新状态。
+// 读取 Progressing/Available 条件、对比新旧 ReplicaSet 的就绪副本数，
+// 并对新 ReplicaSet 中具有代表性的失败 Pod 调用 AnalyzePod，最终汇总为一份工作负载报告。
+func (a *Analyzer) AnalyzeDeployment(dep *appsv1.Deployment) WorkloadResult {
+	var desired int32 = 1
+	if dep.Spec.Replicas != nil {
+		desired = *dep.Spec.Replicas
+	}
+
+	result := WorkloadResult{
+		Kind:      "Deployment",
+		Name:      dep.Name,
+		Namespace: dep.Namespace,
+		Pods:      []PodSummary{},
+		Issues:    []Issue{},
+		Events:    a.getObjectEvents(dep.Namespace, dep.Name, dep.UID),
+	}
+
+	result.Details = append(result.Details,
+		Detail{Label: "版本 (Revision)", Value: dep.Annotations[revisionAnnotation]},
+		Detail{Label: "更新策略", Value: string(dep.Spec.Strategy.Type)},
+		Detail{Label: "副本数", Value: fmt.Sprintf("期望 %d / 已更新 %d / 就绪 %d / 可用 %d",
+			desired, dep.Status.UpdatedReplicas, dep.Status.ReadyReplicas, dep.Status.AvailableReplicas)},
+	)
+
+	// ----------------------------------------------------
+	// 1. 解读 Deployment Conditions
+	// ----------------------------------------------------
+	deadlineExceeded := false
+	for _, cond := range dep.Status.Conditions {
+		switch {
+		case cond.Type == appsv1.DeploymentProgressing && cond.Reason == "ProgressDeadlineExceeded":
+			deadlineExceeded = true
+			result.Issues = append(result.Issues, Issue{
+				Type:       "Error",
+				Title:      "滚动更新超时 (ProgressDeadlineExceeded)",
+				RawError:   cond.Message,
+				Suggestion: "新版本在 progressDeadlineSeconds 内没有完成就绪，请参考下方代表性 Pod 的诊断结果定位原因，必要时执行 kubectl rollout undo",
+			})
+		case cond.Type == appsv1.DeploymentAvailable && cond.Status == corev1.ConditionFalse:
+			result.Issues = append(result.Issues, Issue{
+				Type:       "Warning",
+				Title:      fmt.Sprintf("Deployment 不可用 (%s)", cond.Reason),
+				RawError:   cond.Message,
+				Suggestion: "可用副本数低于 maxUnavailable 允许的下限，服务容量已受影响",
+			})
+		case cond.Type == appsv1.DeploymentReplicaFailure && cond.Status == corev1.ConditionTrue:
+			result.Issues = append(result.Issues, Issue{
+				Type:       "Error",
+				Title:      fmt.Sprintf("ReplicaSet 创建 Pod 失败 (%s)", cond.Reason),
+				RawError:   cond.Message,
+				Suggestion: "请查看下方新 ReplicaSet 的准入拒绝分析",
+			})
+		}
+	}
+
+	// ----------------------------------------------------
+	// 2. 找到新旧 ReplicaSet
+	// ----------------------------------------------------
+	newRS, oldRSs, err := a.getDeploymentReplicaSets(dep)
+	if err != nil {
+		result.Issues = append(result.Issues, Issue{
+			Type:     "Warning",
+			Title:    "无法列出 Deployment 管理的 ReplicaSet",
+			RawError: err.Error(),
+		})
+		return result
+	}
+
+	if newRS != nil {
+		result.Details = append(result.Details, Detail{Label: "新 ReplicaSet", Value: formatReplicaSet(newRS)})
+	}
+	var oldReady int32
+	var oldNames []string
+	for _, rs := range oldRSs {
+		oldReady += rs.Status.ReadyReplicas
+		oldNames = append(oldNames, formatReplicaSet(rs))
+	}
+	if len(oldNames) > 0 {
+		result.Details = append(result.Details, Detail{Label: "旧 ReplicaSet", Value: strings.Join(oldNames, "; ")})
+	}
+
+	if newRS == nil {
+		return result
+	}
+
+	// 新 ReplicaSet 可能因为准入拒绝根本创建不出 Pod
+	result.Issues = append(result.Issues, a.GetAdmissionIssues(newRS.Namespace, newRS.Name, newRS.UID)...)
+
+	// ----------------------------------------------------
+	// 3. 分析新 ReplicaSet 的 Pod
+	// ----------------------------------------------------
+	newPods, err := a.listOwnedPods(newRS.Namespace, newRS.Spec.Selector, newRS.UID)
+	if err != nil {
+		result.Issues = append(result.Issues, Issue{
+			Type:     "Warning",
+			Title:    "无法列出新 ReplicaSet 的 Pod",
+			RawError: err.Error(),
+		})
+		return result
+	}
+
+	var failing []*corev1.Pod
+	for i := range newPods {
+		summary := SummarizePod(&newPods[i])
+		result.Pods = append(result.Pods, summary)
+		if !summary.Ready {
+			failing = append(failing, &newPods[i])
+		}
+	}
+	// 新 ReplicaSet 的 Pod 模板相同，摘要不一致说明 tag 被重新推送过
+	result.Issues = append(result.Issues, CheckImageConsistency(newPods)...)
+
+	newDesired := int32(0)
+	if newRS.Spec.Replicas != nil {
+		newDesired = *newRS.Spec.Replicas
+	}
+	rolloutStuck := deadlineExceeded || (newRS.Status.ReadyReplicas < newDesired && len(failing) > 0)
+	if !rolloutStuck {
+		return result
+	}
+
+	// 选取重启次数最多的失败 Pod 作为代表
+	sort.SliceStable(failing, func(i, j int) bool {
+		return SumRestarts(failing[i]) > SumRestarts(failing[j])
+	})
+	if len(failing) > maxRepresentativePods {
+		failing = failing[:maxRepresentativePods]
+	}
+
+	var causes []string
+	for _, pod := range failing {
+		podResult := a.AnalyzePod(pod)
+		result.PodResults = append(result.PodResults, podResult)
+		causes = append(causes, summarizePodIssues(podResult)...)
+	}
+
+	// ----------------------------------------------------
+	// 4. 汇总为一条 "为什么卡住" 的结论
+	// ----------------------------------------------------
+	summary := Issue{
+		Type:  "Error",
+		Title: "滚动更新卡住: 新版本 Pod 无法就绪",
+		RawError: fmt.Sprintf("新 ReplicaSet %s 就绪 %d/%d，旧 ReplicaSet 仍有 %d 个就绪副本在承载流量",
+			newRS.Name, newRS.Status.ReadyReplicas, newDesired, oldReady),
+	}
+	if len(causes) > 0 {
+		summary.Suggestion = fmt.Sprintf("代表性 Pod 的失败原因: %s。修复后重新发布，或执行 kubectl rollout undo deployment/%s 回滚",
+			strings.Join(uniqueStrings(causes...), "; "), dep.Name)
+	} else {
+		summary.Suggestion = "新 Pod 未就绪但规则引擎没有发现明确原因，请检查 readinessProbe 配置与应用启动日志"
+	}
+	result.Issues = append(result.Issues, summary)
+
+	return result
+}
+
+// getDeploymentReplicaSets 返回 Deployment 的新 ReplicaSet 与仍有副本的旧 ReplicaSet
+// 新 ReplicaSet 通过版本注解与 Deployment 保持一致来识别
+func (a *Analyzer) getDeploymentReplicaSets(dep *appsv1.Deployment) (*appsv1.ReplicaSet, []*appsv1.ReplicaSet, error) {
+	rsList, err := a.listOwnedReplicaSets(dep)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	revision := dep.Annotations[revisionAnnotation]
+	var newRS *appsv1.ReplicaSet
+	var oldRSs []*appsv1.ReplicaSet
+	for i := range rsList {
+		rs := &rsList[i]
+		if revision != "" && rs.Annotations[revisionAnnotation] == revision {
+			newRS = rs
+			continue
+		}
+		if (rs.Spec.Replicas != nil && *rs.Spec.Replicas > 0) || rs.Status.Replicas > 0 {
+			oldRSs = append(oldRSs, rs)
+		}
+	}
+	return newRS, oldRSs, nil
+}
+
+// listOwnedReplicaSets 列出 Deployment 直接管理的所有 ReplicaSet
+func (a *Analyzer) listOwnedReplicaSets(dep *appsv1.Deployment) ([]appsv1.ReplicaSet, error) {
+	opts := metav1.ListOptions{}
+	if dep.Spec.Selector != nil {
+		s, err := metav1.LabelSelectorAsSelector(dep.Spec.Selector)
+		if err != nil {
+			return nil, err
+		}
+		opts.LabelSelector = s.String()
+	}
+
+	list, err := a.client.AppsV1().ReplicaSets(dep.Namespace).List(a.ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []appsv1.ReplicaSet
+	for _, rs := range list.Items {
+		for _, ref := range rs.OwnerReferences {
+			if ref.UID == dep.UID {
+				result = append(result, rs)
+				break
+			}
+		}
+	}
+	return result, nil
+}
+
+// summarizePodIssues 提取 Pod 诊断结果中各容器的问题标题
+func summarizePodIssues(result DiagnosisResult) []string {
+	var titles []string
+	for _, c := range result.Containers {
+		for _, issue := range c.Issues {
+			titles = append(titles, fmt.Sprintf("[%s] %s", c.Name, issue.Title))
+		}
+	}
+	return titles
+}
+
+// formatReplicaSet 格式化 ReplicaSet 概况，例如 "web-5d8f (rev 3, 就绪 1/3)"
+func formatReplicaSet(rs *appsv1.ReplicaSet) string {
+	var desired int32
+	if rs.Spec.Replicas != nil {
+		desired = *rs.Spec.Replicas
+	}
+	return fmt.Sprintf("%s (rev %s, 就绪 %d/%d)", rs.Name, rs.Annotations[revisionAnnotation], rs.Status.ReadyReplicas, desired)
+}
@@ -0,0 +1,92 @@
+package diagnosis
+
+import (
+	"strings"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func int32Ptr(i int32) *int32 { return &i }
+
+func TestAnalyzer_AnalyzeDeployment_StuckRollout(t *testing.T) {
+	selector := &metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}}
+
+	dep := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "web", Namespace: "default", UID: "dep-uid",
+			Annotations: map[string]string{revisionAnnotation: "2"},
+		},
+		Spec: appsv1.DeploymentSpec{Replicas: int32Ptr(2), Selector: selector},
+		Status: appsv1.DeploymentStatus{
+			Conditions: []appsv1.DeploymentCondition{{
+				Type:    appsv1.DeploymentProgressing,
+				Status:  corev1.ConditionFalse,
+				Reason:  "ProgressDeadlineExceeded",
+				Message: `ReplicaSet "web-v2" has timed out progressing.`,
+			}},
+		},
+	}
+	owner := []metav1.OwnerReference{{Kind: "Deployment", Name: "web", UID: "dep-uid"}}
+
+	oldRS := &appsv1.ReplicaSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "web-v1", Namespace: "default", UID: "rs-v1", Labels: selector.MatchLabels,
+			Annotations: map[string]string{revisionAnnotation: "1"}, OwnerReferences: owner,
+		},
+		Spec:   appsv1.ReplicaSetSpec{Replicas: int32Ptr(2), Selector: selector},
+		Status: appsv1.ReplicaSetStatus{Replicas: 2, ReadyReplicas: 2},
+	}
+	newRS := &appsv1.ReplicaSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "web-v2", Namespace: "default", UID: "rs-v2", Labels: selector.MatchLabels,
+			Annotations: map[string]string{revisionAnnotation: "2"}, OwnerReferences: owner,
+		},
+		Spec:   appsv1.ReplicaSetSpec{Replicas: int32Ptr(1), Selector: selector},
+		Status: appsv1.ReplicaSetStatus{Replicas: 1},
+	}
+
+	// 新版本的 Pod 反复 OOM
+	newPod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "web-v2-abcde", Namespace: "default", Labels: selector.MatchLabels,
+			OwnerReferences: []metav1.OwnerReference{{Kind: "ReplicaSet", Name: "web-v2", UID: "rs-v2"}},
+		},
+		Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "app"}}},
+		Status: corev1.PodStatus{
+			Phase: corev1.PodRunning,
+			ContainerStatuses: []corev1.ContainerStatus{{
+				Name:         "app",
+				RestartCount: 4,
+				State:        corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "CrashLoopBackOff"}},
+				LastTerminationState: corev1.ContainerState{
+					Terminated: &corev1.ContainerStateTerminated{Reason: "OOMKilled", ExitCode: 137},
+				},
+			}},
+		},
+	}
+
+	fakeClient := fake.NewSimpleClientset(dep, oldRS, newRS, newPod)
+	result := NewAnalyzer(fakeClient).AnalyzeDeployment(dep)
+
+	if !hasIssue(result.Issues, "滚动更新超时 (ProgressDeadlineExceeded)") {
+		t.Errorf("expected ProgressDeadlineExceeded issue, got %+v", result.Issues)
+	}
+	if !hasIssue(result.Issues, "滚动更新卡住: 新版本 Pod 无法就绪") {
+		t.Fatalf("expected stuck rollout summary, got %+v", result.Issues)
+	}
+	if len(result.PodResults) != 1 || result.PodResults[0].PodName != "web-v2-abcde" {
+		t.Fatalf("expected one representative pod result, got %+v", result.PodResults)
+	}
+
+	summary := result.Issues[len(result.Issues)-1]
+	if !strings.Contains(summary.Suggestion, "内存溢出 (OOMKilled)") {
+		t.Errorf("summary should explain the pod failure, got %q", summary.Suggestion)
+	}
+	if !strings.Contains(summary.RawError, "旧 ReplicaSet 仍有 2 个就绪副本") {
+		t.Errorf("summary should compare old and new ReplicaSets, got %q", summary.RawError)
+	}
+}
@@ -13,10 +13,13 @@ type RuleEngine struct {
 func NewRuleEngine() *RuleEngine {
 	return &RuleEngine{
 		rules: []Rule{
-			&OOMRule{},       // 注册 OOM 规则
-			&ImagePullRule{}, // 注册镜像拉取失败规则
-			&CrashRule{},     // 注册崩溃循环规则
-			&PendingRule{},   // 注册调度失败规则
+			&OOMRule{},           // 注册 OOM 规则
+			&ImagePullRule{},     // 注册镜像拉取失败规则
+			&LifecycleHookRule{}, // 注册 postStart / preStop 钩子失败规则
+			&TerminationRule{},   // 注册优雅终止超时 / 探针重启规则 (需在 CrashRule 之前)
+			&CrashRule{},         // 注册崩溃循环规则
+			&ResizeRule{},        // 注册原地扩缩容未生效规则
+			&PendingRule{},       // 注册调度失败规则
 		},
 	}
 }
@@ -29,8 +32,19 @@ func (e *RuleEngine) Register(r Rule) {
 // Run 对单个容器运行所有规则，返回第一个命中的结果 (或者收集所有结果)
 // 这里我们采取“短路”策略：一旦发现严重问题(Matched=true)，就返回
 func (e *RuleEngine) Run(pod *corev1.Pod, container *corev1.Container, status corev1.ContainerStatus) *CheckResult {
+	return e.RunWithContext(nil, pod, container, status)
+}
+
+// RunWithContext 与 Run 相同，但会把集群上下文传给实现了 ContextRule 的规则
+// ctx 为 nil 时所有规则都退化为普通的 Check
+func (e *RuleEngine) RunWithContext(ctx *RuleContext, pod *corev1.Pod, container *corev1.Container, status corev1.ContainerStatus) *CheckResult {
 	for _, rule := range e.rules {
-		res := rule.Check(pod, container, status)
+		var res CheckResult
+		if cr, ok := rule.(ContextRule); ok && ctx != nil {
+			res = cr.CheckWithContext(ctx, pod, container, status)
+		} else {
+			res = rule.Check(pod, container, status)
+		}
 		if res.Matched {
 			// 命中规则，返回结果
 			return &res
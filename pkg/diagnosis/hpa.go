@@ -0,0 +1,221 @@
+package diagnosis
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// -----------------------------------------------------------
+// HPA 饱和与抖动诊断
+// Pod 看起来不健康，有时只是因为 HPA 已经顶到 maxReplicas，或者根本读不到指标
+// -----------------------------------------------------------
+
+const (
+	// hpaFlapWindow 判断抖动的时间窗口
+	hpaFlapWindow = 10 * time.Minute
+	// hpaFlapDirectionChanges 窗口内扩缩容方向反转的次数达到该值即视为抖动 (例如 扩->缩->扩)
+	hpaFlapDirectionChanges = 2
+)
+
+// rescaleSizeRe 匹配 SuccessfulRescale 事件中的目标副本数，例如 "New size: 5; reason: cpu ..."
+var rescaleSizeRe = regexp.MustCompile(`New size: (\d+)`)
+
+// HPAStatus 是作用于 Pod 所属工作负载的 HPA 概况
+type HPAStatus struct {
+	Name            string   `json:"name"`
+	Target          string   `json:"target"` // 例如 Deployment/web
+	MinReplicas     int32    `json:"min_replicas"`
+	MaxReplicas     int32    `json:"max_replicas"`
+	CurrentReplicas int32    `json:"current_replicas"`
+	DesiredReplicas int32    `json:"desired_replicas"`
+	Conditions      []string `json:"conditions"`    // 例如 "ScalingActive=False (FailedGetResourceMetric)"
+	MetricErrors    []string `json:"metric_errors"` // 窗口内获取指标失败的事件
+	RecentScales    []int32  `json:"recent_scales"` // 窗口内扩缩容的目标副本数 (按时间顺序)
+}
+
+// GetHPAStatus 找到作用于 Pod 所属工作负载的 HPA，并评估其状态
+// 没有 HPA 时返回 nil
+func (a *Analyzer) GetHPAStatus(pod *corev1.Pod) (*HPAStatus, []Issue) {
+	workload := a.ResolveWorkload(pod)
+	if workload == nil {
+		return nil, nil
+	}
+
+	list, err := a.client.AutoscalingV2().HorizontalPodAutoscalers(pod.Namespace).List(a.ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, nil
+	}
+
+	for i := range list.Items {
+		hpa := &list.Items[i]
+		ref := hpa.Spec.ScaleTargetRef
+		if ref.Kind != workload.Kind || ref.Name != workload.Name {
+			continue
+		}
+		events, _ := a.listObjectEvents(hpa.Namespace, hpa.Name, hpa.UID)
+		return EvaluateHPA(hpa, events, time.Now())
+	}
+	return nil, nil
+}
+
+// EvaluateHPA 根据 HPA 状态与事件评估是否饱和、无法读取指标或发生抖动
+func EvaluateHPA(hpa *autoscalingv2.HorizontalPodAutoscaler, events []corev1.Event, now time.Time) (*HPAStatus, []Issue) {
+	status := &HPAStatus{
+		Name:            hpa.Name,
+		Target:          fmt.Sprintf("%s/%s", hpa.Spec.ScaleTargetRef.Kind, hpa.Spec.ScaleTargetRef.Name),
+		MinReplicas:     1,
+		MaxReplicas:     hpa.Spec.MaxReplicas,
+		CurrentReplicas: hpa.Status.CurrentReplicas,
+		DesiredReplicas: hpa.Status.DesiredReplicas,
+		Conditions:      []string{},
+		MetricErrors:    []string{},
+		RecentScales:    []int32{},
+	}
+	if hpa.Spec.MinReplicas != nil {
+		status.MinReplicas = *hpa.Spec.MinReplicas
+	}
+
+	var issues []Issue
+	scalingActiveFailed := false
+	atMax := status.CurrentReplicas >= status.MaxReplicas && status.MaxReplicas > 0
+
+	// ----------------------------------------------------
+	// 1. 解读 Conditions
+	// ----------------------------------------------------
+	for _, cond := range hpa.Status.Conditions {
+		status.Conditions = append(status.Conditions, fmt.Sprintf("%s=%s (%s)", cond.Type, cond.Status, cond.Reason))
+
+		switch {
+		case cond.Type == autoscalingv2.ScalingActive && cond.Status == corev1.ConditionFalse:
+			scalingActiveFailed = true
+			issues = append(issues, Issue{
+				Type:       "Error",
+				Title:      fmt.Sprintf("HPA %s 无法计算副本数 (ScalingActive=False, %s)", hpa.Name, cond.Reason),
+				RawError:   cond.Message,
+				Suggestion: "请确认 metrics-server / 自定义指标适配器正常运行，且目标容器设置了对应的 resources.requests (按利用率扩缩容时必需)",
+			})
+		case cond.Type == autoscalingv2.AbleToScale && cond.Status == corev1.ConditionFalse:
+			issues = append(issues, Issue{
+				Type:       "Error",
+				Title:      fmt.Sprintf("HPA %s 无法执行扩缩容 (AbleToScale=False, %s)", hpa.Name, cond.Reason),
+				RawError:   cond.Message,
+				Suggestion: "请检查 scaleTargetRef 是否存在，以及 HPA 控制器是否有权限更新目标的 scale 子资源",
+			})
+		case cond.Type == autoscalingv2.ScalingLimited && cond.Status == corev1.ConditionTrue && cond.Reason == "TooManyReplicas":
+			atMax = true
+		}
+	}
+
+	if atMax {
+		issues = append(issues, Issue{
+			Type:  "Warning",
+			Title: fmt.Sprintf("HPA %s 已达到 maxReplicas 上限 (%d/%d)", hpa.Name, status.CurrentReplicas, status.MaxReplicas),
+			RawError: fmt.Sprintf("当前副本 %d，期望副本 %d，最大副本 %d",
+				status.CurrentReplicas, status.DesiredReplicas, status.MaxReplicas),
+			Suggestion: "负载已超出 HPA 能提供的容量，Pod 的高延迟/不健康可能只是过载导致；请调大 maxReplicas 或优化单 Pod 性能",
+		})
+	}
+
+	// ----------------------------------------------------
+	// 2. 分析窗口内的事件：指标获取失败与扩缩容历史
+	// ----------------------------------------------------
+	since := now.Add(-hpaFlapWindow)
+	var rescales []corev1.Event
+	for _, e := range events {
+		t := EventTime(e)
+		if t.Before(since) {
+			continue
+		}
+		switch {
+		case e.Reason == "SuccessfulRescale":
+			rescales = append(rescales, e)
+		case strings.HasPrefix(e.Reason, "FailedGet") || e.Reason == "FailedComputeMetricsReplicas":
+			status.MetricErrors = append(status.MetricErrors, fmt.Sprintf("%s: %s", e.Reason, e.Message))
+		}
+	}
+
+	if len(status.MetricErrors) > 0 && !scalingActiveFailed {
+		issues = append(issues, Issue{
+			Type:       "Warning",
+			Title:      fmt.Sprintf("HPA %s 最近获取指标失败 (%d 次)", hpa.Name, len(status.MetricErrors)),
+			RawError:   status.MetricErrors[len(status.MetricErrors)-1],
+			Suggestion: "指标间歇性不可用会让 HPA 维持旧的副本数，请检查 metrics-server 的日志与资源配置",
+		})
+	}
+
+	sort.Slice(rescales, func(i, j int) bool {
+		return EventTime(rescales[i]).Before(EventTime(rescales[j]))
+	})
+	repeated := false
+	for _, e := range rescales {
+		if m := rescaleSizeRe.FindStringSubmatch(e.Message); m != nil {
+			size, _ := strconv.Atoi(m[1])
+			status.RecentScales = append(status.RecentScales, int32(size))
+		}
+		// 相同消息的事件会被聚合，Count > 1 说明同一个副本数在窗口内被反复设置
+		if e.Count > 1 {
+			repeated = true
+		}
+	}
+
+	changes := countDirectionChanges(status.RecentScales)
+	distinct := len(uniqueInt32(status.RecentScales))
+	if changes >= hpaFlapDirectionChanges || (repeated && distinct >= 2) {
+		issues = append(issues, Issue{
+			Type:     "Warning",
+			Title:    fmt.Sprintf("HPA %s 副本数抖动 (%s 内扩缩容 %d 次)", hpa.Name, hpaFlapWindow, len(rescales)),
+			RawError: fmt.Sprintf("副本数变化: %s", joinInt32(status.RecentScales, " -> ")),
+			Suggestion: "频繁扩缩容会导致 Pod 反复创建销毁；请配置 behavior.scaleDown.stabilizationWindowSeconds，" +
+				"或检查目标利用率是否设置得过于敏感",
+		})
+	}
+
+	return status, issues
+}
+
+// countDirectionChanges 统计副本数序列中扩缩容方向反转的次数
+func countDirectionChanges(sizes []int32) int {
+	changes := 0
+	lastDir := 0
+	for i := 1; i < len(sizes); i++ {
+		dir := 0
+		switch {
+		case sizes[i] > sizes[i-1]:
+			dir = 1
+		case sizes[i] < sizes[i-1]:
+			dir = -1
+		}
+		if dir == 0 {
+			continue
+		}
+		if lastDir != 0 && dir != lastDir {
+			changes++
+		}
+		lastDir = dir
+	}
+	return changes
+}
+
+func uniqueInt32(values []int32) map[int32]bool {
+	set := map[int32]bool{}
+	for _, v := range values {
+		set[v] = true
+	}
+	return set
+}
+
+func joinInt32(values []int32, sep string) string {
+	parts := make([]string, 0, len(values))
+	for _, v := range values {
+		parts = append(parts, strconv.Itoa(int(v)))
+	}
+	return strings.Join(parts, sep)
+}
@@ -0,0 +1,169 @@
+package diagnosis
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func newHPA(current, max int32, conditions ...autoscalingv2.HorizontalPodAutoscalerCondition) *autoscalingv2.HorizontalPodAutoscaler {
+	return &autoscalingv2.HorizontalPodAutoscaler{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default", UID: "hpa-uid"},
+		Spec: autoscalingv2.HorizontalPodAutoscalerSpec{
+			ScaleTargetRef: autoscalingv2.CrossVersionObjectReference{Kind: "Deployment", Name: "web", APIVersion: "apps/v1"},
+			MinReplicas:    int32Ptr(2),
+			MaxReplicas:    max,
+		},
+		Status: autoscalingv2.HorizontalPodAutoscalerStatus{
+			CurrentReplicas: current,
+			DesiredReplicas: current,
+			Conditions:      conditions,
+		},
+	}
+}
+
+func rescaleEvent(size string, at time.Time, count int32) corev1.Event {
+	return corev1.Event{
+		Reason:        "SuccessfulRescale",
+		Message:       "New size: " + size + "; reason: cpu resource utilization (percentage of request) above target",
+		LastTimestamp: metav1.NewTime(at),
+		Count:         count,
+	}
+}
+
+func TestEvaluateHPA(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		name       string
+		hpa        *autoscalingv2.HorizontalPodAutoscaler
+		events     []corev1.Event
+		wantTitles []string // 期望出现的问题标题前缀
+	}{
+		{
+			name:   "healthy",
+			hpa:    newHPA(3, 10),
+			events: []corev1.Event{rescaleEvent("3", now.Add(-2*time.Minute), 1)},
+		},
+		{
+			name:       "at max replicas",
+			hpa:        newHPA(10, 10),
+			wantTitles: []string{"HPA web 已达到 maxReplicas 上限"},
+		},
+		{
+			name: "scaling limited by TooManyReplicas",
+			hpa: newHPA(8, 10, autoscalingv2.HorizontalPodAutoscalerCondition{
+				Type: autoscalingv2.ScalingLimited, Status: corev1.ConditionTrue, Reason: "TooManyReplicas",
+			}),
+			wantTitles: []string{"HPA web 已达到 maxReplicas 上限"},
+		},
+		{
+			name: "metrics unavailable",
+			hpa: newHPA(2, 10, autoscalingv2.HorizontalPodAutoscalerCondition{
+				Type: autoscalingv2.ScalingActive, Status: corev1.ConditionFalse, Reason: "FailedGetResourceMetric",
+				Message: "the HPA was unable to compute the replica count: missing request for cpu",
+			}),
+			events: []corev1.Event{{
+				Reason: "FailedGetResourceMetric", Message: "missing request for cpu",
+				LastTimestamp: metav1.NewTime(now.Add(-time.Minute)),
+			}},
+			wantTitles: []string{"HPA web 无法计算副本数 (ScalingActive=False, FailedGetResourceMetric)"},
+		},
+		{
+			name: "intermittent metric errors",
+			hpa:  newHPA(3, 10),
+			events: []corev1.Event{{
+				Reason: "FailedGetResourceMetric", Message: "unable to fetch metrics from resource metrics API",
+				LastTimestamp: metav1.NewTime(now.Add(-time.Minute)),
+			}},
+			wantTitles: []string{"HPA web 最近获取指标失败"},
+		},
+		{
+			name: "flapping",
+			hpa:  newHPA(4, 10),
+			events: []corev1.Event{
+				rescaleEvent("4", now.Add(-8*time.Minute), 1),
+				rescaleEvent("2", now.Add(-6*time.Minute), 1),
+				rescaleEvent("5", now.Add(-4*time.Minute), 1),
+				rescaleEvent("3", now.Add(-2*time.Minute), 1),
+			},
+			wantTitles: []string{"HPA web 副本数抖动"},
+		},
+		{
+			name: "old rescales outside window are ignored",
+			hpa:  newHPA(4, 10),
+			events: []corev1.Event{
+				rescaleEvent("4", now.Add(-58*time.Minute), 1),
+				rescaleEvent("2", now.Add(-56*time.Minute), 1),
+				rescaleEvent("5", now.Add(-54*time.Minute), 1),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, issues := EvaluateHPA(tt.hpa, tt.events, now)
+			if status == nil {
+				t.Fatal("status should not be nil")
+			}
+			if len(issues) != len(tt.wantTitles) {
+				t.Fatalf("got %d issues %+v, want %v", len(issues), issues, tt.wantTitles)
+			}
+			for i, want := range tt.wantTitles {
+				if !strings.HasPrefix(issues[i].Title, want) {
+					t.Errorf("issue[%d] = %q, want prefix %q", i, issues[i].Title, want)
+				}
+			}
+		})
+	}
+}
+
+func TestCountDirectionChanges(t *testing.T) {
+	tests := []struct {
+		sizes []int32
+		want  int
+	}{
+		{nil, 0},
+		{[]int32{2, 4, 6}, 0},
+		{[]int32{2, 4, 2}, 1},
+		{[]int32{2, 4, 4, 2, 5}, 2},
+	}
+	for _, tt := range tests {
+		if got := countDirectionChanges(tt.sizes); got != tt.want {
+			t.Errorf("countDirectionChanges(%v) = %d, want %d", tt.sizes, got, tt.want)
+		}
+	}
+}
+
+func TestAnalyzer_AnalyzePod_HPA(t *testing.T) {
+	controller := true
+	rs := &appsv1.ReplicaSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "web-6d4f", Namespace: "default", UID: "rs-uid",
+			OwnerReferences: []metav1.OwnerReference{{Kind: "Deployment", Name: "web", UID: "dep-uid", Controller: &controller}},
+		},
+	}
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "web-6d4f-abcde", Namespace: "default",
+			OwnerReferences: []metav1.OwnerReference{{Kind: "ReplicaSet", Name: "web-6d4f", UID: "rs-uid", Controller: &controller}},
+		},
+		Status: corev1.PodStatus{Phase: corev1.PodRunning},
+	}
+
+	fakeClient := fake.NewSimpleClientset(rs, pod, newHPA(10, 10))
+	result := NewAnalyzer(fakeClient).AnalyzePod(pod)
+
+	if result.HPA == nil || result.HPA.Target != "Deployment/web" {
+		t.Fatalf("expected HPA targeting Deployment/web, got %+v", result.HPA)
+	}
+	if len(result.Issues) != 1 || !strings.HasPrefix(result.Issues[0].Title, "HPA web 已达到 maxReplicas 上限") {
+		t.Errorf("expected pod-level saturation issue, got %+v", result.Issues)
+	}
+}
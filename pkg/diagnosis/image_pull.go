@@ -0,0 +1,275 @@
+package diagnosis
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// -----------------------------------------------------------
+// 镜像拉取耗时分析
+// kubelet 在 Pulled 事件中记录拉取耗时与镜像大小，例如
+// Successfully pulled image "nginx:1.25" in 3.021s (12.5s including waiting). Image size: 70542235 bytes.
+// -----------------------------------------------------------
+
+// DefaultSlowPullThreshold 是判定镜像拉取缓慢的默认阈值
+const DefaultSlowPullThreshold = 30 * time.Second
+
+var (
+	// pulledRe 解析 Pulled 事件，耗时、"including waiting" 与镜像大小只在较新的 kubelet 中出现
+	pulledRe = regexp.MustCompile(`^Successfully pulled image "([^"]+)"(?: in ([0-9][0-9.a-zµ]*))?(?: \(([0-9][0-9.a-zµ]*) including waiting\))?\.?(?: Image size: (\d+) bytes\.?)?`)
+	// pullingRe 解析 Pulling 事件，例如 Pulling image "nginx:1.25"
+	pullingRe = regexp.MustCompile(`^Pulling image "([^"]+)"`)
+)
+
+// ImagePull 是一次镜像拉取记录
+type ImagePull struct {
+	Image     string        `json:"image"`
+	Pod       string        `json:"pod"`
+	Node      string        `json:"node"`
+	Duration  time.Duration `json:"duration"`             // 实际拉取耗时
+	Waiting   time.Duration `json:"waiting,omitempty"`    // 含排队等待的总耗时 (串行拉取时会明显大于 Duration)
+	SizeBytes int64         `json:"size_bytes,omitempty"` // 镜像大小 (旧版本 kubelet 不上报)
+	Time      time.Time     `json:"time"`
+}
+
+// String 返回 "web-1 @ node-1: 45s (含等待 1m10s, 350Mi)" 形式
+func (p ImagePull) String() string {
+	var extra []string
+	if p.Waiting > p.Duration {
+		extra = append(extra, "含等待 "+formatDuration(p.Waiting))
+	}
+	if p.SizeBytes > 0 {
+		extra = append(extra, FormatMemory(p.SizeBytes))
+	}
+	s := fmt.Sprintf("%s @ %s: %s", p.Pod, p.Node, formatDuration(p.Duration))
+	if len(extra) > 0 {
+		s += " (" + strings.Join(extra, ", ") + ")"
+	}
+	return s
+}
+
+// ImagePullStats 是按镜像或节点聚合的拉取统计
+type ImagePullStats struct {
+	Key       string        `json:"key"` // 镜像名或节点名
+	Pulls     int           `json:"pulls"`
+	Slow      int           `json:"slow"`
+	Total     time.Duration `json:"total"`
+	Max       time.Duration `json:"max"`
+	SizeBytes int64         `json:"size_bytes,omitempty"` // 观测到的最大镜像大小
+}
+
+// Average 返回平均拉取耗时
+func (s ImagePullStats) Average() time.Duration {
+	if s.Pulls == 0 {
+		return 0
+	}
+	return s.Total / time.Duration(s.Pulls)
+}
+
+// String 返回 "拉取 3 次, 平均 12s, 最长 40s, 慢 1 次, 350Mi" 形式
+func (s ImagePullStats) String() string {
+	str := fmt.Sprintf("拉取 %d 次, 平均 %s, 最长 %s, 慢 %d 次",
+		s.Pulls, formatDuration(s.Average()), formatDuration(s.Max), s.Slow)
+	if s.SizeBytes > 0 {
+		str += ", " + FormatMemory(s.SizeBytes)
+	}
+	return str
+}
+
+// ParseImagePulls 从事件中提取镜像拉取记录
+// Pulled 事件没有耗时 (旧版本 kubelet) 时，使用同一 Pod 同一镜像的 Pulling 事件时间计算
+func ParseImagePulls(events []corev1.Event) []ImagePull {
+	pullingAt := map[string]time.Time{}
+	for _, e := range events {
+		if e.Reason != "Pulling" {
+			continue
+		}
+		if m := pullingRe.FindStringSubmatch(e.Message); m != nil {
+			pullingAt[e.InvolvedObject.Name+"|"+m[1]] = EventTime(e)
+		}
+	}
+
+	var pulls []ImagePull
+	for _, e := range events {
+		if e.Reason != "Pulled" {
+			continue
+		}
+		m := pulledRe.FindStringSubmatch(e.Message)
+		if m == nil {
+			continue // 例如 "Container image ... already present on machine"
+		}
+		pull := ImagePull{
+			Image: m[1],
+			Pod:   e.InvolvedObject.Name,
+			Node:  e.Source.Host,
+			Time:  EventTime(e),
+		}
+		pull.Duration, _ = time.ParseDuration(m[2])
+		pull.Waiting, _ = time.ParseDuration(m[3])
+		pull.SizeBytes, _ = strconv.ParseInt(m[4], 10, 64)
+
+		if pull.Duration == 0 {
+			if start, ok := pullingAt[pull.Pod+"|"+pull.Image]; ok && !start.IsZero() && pull.Time.After(start) {
+				pull.Duration = pull.Time.Sub(start)
+			}
+		}
+		pulls = append(pulls, pull)
+	}
+	return pulls
+}
+
+// AggregateImagePulls 按 key 聚合拉取记录，按最长耗时从大到小排序
+func AggregateImagePulls(pulls []ImagePull, threshold time.Duration, key func(ImagePull) string) []ImagePullStats {
+	index := map[string]int{}
+	var stats []ImagePullStats
+	for _, p := range pulls {
+		k := key(p)
+		i, ok := index[k]
+		if !ok {
+			i = len(stats)
+			index[k] = i
+			stats = append(stats, ImagePullStats{Key: k})
+		}
+		s := &stats[i]
+		s.Pulls++
+		s.Total += p.Duration
+		if p.Duration > s.Max {
+			s.Max = p.Duration
+		}
+		if p.Duration >= threshold {
+			s.Slow++
+		}
+		if p.SizeBytes > s.SizeBytes {
+			s.SizeBytes = p.SizeBytes
+		}
+	}
+	sort.SliceStable(stats, func(i, j int) bool { return stats[i].Max > stats[j].Max })
+	return stats
+}
+
+// SlowImagePullIssues 为超过阈值的镜像拉取生成问题，每个镜像一条
+func SlowImagePullIssues(pulls []ImagePull, threshold time.Duration) []Issue {
+	slowByImage := map[string][]ImagePull{}
+	var order []string
+	for _, p := range pulls {
+		if p.Duration < threshold {
+			continue
+		}
+		if _, ok := slowByImage[p.Image]; !ok {
+			order = append(order, p.Image)
+		}
+		slowByImage[p.Image] = append(slowByImage[p.Image], p)
+	}
+
+	var issues []Issue
+	for _, image := range order {
+		slow := slowByImage[image]
+		var max time.Duration
+		details := make([]string, 0, len(slow))
+		for _, p := range slow {
+			if p.Duration > max {
+				max = p.Duration
+			}
+			details = append(details, p.String())
+		}
+		issues = append(issues, Issue{
+			Type:     "Warning",
+			Title:    fmt.Sprintf("镜像拉取缓慢: %s (最长 %s，阈值 %s)", image, formatDuration(max), formatDuration(threshold)),
+			RawError: strings.Join(details, "; "),
+			Suggestion: "镜像拉取拖慢了 Pod 启动: 1.精简镜像 (多阶段构建、distroless / alpine 基础镜像) " +
+				"2.通过 DaemonSet 或节点初始化脚本预拉取镜像 3.使用就近的镜像仓库 / 镜像缓存 " +
+				"4.\"含等待\" 明显大于拉取耗时说明 kubelet 在串行拉取 (serializeImagePulls)，可考虑开启并行拉取",
+		})
+	}
+	return issues
+}
+
+// ScanImagePulls 统计命名空间下的镜像拉取耗时，按镜像和节点聚合并标记慢拉取
+func (a *Analyzer) ScanImagePulls(namespace string, threshold time.Duration) (WorkloadResult, error) {
+	result := WorkloadResult{
+		Kind:      "Namespace",
+		Name:      namespace,
+		Namespace: namespace,
+		Details:   []Detail{},
+		Pods:      []PodSummary{},
+		Issues:    []Issue{},
+		Events:    []string{},
+	}
+
+	list, err := a.client.CoreV1().Events(namespace).List(a.ctx, metav1.ListOptions{})
+	if err != nil {
+		return result, fmt.Errorf("无法获取事件列表: %w", err)
+	}
+	var podEvents []corev1.Event
+	for _, e := range list.Items {
+		if e.InvolvedObject.Kind == "Pod" {
+			podEvents = append(podEvents, e)
+		}
+	}
+	pulls := ParseImagePulls(podEvents)
+	a.fillPullNodes(namespace, pulls)
+
+	slow := 0
+	for _, p := range pulls {
+		if p.Duration >= threshold {
+			slow++
+		}
+	}
+	result.Details = append(result.Details, Detail{
+		Label: "镜像拉取",
+		Value: fmt.Sprintf("%d 次 (慢拉取 %d 次，阈值 %s)", len(pulls), slow, formatDuration(threshold)),
+	})
+	for _, s := range AggregateImagePulls(pulls, threshold, func(p ImagePull) string { return p.Image }) {
+		result.Details = append(result.Details, Detail{Label: "镜像 " + s.Key, Value: s.String()})
+	}
+	for _, s := range AggregateImagePulls(pulls, threshold, func(p ImagePull) string { return p.Node }) {
+		result.Details = append(result.Details, Detail{Label: "节点 " + s.Key, Value: s.String()})
+	}
+
+	result.Issues = append(result.Issues, SlowImagePullIssues(pulls, threshold)...)
+	return result, nil
+}
+
+// fillPullNodes 事件没有记录节点时 (Source.Host 为空)，使用 Pod 所在节点补全
+func (a *Analyzer) fillPullNodes(namespace string, pulls []ImagePull) {
+	missing := false
+	for _, p := range pulls {
+		if p.Node == "" {
+			missing = true
+			break
+		}
+	}
+	if !missing {
+		return
+	}
+
+	nodes := map[string]string{}
+	if podList, err := a.client.CoreV1().Pods(namespace).List(a.ctx, metav1.ListOptions{}); err == nil {
+		for _, pod := range podList.Items {
+			nodes[pod.Name] = pod.Spec.NodeName
+		}
+	}
+	for i := range pulls {
+		if pulls[i].Node == "" {
+			pulls[i].Node = nodes[pulls[i].Pod]
+		}
+		if pulls[i].Node == "" {
+			pulls[i].Node = "<未知>"
+		}
+	}
+}
+
+// formatDuration 将耗时格式化为最多保留一位小数的秒数，例如 "12.3s"、"2m5s"
+func formatDuration(d time.Duration) string {
+	if d < time.Minute {
+		return d.Round(100 * time.Millisecond).String()
+	}
+	return d.Round(time.Second).String()
+}
@@ -0,0 +1,105 @@
+package diagnosis
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// newPullEvent 创建 Pod 的 Pulling / Pulled 事件
+func newPullEvent(name, pod, node, reason, message string, at time.Time) *corev1.Event {
+	return &corev1.Event{
+		ObjectMeta:     metav1.ObjectMeta{Name: name, Namespace: "default"},
+		InvolvedObject: corev1.ObjectReference{Kind: "Pod", Name: pod, Namespace: "default"},
+		Reason:         reason,
+		Message:        message,
+		Source:         corev1.EventSource{Host: node},
+		LastTimestamp:  metav1.NewTime(at),
+	}
+}
+
+func TestParseImagePulls(t *testing.T) {
+	now := time.Now()
+	events := []corev1.Event{
+		*newPullEvent("e1", "web-1", "node-1", "Pulled",
+			`Successfully pulled image "shop/web:1.2" in 45.512s (1m10.2s including waiting). Image size: 367001600 bytes.`, now),
+		*newPullEvent("e2", "web-2", "node-2", "Pulled", `Successfully pulled image "shop/web:1.2" in 850ms`, now),
+		*newPullEvent("e3", "web-3", "node-2", "Pulled", `Container image "shop/web:1.2" already present on machine`, now),
+		// 旧版本 kubelet 没有上报耗时，使用 Pulling 事件计算
+		*newPullEvent("e4", "api-1", "node-1", "Pulling", `Pulling image "shop/api:2.0"`, now.Add(-40*time.Second)),
+		*newPullEvent("e5", "api-1", "node-1", "Pulled", `Successfully pulled image "shop/api:2.0"`, now),
+	}
+
+	pulls := ParseImagePulls(events)
+	if len(pulls) != 3 {
+		t.Fatalf("pulls = %d, want 3", len(pulls))
+	}
+	want := []string{
+		"web-1 @ node-1: 45.5s (含等待 1m10s, 350Mi)",
+		"web-2 @ node-2: 900ms",
+		"api-1 @ node-1: 40s",
+	}
+	for i, w := range want {
+		if got := pulls[i].String(); got != w {
+			t.Errorf("pull[%d] = %q, want %q", i, got, w)
+		}
+	}
+}
+
+func TestAggregateImagePulls(t *testing.T) {
+	pulls := []ImagePull{
+		{Image: "shop/web:1.2", Node: "node-1", Duration: 40 * time.Second, SizeBytes: 100 << 20},
+		{Image: "shop/web:1.2", Node: "node-2", Duration: 20 * time.Second, SizeBytes: 100 << 20},
+		{Image: "shop/api:2.0", Node: "node-1", Duration: 5 * time.Second},
+	}
+
+	byImage := AggregateImagePulls(pulls, 30*time.Second, func(p ImagePull) string { return p.Image })
+	if len(byImage) != 2 || byImage[0].Key != "shop/web:1.2" {
+		t.Fatalf("byImage = %+v", byImage)
+	}
+	if got := byImage[0].String(); got != "拉取 2 次, 平均 30s, 最长 40s, 慢 1 次, 100Mi" {
+		t.Errorf("stats = %q", got)
+	}
+
+	byNode := AggregateImagePulls(pulls, 30*time.Second, func(p ImagePull) string { return p.Node })
+	if len(byNode) != 2 || byNode[0].Key != "node-1" || byNode[0].Pulls != 2 {
+		t.Errorf("byNode = %+v", byNode)
+	}
+}
+
+func TestAnalyzer_ScanImagePulls(t *testing.T) {
+	now := time.Now()
+	client := fake.NewSimpleClientset(
+		&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "web-2", Namespace: "default"}, Spec: corev1.PodSpec{NodeName: "node-2"}},
+		newPullEvent("e1", "web-1", "node-1", "Pulled", `Successfully pulled image "shop/web:1.2" in 1m5s (1m5s including waiting). Image size: 367001600 bytes.`, now),
+		newPullEvent("e2", "web-2", "", "Pulled", `Successfully pulled image "shop/web:1.2" in 50s`, now),
+		newPullEvent("e3", "api-1", "node-1", "Pulled", `Successfully pulled image "shop/api:2.0" in 2.5s`, now),
+	)
+
+	result, err := NewAnalyzer(client).ScanImagePulls("default", 30*time.Second)
+	if err != nil {
+		t.Fatalf("ScanImagePulls failed: %v", err)
+	}
+	if result.Details[0].Value != "3 次 (慢拉取 2 次，阈值 30s)" {
+		t.Errorf("summary = %q", result.Details[0].Value)
+	}
+	if len(result.Issues) != 1 || !strings.Contains(result.Issues[0].Title, "shop/web:1.2 (最长 1m5s") {
+		t.Fatalf("issues = %+v, want one slow image", result.Issues)
+	}
+	// 没有 Source.Host 的事件使用 Pod 所在节点补全
+	if !strings.Contains(result.Issues[0].RawError, "web-2 @ node-2: 50s") {
+		t.Errorf("RawError = %q", result.Issues[0].RawError)
+	}
+
+	var labels []string
+	for _, d := range result.Details {
+		labels = append(labels, d.Label)
+	}
+	if got := strings.Join(labels, ","); got != "镜像拉取,镜像 shop/web:1.2,镜像 shop/api:2.0,节点 node-1,节点 node-2" {
+		t.Errorf("detail labels = %s", got)
+	}
+}
@@ -0,0 +1,226 @@
+package diagnosis
+
+import (
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// -----------------------------------------------------------
+// 镜像一致性检查
+// 可变 tag (例如 :latest) 会让同一控制器的副本在不同节点上拉取到不同的镜像内容
+// -----------------------------------------------------------
+
+// shortDigestLen 报告中展示的摘要长度 (不含 "sha256:" 前缀)
+const shortDigestLen = 12
+
+// ImageDigest 是某个容器的一个镜像摘要及运行它的 Pod / 节点
+type ImageDigest struct {
+	Digest string   `json:"digest"`
+	Pods   []string `json:"pods"`
+	Nodes  []string `json:"nodes"`
+}
+
+// String 返回 "sha256:0123456789ab: node-1 (web-1), node-2 (web-2)" 形式
+func (d ImageDigest) String() string {
+	parts := make([]string, 0, len(d.Pods))
+	for i, pod := range d.Pods {
+		node := d.Nodes[i]
+		if node == "" {
+			node = "<未调度>"
+		}
+		parts = append(parts, fmt.Sprintf("%s (%s)", node, pod))
+	}
+	return fmt.Sprintf("%s: %s", shortDigest(d.Digest), strings.Join(parts, ", "))
+}
+
+// ContainerImages 是同一控制器下某个容器在所有副本上运行的镜像摘要
+type ContainerImages struct {
+	Container string        `json:"container"`
+	Image     string        `json:"image"` // spec 中的镜像
+	Digests   []ImageDigest `json:"digests"`
+}
+
+// CollectContainerImages 按容器汇总副本实际运行的镜像摘要 (来自 ContainerStatuses 的 imageID)
+func CollectContainerImages(pods []corev1.Pod) []ContainerImages {
+	var result []ContainerImages
+	index := map[string]int{}
+	for _, pod := range pods {
+		for _, cs := range pod.Status.ContainerStatuses {
+			digest := imageDigest(cs.ImageID)
+			if digest == "" {
+				continue // 镜像尚未拉取完成
+			}
+			i, ok := index[cs.Name]
+			if !ok {
+				i = len(result)
+				index[cs.Name] = i
+				result = append(result, ContainerImages{Container: cs.Name, Image: specImage(&pod, cs.Name, cs.Image)})
+			}
+			result[i].Digests = addDigest(result[i].Digests, digest, pod.Name, pod.Spec.NodeName)
+		}
+	}
+	return result
+}
+
+// CheckImageConsistency 检查同一控制器的副本镜像是否一致，以及是否使用了可变 tag + IfNotPresent
+func CheckImageConsistency(pods []corev1.Pod) []Issue {
+	var issues []Issue
+	for _, ci := range CollectContainerImages(pods) {
+		if len(ci.Digests) < 2 {
+			continue
+		}
+		lines := make([]string, 0, len(ci.Digests))
+		for _, d := range ci.Digests {
+			lines = append(lines, d.String())
+		}
+		issues = append(issues, Issue{
+			Type:     "Warning",
+			Title:    fmt.Sprintf("副本镜像不一致: 容器 %s 运行了 %d 个不同的镜像摘要", ci.Container, len(ci.Digests)),
+			RawError: fmt.Sprintf("镜像 %s | %s", ci.Image, strings.Join(lines, "; ")),
+			Suggestion: "镜像 tag 被重新推送过，各节点在不同时间拉取到了不同内容: 1.使用 digest 固定镜像 (image@sha256:...) " +
+				"2.每次发布使用不可变的 tag (例如 git commit 或版本号) 3.需要立即统一时执行 kubectl rollout restart，并确保 imagePullPolicy 为 Always",
+		})
+	}
+
+	seen := map[string]bool{}
+	for _, pod := range pods {
+		for _, c := range pod.Spec.Containers {
+			key := c.Name + "|" + c.Image
+			if seen[key] || c.ImagePullPolicy != corev1.PullIfNotPresent || !isMutableTag(c.Image) {
+				continue
+			}
+			seen[key] = true
+			issues = append(issues, Issue{
+				Type:     "Warning",
+				Title:    fmt.Sprintf("容器 %s 使用可变 tag 且 imagePullPolicy 为 IfNotPresent", c.Name),
+				RawError: fmt.Sprintf("镜像 %s", c.Image),
+				Suggestion: "节点上已有同名镜像时不会重新拉取，新旧节点上的副本可能运行不同版本: " +
+					"请使用带版本号的 tag 或 digest；确实需要跟随 latest 时设置 imagePullPolicy: Always",
+			})
+		}
+	}
+	return issues
+}
+
+// ScanImages 扫描命名空间下所有控制器的副本镜像一致性
+func (a *Analyzer) ScanImages(namespace string) (WorkloadResult, error) {
+	result := WorkloadResult{
+		Kind:      "Namespace",
+		Name:      namespace,
+		Namespace: namespace,
+		Details:   []Detail{},
+		Pods:      []PodSummary{},
+		Issues:    []Issue{},
+		Events:    []string{},
+	}
+
+	list, err := a.client.CoreV1().Pods(namespace).List(a.ctx, metav1.ListOptions{})
+	if err != nil {
+		return result, fmt.Errorf("无法获取 Pod 列表: %w", err)
+	}
+
+	// 按直接控制器分组：同一 Deployment 的新旧 ReplicaSet 本来就运行不同镜像，不应比较
+	groups := map[types.UID][]corev1.Pod{}
+	var order []types.UID
+	for _, pod := range list.Items {
+		owner := metav1.GetControllerOf(&pod)
+		if owner == nil {
+			continue
+		}
+		if _, ok := groups[owner.UID]; !ok {
+			order = append(order, owner.UID)
+		}
+		groups[owner.UID] = append(groups[owner.UID], pod)
+	}
+
+	inconsistent := 0
+	cache := map[types.UID]*WorkloadRef{}
+	for _, uid := range order {
+		pods := groups[uid]
+		issues := CheckImageConsistency(pods)
+		if len(issues) == 0 {
+			continue
+		}
+		ref := a.cachedWorkload(&pods[0], cache)
+		for _, issue := range issues {
+			issue.Title = fmt.Sprintf("[%s] %s", ref.String(), issue.Title)
+			result.Issues = append(result.Issues, issue)
+		}
+		for _, ci := range CollectContainerImages(pods) {
+			if len(ci.Digests) > 1 {
+				inconsistent++
+				result.Details = append(result.Details, imageDetail(ref.String()+" "+ci.Container, ci))
+			}
+		}
+	}
+	result.Details = append([]Detail{
+		{Label: "控制器数量", Value: fmt.Sprintf("%d (镜像不一致的容器 %d)", len(order), inconsistent)},
+	}, result.Details...)
+	return result, nil
+}
+
+// imageDetail 生成 "镜像摘要 <label>" 的基础信息
+func imageDetail(label string, ci ContainerImages) Detail {
+	lines := make([]string, 0, len(ci.Digests))
+	for _, d := range ci.Digests {
+		lines = append(lines, d.String())
+	}
+	return Detail{Label: "镜像摘要 " + label, Value: strings.Join(lines, "; ")}
+}
+
+// addDigest 将 Pod 记录到对应摘要下，摘要按首次出现的顺序排列
+func addDigest(digests []ImageDigest, digest, pod, node string) []ImageDigest {
+	for i := range digests {
+		if digests[i].Digest == digest {
+			digests[i].Pods = append(digests[i].Pods, pod)
+			digests[i].Nodes = append(digests[i].Nodes, node)
+			return digests
+		}
+	}
+	return append(digests, ImageDigest{Digest: digest, Pods: []string{pod}, Nodes: []string{node}})
+}
+
+// specImage 返回 Pod spec 中容器的镜像，找不到时使用状态中的镜像
+func specImage(pod *corev1.Pod, name, fallback string) string {
+	for _, c := range pod.Spec.Containers {
+		if c.Name == name {
+			return c.Image
+		}
+	}
+	return fallback
+}
+
+// imageDigest 从 imageID 中提取摘要
+// 不同运行时的格式不同: "docker-pullable://nginx@sha256:..."、"docker.io/library/nginx@sha256:..." 或 "sha256:..."
+func imageDigest(imageID string) string {
+	if i := strings.LastIndex(imageID, "@"); i >= 0 {
+		return imageID[i+1:]
+	}
+	if i := strings.Index(imageID, "://"); i >= 0 {
+		return imageID[i+3:]
+	}
+	return imageID
+}
+
+// shortDigest 将 "sha256:<64 位>" 缩短为 "sha256:<前 12 位>"
+func shortDigest(digest string) string {
+	algo, hex, found := strings.Cut(digest, ":")
+	if !found || len(hex) <= shortDigestLen {
+		return digest
+	}
+	return algo + ":" + hex[:shortDigestLen]
+}
+
+// isMutableTag 判断镜像是否使用 latest 或未指定 tag (digest 固定的镜像不可变)
+func isMutableTag(image string) bool {
+	if strings.Contains(image, "@") {
+		return false
+	}
+	name := image[strings.LastIndex(image, "/")+1:]
+	_, tag, found := strings.Cut(name, ":")
+	return !found || tag == "latest"
+}
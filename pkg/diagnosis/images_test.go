@@ -0,0 +1,131 @@
+package diagnosis
+
+import (
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+const (
+	digestA = "sha256:aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"
+	digestB = "sha256:bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb"
+)
+
+// newImagePod 创建一个运行指定镜像摘要的副本
+func newImagePod(name, node, image string, policy corev1.PullPolicy, imageID string) corev1.Pod {
+	isController := true
+	return corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: name, Namespace: "default",
+			OwnerReferences: []metav1.OwnerReference{{Kind: "ReplicaSet", Name: "web-7d9c", UID: "rs-uid", Controller: &isController}},
+		},
+		Spec: corev1.PodSpec{
+			NodeName:   node,
+			Containers: []corev1.Container{{Name: "app", Image: image, ImagePullPolicy: policy}},
+		},
+		Status: corev1.PodStatus{ContainerStatuses: []corev1.ContainerStatus{{Name: "app", Image: image, ImageID: imageID}}},
+	}
+}
+
+func TestCheckImageConsistency(t *testing.T) {
+	tests := []struct {
+		name       string
+		pods       []corev1.Pod
+		wantTitles []string
+		wantText   string
+	}{
+		{
+			name: "副本摘要一致",
+			pods: []corev1.Pod{
+				newImagePod("web-1", "node-1", "shop/web:1.2.0", corev1.PullIfNotPresent, "docker.io/shop/web@"+digestA),
+				newImagePod("web-2", "node-2", "shop/web:1.2.0", corev1.PullIfNotPresent, "docker.io/shop/web@"+digestA),
+			},
+		},
+		{
+			name: "可变 tag 导致摘要不一致",
+			pods: []corev1.Pod{
+				newImagePod("web-1", "node-1", "shop/web:latest", corev1.PullIfNotPresent, "docker-pullable://shop/web@"+digestA),
+				newImagePod("web-2", "node-2", "shop/web:latest", corev1.PullIfNotPresent, "docker-pullable://shop/web@"+digestB),
+				newImagePod("web-3", "node-3", "shop/web:latest", corev1.PullIfNotPresent, digestA),
+			},
+			wantTitles: []string{
+				"副本镜像不一致: 容器 app 运行了 2 个不同的镜像摘要",
+				"容器 app 使用可变 tag 且 imagePullPolicy 为 IfNotPresent",
+			},
+			wantText: "sha256:aaaaaaaaaaaa: node-1 (web-1), node-3 (web-3); sha256:bbbbbbbbbbbb: node-2 (web-2)",
+		},
+		{
+			name: "未指定 tag 且 IfNotPresent",
+			pods: []corev1.Pod{
+				newImagePod("web-1", "node-1", "registry:5000/shop/web", corev1.PullIfNotPresent, ""),
+			},
+			wantTitles: []string{"容器 app 使用可变 tag 且 imagePullPolicy 为 IfNotPresent"},
+		},
+		{
+			name: "latest 但 imagePullPolicy 为 Always",
+			pods: []corev1.Pod{
+				newImagePod("web-1", "node-1", "shop/web:latest", corev1.PullAlways, digestA),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			issues := CheckImageConsistency(tt.pods)
+			if len(issues) != len(tt.wantTitles) {
+				t.Fatalf("issues = %+v, want %d", issues, len(tt.wantTitles))
+			}
+			for i, title := range tt.wantTitles {
+				if issues[i].Title != title {
+					t.Errorf("issue[%d] = %q, want %q", i, issues[i].Title, title)
+				}
+			}
+			if tt.wantText != "" && !strings.Contains(issues[0].RawError, tt.wantText) {
+				t.Errorf("RawError %q should contain %q", issues[0].RawError, tt.wantText)
+			}
+		})
+	}
+}
+
+func TestIsMutableTag(t *testing.T) {
+	tests := map[string]bool{
+		"nginx":                       true,
+		"nginx:latest":                true,
+		"registry:5000/shop/web":      true,
+		"registry:5000/shop/web:1.0":  false,
+		"nginx@" + digestA:            false,
+		"shop/web:latest@" + digestA:  false,
+		"ghcr.io/org/app:v2.3.1-beta": false,
+	}
+	for image, want := range tests {
+		if got := isMutableTag(image); got != want {
+			t.Errorf("isMutableTag(%q) = %v, want %v", image, got, want)
+		}
+	}
+}
+
+func TestAnalyzer_ScanImages(t *testing.T) {
+	pod1 := newImagePod("web-1", "node-1", "shop/web:v1", corev1.PullIfNotPresent, digestA)
+	pod2 := newImagePod("web-2", "node-2", "shop/web:v1", corev1.PullIfNotPresent, digestB)
+	standalone := newImagePod("debug", "node-1", "busybox", corev1.PullIfNotPresent, digestA)
+	standalone.OwnerReferences = nil
+
+	analyzer := NewAnalyzer(fake.NewSimpleClientset(&pod1, &pod2, &standalone))
+	result, err := analyzer.ScanImages("default")
+	if err != nil {
+		t.Fatalf("ScanImages failed: %v", err)
+	}
+
+	if len(result.Issues) != 1 || !strings.HasPrefix(result.Issues[0].Title, "[ReplicaSet/web-7d9c] 副本镜像不一致") {
+		t.Fatalf("issues = %+v, want one inconsistency for ReplicaSet/web-7d9c", result.Issues)
+	}
+	if result.Details[0].Value != "1 (镜像不一致的容器 1)" {
+		t.Errorf("summary = %q", result.Details[0].Value)
+	}
+	if len(result.Details) != 2 || !strings.Contains(result.Details[1].Value, "node-2 (web-2)") {
+		t.Errorf("details should list digests per node, got %+v", result.Details)
+	}
+}
@@ -4,86 +4,331 @@ import (
 	"bufio"
 	"context"
 	"fmt"
-	"regexp"
+	"sort"
+	"time"
 
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/client-go/kubernetes"
 )
 
-// 常见错误模式库
-// 使用正则表达式匹配各种语言/框架的典型报错
-var errorPatterns = map[string]*regexp.Regexp{
-	"Java Exception":    regexp.MustCompile(`(?i)(Exception|Error):`),
-	"Go Panic":          regexp.MustCompile(`panic:`),
-	"Python Traceback":  regexp.MustCompile(`Traceback \(most recent call last\):`),
-	"Node Error":        regexp.MustCompile(`(?i)ReferenceError|TypeError|SyntaxError`),
-	"OOM Message":       regexp.MustCompile(`(?i)Kill process|Out of memory`),
-	"Permission Denied": regexp.MustCompile(`(?i)permission denied`),
-	"Common Error":      regexp.MustCompile(`(?i)(error|fail|fatal|exception)`),
-}
+// 日志抓取的默认限制
+const (
+	DefaultLogTailLines  int64 = 50               // 只看最后 50 行
+	DefaultLogLimitBytes int64 = 1 << 20          // 单次最多读取 1MiB
+	DefaultLogTimeout          = 15 * time.Second // 单次请求 (建立连接 + 读取) 的超时
+)
+
+// DefaultLogMatchContext 每处命中默认附带的上下文行数 (前后各 N 行)
+const DefaultLogMatchContext = 2
+
+// maxLogLineBytes 单行日志的最大长度 (超长的 JSON 日志行很常见，bufio 默认只有 64KiB)
+const maxLogLineBytes = 1 << 20
 
 // LogAnalysisResult 日志分析结果
 type LogAnalysisResult struct {
-	Logs           []string // 抓取的最后几行日志
-	MatchedKeyords []string // 匹配到的错误关键字
+	Logs            []string            // 抓取的最后几行日志
+	MatchedKeywords []string            // 匹配到的错误关键字 (模式名)
+	Matches         []LogMatch          // 每一处命中 (按行号排序，带上下文)
+	StackTraces     []StackTrace        // 提取出的完整堆栈
+	Templates       []LogTemplate       // 日志模板聚类结果 (按首次出现排序)
+	JSONEntries     []JSONLogEntry      // 解析出的 JSON 日志
+	Truncated       bool                // 日志是否不完整
+	Truncation      []string            // 不完整的原因，例如 "只获取了最后 50 行"
+	Comparison      *LogRunComparison   // 上一次运行与当前运行的对比 (只有两边的日志都拿到时才有)
+	Dependencies    []DependencyFailure // 识别出的依赖故障 (连接被拒绝、DNS、证书、数据库认证、上游 5xx)
+
+	// Deprecated: 使用 MatchedKeywords。这是旧的拼写错误的字段名，内容与 MatchedKeywords 相同，仅为兼容外部调用方而保留
+	MatchedKeyords []string
 }
 
-// AnalyzeContainerLogs 获取并分析容器日志
-func AnalyzeContainerLogs(client kubernetes.Interface, pod *corev1.Pod, containerName string) LogAnalysisResult {
-	result := LogAnalysisResult{
-		Logs:           []string{},
-		MatchedKeyords: []string{},
+// LogCollectOptions 日志抓取选项，字段为 0 表示不限制
+type LogCollectOptions struct {
+	TailLines    int64         // 只取最后 N 行
+	SinceSeconds int64         // 只取最近 N 秒的日志
+	LimitBytes   int64         // 最多读取的字节数
+	Timeout      time.Duration // 单次请求的超时
+	Timestamps   bool          // 每行前加上 kubelet 的时间戳 (趋势分析使用)
+}
+
+// DefaultLogCollectOptions 返回默认的日志抓取限制
+func DefaultLogCollectOptions() LogCollectOptions {
+	return LogCollectOptions{
+		TailLines:  DefaultLogTailLines,
+		LimitBytes: DefaultLogLimitBytes,
+		Timeout:    DefaultLogTimeout,
 	}
+}
 
-	// 配置日志获取选项
-	lineLimit := int64(50) // 只看最后 50 行
-	opts := &corev1.PodLogOptions{
-		Container: containerName,
-		TailLines: &lineLimit,
-		// 如果容器当前挂了，尝试获取上一次运行的日志 (Previous)
-		// 这对于 CrashLoopBackOff 特别重要！
-		Previous: isContainerRestarted(pod, containerName),
+// LogAnalysisConfig 日志分析配置
+type LogAnalysisConfig struct {
+	Patterns     *PatternLibrary   // 日志模式库
+	JSONFields   JSONLogFields     // JSON 日志的字段约定
+	Collect      LogCollectOptions // 日志抓取限制
+	DeepWindow   time.Duration     // 深度模式的时间窗口，0 表示只分析异常容器
+	MatchContext int               // 每处命中附带的上下文行数 (前后各 N 行)
+}
+
+// DefaultLogAnalysisConfig 返回使用内置模式库、自动识别 JSON 日志字段的配置
+func DefaultLogAnalysisConfig() LogAnalysisConfig {
+	return LogAnalysisConfig{
+		Patterns:     DefaultPatternLibrary(),
+		JSONFields:   DefaultJSONLogFields(),
+		Collect:      DefaultLogCollectOptions(),
+		MatchContext: DefaultLogMatchContext,
 	}
+}
 
-	req := client.CoreV1().Pods(pod.Namespace).GetLogs(pod.Name, opts)
-	stream, err := req.Stream(context.TODO())
-	if err != nil {
+// WithLogCollection 设置日志抓取限制 (行数、时间窗口、字节数与超时)
+func (a *Analyzer) WithLogCollection(opts LogCollectOptions) *Analyzer {
+	a.logs.Collect = opts
+	return a
+}
+
+// WithLogMatchContext 设置每处命中附带的上下文行数
+func (a *Analyzer) WithLogMatchContext(n int) *Analyzer {
+	if n >= 0 {
+		a.logs.MatchContext = n
+	}
+	return a
+}
+
+// AnalyzeContainerLogs 获取并使用默认配置分析容器日志
+func AnalyzeContainerLogs(client kubernetes.Interface, pod *corev1.Pod, containerName string) LogAnalysisResult {
+	return AnalyzeContainerLogsWith(context.Background(), client, pod, containerName, DefaultLogAnalysisConfig())
+}
+
+// AnalyzeContainerLogsWith 获取容器日志并按指定配置分析
+// ctx 被取消时立即停止读取，已读到的日志仍会被分析并标记为不完整
+func AnalyzeContainerLogsWith(ctx context.Context, client kubernetes.Interface, pod *corev1.Pod, containerName string, cfg LogAnalysisConfig) LogAnalysisResult {
+	// 如果容器当前挂了，尝试获取上一次运行的日志 (Previous)
+	// 这对于 CrashLoopBackOff 特别重要！
+	previous := isContainerRestarted(pod, containerName)
+	lines, truncation, err := FetchContainerLogs(ctx, client, pod, containerName, previous, cfg.Collect)
+	if err != nil && previous {
 		// 如果获取失败（比如没有 Previous 日志），尝试获取当前日志
-		if opts.Previous {
-			opts.Previous = false
-			req = client.CoreV1().Pods(pod.Namespace).GetLogs(pod.Name, opts)
-			stream, err = req.Stream(context.TODO())
+		previous = false
+		lines, truncation, err = FetchContainerLogs(ctx, client, pod, containerName, false, cfg.Collect)
+	}
+	if err != nil {
+		return LogAnalysisResult{
+			Logs:            []string{fmt.Sprintf("❌ 无法获取日志: %v", err)},
+			MatchedKeywords: []string{},
+			MatchedKeyords:  []string{},
 		}
+	}
+
+	result := AnalyzeLogLines(lines, cfg)
+	result.Truncation = truncation
+	result.Truncated = len(truncation) > 0
 
-		if err != nil {
-			result.Logs = append(result.Logs, fmt.Sprintf("❌ 无法获取日志: %v", err))
-			return result
+	// 拿到了上一次运行的日志时，再抓一份当前运行的日志做对比
+	if previous {
+		current, _, curErr := FetchContainerLogs(ctx, client, pod, containerName, false, cfg.Collect)
+		if curErr == nil && len(current) > 0 {
+			result.Comparison = CompareLogRuns(lines, current, cfg)
 		}
 	}
+	return result
+}
+
+// FetchContainerLogs 按抓取选项读取容器日志，返回日志行与不完整的原因
+// 只有请求本身失败时返回 error；读取中途超时或被取消时返回已读到的部分
+func FetchContainerLogs(ctx context.Context, client kubernetes.Interface, pod *corev1.Pod, containerName string, previous bool, collect LogCollectOptions) ([]string, []string, error) {
+	lines, truncation, err := fetchContainerLogs(ctx, client, pod, containerName, previous, collect)
+	if err == nil && collect.SinceSeconds > 0 && !previous {
+		truncation = append(truncation, fmt.Sprintf("只获取了最近 %s 的日志", formatDuration(time.Duration(collect.SinceSeconds)*time.Second)))
+	}
+	return lines, truncation, err
+}
+
+// fetchContainerLogs 读取容器日志，不完整的原因中不包含时间窗口 (由调用方决定时间窗口是否算作不完整)
+func fetchContainerLogs(ctx context.Context, client kubernetes.Interface, pod *corev1.Pod, containerName string, previous bool, collect LogCollectOptions) ([]string, []string, error) {
+	parent := ctx
+	if collect.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, collect.Timeout)
+		defer cancel()
+	}
+
+	opts := &corev1.PodLogOptions{
+		Container:  containerName,
+		Previous:   previous,
+		Timestamps: collect.Timestamps,
+	}
+	if collect.TailLines > 0 {
+		opts.TailLines = &collect.TailLines
+	}
+	if collect.SinceSeconds > 0 && !previous {
+		// 上一次运行已经结束，按时间窗口过滤可能一行都拿不到
+		opts.SinceSeconds = &collect.SinceSeconds
+	}
+	if collect.LimitBytes > 0 {
+		opts.LimitBytes = &collect.LimitBytes
+	}
+
+	stream, err := client.CoreV1().Pods(pod.Namespace).GetLogs(pod.Name, opts).Stream(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
 	defer stream.Close()
 
-	// 扫描日志
+	var lines []string
+	var read int64
 	scanner := bufio.NewScanner(stream)
-	uniqueMatches := make(map[string]bool)
-
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLogLineBytes)
 	for scanner.Scan() {
-		line := scanner.Text()
-		result.Logs = append(result.Logs, line)
-
-		// 正则匹配
-		for name, pattern := range errorPatterns {
-			if pattern.MatchString(line) {
-				if !uniqueMatches[name] {
-					uniqueMatches[name] = true
-					result.MatchedKeyords = append(result.MatchedKeyords, name)
-				}
-			}
+		lines = append(lines, scanner.Text())
+		read += int64(len(scanner.Bytes())) + 1
+	}
+
+	var truncation []string
+	if err := scanner.Err(); err != nil {
+		truncation = append(truncation, readInterruption(parent, ctx, collect.Timeout, err))
+	}
+	if collect.TailLines > 0 && int64(len(lines)) >= collect.TailLines {
+		truncation = append(truncation, tailTruncation(collect.TailLines))
+	}
+	if collect.LimitBytes > 0 && read >= collect.LimitBytes {
+		// kubelet 先取最后 N 行再从头截取 limitBytes，因此被截掉的是最新的日志
+		limit := FormatMemory(collect.LimitBytes)
+		if collect.LimitBytes < 1024 {
+			limit = fmt.Sprintf("%d 字节", collect.LimitBytes)
+		}
+		truncation = append(truncation, fmt.Sprintf("达到 %s 读取上限，最新的日志被截断", limit))
+	}
+	return lines, truncation, nil
+}
+
+// readInterruption 描述日志读取中断的原因
+// parent 是调用方的上下文 (诊断的整体超时)，ctx 是叠加了 timeout (读取日志的超时) 之后的上下文
+func readInterruption(parent, ctx context.Context, timeout time.Duration, err error) string {
+	switch {
+	case parent.Err() == context.DeadlineExceeded:
+		return "超过诊断的整体超时时间，日志不完整"
+	case parent.Err() != nil:
+		return "读取被取消，日志不完整"
+	case timeout > 0 && ctx.Err() == context.DeadlineExceeded:
+		return fmt.Sprintf("读取超时 (%s)，日志不完整", timeout)
+	}
+	return fmt.Sprintf("读取中断: %v", err)
+}
+
+// tailTruncation 返回 "只获取了最后 N 行" 的不完整原因
+func tailTruncation(tailLines int64) string {
+	return fmt.Sprintf("只获取了最后 %d 行", tailLines)
+}
+
+// AnalyzeLogLines 分析已经获取到的日志行
+// JSON 行先按字段约定转换为 "级别 消息: 错误" 的可读形式，再做模式匹配与模板聚类
+func AnalyzeLogLines(lines []string, cfg LogAnalysisConfig) LogAnalysisResult {
+	if cfg.Patterns == nil {
+		cfg.Patterns = DefaultPatternLibrary()
+	}
+	result := LogAnalysisResult{
+		Logs:            append([]string{}, lines...),
+		MatchedKeywords: []string{},
+		JSONEntries:     ParseJSONLogs(lines, cfg.JSONFields),
+	}
+	text := jsonTextLines(lines, result.JSONEntries)
+
+	// 模式匹配 (多行堆栈需要看到完整的日志，所以在读取完后统一分析)
+	matches, traces := cfg.Patterns.Match(text)
+	jsonKeywords, jsonTraces := jsonStackTraces(cfg.Patterns, result.JSONEntries)
+	var keywords []string
+	for _, m := range matches {
+		keywords = append(keywords, m.Pattern)
+	}
+	result.MatchedKeywords = append(result.MatchedKeywords, uniqueStrings(append(keywords, jsonKeywords...)...)...)
+	result.MatchedKeyords = result.MatchedKeywords
+	for _, t := range jsonTraces {
+		matches = append(matches, LogMatch{Pattern: t.Pattern, Line: t.StartLine})
+	}
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].Line < matches[j].Line })
+	result.Matches = withMatchContext(matches, lines, cfg.MatchContext)
+
+	result.StackTraces = append(traces, jsonTraces...)
+	sort.SliceStable(result.StackTraces, func(i, j int) bool {
+		return result.StackTraces[i].StartLine < result.StackTraces[j].StartLine
+	})
+	result.Templates = ClusterLogLines(text)
+	result.Dependencies = ClassifyDependencyFailures(text)
+	return result
+}
+
+// withMatchContext 为每处命中填上原始日志行与前后各 n 行上下文
+func withMatchContext(matches []LogMatch, lines []string, n int) []LogMatch {
+	for i := range matches {
+		m := &matches[i]
+		m.Text = lines[m.Line]
+		from, to := m.Line-n, m.Line+n+1
+		if from < 0 {
+			from = 0
+		}
+		if to > len(lines) {
+			to = len(lines)
+		}
+		if from < m.Line {
+			m.Before = append([]string(nil), lines[from:m.Line]...)
+		}
+		if m.Line+1 < to {
+			m.After = append([]string(nil), lines[m.Line+1:to]...)
 		}
 	}
+	return matches
+}
 
+// TopLogMatches 挑选最值得展示的 n 处命中，按行号排序返回
+// 先取每个模式最后一次命中 (离崩溃最近)，名额有剩余时再按从后往前补充
+func TopLogMatches(matches []LogMatch, n int) []LogMatch {
+	if len(matches) <= n {
+		return matches
+	}
+	picked := make([]bool, len(matches))
+	seen := map[string]bool{}
+	count := 0
+	for i := len(matches) - 1; i >= 0 && count < n; i-- {
+		if !seen[matches[i].Pattern] {
+			seen[matches[i].Pattern] = true
+			picked[i] = true
+			count++
+		}
+	}
+	for i := len(matches) - 1; i >= 0 && count < n; i-- {
+		if !picked[i] {
+			picked[i] = true
+			count++
+		}
+	}
+	result := make([]LogMatch, 0, n)
+	for i, m := range matches {
+		if picked[i] {
+			result = append(result, m)
+		}
+	}
 	return result
 }
 
+// LogLine 是报告中展示的一行原始日志
+type LogLine struct {
+	No       int      // 行号 (从 1 开始)
+	Text     string   // 日志内容
+	Patterns []string // 该行命中的模式
+}
+
+// LogLines 返回带行号与命中模式的原始日志，供报告逐行高亮
+func (c ContainerDiagnosis) LogLines() []LogLine {
+	lines := make([]LogLine, len(c.Logs))
+	for i, text := range c.Logs {
+		lines[i] = LogLine{No: i + 1, Text: text}
+	}
+	for _, m := range c.LogMatches {
+		if m.Line < len(lines) {
+			lines[m.Line].Patterns = append(lines[m.Line].Patterns, m.Pattern)
+		}
+	}
+	return lines
+}
+
 // 辅助函数：判断容器是否重启过（决定是否加 Previous 参数）
 func isContainerRestarted(pod *corev1.Pod, containerName string) bool {
 	for _, cs := range pod.Status.ContainerStatuses {
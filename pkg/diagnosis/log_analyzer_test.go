@@ -0,0 +1,177 @@
+package diagnosis
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestAnalyzeContainerLogsWith_Truncation(t *testing.T) {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+		Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "app"}}},
+	}
+	// fake 客户端的日志固定为一行 "fake logs" (10 字节含换行)
+	tests := []struct {
+		name    string
+		collect LogCollectOptions
+		want    []string
+	}{
+		{
+			name:    "未触及任何限制",
+			collect: DefaultLogCollectOptions(),
+			want:    nil,
+		},
+		{
+			name:    "行数上限",
+			collect: LogCollectOptions{TailLines: 1},
+			want:    []string{"只获取了最后 1 行"},
+		},
+		{
+			name:    "字节上限",
+			collect: LogCollectOptions{LimitBytes: 8},
+			want:    []string{"达到 8 字节 读取上限，最新的日志被截断"},
+		},
+		{
+			name:    "时间窗口",
+			collect: LogCollectOptions{SinceSeconds: 600, Timeout: time.Second},
+			want:    []string{"只获取了最近 10m0s 的日志"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := DefaultLogAnalysisConfig()
+			cfg.Collect = tt.collect
+			result := AnalyzeContainerLogsWith(context.Background(), fake.NewSimpleClientset(pod), pod, "app", cfg)
+
+			if !reflect.DeepEqual(result.Logs, []string{"fake logs"}) {
+				t.Fatalf("logs = %v", result.Logs)
+			}
+			if !reflect.DeepEqual(result.Truncation, tt.want) || result.Truncated != (len(tt.want) > 0) {
+				t.Errorf("truncation = %v (truncated=%v), want %v", result.Truncation, result.Truncated, tt.want)
+			}
+		})
+	}
+}
+
+func TestAnalyzeLogLines_MatchContext(t *testing.T) {
+	lines := []string{
+		"starting",
+		"ERROR db down",
+		"retry",
+		`{"level":"error","msg":"order failed","stacktrace":"main.placeOrder\n\t/app/order.go:42"}`,
+		"open /data: permission denied",
+	}
+	cfg := DefaultLogAnalysisConfig()
+	cfg.MatchContext = 1
+	result := AnalyzeLogLines(lines, cfg)
+
+	want := []LogMatch{
+		{Pattern: "Common Error", Line: 1, Text: lines[1], Before: []string{lines[0]}, After: []string{lines[2]}},
+		{Pattern: "Common Error", Line: 3, Text: lines[3], Before: []string{lines[2]}, After: []string{lines[4]}},
+		{Pattern: "JSON Stacktrace", Line: 3, Text: lines[3], Before: []string{lines[2]}, After: []string{lines[4]}},
+		{Pattern: "Permission Denied", Line: 4, Text: lines[4], Before: []string{lines[3]}},
+	}
+	if !reflect.DeepEqual(result.Matches, want) {
+		t.Errorf("matches =\n%+v\nwant\n%+v", result.Matches, want)
+	}
+
+	c := ContainerDiagnosis{Logs: lines, LogMatches: result.Matches}
+	logLines := c.LogLines()
+	if got := logLines[3]; got.No != 4 || !reflect.DeepEqual(got.Patterns, []string{"Common Error", "JSON Stacktrace"}) {
+		t.Errorf("line 4 = %+v", got)
+	}
+	if logLines[0].Patterns != nil {
+		t.Errorf("line 1 = %+v", logLines[0])
+	}
+}
+
+func TestTopLogMatches(t *testing.T) {
+	matches := []LogMatch{
+		{Pattern: "Permission Denied", Line: 0},
+		{Pattern: "Common Error", Line: 1},
+		{Pattern: "Common Error", Line: 5},
+		{Pattern: "Common Error", Line: 8},
+		{Pattern: "Common Error", Line: 9},
+	}
+	// 每个模式先取最后一次，剩余名额从后往前补
+	got := TopLogMatches(matches, 3)
+	var lines []int
+	for _, m := range got {
+		lines = append(lines, m.Line)
+	}
+	if !reflect.DeepEqual(lines, []int{0, 8, 9}) {
+		t.Errorf("lines = %v, want [0 8 9]", lines)
+	}
+	if len(TopLogMatches(matches, 10)) != len(matches) {
+		t.Error("fewer matches than n should be returned unchanged")
+	}
+}
+
+func TestReadInterruption(t *testing.T) {
+	expired := func(parent context.Context) context.Context {
+		ctx, cancel := context.WithDeadline(parent, time.Now().Add(-time.Second))
+		t.Cleanup(cancel)
+		return ctx
+	}
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	readErr := errors.New("unexpected EOF")
+
+	tests := []struct {
+		name    string
+		parent  context.Context
+		ctx     context.Context // 为空时与 parent 相同 (没有设置读取超时)
+		timeout time.Duration
+		want    string
+	}{
+		{
+			name:    "读取日志自身的超时",
+			parent:  context.Background(),
+			ctx:     expired(context.Background()),
+			timeout: 10 * time.Second,
+			want:    "读取超时 (10s)，日志不完整",
+		},
+		{
+			name:   "调用方的整体超时 (没有设置读取超时)",
+			parent: expired(context.Background()),
+			want:   "超过诊断的整体超时时间，日志不完整",
+		},
+		{
+			name:    "调用方的整体超时先于读取超时到期",
+			parent:  expired(context.Background()),
+			ctx:     expired(context.Background()),
+			timeout: 10 * time.Second,
+			want:    "超过诊断的整体超时时间，日志不完整",
+		},
+		{
+			name:   "调用方取消",
+			parent: canceled,
+			want:   "读取被取消，日志不完整",
+		},
+		{
+			name:   "连接中断",
+			parent: context.Background(),
+			want:   "读取中断: unexpected EOF",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := tt.ctx
+			if ctx == nil {
+				ctx = tt.parent
+			}
+			if got := readInterruption(tt.parent, ctx, tt.timeout, readErr); got != tt.want {
+				t.Errorf("readInterruption() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,157 @@
+package diagnosis
+
+import "fmt"
+
+// -----------------------------------------------------------
+// 两次运行的日志对比
+// 重启过的容器同时抓取上一次 (崩溃的) 运行与当前运行的日志:
+//   - 只在崩溃那次出现的错误模板，往往就是崩溃原因
+//   - 当前运行是否已经越过上次崩溃前的最后一条日志，可以判断是已经恢复还是即将再次崩溃
+// -----------------------------------------------------------
+
+// RunProgress 当前运行相对上次崩溃点的进度
+type RunProgress string
+
+const (
+	RunProgressPassed       RunProgress = "passed"         // 已经越过上次崩溃前的最后一条日志
+	RunProgressAtCrashPoint RunProgress = "at_crash_point" // 停在上次崩溃前的最后一条日志
+	RunProgressBehind       RunProgress = "behind"         // 尚未到达上次崩溃的位置
+	RunProgressUnknown      RunProgress = "unknown"        // 两次运行没有共同的日志，无法判断
+)
+
+// ComparedLogLine 对比视图中的一行日志
+type ComparedLogLine struct {
+	Text       string `json:"text"`
+	CrashOnly  bool   `json:"crash_only,omitempty"`  // 属于只在崩溃那次出现的错误模板
+	CrashPoint bool   `json:"crash_point,omitempty"` // 上次崩溃前的最后一条日志 (当前运行中为同一模板最后一次出现的位置)
+}
+
+// LogRunComparison 上一次运行与当前运行的日志对比
+type LogRunComparison struct {
+	Previous       []ComparedLogLine `json:"previous"`             // 上一次 (崩溃的) 运行
+	Current        []ComparedLogLine `json:"current"`              // 当前运行
+	CrashOnly      []LogTemplate     `json:"crash_only,omitempty"` // 只在崩溃那次出现的错误模板 (行号对应上一次运行)
+	CrashPoint     string            `json:"crash_point"`          // 上一次运行的最后一条日志
+	Progress       RunProgress       `json:"progress"`
+	ProgressDetail string            `json:"progress_detail"` // 进度的可读说明
+}
+
+// CompareLogRuns 对比上一次运行与当前运行的日志
+// 两边的日志放在一起做模板聚类，这样参数不同的同一条日志能对应起来
+func CompareLogRuns(previous, current []string, cfg LogAnalysisConfig) *LogRunComparison {
+	if cfg.Patterns == nil {
+		cfg.Patterns = DefaultPatternLibrary()
+	}
+	prevText := jsonTextLines(previous, ParseJSONLogs(previous, cfg.JSONFields))
+	curText := jsonTextLines(current, ParseJSONLogs(current, cfg.JSONFields))
+
+	all := append(append([]string(nil), prevText...), curText...)
+	templates, index := clusterLogLines(all)
+	prevIndex, curIndex := index[:len(prevText)], index[len(prevText):]
+
+	// 每个模板在当前运行中最后一次出现的位置
+	lastInCurrent := make([]int, len(templates))
+	for i := range lastInCurrent {
+		lastInCurrent[i] = -1
+	}
+	for i, t := range curIndex {
+		if t >= 0 {
+			lastInCurrent[t] = i
+		}
+	}
+
+	cmp := &LogRunComparison{
+		Previous: make([]ComparedLogLine, len(previous)),
+		Current:  make([]ComparedLogLine, len(current)),
+	}
+	for i, line := range previous {
+		cmp.Previous[i].Text = line
+	}
+	for i, line := range current {
+		cmp.Current[i].Text = line
+	}
+
+	// 只在崩溃那次出现的错误模板 (所有出现位置都在上一次运行中)
+	crashOnly := map[int]bool{}
+	for i, t := range templates {
+		if t.FirstLine >= len(prevText) || lastInCurrent[i] >= 0 {
+			continue
+		}
+		if keywords, _ := cfg.Patterns.Analyze([]string{t.Sample}); len(keywords) == 0 {
+			continue
+		}
+		t.Suspicious = false
+		crashOnly[i] = true
+		cmp.CrashOnly = append(cmp.CrashOnly, t)
+	}
+	for i, t := range prevIndex {
+		cmp.Previous[i].CrashOnly = t >= 0 && crashOnly[t]
+	}
+
+	// 上次崩溃前的最后一条日志
+	crash := -1
+	for i := len(prevIndex) - 1; i >= 0; i-- {
+		if prevIndex[i] >= 0 {
+			crash = i
+			break
+		}
+	}
+	if crash < 0 {
+		cmp.Progress = RunProgressUnknown
+		cmp.ProgressDetail = "上一次运行没有输出日志，无法判断当前运行的进度"
+		return cmp
+	}
+	cmp.CrashPoint = previous[crash]
+	cmp.Previous[crash].CrashPoint = true
+
+	// 周期性输出的日志 (心跳、健康检查) 在两次运行中都会反复出现，不能用来定位当前运行的进度
+	repeats := 0
+	for _, t := range prevIndex {
+		if t == prevIndex[crash] {
+			repeats++
+		}
+	}
+	if repeats > rareTemplateMax {
+		cmp.Progress = RunProgressUnknown
+		cmp.ProgressDetail = fmt.Sprintf("上次崩溃前的最后一条日志在上一次运行中重复出现了 %d 次 (周期性日志)，无法判断当前运行的进度", repeats)
+		return cmp
+	}
+
+	if pos := lastInCurrent[prevIndex[crash]]; pos >= 0 {
+		cmp.Current[pos].CrashPoint = true
+		after := 0
+		for _, t := range curIndex[pos+1:] {
+			if t >= 0 {
+				after++
+			}
+		}
+		if after > 0 {
+			cmp.Progress = RunProgressPassed
+			cmp.ProgressDetail = fmt.Sprintf("当前运行已越过上次崩溃前的最后一条日志 (第 %d 行)，之后又输出了 %d 行", pos+1, after)
+		} else {
+			cmp.Progress = RunProgressAtCrashPoint
+			cmp.ProgressDetail = fmt.Sprintf("当前运行停在上次崩溃前的最后一条日志 (第 %d 行)，可能即将再次崩溃", pos+1)
+		}
+		return cmp
+	}
+
+	// 当前运行还没输出崩溃点的日志: 看它走到了上一次运行的哪个位置
+	reached, total := -1, 0
+	for i, t := range prevIndex {
+		if t < 0 {
+			continue
+		}
+		total++
+		if lastInCurrent[t] >= 0 {
+			reached = i
+		}
+	}
+	if reached < 0 {
+		cmp.Progress = RunProgressUnknown
+		cmp.ProgressDetail = "两次运行的日志没有共同的模板，无法判断当前运行的进度"
+		return cmp
+	}
+	cmp.Progress = RunProgressBehind
+	cmp.ProgressDetail = fmt.Sprintf("当前运行尚未到达上次崩溃的位置 (已输出到上一次运行的第 %d/%d 行)", reached+1, len(previous))
+	return cmp
+}
@@ -0,0 +1,99 @@
+package diagnosis
+
+import "testing"
+
+func TestCompareLogRuns(t *testing.T) {
+	previous := []string{
+		"starting app",
+		"connected to db at 10.0.0.5:5432",
+		"ERROR upstream quota exceeded",
+		"loading orders batch 7",
+	}
+	tests := []struct {
+		name       string
+		current    []string
+		want       RunProgress
+		wantDetail string
+		crashPoint int // 当前运行中标记为崩溃点的行，-1 表示没有
+	}{
+		{
+			name:       "已越过崩溃点",
+			current:    []string{"starting app", "connected to db at 10.0.0.6:5432", "loading orders batch 7", "loading orders batch 8", "serving on :8080"},
+			want:       RunProgressPassed,
+			wantDetail: "当前运行已越过上次崩溃前的最后一条日志 (第 4 行)，之后又输出了 1 行",
+			crashPoint: 3,
+		},
+		{
+			name:       "停在崩溃点",
+			current:    []string{"starting app", "connected to db at 10.0.0.6:5432", "loading orders batch 3"},
+			want:       RunProgressAtCrashPoint,
+			wantDetail: "当前运行停在上次崩溃前的最后一条日志 (第 3 行)，可能即将再次崩溃",
+			crashPoint: 2,
+		},
+		{
+			name:       "尚未到达",
+			current:    []string{"starting app", ""},
+			want:       RunProgressBehind,
+			wantDetail: "当前运行尚未到达上次崩溃的位置 (已输出到上一次运行的第 1/4 行)",
+			crashPoint: -1,
+		},
+		{
+			name:       "没有共同日志",
+			current:    []string{"completely different output"},
+			want:       RunProgressUnknown,
+			wantDetail: "两次运行的日志没有共同的模板，无法判断当前运行的进度",
+			crashPoint: -1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmp := CompareLogRuns(previous, tt.current, DefaultLogAnalysisConfig())
+
+			if cmp.Progress != tt.want || cmp.ProgressDetail != tt.wantDetail {
+				t.Errorf("progress = %s (%s), want %s (%s)", cmp.Progress, cmp.ProgressDetail, tt.want, tt.wantDetail)
+			}
+			for i, line := range cmp.Current {
+				if line.CrashPoint != (i == tt.crashPoint) {
+					t.Errorf("current[%d].CrashPoint = %v", i, line.CrashPoint)
+				}
+			}
+			if cmp.CrashPoint != "loading orders batch 7" || !cmp.Previous[3].CrashPoint {
+				t.Errorf("crash point = %q", cmp.CrashPoint)
+			}
+			// 只在崩溃那次出现的错误模板，正常日志不算
+			if len(cmp.CrashOnly) != 1 || cmp.CrashOnly[0].Template != "ERROR upstream quota exceeded" || !cmp.Previous[2].CrashOnly {
+				t.Errorf("crash only = %+v", cmp.CrashOnly)
+			}
+		})
+	}
+}
+
+func TestCompareLogRuns_ErrorInBothRuns(t *testing.T) {
+	previous := []string{`{"level":"error","msg":"cache miss storm","shard":1}`, "exiting"}
+	current := []string{`{"level":"error","msg":"cache miss storm","shard":2}`}
+
+	cmp := CompareLogRuns(previous, current, DefaultLogAnalysisConfig())
+	if len(cmp.CrashOnly) != 0 {
+		t.Errorf("error present in both runs should not be crash-only: %+v", cmp.CrashOnly)
+	}
+	if cmp.Progress != RunProgressBehind {
+		t.Errorf("progress = %s", cmp.Progress)
+	}
+}
+
+func TestCompareLogRuns_PeriodicCrashPoint(t *testing.T) {
+	// 上次崩溃前的最后一条日志是心跳: 当前运行一定也会输出，不能据此判断停在崩溃点
+	previous := []string{"starting", "connecting", "health check ok 1", "health check ok 2", "health check ok 3", "health check ok 4"}
+	current := []string{"starting", "connecting", "health check ok 1", "health check ok 2"}
+
+	cmp := CompareLogRuns(previous, current, DefaultLogAnalysisConfig())
+	if cmp.Progress != RunProgressUnknown {
+		t.Errorf("progress = %s (%s), want unknown", cmp.Progress, cmp.ProgressDetail)
+	}
+	for i, line := range cmp.Current {
+		if line.CrashPoint {
+			t.Errorf("current[%d] should not be marked as crash point", i)
+		}
+	}
+}
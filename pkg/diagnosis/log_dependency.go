@@ -0,0 +1,506 @@
+package diagnosis
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// -----------------------------------------------------------
+// 依赖故障识别
+// 很多崩溃不是代码 bug，而是依赖 (数据库、上游服务、DNS) 不可达:
+// 从日志中识别连接被拒绝、DNS 解析失败、证书错误、数据库认证失败与上游 5xx，
+// 提取目标地址，并检查它是否是集群内的 Service、有没有就绪的 Endpoint
+// -----------------------------------------------------------
+
+// DependencyKind 依赖故障的类别
+type DependencyKind string
+
+const (
+	DependencyConnectionRefused DependencyKind = "connection_refused"
+	DependencyDNS               DependencyKind = "dns"
+	DependencyTLS               DependencyKind = "tls"
+	DependencyDBAuth            DependencyKind = "db_auth"
+	DependencyUpstream5xx       DependencyKind = "upstream_5xx"
+)
+
+// Label 返回类别的中文名称
+func (k DependencyKind) Label() string {
+	switch k {
+	case DependencyConnectionRefused:
+		return "连接被拒绝"
+	case DependencyDNS:
+		return "DNS 解析失败"
+	case DependencyTLS:
+		return "TLS 证书错误"
+	case DependencyDBAuth:
+		return "数据库认证失败"
+	case DependencyUpstream5xx:
+		return "上游返回 5xx"
+	default:
+		return string(k)
+	}
+}
+
+// DependencyFailure 日志中同一目标、同一类别的依赖故障 (多次出现合并为一条)
+type DependencyFailure struct {
+	Kind      DependencyKind     `json:"kind"`
+	Host      string             `json:"host,omitempty"`    // 目标主机 (域名或 IP)，无法提取时为空
+	Port      string             `json:"port,omitempty"`    // 目标端口，无法提取时为空
+	Detail    string             `json:"detail,omitempty"`  // 细分原因，例如 NXDOMAIN、证书已过期、HTTP 503
+	Count     int                `json:"count"`             // 出现次数
+	FirstLine int                `json:"first_line"`        // 首次出现的行号 (从 0 开始)
+	LastLine  int                `json:"last_line"`         // 最后一次出现的行号 (从 0 开始)
+	Sample    string             `json:"sample"`            // 最后一次出现的日志行
+	Service   *DependencyService `json:"service,omitempty"` // 目标是集群内的 Service 时的检查结果
+
+	// addressed 表示主机取自 dial / URL / host=port / DNS 查询等明确的目标写法，
+	// 只有这样的单段主机名才能当作同命名空间的 Service 名
+	addressed bool
+}
+
+// DependencyService 依赖目标对应的集群内 Service
+type DependencyService struct {
+	Namespace    string `json:"namespace"`
+	Name         string `json:"name"`
+	Found        bool   `json:"found"`                   // Service 是否存在
+	ExternalName string `json:"external_name,omitempty"` // ExternalName 类型的 Service 指向的域名
+	Ready        int    `json:"ready"`                   // 就绪的 Endpoint 数
+	Total        int    `json:"total"`                   // Endpoint 总数
+	PortMissing  bool   `json:"port_missing,omitempty"`  // Service 没有暴露日志中访问的端口
+	Error        string `json:"error,omitempty"`         // 查询失败的原因
+}
+
+// Target 返回 "host:port" 形式的目标地址，没有提取到主机时返回 "未知目标"
+func (f DependencyFailure) Target() string {
+	switch {
+	case f.Host == "":
+		return "未知目标"
+	case f.Port == "":
+		return f.Host
+	default:
+		return net.JoinHostPort(f.Host, f.Port)
+	}
+}
+
+// LineNo 返回最后一次出现的行号 (从 1 开始，用于展示)
+func (f DependencyFailure) LineNo() int {
+	return f.LastLine + 1
+}
+
+// Status 返回集群内 Service 的检查结论，目标不是集群内的 Service 时返回空字符串
+func (s *DependencyService) Status() string {
+	switch {
+	case s == nil:
+		return ""
+	case s.Error != "":
+		return fmt.Sprintf("Service %s/%s 查询失败: %s", s.Namespace, s.Name, s.Error)
+	case !s.Found:
+		return fmt.Sprintf("Service %s/%s 不存在", s.Namespace, s.Name)
+	case s.ExternalName != "":
+		return fmt.Sprintf("Service %s/%s 指向外部域名 %s", s.Namespace, s.Name, s.ExternalName)
+	case s.PortMissing:
+		return fmt.Sprintf("Service %s/%s 没有暴露该端口 (Endpoint %d/%d 就绪)", s.Namespace, s.Name, s.Ready, s.Total)
+	default:
+		return fmt.Sprintf("Service %s/%s Endpoint %d/%d 就绪", s.Namespace, s.Name, s.Ready, s.Total)
+	}
+}
+
+// Healthy 判断 Service 本身是否正常 (存在、暴露了端口且有就绪的 Endpoint)
+func (s *DependencyService) Healthy() bool {
+	if s == nil || s.Error != "" {
+		return false
+	}
+	return s.Found && !s.PortMissing && (s.ExternalName != "" || s.Ready > 0)
+}
+
+// dependencyRule 识别一类依赖故障的规则
+// detail 为固定的细分原因 (其余类别在 classifyDependencyLine 中按命中内容细分)；hosts 按顺序尝试提取目标地址
+type dependencyRule struct {
+	kind   DependencyKind
+	match  *regexp.Regexp
+	detail string
+	hosts  []hostExtractor
+}
+
+// hostExtractor 提取目标地址的正则 (分组 1 为主机，分组 2 为端口)
+// addressed 为 false 的正则只是从报错文字中取出主机名，结果不作为 Service 是否存在的依据
+type hostExtractor struct {
+	re        *regexp.Regexp
+	addressed bool
+}
+
+// 通用的目标地址提取
+var (
+	dialTargetRe = regexp.MustCompile(`dial tcp (\[[0-9a-fA-F:.]+\]|[\w.-]+):(\d+)`)
+	urlTargetRe  = regexp.MustCompile(`\b[a-zA-Z][a-zA-Z0-9+.-]*://(?:[^/@\s"']*@)?(\[[0-9a-fA-F:.]+\]|[\w.-]+)(?::(\d+))?`)
+	kvTargetRe   = regexp.MustCompile(`(?i)\bhost=['"]?([\w.-]+)['"]?,?\s+port=['"]?(\d+)`)
+	addrTargetRe = regexp.MustCompile(`\b((?:\d{1,3}\.){3}\d{1,3}|[a-zA-Z][\w-]*(?:\.[\w-]+)*):(\d{2,5})\b`)
+
+	dialTarget = hostExtractor{dialTargetRe, true}
+	urlTarget  = hostExtractor{urlTargetRe, true}
+	kvTarget   = hostExtractor{kvTargetRe, true}
+	addrTarget = hostExtractor{addrTargetRe, true}
+)
+
+// dependencyRules 按顺序匹配，一行只归入第一条命中的规则
+var dependencyRules = []dependencyRule{
+	{
+		kind:   DependencyConnectionRefused,
+		match:  regexp.MustCompile(`(?i)connection refused|ECONNREFUSED`),
+		detail: "connection refused",
+		hosts: []hostExtractor{
+			dialTarget,
+			{regexp.MustCompile(`ECONNREFUSED (\[[0-9a-fA-F:.]+\]|[\w.-]+):(\d+)`), true},
+			kvTarget,
+			urlTarget,
+			addrTarget,
+		},
+	},
+	{
+		kind:  DependencyDNS,
+		match: regexp.MustCompile(`(no such host|NXDOMAIN|server misbehaving|i/o timeout|UnknownHostException|ENOTFOUND|EAI_AGAIN|Name or service not known|Temporary failure in name resolution|nodename nor servname provided)`),
+		hosts: []hostExtractor{
+			{regexp.MustCompile(`lookup ([\w.-]+)`), true},
+			{regexp.MustCompile(`UnknownHostException: ([\w.-]+)`), true},
+			{regexp.MustCompile(`(?:ENOTFOUND|EAI_AGAIN) ([\w.-]+)`), true},
+			urlTarget,
+			kvTarget,
+		},
+	},
+	{
+		kind:  DependencyTLS,
+		match: regexp.MustCompile(`(x509: certificate (?:signed by unknown authority|has expired or is not yet valid|is valid for [^,]+(?:, [^,]+)*, not [\w.-]+|relies on legacy Common Name field)|x509: [\w ]+|CERTIFICATE_VERIFY_FAILED|PKIX path building failed|SSLHandshakeException|certificate has expired|self[- ]signed certificate)`),
+		hosts: []hostExtractor{
+			dialTarget,
+			urlTarget,
+			{regexp.MustCompile(`, not ([\w.-]+)`), false},
+			kvTarget,
+			addrTarget,
+		},
+	},
+	{
+		kind:  DependencyDBAuth,
+		match: regexp.MustCompile(`(?i)(password authentication failed for user|Access denied for user|Authentication failed\.?.*(?:mongo|SCRAM)|(?:mongo|SCRAM).*Authentication failed|WRONGPASS|NOAUTH Authentication required|Login failed for user|ORA-01017)`),
+		hosts: []hostExtractor{
+			{regexp.MustCompile(`server at "([\w.-]+)"(?: \([^)]*\))?, port (\d+)`), true},
+			dialTarget,
+			urlTarget,
+			kvTarget,
+		},
+	},
+	{
+		kind:  DependencyUpstream5xx,
+		match: regexp.MustCompile(`(?i)(?:status(?:[ _]?code)?|HTTP(?:/\d(?:\.\d)?)?|response code|returned|responded with)[\s:=]*"?(5\d\d)\b`),
+		hosts: []hostExtractor{
+			urlTarget,
+			kvTarget,
+		},
+	},
+}
+
+// upstreamWordRe 没有提取到目标地址的 5xx 只有明确提到上游时才算依赖故障 (否则多半是本服务的访问日志)
+var upstreamWordRe = regexp.MustCompile(`(?i)upstream|backend|downstream|proxy`)
+
+// dbNames 数据库认证失败时按关键字识别数据库类型
+var dbNames = []struct {
+	re   *regexp.Regexp
+	name string
+}{
+	{regexp.MustCompile(`(?i)password authentication failed|pq:|postgres`), "PostgreSQL"},
+	{regexp.MustCompile(`(?i)Access denied for user|mysql`), "MySQL"},
+	{regexp.MustCompile(`(?i)mongo|SCRAM`), "MongoDB"},
+	{regexp.MustCompile(`(?i)WRONGPASS|NOAUTH|redis`), "Redis"},
+	{regexp.MustCompile(`(?i)Login failed for user|sqlserver|mssql`), "SQL Server"},
+	{regexp.MustCompile(`ORA-01017`), "Oracle"},
+}
+
+// dnsDetails DNS 失败的细分原因
+var dnsDetails = []struct {
+	re     *regexp.Regexp
+	detail string
+}{
+	{regexp.MustCompile(`no such host|NXDOMAIN|UnknownHostException|ENOTFOUND|Name or service not known|nodename nor servname provided`), "NXDOMAIN"},
+	{regexp.MustCompile(`i/o timeout|EAI_AGAIN|Temporary failure in name resolution`), "timeout"},
+	{regexp.MustCompile(`server misbehaving`), "server misbehaving"},
+}
+
+// dnsLookupRe i/o timeout 只有出现在 DNS 查询中时才算 DNS 失败 (否则是普通的连接超时)
+var dnsLookupRe = regexp.MustCompile(`lookup [\w.-]+`)
+
+// ClassifyDependencyFailures 从日志中识别依赖故障，同一类别、同一目标的多次出现合并为一条
+// 返回结果按出现次数降序排列
+func ClassifyDependencyFailures(lines []string) []DependencyFailure {
+	index := map[string]int{}
+	var result []DependencyFailure
+	for i, line := range lines {
+		f, ok := classifyDependencyLine(line)
+		if !ok {
+			continue
+		}
+		key := string(f.Kind) + "|" + f.Host + "|" + f.Port + "|" + f.Detail
+		if j, seen := index[key]; seen {
+			result[j].Count++
+			result[j].LastLine = i
+			result[j].Sample = strings.TrimSpace(line)
+			continue
+		}
+		f.Count, f.FirstLine, f.LastLine = 1, i, i
+		f.Sample = strings.TrimSpace(line)
+		index[key] = len(result)
+		result = append(result, f)
+	}
+	sort.SliceStable(result, func(i, j int) bool { return result[i].Count > result[j].Count })
+	return result
+}
+
+// classifyDependencyLine 识别单行日志中的依赖故障
+func classifyDependencyLine(line string) (DependencyFailure, bool) {
+	for _, rule := range dependencyRules {
+		m := rule.match.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		f := DependencyFailure{Kind: rule.kind, Detail: rule.detail}
+		f.Host, f.Port, f.addressed = extractDependencyTarget(line, rule.hosts)
+
+		switch rule.kind {
+		case DependencyDNS:
+			if m[1] == "i/o timeout" && !dnsLookupRe.MatchString(line) {
+				continue
+			}
+			for _, d := range dnsDetails {
+				if d.re.MatchString(line) {
+					f.Detail = d.detail
+					break
+				}
+			}
+		case DependencyTLS:
+			f.Detail = m[1]
+		case DependencyDBAuth:
+			for _, db := range dbNames {
+				if db.re.MatchString(line) {
+					f.Detail = db.name
+					break
+				}
+			}
+		case DependencyUpstream5xx:
+			if f.Host == "" && !upstreamWordRe.MatchString(line) {
+				continue
+			}
+			f.Detail = "HTTP " + m[1]
+		}
+		return f, true
+	}
+	return DependencyFailure{}, false
+}
+
+// extractDependencyTarget 按顺序尝试提取目标主机与端口
+func extractDependencyTarget(line string, extractors []hostExtractor) (host, port string, addressed bool) {
+	for _, e := range extractors {
+		for _, m := range e.re.FindAllStringSubmatch(line, -1) {
+			host = strings.Trim(m[1], "[]")
+			if !plausibleHost(host) {
+				continue
+			}
+			if len(m) > 2 {
+				port = m[2]
+			}
+			if p, err := strconv.Atoi(port); port != "" && (err != nil || p <= 0 || p > 65535) {
+				port = ""
+			}
+			return strings.TrimSuffix(strings.ToLower(host), "."), port, e.addressed
+		}
+	}
+	return "", "", false
+}
+
+// plausibleHost 过滤掉时间戳、版本号等被误识别为主机的片段
+func plausibleHost(host string) bool {
+	if host == "" {
+		return false
+	}
+	if net.ParseIP(host) != nil {
+		return true
+	}
+	first := host[0]
+	return (first >= 'a' && first <= 'z') || (first >= 'A' && first <= 'Z')
+}
+
+// serviceRef 从主机名解析出可能对应的 Service
+// explicit 表示主机名明确指向集群内 Service (包含 .svc，或取自明确目标写法的单段名称)，此时 Service 不存在也值得报告
+func serviceRef(host, podNamespace string, addressed bool) (namespace, name string, explicit, ok bool) {
+	if host == "" || host == "localhost" || net.ParseIP(host) != nil {
+		return "", "", false, false
+	}
+	parts := strings.Split(host, ".")
+	for i, p := range parts {
+		// <svc>.<ns>.svc[.cluster.local] 或 StatefulSet 的 <pod>.<svc>.<ns>.svc[.cluster.local]
+		if p == "svc" && i >= 2 {
+			return parts[i-1], parts[i-2], true, true
+		}
+	}
+	switch len(parts) {
+	case 1:
+		// 从报错文字中取出的单段名称可能只是普通单词，Service 不存在时不报告
+		return podNamespace, parts[0], addressed, true
+	case 2:
+		// 可能是 <svc>.<ns>，也可能是外部域名，Service 不存在时不报告
+		return parts[1], parts[0], false, true
+	}
+	return "", "", false, false
+}
+
+// checkDependencyServices 检查每个依赖目标是否是集群内的 Service 以及它的 Endpoint 状态
+func (a *Analyzer) checkDependencyServices(pod *corev1.Pod, failures []DependencyFailure) {
+	var services []corev1.Service // Pod 所在命名空间的 Service，用于按 ClusterIP 匹配，按需列出
+	listed := false
+	cache := map[string]*DependencyService{}
+
+	for i := range failures {
+		f := &failures[i]
+		if f.Host == "" {
+			continue
+		}
+		key := f.Host + ":" + f.Port
+		if svc, ok := cache[key]; ok {
+			f.Service = svc
+			continue
+		}
+
+		var svc *corev1.Service
+		var ref *DependencyService
+		if net.ParseIP(f.Host) != nil {
+			if !listed {
+				listed = true
+				if list, err := a.client.CoreV1().Services(pod.Namespace).List(a.ctx, metav1.ListOptions{}); err == nil {
+					services = list.Items
+				}
+			}
+			for j := range services {
+				if services[j].Spec.ClusterIP == f.Host {
+					svc = &services[j]
+					ref = &DependencyService{Namespace: svc.Namespace, Name: svc.Name, Found: true}
+					break
+				}
+			}
+		} else if ns, name, explicit, ok := serviceRef(f.Host, pod.Namespace, f.addressed); ok {
+			got, err := a.client.CoreV1().Services(ns).Get(a.ctx, name, metav1.GetOptions{})
+			switch {
+			case err == nil:
+				svc = got
+				ref = &DependencyService{Namespace: ns, Name: name, Found: true}
+			case apierrors.IsNotFound(err):
+				if explicit {
+					ref = &DependencyService{Namespace: ns, Name: name}
+				}
+			default:
+				if explicit {
+					ref = &DependencyService{Namespace: ns, Name: name, Error: err.Error()}
+				}
+			}
+		}
+
+		if svc != nil {
+			a.fillServiceEndpoints(ref, svc, f.Port)
+		}
+		cache[key] = ref
+		f.Service = ref
+	}
+}
+
+// fillServiceEndpoints 统计 Service 的就绪 Endpoint，并检查是否暴露了日志中访问的端口
+func (a *Analyzer) fillServiceEndpoints(ref *DependencyService, svc *corev1.Service, port string) {
+	if svc.Spec.Type == corev1.ServiceTypeExternalName {
+		ref.ExternalName = svc.Spec.ExternalName
+		return
+	}
+	if p, err := strconv.Atoi(port); err == nil {
+		ref.PortMissing = true
+		for _, sp := range svc.Spec.Ports {
+			if int(sp.Port) == p {
+				ref.PortMissing = false
+				break
+			}
+		}
+	}
+	slices, err := a.client.DiscoveryV1().EndpointSlices(svc.Namespace).List(a.ctx, metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("%s=%s", discoveryv1.LabelServiceName, svc.Name),
+	})
+	if err != nil {
+		ref.Error = err.Error()
+		return
+	}
+	ref.Ready, ref.Total = CountEndpoints(slices.Items)
+}
+
+// Issue 为依赖故障生成问题
+// 目标是集群内 Service 且 Service 有问题时为 Error (根因在集群内，可以直接处理)；
+// 其余情况只有容器已崩溃时才报告 (always 为 false 且 Service 正常时返回 nil)
+func (f DependencyFailure) Issue(always bool) *Issue {
+	svc := f.Service
+	raw := f.Sample
+	if f.Count > 1 {
+		raw = fmt.Sprintf("%s (共 %d 次)", f.Sample, f.Count)
+	}
+
+	if svc != nil && svc.Error == "" && !svc.Healthy() {
+		issue := &Issue{
+			Type:     "Error",
+			Title:    fmt.Sprintf("%s: %s，%s", f.Kind.Label(), f.Target(), svc.Status()),
+			RawError: raw,
+		}
+		switch {
+		case !svc.Found:
+			issue.Suggestion = fmt.Sprintf("请确认依赖的 Service 名称与命名空间是否正确，或先部署 %s/%s", svc.Namespace, svc.Name)
+		case svc.PortMissing:
+			issue.Suggestion = fmt.Sprintf("应用访问的端口 %s 不在 Service 的 ports 中，请核对应用配置中的端口或 Service 定义", f.Port)
+		default:
+			issue.Suggestion = fmt.Sprintf("依赖的 Service 没有就绪的后端，请先排查它: kubehealer diagnose service/%s -n %s", svc.Name, svc.Namespace)
+		}
+		return issue
+	}
+	if !always {
+		return nil
+	}
+
+	issue := &Issue{
+		Type:       "Warning",
+		Title:      fmt.Sprintf("%s: %s", f.Kind.Label(), f.Target()),
+		RawError:   raw,
+		Suggestion: f.Kind.suggestion(),
+	}
+	if f.Detail != "" && f.Kind != DependencyConnectionRefused {
+		issue.Title += fmt.Sprintf(" (%s)", f.Detail)
+	}
+	if status := svc.Status(); status != "" {
+		issue.Suggestion = fmt.Sprintf("%s；%s", status, issue.Suggestion)
+	}
+	return issue
+}
+
+// suggestion 各类依赖故障的排查建议
+func (k DependencyKind) suggestion() string {
+	switch k {
+	case DependencyConnectionRefused:
+		return "目标地址没有进程在监听，请确认依赖服务已启动、端口配置正确；依赖启动较慢时可以在应用中加入重试或使用 initContainer 等待"
+	case DependencyDNS:
+		return "请确认域名拼写与命名空间 (跨命名空间需写成 <svc>.<ns>)，超时类错误请检查 CoreDNS 与 Pod 的 dnsPolicy / NetworkPolicy 是否放行 53 端口"
+	case DependencyTLS:
+		return "请检查证书是否过期、签发的域名是否包含访问地址，以及容器中是否挂载了对应的 CA 证书"
+	case DependencyDBAuth:
+		return "请核对 Secret 中的用户名 / 密码是否与数据库一致 (注意密码轮换后 Pod 需要重启才能读到新的环境变量)"
+	case DependencyUpstream5xx:
+		return "上游服务自身出错，请沿调用链继续诊断上游服务，并确认应用对上游错误有重试与降级"
+	default:
+		return ""
+	}
+}
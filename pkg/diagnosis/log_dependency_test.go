@@ -0,0 +1,244 @@
+package diagnosis
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestClassifyDependencyFailures(t *testing.T) {
+	tests := []struct {
+		name       string
+		line       string
+		wantKind   DependencyKind
+		wantHost   string
+		wantPort   string
+		wantDetail string
+	}{
+		{
+			name:       "Go 连接被拒绝",
+			line:       "failed to connect: dial tcp 10.96.12.4:5432: connect: connection refused",
+			wantKind:   DependencyConnectionRefused,
+			wantHost:   "10.96.12.4",
+			wantPort:   "5432",
+			wantDetail: "connection refused",
+		},
+		{
+			name:       "Node.js 连接被拒绝",
+			line:       "Error: connect ECONNREFUSED 127.0.0.1:6379",
+			wantKind:   DependencyConnectionRefused,
+			wantHost:   "127.0.0.1",
+			wantPort:   "6379",
+			wantDetail: "connection refused",
+		},
+		{
+			name:       "Python urllib3 连接被拒绝",
+			line:       "HTTPConnectionPool(host='orders', port=8080): Max retries exceeded (Caused by NewConnectionError: [Errno 111] Connection refused)",
+			wantKind:   DependencyConnectionRefused,
+			wantHost:   "orders",
+			wantPort:   "8080",
+			wantDetail: "connection refused",
+		},
+		{
+			name:       "Go DNS NXDOMAIN",
+			line:       "dial tcp: lookup redis.cache.svc.cluster.local on 10.96.0.10:53: no such host",
+			wantKind:   DependencyDNS,
+			wantHost:   "redis.cache.svc.cluster.local",
+			wantDetail: "NXDOMAIN",
+		},
+		{
+			name:       "Go DNS 超时",
+			line:       "dial tcp: lookup payments on 10.96.0.10:53: read udp 10.244.1.5:40000->10.96.0.10:53: i/o timeout",
+			wantKind:   DependencyDNS,
+			wantHost:   "payments",
+			wantDetail: "timeout",
+		},
+		{
+			name:       "Java UnknownHostException",
+			line:       "java.net.UnknownHostException: kafka-0.kafka.data.svc",
+			wantKind:   DependencyDNS,
+			wantHost:   "kafka-0.kafka.data.svc",
+			wantDetail: "NXDOMAIN",
+		},
+		{
+			name:       "x509 证书",
+			line:       `Get "https://auth.example.com:8443/token": tls: failed to verify certificate: x509: certificate signed by unknown authority`,
+			wantKind:   DependencyTLS,
+			wantHost:   "auth.example.com",
+			wantPort:   "8443",
+			wantDetail: "x509: certificate signed by unknown authority",
+		},
+		{
+			name:       "PostgreSQL 认证失败",
+			line:       `connection to server at "postgres" (10.96.3.3), port 5432 failed: FATAL:  password authentication failed for user "app"`,
+			wantKind:   DependencyDBAuth,
+			wantHost:   "postgres",
+			wantPort:   "5432",
+			wantDetail: "PostgreSQL",
+		},
+		{
+			name:       "MySQL 认证失败 (客户端地址不是目标)",
+			line:       "Error 1045 (28000): Access denied for user 'app'@'10.244.1.5' (using password: YES)",
+			wantKind:   DependencyDBAuth,
+			wantDetail: "MySQL",
+		},
+		{
+			name:       "上游 5xx",
+			line:       `request to http://inventory.shop:9000/items failed: status code 503`,
+			wantKind:   DependencyUpstream5xx,
+			wantHost:   "inventory.shop",
+			wantPort:   "9000",
+			wantDetail: "HTTP 503",
+		},
+		{
+			name:       "Envoy 上游连接失败 (没有目标地址)",
+			line:       "upstream connect error or disconnect/reset before headers. reset reason: connection failure, HTTP 503",
+			wantKind:   DependencyUpstream5xx,
+			wantDetail: "HTTP 503",
+		},
+		{
+			name:       "upstream 后面的单词不是主机",
+			line:       "upstream returned 502",
+			wantKind:   DependencyUpstream5xx,
+			wantDetail: "HTTP 502",
+		},
+		{
+			name: "本服务的访问日志不算上游 5xx",
+			line: "GET /api/orders status=500 duration=12ms",
+		},
+		{
+			name: "普通的连接超时不算 DNS 失败",
+			line: "read tcp 10.244.1.5:40000->10.0.0.8:443: i/o timeout",
+		},
+		{
+			name: "普通日志",
+			line: "INFO started at 10:00:01",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ClassifyDependencyFailures([]string{tt.line})
+			if tt.wantKind == "" {
+				if len(got) != 0 {
+					t.Errorf("unexpected failures: %+v", got)
+				}
+				return
+			}
+			if len(got) != 1 {
+				t.Fatalf("failures = %+v, want 1", got)
+			}
+			f := got[0]
+			if f.Kind != tt.wantKind || f.Host != tt.wantHost || f.Port != tt.wantPort || f.Detail != tt.wantDetail {
+				t.Errorf("got %s %q:%q (%s), want %s %q:%q (%s)", f.Kind, f.Host, f.Port, f.Detail, tt.wantKind, tt.wantHost, tt.wantPort, tt.wantDetail)
+			}
+		})
+	}
+}
+
+func TestClassifyDependencyFailures_Merge(t *testing.T) {
+	lines := []string{
+		"dial tcp 10.0.0.5:5432: connect: connection refused",
+		"retrying in 1s",
+		"dial tcp 10.0.0.5:5432: connect: connection refused",
+		"dial tcp: lookup cache on 10.96.0.10:53: no such host",
+		"dial tcp 10.0.0.5:5432: connect: connection refused",
+	}
+	got := ClassifyDependencyFailures(lines)
+	if len(got) != 2 {
+		t.Fatalf("failures = %+v, want 2", got)
+	}
+	if got[0].Count != 3 || got[0].FirstLine != 0 || got[0].LastLine != 4 || got[0].Target() != "10.0.0.5:5432" {
+		t.Errorf("first = %+v", got[0])
+	}
+	if got[1].Kind != DependencyDNS || got[1].Target() != "cache" {
+		t.Errorf("second = %+v", got[1])
+	}
+}
+
+func TestAnalyzer_CheckDependencyServices(t *testing.T) {
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "api", Namespace: "default"}}
+	newService := func(name, clusterIP string, port int32) *corev1.Service {
+		return &corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Spec: corev1.ServiceSpec{
+				ClusterIP: clusterIP,
+				Ports:     []corev1.ServicePort{{Port: port}},
+			},
+		}
+	}
+
+	tests := []struct {
+		name      string
+		failure   DependencyFailure
+		objects   []runtime.Object
+		want      *DependencyService
+		wantIssue string // always=false 时期望的问题标题，空表示不报告
+	}{
+		{
+			name:      "Service 没有就绪的 Endpoint",
+			failure:   DependencyFailure{Kind: DependencyConnectionRefused, Host: "postgres", Port: "5432", addressed: true},
+			objects:   []runtime.Object{newService("postgres", "10.96.3.3", 5432), newEndpointSlice("postgres", false)},
+			want:      &DependencyService{Namespace: "default", Name: "postgres", Found: true, Ready: 0, Total: 1},
+			wantIssue: "连接被拒绝: postgres:5432，Service default/postgres Endpoint 0/1 就绪",
+		},
+		{
+			name:    "按 ClusterIP 匹配到健康的 Service",
+			failure: DependencyFailure{Kind: DependencyConnectionRefused, Host: "10.96.3.3", Port: "5432"},
+			objects: []runtime.Object{newService("postgres", "10.96.3.3", 5432), newEndpointSlice("postgres", true, true)},
+			want:    &DependencyService{Namespace: "default", Name: "postgres", Found: true, Ready: 2, Total: 2},
+		},
+		{
+			name:      "Service 不存在",
+			failure:   DependencyFailure{Kind: DependencyDNS, Host: "redis.default.svc.cluster.local", Detail: "NXDOMAIN"},
+			want:      &DependencyService{Namespace: "default", Name: "redis"},
+			wantIssue: "DNS 解析失败: redis.default.svc.cluster.local，Service default/redis 不存在",
+		},
+		{
+			name:      "Service 没有暴露该端口",
+			failure:   DependencyFailure{Kind: DependencyConnectionRefused, Host: "postgres", Port: "5433", addressed: true},
+			objects:   []runtime.Object{newService("postgres", "10.96.3.3", 5432), newEndpointSlice("postgres", true)},
+			want:      &DependencyService{Namespace: "default", Name: "postgres", Found: true, Ready: 1, Total: 1, PortMissing: true},
+			wantIssue: "连接被拒绝: postgres:5433，Service default/postgres 没有暴露该端口 (Endpoint 1/1 就绪)",
+		},
+		{
+			name:    "报错文字中的单段名称不当作 Service",
+			failure: DependencyFailure{Kind: DependencyTLS, Host: "connect"},
+		},
+		{
+			name:      "DNS 查询的单段名称当作同命名空间的 Service",
+			failure:   DependencyFailure{Kind: DependencyDNS, Host: "redis", Detail: "NXDOMAIN", addressed: true},
+			want:      &DependencyService{Namespace: "default", Name: "redis"},
+			wantIssue: "DNS 解析失败: redis，Service default/redis 不存在",
+		},
+		{
+			name:    "外部域名",
+			failure: DependencyFailure{Kind: DependencyTLS, Host: "auth.example.com", Port: "443"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			failures := []DependencyFailure{tt.failure}
+			NewAnalyzer(fake.NewSimpleClientset(tt.objects...)).checkDependencyServices(pod, failures)
+
+			got := failures[0].Service
+			if (got == nil) != (tt.want == nil) || (got != nil && *got != *tt.want) {
+				t.Errorf("service = %+v, want %+v", got, tt.want)
+			}
+			issue := failures[0].Issue(false)
+			if tt.wantIssue == "" {
+				if issue != nil {
+					t.Errorf("unexpected issue: %+v", issue)
+				}
+				return
+			}
+			if issue == nil || issue.Type != "Error" || issue.Title != tt.wantIssue {
+				t.Errorf("issue = %+v, want %q", issue, tt.wantIssue)
+			}
+		})
+	}
+}
@@ -0,0 +1,310 @@
+package diagnosis
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// -----------------------------------------------------------
+// 结构化 (JSON) 日志
+// 直接对 JSON 行跑正则容易误报 (例如 "error_count":0 或字段名 "error")，
+// 因此先按日志库的字段约定解析出级别、消息、错误与堆栈，再交给模式库分析
+// -----------------------------------------------------------
+
+// JSON 日志的标准级别
+const (
+	LogLevelDebug = "debug"
+	LogLevelInfo  = "info"
+	LogLevelWarn  = "warn"
+	LogLevelError = "error"
+	LogLevelFatal = "fatal"
+)
+
+// JSONLogFields 是各类信息在 JSON 日志中的字段名，按顺序取第一个存在的字段
+type JSONLogFields struct {
+	Level      []string `json:"level" mapstructure:"level"`
+	Message    []string `json:"message" mapstructure:"message"`
+	Error      []string `json:"error" mapstructure:"error"`
+	StackTrace []string `json:"stacktrace" mapstructure:"stacktrace"`
+}
+
+// JSONLogPresets 是常见日志库的字段约定
+var JSONLogPresets = map[string]JSONLogFields{
+	// go.uber.org/zap (production config)
+	"zap": {Level: []string{"level"}, Message: []string{"msg"}, Error: []string{"error"}, StackTrace: []string{"stacktrace"}},
+	// github.com/sirupsen/logrus (JSONFormatter, WithError 使用 "error" 字段)
+	"logrus": {Level: []string{"level"}, Message: []string{"msg"}, Error: []string{"error"}},
+	// node-bunyan: level 为数字，错误为 {message, name, stack} 对象
+	"bunyan": {Level: []string{"level"}, Message: []string{"msg"}, Error: []string{"err"}},
+	// python structlog (JSONRenderer + format_exc_info)
+	"structlog": {Level: []string{"level", "log_level"}, Message: []string{"event"}, Error: []string{"error"}, StackTrace: []string{"exception"}},
+}
+
+// DefaultJSONLogFields 返回所有预设字段的并集，能识别大部分日志库的输出
+// 额外包含 GCP / logstash-logback 等常见字段 (severity、message、stack_trace)
+func DefaultJSONLogFields() JSONLogFields {
+	names := make([]string, 0, len(JSONLogPresets))
+	for name := range JSONLogPresets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var level, message, errs, stack []string
+	for _, name := range names {
+		p := JSONLogPresets[name]
+		level = append(level, p.Level...)
+		message = append(message, p.Message...)
+		errs = append(errs, p.Error...)
+		stack = append(stack, p.StackTrace...)
+	}
+	return JSONLogFields{
+		Level:      uniqueStrings(append(level, "severity")...),
+		Message:    uniqueStrings(append(message, "message")...),
+		Error:      uniqueStrings(errs...),
+		StackTrace: uniqueStrings(append(stack, "stack_trace")...),
+	}
+}
+
+// WithJSONLogFields 设置 JSON 日志的字段约定 (例如 JSONLogPresets["zap"])
+func (a *Analyzer) WithJSONLogFields(fields JSONLogFields) *Analyzer {
+	a.logs.JSONFields = fields
+	return a
+}
+
+// JSONLogPreset 按名称返回预设字段，"" 或 "auto" 返回所有预设的并集
+func JSONLogPreset(name string) (JSONLogFields, error) {
+	if name == "" || name == "auto" {
+		return DefaultJSONLogFields(), nil
+	}
+	fields, ok := JSONLogPresets[name]
+	if !ok {
+		return JSONLogFields{}, fmt.Errorf("未知的 JSON 日志格式: %s (可选 auto, zap, logrus, bunyan, structlog)", name)
+	}
+	return fields, nil
+}
+
+// Merge 用 override 中非空的字段列表覆盖当前字段
+func (f JSONLogFields) Merge(override JSONLogFields) JSONLogFields {
+	if len(override.Level) > 0 {
+		f.Level = override.Level
+	}
+	if len(override.Message) > 0 {
+		f.Message = override.Message
+	}
+	if len(override.Error) > 0 {
+		f.Error = override.Error
+	}
+	if len(override.StackTrace) > 0 {
+		f.StackTrace = override.StackTrace
+	}
+	return f
+}
+
+// JSONLogEntry 是解析后的一条 JSON 日志
+type JSONLogEntry struct {
+	Line       int    `json:"line"`  // 在抓取的日志中的行号 (从 0 开始)
+	Level      string `json:"level"` // 标准化后的级别: debug / info / warn / error / fatal，无法识别时为空
+	Message    string `json:"message,omitempty"`
+	Error      string `json:"error,omitempty"`
+	StackTrace string `json:"stacktrace,omitempty"`
+}
+
+// Text 返回可读的单行形式，例如 "ERROR failed to connect: dial tcp 10.0.0.1:5432: connection refused"
+// 级别大写放在行首，便于通用模式 (Common Error) 识别
+func (e JSONLogEntry) Text() string {
+	var parts []string
+	if e.Level != "" {
+		parts = append(parts, strings.ToUpper(e.Level))
+	}
+	msg := e.Message
+	if e.Error != "" {
+		if msg != "" {
+			msg += ": "
+		}
+		msg += e.Error
+	}
+	if msg != "" {
+		parts = append(parts, msg)
+	}
+	return strings.Join(parts, " ")
+}
+
+// LineNo 返回从 1 开始的行号，便于对照原始日志
+func (e JSONLogEntry) LineNo() int {
+	return e.Line + 1
+}
+
+// IsError 判断是否为 error / fatal 级别
+func (e JSONLogEntry) IsError() bool {
+	return e.Level == LogLevelError || e.Level == LogLevelFatal
+}
+
+// NotableJSONEntries 返回值得在报告中展示的条目: warn 及以上级别，或带有错误 / 堆栈字段
+func NotableJSONEntries(entries []JSONLogEntry) []JSONLogEntry {
+	var result []JSONLogEntry
+	for _, e := range entries {
+		if e.Level == LogLevelWarn || e.IsError() || e.Error != "" || e.StackTrace != "" {
+			result = append(result, e)
+		}
+	}
+	return result
+}
+
+// ParseJSONLogLine 按字段约定解析一行 JSON 日志，不是 JSON 对象时返回 false
+func ParseJSONLogLine(line string, fields JSONLogFields) (JSONLogEntry, bool) {
+	trimmed := strings.TrimSpace(line)
+	if !strings.HasPrefix(trimmed, "{") || !strings.HasSuffix(trimmed, "}") {
+		return JSONLogEntry{}, false
+	}
+	var obj map[string]interface{}
+	if err := json.Unmarshal([]byte(trimmed), &obj); err != nil {
+		return JSONLogEntry{}, false
+	}
+
+	entry := JSONLogEntry{
+		Level:      normalizeLogLevel(firstField(obj, fields.Level)),
+		Message:    jsonFieldText(firstField(obj, fields.Message)),
+		StackTrace: jsonFieldText(firstField(obj, fields.StackTrace)),
+	}
+	switch v := firstField(obj, fields.Error).(type) {
+	case map[string]interface{}:
+		// bunyan / pino 的错误对象: {"message": "...", "name": "TypeError", "stack": "..."}
+		entry.Error = jsonFieldText(v["message"])
+		if name := jsonFieldText(v["name"]); name != "" && entry.Error != "" {
+			entry.Error = name + ": " + entry.Error
+		}
+		if entry.StackTrace == "" {
+			entry.StackTrace = jsonFieldText(v["stack"])
+		}
+	default:
+		entry.Error = jsonFieldText(v)
+	}
+	return entry, true
+}
+
+// ParseJSONLogs 解析日志中所有的 JSON 行
+func ParseJSONLogs(lines []string, fields JSONLogFields) []JSONLogEntry {
+	var entries []JSONLogEntry
+	for i, line := range lines {
+		if entry, ok := ParseJSONLogLine(line, fields); ok {
+			entry.Line = i
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+// jsonTextLines 将 JSON 行替换为可读形式，供模式匹配与模板聚类使用，非 JSON 行保持不变
+func jsonTextLines(lines []string, entries []JSONLogEntry) []string {
+	if len(entries) == 0 {
+		return lines
+	}
+	text := append([]string(nil), lines...)
+	for _, e := range entries {
+		text[e.Line] = e.Text()
+	}
+	return text
+}
+
+// jsonStackTraces 从 JSON 日志的堆栈字段中提取堆栈，返回命中的模式名与堆栈
+// 字段内容能被语言包识别时 (例如完整的 Java 异常) 使用语言包的结果，否则 (例如 zap 的 stacktrace 只有调用帧)
+// 以错误消息为异常、第一帧为抛出位置
+func jsonStackTraces(patterns *PatternLibrary, entries []JSONLogEntry) ([]string, []StackTrace) {
+	var keywords []string
+	var traces []StackTrace
+	for _, e := range entries {
+		if e.StackTrace == "" {
+			continue
+		}
+		lines := strings.Split(strings.TrimRight(e.StackTrace, "\n"), "\n")
+		if found, parsed := patterns.Analyze(lines); len(parsed) > 0 {
+			keywords = append(keywords, found...)
+			for _, t := range parsed {
+				t.StartLine = e.Line
+				traces = append(traces, t)
+			}
+			continue
+		}
+		trace := StackTrace{
+			Pattern:       "JSON Stacktrace",
+			Language:      "json",
+			ExceptionType: e.Error,
+			Message:       e.Message,
+			StartLine:     e.Line,
+			Lines:         lines,
+		}
+		if trace.ExceptionType == "" {
+			trace.ExceptionType, trace.Message = e.Message, ""
+		}
+		for _, l := range lines {
+			if f := strings.TrimSpace(l); f != "" {
+				trace.TopFrame = f
+				break
+			}
+		}
+		keywords = append(keywords, trace.Pattern)
+		traces = append(traces, trace)
+	}
+	return keywords, traces
+}
+
+// normalizeLogLevel 将各日志库的级别统一为 debug / info / warn / error / fatal
+// bunyan 使用数字级别: 10 trace, 20 debug, 30 info, 40 warn, 50 error, 60 fatal
+func normalizeLogLevel(v interface{}) string {
+	switch level := v.(type) {
+	case float64:
+		switch {
+		case level >= 60:
+			return LogLevelFatal
+		case level >= 50:
+			return LogLevelError
+		case level >= 40:
+			return LogLevelWarn
+		case level >= 30:
+			return LogLevelInfo
+		default:
+			return LogLevelDebug
+		}
+	case string:
+		switch strings.ToLower(level) {
+		case "trace", "debug", "verbose":
+			return LogLevelDebug
+		case "info", "information", "notice":
+			return LogLevelInfo
+		case "warn", "warning":
+			return LogLevelWarn
+		case "error", "err":
+			return LogLevelError
+		case "fatal", "panic", "dpanic", "critical", "crit", "alert", "emergency", "emerg":
+			return LogLevelFatal
+		}
+	}
+	return ""
+}
+
+// firstField 返回第一个存在的字段值
+func firstField(obj map[string]interface{}, names []string) interface{} {
+	for _, name := range names {
+		if v, ok := obj[name]; ok && v != nil {
+			return v
+		}
+	}
+	return nil
+}
+
+// jsonFieldText 将字段值转换为文本，对象与数组保留 JSON 形式
+func jsonFieldText(v interface{}) string {
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return val
+	case map[string]interface{}, []interface{}:
+		data, _ := json.Marshal(val)
+		return string(data)
+	default:
+		return fmt.Sprint(val)
+	}
+}
@@ -0,0 +1,110 @@
+package diagnosis
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseJSONLogLine(t *testing.T) {
+	tests := []struct {
+		name   string
+		preset string
+		line   string
+		want   JSONLogEntry
+		wantOK bool
+	}{
+		{
+			name:   "zap",
+			preset: "zap",
+			line:   `{"level":"error","ts":1714557600.12,"caller":"db/pool.go:88","msg":"failed to connect","error":"dial tcp 10.0.0.5:5432: connect: connection refused","stacktrace":"main.connect\n\t/app/db/pool.go:88"}`,
+			want: JSONLogEntry{Level: "error", Message: "failed to connect", Error: "dial tcp 10.0.0.5:5432: connect: connection refused",
+				StackTrace: "main.connect\n\t/app/db/pool.go:88"},
+			wantOK: true,
+		},
+		{
+			name:   "logrus",
+			preset: "logrus",
+			line:   `{"level":"warning","msg":"retrying request","time":"2024-05-01T10:00:00Z"}`,
+			want:   JSONLogEntry{Level: "warn", Message: "retrying request"},
+			wantOK: true,
+		},
+		{
+			name:   "bunyan 数字级别与错误对象",
+			preset: "bunyan",
+			line:   `{"name":"api","level":50,"msg":"request failed","err":{"message":"boom","name":"TypeError","stack":"TypeError: boom\n    at handler (/app/index.js:3:9)"}}`,
+			want: JSONLogEntry{Level: "error", Message: "request failed", Error: "TypeError: boom",
+				StackTrace: "TypeError: boom\n    at handler (/app/index.js:3:9)"},
+			wantOK: true,
+		},
+		{
+			name:   "structlog",
+			preset: "structlog",
+			line:   `{"event":"order failed","log_level":"critical","exception":"Traceback (most recent call last):\n  File \"/app/x.py\", line 1, in f\nKeyError: 'id'"}`,
+			want: JSONLogEntry{Level: "fatal", Message: "order failed",
+				StackTrace: "Traceback (most recent call last):\n  File \"/app/x.py\", line 1, in f\nKeyError: 'id'"},
+			wantOK: true,
+		},
+		{
+			name:   "不是 JSON",
+			line:   `2024-05-01 ERROR {"id": 1}`,
+			wantOK: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fields, err := JSONLogPreset(tt.preset)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, ok := ParseJSONLogLine(tt.line, fields)
+			if ok != tt.wantOK {
+				t.Fatalf("ok = %v, want %v", ok, tt.wantOK)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("entry = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAnalyzeLogLines_JSON(t *testing.T) {
+	lines := []string{
+		`{"level":"info","msg":"processed batch","error_count":0,"errors":[]}`,
+		`{"level":"info","msg":"Error handler registered"}`,
+		`{"level":"error","msg":"order failed","stacktrace":"main.placeOrder\n\t/app/order.go:42\nmain.main\n\t/app/main.go:10"}`,
+		`{"level":"fatal","msg":"unhandled exception","stack_trace":"java.lang.IllegalStateException: closed\n\tat com.shop.Pool.get(Pool.java:7)"}`,
+	}
+	result := AnalyzeLogLines(lines, DefaultLogAnalysisConfig())
+
+	want := []string{"Common Error", "JSON Stacktrace", "Java Exception"}
+	if !reflect.DeepEqual(result.MatchedKeywords, want) {
+		t.Errorf("keywords = %v, want %v", result.MatchedKeywords, want)
+	}
+	if !reflect.DeepEqual(result.MatchedKeyords, want) {
+		t.Errorf("deprecated keywords = %v, want %v", result.MatchedKeyords, want)
+	}
+	if len(result.StackTraces) != 2 {
+		t.Fatalf("traces = %+v, want 2", result.StackTraces)
+	}
+	if tr := result.StackTraces[0]; tr.StartLine != 2 || tr.ExceptionType != "order failed" || tr.TopFrame != "main.placeOrder" {
+		t.Errorf("zap trace = %+v", tr)
+	}
+	if tr := result.StackTraces[1]; tr.StartLine != 3 || tr.ExceptionType != "java.lang.IllegalStateException" || tr.TopFrame != "com.shop.Pool.get(Pool.java:7)" {
+		t.Errorf("java trace = %+v", tr)
+	}
+	if got := NotableJSONEntries(result.JSONEntries); len(got) != 2 || got[0].Line != 2 {
+		t.Errorf("notable entries = %+v, want the error and fatal lines", got)
+	}
+}
+
+func TestJSONLogFields_Merge(t *testing.T) {
+	fields := JSONLogPresets["zap"].Merge(JSONLogFields{Message: []string{"message"}})
+	entry, ok := ParseJSONLogLine(`{"level":"error","message":"custom","msg":"ignored"}`, fields)
+	if !ok || entry.Message != "custom" || entry.Level != "error" {
+		t.Errorf("entry = %+v", entry)
+	}
+	if _, err := JSONLogPreset("log4j"); err == nil {
+		t.Error("unknown preset should fail")
+	}
+}
@@ -0,0 +1,433 @@
+package diagnosis
+
+import (
+	"embed"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"sigs.k8s.io/yaml"
+)
+
+// -----------------------------------------------------------
+// 日志模式库
+// 模式以语言包 (YAML / JSON 文件) 的形式提供，内置包位于 patterns/ 目录，
+// 用户可以通过 --log-patterns 指定额外的目录，同名模式以用户的为准
+// -----------------------------------------------------------
+
+//go:embed patterns/*.yaml
+var builtinPatternFS embed.FS
+
+// maxTraceLines 单个堆栈最多保留的行数，避免异常日志刷屏时占满报告
+const maxTraceLines = 200
+
+// LogPattern 是模式库中的一条模式
+type LogPattern struct {
+	Name         string `json:"name"`                    // 命中时报告的关键词，例如 "Java Exception"
+	Start        string `json:"start"`                   // 起始行的正则，可以包含命名分组 type / message
+	HeaderLines  int    `json:"header_lines,omitempty"`  // 起始行之后无条件包含的行数
+	Continuation string `json:"continuation,omitempty"`  // 堆栈后续行的正则，为空时为单行模式
+	Final        string `json:"final,omitempty"`         // 后续行结束后紧跟的结尾行 (例如 Python 最后的异常行)
+	Exception    string `json:"exception,omitempty"`     // 提取异常类型的正则 (命名分组 type / message)，默认使用 start
+	ExceptionAt  string `json:"exception_at,omitempty"`  // first (默认) 或 last: 使用第一条还是最后一条匹配的行
+	Type         string `json:"type,omitempty"`          // 正则中没有 type 分组时使用的异常类型
+	Frame        string `json:"frame,omitempty"`         // 调用栈帧的正则，第一个分组为帧描述
+	FrameOrder   string `json:"frame_order,omitempty"`   // first (默认) 或 last: 最内层的帧在前还是在后
+	LibraryFrame string `json:"library_frame,omitempty"` // 标准库 / 框架帧的正则，查找应用代码帧时跳过
+	Fallback     bool   `json:"fallback,omitempty"`      // 兜底模式: 只匹配没有被其他模式命中的行
+}
+
+// LogPatternPack 是一个语言包
+type LogPatternPack struct {
+	Language string       `json:"language"`
+	Patterns []LogPattern `json:"patterns"`
+}
+
+// StackTrace 是从日志中提取出的一段堆栈
+type StackTrace struct {
+	Pattern       string   `json:"pattern"`  // 命中的模式名
+	Language      string   `json:"language"` // 语言包
+	ExceptionType string   `json:"exception_type,omitempty"`
+	Message       string   `json:"message,omitempty"`
+	TopFrame      string   `json:"top_frame,omitempty"` // 最内层的应用代码帧 (跳过标准库 / 框架)
+	StartLine     int      `json:"start_line"`          // 在抓取的日志中的行号 (从 0 开始)
+	Lines         []string `json:"lines"`
+}
+
+// PatternLibrary 是编译后的模式库
+type PatternLibrary struct {
+	multiLine  []*compiledPattern
+	singleLine []*compiledPattern
+	fallback   []*compiledPattern
+}
+
+type compiledPattern struct {
+	LogPattern
+	language     string
+	start        *regexp.Regexp
+	continuation *regexp.Regexp
+	final        *regexp.Regexp
+	exception    *regexp.Regexp
+	frame        *regexp.Regexp
+	libraryFrame *regexp.Regexp
+}
+
+var (
+	defaultLibrary     *PatternLibrary
+	defaultLibraryOnce sync.Once
+)
+
+// DefaultPatternLibrary 返回只包含内置语言包的模式库
+func DefaultPatternLibrary() *PatternLibrary {
+	defaultLibraryOnce.Do(func() {
+		packs, err := builtinPatternPacks()
+		if err == nil {
+			defaultLibrary, err = NewPatternLibrary(packs...)
+		}
+		if err != nil {
+			panic(fmt.Sprintf("内置日志模式无效: %v", err))
+		}
+	})
+	return defaultLibrary
+}
+
+// WithLogPatterns 替换分析日志使用的模式库 (为 nil 时保持内置模式库)
+func (a *Analyzer) WithLogPatterns(lib *PatternLibrary) *Analyzer {
+	if lib != nil {
+		a.logs.Patterns = lib
+	}
+	return a
+}
+
+// LoadPatternLibrary 加载内置语言包以及 dir 下的 *.yaml / *.yml / *.json 语言包
+// 与内置模式同名的用户模式会替换内置模式
+func LoadPatternLibrary(dir string) (*PatternLibrary, error) {
+	packs, err := builtinPatternPacks()
+	if err != nil {
+		return nil, err
+	}
+	if dir == "" {
+		return NewPatternLibrary(packs...)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("无法读取日志模式目录: %w", err)
+	}
+	var userPacks []LogPatternPack
+	for _, entry := range entries {
+		ext := strings.ToLower(filepath.Ext(entry.Name()))
+		if entry.IsDir() || (ext != ".yaml" && ext != ".yml" && ext != ".json") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		pack, err := ParsePatternPack(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", entry.Name(), err)
+		}
+		userPacks = append(userPacks, pack)
+	}
+
+	// 用户模式优先，并移除同名的内置模式
+	overridden := map[string]bool{}
+	for _, pack := range userPacks {
+		for _, p := range pack.Patterns {
+			overridden[p.Name] = true
+		}
+	}
+	for i := range packs {
+		kept := packs[i].Patterns[:0]
+		for _, p := range packs[i].Patterns {
+			if !overridden[p.Name] {
+				kept = append(kept, p)
+			}
+		}
+		packs[i].Patterns = kept
+	}
+	return NewPatternLibrary(append(userPacks, packs...)...)
+}
+
+// ParsePatternPack 解析 YAML 或 JSON 格式的语言包
+func ParsePatternPack(data []byte) (LogPatternPack, error) {
+	var pack LogPatternPack
+	if err := yaml.UnmarshalStrict(data, &pack); err != nil {
+		return pack, err
+	}
+	return pack, nil
+}
+
+// NewPatternLibrary 编译语言包中的所有模式，排在前面的语言包优先匹配
+func NewPatternLibrary(packs ...LogPatternPack) (*PatternLibrary, error) {
+	lib := &PatternLibrary{}
+	for _, pack := range packs {
+		for _, p := range pack.Patterns {
+			cp, err := compilePattern(pack.Language, p)
+			if err != nil {
+				return nil, fmt.Errorf("模式 %q: %w", p.Name, err)
+			}
+			switch {
+			case cp.Fallback:
+				lib.fallback = append(lib.fallback, cp)
+			case cp.continuation != nil:
+				lib.multiLine = append(lib.multiLine, cp)
+			default:
+				lib.singleLine = append(lib.singleLine, cp)
+			}
+		}
+	}
+	return lib, nil
+}
+
+// LogMatch 是一次模式命中，多行模式只记录堆栈的第一行
+// Text / Before / After 只在日志分析结果中填写 (见 AnalyzeLogLines)
+type LogMatch struct {
+	Pattern string   `json:"pattern"`
+	Line    int      `json:"line"`             // 行号 (从 0 开始)
+	Text    string   `json:"text,omitempty"`   // 命中的原始日志行
+	Before  []string `json:"before,omitempty"` // 命中行之前的上下文
+	After   []string `json:"after,omitempty"`  // 命中行之后的上下文
+}
+
+// LineNo 返回从 1 开始的行号
+func (m LogMatch) LineNo() int {
+	return m.Line + 1
+}
+
+// Analyze 在日志中匹配所有模式，返回命中的模式名 (按首次出现排序) 与提取出的堆栈
+func (l *PatternLibrary) Analyze(lines []string) ([]string, []StackTrace) {
+	matches, traces := l.Match(lines)
+	var keywords []string
+	seen := map[string]bool{}
+	for _, m := range matches {
+		if !seen[m.Pattern] {
+			seen[m.Pattern] = true
+			keywords = append(keywords, m.Pattern)
+		}
+	}
+	return keywords, traces
+}
+
+// Match 在日志中匹配所有模式，返回每一次命中 (按多行、单行、兜底的顺序) 与提取出的堆栈
+func (l *PatternLibrary) Match(lines []string) ([]LogMatch, []StackTrace) {
+	var matches []LogMatch
+	var traces []StackTrace
+
+	// 1. 多行模式: 命中后跳过整段堆栈，避免堆栈中的行被重复匹配
+	covered := make([]bool, len(lines))
+	for i := 0; i < len(lines); {
+		end := i + 1
+		for _, p := range l.multiLine {
+			if !p.start.MatchString(lines[i]) {
+				continue
+			}
+			end = p.extent(lines, i)
+			matches = append(matches, LogMatch{Pattern: p.Name, Line: i})
+			traces = append(traces, p.trace(lines[i:end], i))
+			for j := i; j < end; j++ {
+				covered[j] = true
+			}
+			break
+		}
+		i = end
+	}
+
+	// 2. 单行模式: 逐行独立匹配
+	for i, line := range lines {
+		for _, p := range l.singleLine {
+			if p.start.MatchString(line) {
+				matches = append(matches, LogMatch{Pattern: p.Name, Line: i})
+				covered[i] = true
+			}
+		}
+	}
+
+	// 3. 兜底模式: 只看没有被任何模式命中的行
+	for i, line := range lines {
+		if covered[i] {
+			continue
+		}
+		for _, p := range l.fallback {
+			if p.start.MatchString(line) {
+				matches = append(matches, LogMatch{Pattern: p.Name, Line: i})
+			}
+		}
+	}
+	return matches, traces
+}
+
+// extent 返回从 start 行开始的堆栈结束位置 (不含)
+// 结尾行之后如果紧跟 (可隔空行) 后续行，说明是链式异常 (例如 Python 的 "During handling ...")，继续向后合并
+func (p *compiledPattern) extent(lines []string, start int) int {
+	limit := start + maxTraceLines
+	if limit > len(lines) {
+		limit = len(lines)
+	}
+	end := start + 1 + p.HeaderLines
+	if end > limit {
+		end = limit
+	}
+	for {
+		for end < limit && p.continuation.MatchString(lines[end]) {
+			end++
+		}
+		// 去掉末尾的空行
+		for end > start+1 && strings.TrimSpace(lines[end-1]) == "" {
+			end--
+		}
+		if p.final == nil || end >= limit || !p.final.MatchString(lines[end]) {
+			return end
+		}
+		end++
+
+		next := end
+		for next < limit && strings.TrimSpace(lines[next]) == "" {
+			next++
+		}
+		if next >= limit || next == end || !p.continuation.MatchString(lines[next]) {
+			return end
+		}
+		end = next
+	}
+}
+
+// trace 从堆栈行中提取异常类型、消息与最内层的应用代码帧
+func (p *compiledPattern) trace(lines []string, startLine int) StackTrace {
+	st := StackTrace{
+		Pattern:       p.Name,
+		Language:      p.language,
+		ExceptionType: p.Type,
+		StartLine:     startLine,
+		Lines:         append([]string(nil), lines...),
+	}
+
+	order := make([]int, len(lines))
+	for i := range order {
+		order[i] = i
+	}
+	if p.ExceptionAt == "last" {
+		sort.Sort(sort.Reverse(sort.IntSlice(order)))
+	}
+	for _, i := range order {
+		m := p.exception.FindStringSubmatch(lines[i])
+		if m == nil {
+			continue
+		}
+		if idx := p.exception.SubexpIndex("type"); idx > 0 && m[idx] != "" {
+			st.ExceptionType = m[idx]
+		}
+		if idx := p.exception.SubexpIndex("message"); idx > 0 {
+			st.Message = strings.TrimSpace(m[idx])
+		}
+		break
+	}
+
+	if p.frame == nil {
+		return st
+	}
+	var frames []string
+	for _, line := range lines {
+		if m := p.frame.FindStringSubmatch(line); m != nil {
+			frame := m[0]
+			if len(m) > 1 {
+				frame = m[1]
+			}
+			frames = append(frames, strings.TrimSpace(frame))
+		}
+	}
+	if p.FrameOrder == "last" {
+		for i, j := 0, len(frames)-1; i < j; i, j = i+1, j-1 {
+			frames[i], frames[j] = frames[j], frames[i]
+		}
+	}
+	for _, f := range frames {
+		if p.libraryFrame == nil || !p.libraryFrame.MatchString(f) {
+			st.TopFrame = f
+			break
+		}
+	}
+	// 全部是框架帧时退而求其次，使用最内层的帧
+	if st.TopFrame == "" && len(frames) > 0 {
+		st.TopFrame = frames[0]
+	}
+	return st
+}
+
+// Summary 返回单行描述，例如 "java.lang.NullPointerException: boom (at com.shop.Foo.bar(Foo.java:42))"
+func (t StackTrace) Summary() string {
+	s := t.ExceptionType
+	if s == "" {
+		s = t.Pattern
+	}
+	if t.Message != "" {
+		s += ": " + t.Message
+	}
+	if t.TopFrame != "" {
+		s += " (at " + t.TopFrame + ")"
+	}
+	return s
+}
+
+func compilePattern(language string, p LogPattern) (*compiledPattern, error) {
+	if p.Name == "" || p.Start == "" {
+		return nil, fmt.Errorf("name 与 start 不能为空")
+	}
+	cp := &compiledPattern{LogPattern: p, language: language}
+	var err error
+	compile := func(expr string) *regexp.Regexp {
+		if expr == "" || err != nil {
+			return nil
+		}
+		var re *regexp.Regexp
+		re, err = regexp.Compile(expr)
+		return re
+	}
+	cp.start = compile(p.Start)
+	cp.continuation = compile(p.Continuation)
+	cp.final = compile(p.Final)
+	cp.exception = compile(p.Exception)
+	cp.frame = compile(p.Frame)
+	cp.libraryFrame = compile(p.LibraryFrame)
+	if err != nil {
+		return nil, err
+	}
+	if cp.exception == nil {
+		cp.exception = cp.start
+	}
+	return cp, nil
+}
+
+// builtinPatternPacks 读取内置语言包，generic 包排在最后
+func builtinPatternPacks() ([]LogPatternPack, error) {
+	entries, err := builtinPatternFS.ReadDir("patterns")
+	if err != nil {
+		return nil, err
+	}
+	var packs []LogPatternPack
+	var generic *LogPatternPack
+	for _, entry := range entries {
+		data, err := builtinPatternFS.ReadFile("patterns/" + entry.Name())
+		if err != nil {
+			return nil, err
+		}
+		pack, err := ParsePatternPack(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", entry.Name(), err)
+		}
+		if pack.Language == "generic" {
+			generic = &pack
+			continue
+		}
+		packs = append(packs, pack)
+	}
+	if generic != nil {
+		packs = append(packs, *generic)
+	}
+	return packs, nil
+}
@@ -0,0 +1,235 @@
+package diagnosis
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPatternLibrary_StackTraces(t *testing.T) {
+	tests := []struct {
+		name      string
+		logs      string
+		keyword   string
+		wantType  string
+		wantMsg   string
+		wantFrame string
+		wantLines int
+	}{
+		{
+			name: "Java 异常 (带 Caused by)",
+			logs: `2024-05-01 10:00:00 INFO  Starting OrderService
+2024-05-01 10:00:01 ERROR [main] o.s.boot.SpringApplication - Application run failed java.lang.IllegalStateException: Failed to load config
+	at org.springframework.boot.SpringApplication.run(SpringApplication.java:320)
+	at com.shop.order.ConfigLoader.load(ConfigLoader.java:42)
+	at com.shop.order.Application.main(Application.java:12)
+Caused by: java.io.FileNotFoundException: /etc/app/config.yaml
+	at java.io.FileInputStream.open0(Native Method)
+	... 3 more
+2024-05-01 10:00:02 INFO  Shutting down`,
+			keyword:   "Java Exception",
+			wantType:  "java.lang.IllegalStateException",
+			wantMsg:   "Failed to load config",
+			wantFrame: "com.shop.order.ConfigLoader.load(ConfigLoader.java:42)",
+			wantLines: 7,
+		},
+		{
+			name: "Go panic",
+			logs: `starting server on :8080
+panic: runtime error: invalid memory address or nil pointer dereference
+[signal SIGSEGV: segmentation violation code=0x1 addr=0x0 pc=0x4a1b2c]
+
+goroutine 1 [running]:
+main.(*Server).handle(0x0)
+	/app/server.go:57 +0x1c
+main.main()
+	/app/main.go:20 +0x85
+exit status 2`,
+			keyword:   "Go Panic",
+			wantType:  "panic",
+			wantMsg:   "runtime error: invalid memory address or nil pointer dereference",
+			wantFrame: "main.(*Server).handle",
+			wantLines: 9,
+		},
+		{
+			name: "Python traceback",
+			logs: `INFO:root:loading model
+Traceback (most recent call last):
+  File "/usr/local/lib/python3.11/site-packages/flask/app.py", line 1455, in wsgi_app
+    response = self.full_dispatch_request()
+  File "/app/handlers.py", line 31, in get_user
+    return users[user_id]
+  File "/usr/local/lib/python3.11/site-packages/werkzeug/datastructures.py", line 80, in __getitem__
+    raise KeyError(key)
+KeyError: 'alice'
+INFO:root:retrying`,
+			keyword:   "Python Traceback",
+			wantType:  "KeyError",
+			wantMsg:   "'alice'",
+			wantFrame: `File "/app/handlers.py", line 31, in get_user`,
+			wantLines: 8,
+		},
+		{
+			name: "Python 链式异常",
+			logs: `Traceback (most recent call last):
+  File "/app/db.py", line 10, in connect
+    return pool.get()
+KeyError: 'primary'
+
+During handling of the above exception, another exception occurred:
+
+Traceback (most recent call last):
+  File "/app/main.py", line 5, in <module>
+    db.connect()
+app.errors.DatabaseUnavailable: no primary
+worker exited`,
+			keyword:   "Python Traceback",
+			wantType:  "app.errors.DatabaseUnavailable",
+			wantMsg:   "no primary",
+			wantFrame: `File "/app/main.py", line 5, in <module>`,
+			wantLines: 11,
+		},
+		{
+			name: "Node 异常",
+			logs: `Server listening on 3000
+TypeError: Cannot read properties of undefined (reading 'id')
+    at getOrder (/app/src/orders.js:18:25)
+    at Layer.handle [as handle_request] (/app/node_modules/express/lib/router/layer.js:95:5)
+    at process.processTicksAndRejections (node:internal/process/task_queues:95:5)`,
+			keyword:   "Node Error",
+			wantType:  "TypeError",
+			wantMsg:   "Cannot read properties of undefined (reading 'id')",
+			wantFrame: "getOrder (/app/src/orders.js:18:25)",
+			wantLines: 4,
+		},
+		{
+			name: "Rust panic",
+			logs: `thread 'main' panicked at src/main.rs:14:5:
+called ` + "`Option::unwrap()`" + ` on a ` + "`None`" + ` value
+stack backtrace:
+   0: rust_begin_unwind
+   1: core::panicking::panic
+   2: app::config::load
+   3: app::main
+note: Some details are omitted, run with ` + "`RUST_BACKTRACE=full`" + ` for a verbose backtrace.`,
+			keyword:   "Rust Panic",
+			wantType:  "panic",
+			wantMsg:   "src/main.rs:14:5",
+			wantFrame: "app::config::load",
+			wantLines: 8,
+		},
+		{
+			name: ".NET 异常",
+			logs: `Unhandled exception. System.InvalidOperationException: Sequence contains no elements
+   at System.Linq.ThrowHelper.ThrowNoElementsException()
+   at Shop.Api.Services.CartService.First() in /src/Services/CartService.cs:line 27
+   at Shop.Api.Program.Main(String[] args) in /src/Program.cs:line 9`,
+			keyword:   ".NET Exception",
+			wantType:  "System.InvalidOperationException",
+			wantMsg:   "Sequence contains no elements",
+			wantFrame: "Shop.Api.Services.CartService.First()",
+			wantLines: 4,
+		},
+		{
+			name: "Ruby 异常",
+			logs: `/usr/local/bundle/gems/activerecord-7.1.0/lib/active_record/core.rb:253:in ` + "`find'" + `: Couldn't find User (ActiveRecord::RecordNotFound)
+	from /app/app/services/billing.rb:12:in ` + "`charge'" + `
+	from /app/bin/worker:5:in ` + "`<main>'",
+			keyword:   "Ruby Exception",
+			wantType:  "ActiveRecord::RecordNotFound",
+			wantMsg:   "Couldn't find User",
+			wantFrame: "/app/app/services/billing.rb:12:in `charge'",
+			wantLines: 3,
+		},
+	}
+
+	lib := DefaultPatternLibrary()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keywords, traces := lib.Analyze(strings.Split(tt.logs, "\n"))
+			if len(keywords) == 0 || keywords[0] != tt.keyword {
+				t.Errorf("keywords = %v, want first %q", keywords, tt.keyword)
+			}
+			if len(traces) != 1 {
+				t.Fatalf("traces = %+v, want 1", traces)
+			}
+			tr := traces[0]
+			if tr.ExceptionType != tt.wantType {
+				t.Errorf("ExceptionType = %q, want %q", tr.ExceptionType, tt.wantType)
+			}
+			if tr.Message != tt.wantMsg {
+				t.Errorf("Message = %q, want %q", tr.Message, tt.wantMsg)
+			}
+			if tr.TopFrame != tt.wantFrame {
+				t.Errorf("TopFrame = %q, want %q", tr.TopFrame, tt.wantFrame)
+			}
+			if len(tr.Lines) != tt.wantLines {
+				t.Errorf("trace has %d lines, want %d:\n%s", len(tr.Lines), tt.wantLines, strings.Join(tr.Lines, "\n"))
+			}
+		})
+	}
+}
+
+func TestPatternLibrary_CommonError(t *testing.T) {
+	tests := []struct {
+		line string
+		want []string
+	}{
+		{line: "processed 120 requests, 0 errors", want: nil},
+		{line: "metrics: error_count=0 failures=0", want: nil},
+		{line: "GET /api/errors 200 12ms", want: nil},
+		{line: "2024-05-01 10:00:00 ERROR db connection lost", want: []string{"Common Error"}},
+		{line: `time="2024-05-01T10:00:00Z" level=error msg="db connection lost"`, want: []string{"Common Error"}},
+		{line: `{"level":"error","msg":"db connection lost"}`, want: []string{"Common Error"}},
+		{line: "E0501 10:00:00.123456       1 controller.go:42] sync failed", want: []string{"Common Error"}},
+		{line: "open /data/db: permission denied", want: []string{"Permission Denied"}},
+		// 已经被更具体的模式匹配的行不再计入 Common Error
+		{line: "ERROR open /data/db: permission denied", want: []string{"Permission Denied"}},
+	}
+
+	lib := DefaultPatternLibrary()
+	for _, tt := range tests {
+		keywords, _ := lib.Analyze([]string{tt.line})
+		if !reflect.DeepEqual(keywords, tt.want) {
+			t.Errorf("Analyze(%q) = %v, want %v", tt.line, keywords, tt.want)
+		}
+	}
+}
+
+func TestLoadPatternLibrary(t *testing.T) {
+	dir := t.TempDir()
+	pack := `language: shop
+patterns:
+  - name: Payment Timeout
+    start: 'payment gateway timeout'
+  - name: Permission Denied
+    start: 'EACCES'
+`
+	if err := os.WriteFile(filepath.Join(dir, "shop.yaml"), []byte(pack), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	lib, err := LoadPatternLibrary(dir)
+	if err != nil {
+		t.Fatalf("LoadPatternLibrary failed: %v", err)
+	}
+	keywords, _ := lib.Analyze([]string{
+		"payment gateway timeout after 30s",
+		"open /data: permission denied", // 内置的同名模式被替换
+		"open '/data' failed: EACCES",
+		"panic: boom",
+	})
+	want := []string{"Go Panic", "Payment Timeout", "Permission Denied"}
+	if !reflect.DeepEqual(keywords, want) {
+		t.Errorf("keywords = %v, want %v", keywords, want)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "bad.yaml"), []byte("language: x\npatterns:\n  - name: bad\n    start: '('\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadPatternLibrary(dir); err == nil {
+		t.Error("invalid regexp should fail to load")
+	}
+}
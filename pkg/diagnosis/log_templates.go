@@ -0,0 +1,154 @@
+package diagnosis
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// -----------------------------------------------------------
+// 日志模板聚类 (Drain 算法的简化实现)
+// 将结构相同、只有参数不同的日志行合并为一个模板，例如
+//   "connected to 10.0.0.1:5432 in 3ms" / "connected to 10.0.0.2:5432 in 5ms"
+//   => "connected to <*> in <*>"
+// 重复刷屏的日志被折叠后，崩溃前只出现一两次的罕见日志往往就是线索
+// -----------------------------------------------------------
+
+const (
+	// templateWildcard 模板中的参数占位符
+	templateWildcard = "<*>"
+	// templateSimilarity 日志行与模板相同 token 的比例达到该值时归入该模板
+	templateSimilarity = 0.5
+	// rareTemplateMax 出现次数不超过该值的模板视为罕见
+	rareTemplateMax = 2
+	// rareTemplateRatio 日志总行数至少是模板出现次数的该倍数时才算罕见 (日志很少时不做判断)
+	rareTemplateRatio = 10
+	// crashWindow 距离日志末尾 (崩溃点) 该行数以内的罕见模板视为可疑
+	crashWindow = 10
+)
+
+// LogTemplate 是聚类得到的一个日志模板
+type LogTemplate struct {
+	Template   string `json:"template"`   // 参数被替换为 <*> 的模板
+	Sample     string `json:"sample"`     // 第一条原始日志
+	Count      int    `json:"count"`      // 出现次数
+	FirstLine  int    `json:"first_line"` // 首次出现的行号 (从 0 开始)
+	LastLine   int    `json:"last_line"`  // 最后一次出现的行号
+	Suspicious bool   `json:"suspicious"` // 罕见且出现在崩溃前
+}
+
+// Lines 返回 "12" 或 "3-47" 形式的行号范围 (从 1 开始，便于对照原始日志)
+func (t LogTemplate) Lines() string {
+	if t.FirstLine == t.LastLine {
+		return strconv.Itoa(t.FirstLine + 1)
+	}
+	return fmt.Sprintf("%d-%d", t.FirstLine+1, t.LastLine+1)
+}
+
+// logCluster 是聚类过程中的模板
+type logCluster struct {
+	id     int // 在 clusters 中的下标
+	tokens []string
+	tmpl   LogTemplate
+}
+
+// ClusterLogLines 将日志行聚类为模板，按首次出现排序，并标记崩溃前的罕见模板
+// 空行不参与聚类
+func ClusterLogLines(lines []string) []LogTemplate {
+	templates, _ := clusterLogLines(lines)
+	return templates
+}
+
+// clusterLogLines 聚类并返回每一行所属模板的下标 (空行为 -1)
+func clusterLogLines(lines []string) ([]LogTemplate, []int) {
+	// 按 token 数量与第一个 token 分组 (Drain 前缀树的前两层)，只在组内比较相似度
+	groups := map[string][]*logCluster{}
+	var clusters []*logCluster
+	index := make([]int, len(lines))
+	total := 0
+
+	for i, line := range lines {
+		index[i] = -1
+		tokens := templateTokens(line)
+		if len(tokens) == 0 {
+			continue
+		}
+		total++
+
+		key := strconv.Itoa(len(tokens)) + "|" + tokens[0]
+		best, bestSim := (*logCluster)(nil), 0.0
+		for _, c := range groups[key] {
+			if sim := templateSimilarityOf(c.tokens, tokens); sim > bestSim {
+				best, bestSim = c, sim
+			}
+		}
+
+		if best == nil || bestSim < templateSimilarity {
+			c := &logCluster{
+				id:     len(clusters),
+				tokens: tokens,
+				tmpl:   LogTemplate{Sample: line, Count: 1, FirstLine: i, LastLine: i},
+			}
+			groups[key] = append(groups[key], c)
+			index[i] = len(clusters)
+			clusters = append(clusters, c)
+			continue
+		}
+		for j := range best.tokens {
+			if best.tokens[j] != tokens[j] {
+				best.tokens[j] = templateWildcard
+			}
+		}
+		best.tmpl.Count++
+		best.tmpl.LastLine = i
+		index[i] = best.id
+	}
+
+	templates := make([]LogTemplate, 0, len(clusters))
+	for _, c := range clusters {
+		t := c.tmpl
+		t.Template = strings.Join(c.tokens, " ")
+		t.Suspicious = t.Count <= rareTemplateMax &&
+			t.Count*rareTemplateRatio <= total &&
+			len(lines)-1-t.LastLine < crashWindow
+		templates = append(templates, t)
+	}
+	return templates, index
+}
+
+// SuspiciousTemplates 返回可疑模板，离崩溃点越近越靠前
+func SuspiciousTemplates(templates []LogTemplate) []LogTemplate {
+	var result []LogTemplate
+	for _, t := range templates {
+		if t.Suspicious {
+			result = append(result, t)
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool { return result[i].LastLine > result[j].LastLine })
+	return result
+}
+
+// templateTokens 按空白切分日志行，并将包含数字的 token (时间、ID、IP、耗时等) 预先替换为 <*>
+func templateTokens(line string) []string {
+	tokens := strings.Fields(line)
+	for i, tok := range tokens {
+		if strings.IndexFunc(tok, unicode.IsDigit) >= 0 {
+			tokens[i] = templateWildcard
+		}
+	}
+	return tokens
+}
+
+// templateSimilarityOf 返回相同位置 token 相同的比例
+// 两边都是 <*> (例如都是数字参数) 时计为相同
+func templateSimilarityOf(template, tokens []string) float64 {
+	same := 0
+	for i, tok := range template {
+		if tok == tokens[i] {
+			same++
+		}
+	}
+	return float64(same) / float64(len(tokens))
+}
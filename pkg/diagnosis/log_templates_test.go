@@ -0,0 +1,60 @@
+package diagnosis
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestClusterLogLines(t *testing.T) {
+	var lines []string
+	for i := 0; i < 20; i++ {
+		lines = append(lines, fmt.Sprintf("2024-05-01T10:00:%02dZ INFO handled request id=%d in %dms", i, 1000+i, i*3))
+		if i%5 == 0 {
+			lines = append(lines, fmt.Sprintf("2024-05-01T10:00:%02dZ INFO cache refresh for tenant acme", i))
+		}
+	}
+	lines = append(lines,
+		"",
+		"2024-05-01T10:00:21Z WARN connection pool exhausted, waiting for free connection",
+		"2024-05-01T10:00:22Z INFO handled request id=1020 in 9ms",
+	)
+
+	templates := ClusterLogLines(lines)
+	if len(templates) != 3 {
+		t.Fatalf("templates = %+v, want 3", templates)
+	}
+
+	want := []struct {
+		template   string
+		count      int
+		lines      string
+		suspicious bool
+	}{
+		{"<*> INFO handled request <*> in <*>", 21, "1-27", false},
+		{"<*> INFO cache refresh for tenant acme", 4, "2-20", false},
+		{"<*> WARN connection pool exhausted, waiting for free connection", 1, "26", true},
+	}
+	for i, w := range want {
+		got := templates[i]
+		if got.Template != w.template || got.Count != w.count || got.Lines() != w.lines || got.Suspicious != w.suspicious {
+			t.Errorf("template[%d] = %q x%d lines %s suspicious=%v, want %q x%d lines %s suspicious=%v",
+				i, got.Template, got.Count, got.Lines(), got.Suspicious, w.template, w.count, w.lines, w.suspicious)
+		}
+	}
+
+	suspicious := SuspiciousTemplates(templates)
+	if len(suspicious) != 1 || suspicious[0].Sample != lines[25] {
+		t.Errorf("suspicious = %+v, want the connection pool line", suspicious)
+	}
+}
+
+func TestClusterLogLines_FewLines(t *testing.T) {
+	// 日志很少时每一行都只出现一次，不应全部标记为可疑
+	templates := ClusterLogLines([]string{"starting", "listening on :8080", "shutting down"})
+	if len(templates) != 3 {
+		t.Fatalf("templates = %+v, want 3", templates)
+	}
+	if got := SuspiciousTemplates(templates); len(got) != 0 {
+		t.Errorf("suspicious = %+v, want none", got)
+	}
+}
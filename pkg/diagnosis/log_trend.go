@@ -0,0 +1,262 @@
+package diagnosis
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// -----------------------------------------------------------
+// 日志错误率趋势 (diagnose --deep)
+// 正常运行、从未重启的容器默认不看日志，但 "没崩溃" 不等于 "没问题":
+// 把时间窗口内的日志按时间分段，统计每个模式在各段的命中次数，最近一段突增的容器单独标出
+// -----------------------------------------------------------
+
+const (
+	// DefaultDeepLogWindow 深度模式默认分析的时间窗口
+	DefaultDeepLogWindow = 30 * time.Minute
+	// deepLogTailLines 深度模式至少获取的日志行数 (时间窗口内的日志通常远多于默认的 50 行)
+	deepLogTailLines int64 = 5000
+	// trendBuckets 时间窗口切分的段数
+	trendBuckets = 6
+	// spikeMinCount 最近一段至少命中该次数才判断为突增 (避免 0 → 1 这种噪音)
+	spikeMinCount = 5
+	// spikeRatio 最近一段的命中次数达到之前各段平均值的该倍数时视为突增
+	spikeRatio = 3
+)
+
+// PatternTrend 单个模式在时间窗口内的命中趋势
+type PatternTrend struct {
+	Pattern      string  `json:"pattern"`
+	Counts       []int   `json:"counts"`        // 每一段的命中次数 (从早到晚)
+	Total        int     `json:"total"`         // 窗口内的总次数
+	RecentRate   float64 `json:"recent_rate"`   // 最近一段的每分钟次数
+	BaselineRate float64 `json:"baseline_rate"` // 之前有日志的各段的平均每分钟次数
+	Spike        bool    `json:"spike"`         // 最近一段突增
+}
+
+// LogTrend 一个容器在时间窗口内的日志错误趋势
+type LogTrend struct {
+	Start      time.Time      `json:"start"`
+	End        time.Time      `json:"end"`
+	BucketSize time.Duration  `json:"bucket_size"`
+	Lines      []int          `json:"lines"`                // 每一段的日志行数
+	Patterns   []PatternTrend `json:"patterns,omitempty"`   // 按总次数降序
+	Truncation []string       `json:"truncation,omitempty"` // 日志不完整的原因
+}
+
+// Spikes 返回最近一段突增的模式
+func (t *LogTrend) Spikes() []PatternTrend {
+	var result []PatternTrend
+	for _, p := range t.Patterns {
+		if p.Spike {
+			result = append(result, p)
+		}
+	}
+	return result
+}
+
+// sparkBlocks 迷你趋势图使用的字符，从低到高
+var sparkBlocks = []rune("▁▂▃▄▅▆▇█")
+
+// Sparkline 返回各时间段次数的迷你趋势图，例如 "·▁▁▂▁█"，0 次显示为 "·"
+func (p PatternTrend) Sparkline() string {
+	peak := 0
+	for _, c := range p.Counts {
+		if c > peak {
+			peak = c
+		}
+	}
+	var sb strings.Builder
+	for _, c := range p.Counts {
+		if c == 0 {
+			sb.WriteRune('·')
+			continue
+		}
+		sb.WriteRune(sparkBlocks[(c*len(sparkBlocks)-1)/peak])
+	}
+	return sb.String()
+}
+
+// WithDeepLogs 开启深度模式: 所有容器 (包括正常运行的) 都分析 window 时间窗口内的日志趋势，window 为 0 时关闭
+func (a *Analyzer) WithDeepLogs(window time.Duration) *Analyzer {
+	a.logs.DeepWindow = window
+	return a
+}
+
+// AnalyzeLogTrend 获取当前运行在 window 内的日志 (带时间戳) 并统计趋势
+// 同时返回去掉时间戳的日志行，正常运行的容器直接复用这份日志做常规分析，不再单独抓取
+func AnalyzeLogTrend(ctx context.Context, client kubernetes.Interface, pod *corev1.Pod, containerName string, cfg LogAnalysisConfig, window time.Duration) (*LogTrend, []string, error) {
+	collect := trendCollectOptions(cfg.Collect, window)
+	// 时间窗口是有意设置的，不算作日志不完整
+	lines, truncation, err := fetchContainerLogs(ctx, client, pod, containerName, false, collect)
+	if err != nil {
+		return nil, nil, err
+	}
+	trend := ComputeLogTrend(lines, cfg, time.Now(), window)
+	trend.Truncation = truncation
+
+	text := make([]string, len(lines))
+	for i, line := range lines {
+		_, text[i], _ = SplitLogTimestamp(line)
+	}
+	return trend, text, nil
+}
+
+// trendCollectOptions 返回趋势分析的抓取选项: 带时间戳、按时间窗口、至少 deepLogTailLines 行
+func trendCollectOptions(collect LogCollectOptions, window time.Duration) LogCollectOptions {
+	collect.Timestamps = true
+	collect.SinceSeconds = int64(window.Seconds())
+	if collect.TailLines > 0 && collect.TailLines < deepLogTailLines {
+		collect.TailLines = deepLogTailLines
+	}
+	// 字节上限是从头截取的，会截掉最新的日志，而趋势恰恰要看最新的一段；由行数限制兜底
+	collect.LimitBytes = 0
+	return collect
+}
+
+// recentLogResult 用趋势抓取的日志生成常规的日志分析结果，只分析最后 TailLines 行
+func recentLogResult(lines []string, trend *LogTrend, cfg LogAnalysisConfig) LogAnalysisResult {
+	var truncation []string
+	if tail := cfg.Collect.TailLines; tail > 0 && int64(len(lines)) > tail {
+		lines = lines[int64(len(lines))-tail:]
+		truncation = append(truncation, tailTruncation(tail))
+	}
+	// 趋势抓取的行数上限远大于这里分析的行数，只保留超时等其他原因
+	trendTail := tailTruncation(trendCollectOptions(cfg.Collect, 0).TailLines)
+	for _, reason := range trend.Truncation {
+		if reason != trendTail {
+			truncation = append(truncation, reason)
+		}
+	}
+	result := AnalyzeLogLines(lines, cfg)
+	result.Truncation = truncation
+	result.Truncated = len(truncation) > 0
+	return result
+}
+
+// ComputeLogTrend 统计带时间戳的日志 (kubelet Timestamps 格式: "<RFC3339Nano> <内容>") 在 [end-window, end) 内的趋势
+// 没有时间戳的行 (例如堆栈的续行) 归入上一行所在的时间段
+func ComputeLogTrend(lines []string, cfg LogAnalysisConfig, end time.Time, window time.Duration) *LogTrend {
+	if cfg.Patterns == nil {
+		cfg.Patterns = DefaultPatternLibrary()
+	}
+	trend := &LogTrend{
+		Start:      end.Add(-window),
+		End:        end,
+		BucketSize: window / trendBuckets,
+		Lines:      make([]int, trendBuckets),
+	}
+
+	// 去掉时间戳，并记录每一行所在的时间段
+	text := make([]string, len(lines))
+	bucket := make([]int, len(lines))
+	current := 0
+	for i, line := range lines {
+		ts, rest, ok := SplitLogTimestamp(line)
+		if ok {
+			current = trend.bucketOf(ts)
+		}
+		text[i], bucket[i] = rest, current
+		trend.Lines[current]++
+	}
+	entries := ParseJSONLogs(text, cfg.JSONFields)
+	text = jsonTextLines(text, entries)
+
+	// 统计每个模式在各时间段的命中次数 (JSON 堆栈字段中的异常记在所在行)
+	matches, _ := cfg.Patterns.Match(text)
+	_, jsonTraces := jsonStackTraces(cfg.Patterns, entries)
+	for _, t := range jsonTraces {
+		matches = append(matches, LogMatch{Pattern: t.Pattern, Line: t.StartLine})
+	}
+	counts := map[string][]int{}
+	for _, m := range matches {
+		if counts[m.Pattern] == nil {
+			counts[m.Pattern] = make([]int, trendBuckets)
+		}
+		counts[m.Pattern][bucket[m.Line]]++
+	}
+
+	for name, c := range counts {
+		trend.Patterns = append(trend.Patterns, trend.patternTrend(name, c))
+	}
+	sort.Slice(trend.Patterns, func(i, j int) bool {
+		if trend.Patterns[i].Total != trend.Patterns[j].Total {
+			return trend.Patterns[i].Total > trend.Patterns[j].Total
+		}
+		return trend.Patterns[i].Pattern < trend.Patterns[j].Pattern
+	})
+	return trend
+}
+
+// patternTrend 计算单个模式的速率并判断最近一段是否突增
+// 基线只取有日志的时间段 (容器启动不久时窗口前段没有日志，不能算作 0)
+func (t *LogTrend) patternTrend(name string, counts []int) PatternTrend {
+	p := PatternTrend{Pattern: name, Counts: counts}
+	for _, c := range counts {
+		p.Total += c
+	}
+	minutes := t.BucketSize.Minutes()
+	last := len(counts) - 1
+	recent := counts[last]
+	p.RecentRate = float64(recent) / minutes
+
+	baseline, active := 0, 0
+	for i := 0; i < last; i++ {
+		if t.Lines[i] > 0 {
+			baseline += counts[i]
+			active++
+		}
+	}
+	if active == 0 {
+		return p
+	}
+	avg := float64(baseline) / float64(active)
+	p.BaselineRate = avg / minutes
+	p.Spike = recent >= spikeMinCount && float64(recent) >= spikeRatio*avg
+	return p
+}
+
+// bucketOf 返回时间点所在的时间段，超出窗口的归入首段 / 末段
+func (t *LogTrend) bucketOf(ts time.Time) int {
+	if t.BucketSize <= 0 {
+		return trendBuckets - 1
+	}
+	i := int(ts.Sub(t.Start) / t.BucketSize)
+	if i < 0 {
+		return 0
+	}
+	if i >= trendBuckets {
+		return trendBuckets - 1
+	}
+	return i
+}
+
+// SplitLogTimestamp 拆分 kubelet 加在行首的时间戳 (PodLogOptions.Timestamps 为 true 时)，没有时间戳时 ok 为 false
+func SplitLogTimestamp(line string) (time.Time, string, bool) {
+	stamp, rest, found := strings.Cut(line, " ")
+	if !found {
+		stamp, rest = line, ""
+	}
+	ts, err := time.Parse(time.RFC3339Nano, stamp)
+	if err != nil {
+		return time.Time{}, line, false
+	}
+	return ts, rest, true
+}
+
+// SpikeIssue 为突增的模式生成一条警告
+func (p PatternTrend) SpikeIssue(bucket time.Duration) Issue {
+	return Issue{
+		Type:  "Warning",
+		Title: fmt.Sprintf("日志错误率近期突增: %s", p.Pattern),
+		RawError: fmt.Sprintf("最近 %s 每分钟 %.2f 次，之前平均每分钟 %.2f 次",
+			formatDuration(bucket), p.RecentRate, p.BaselineRate),
+		Suggestion: "容器仍在运行但错误在增多，请结合下方日志趋势与最近的变更 (发布、配置、依赖服务) 排查",
+	}
+}
@@ -0,0 +1,129 @@
+package diagnosis
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestComputeLogTrend(t *testing.T) {
+	end := time.Date(2024, 5, 1, 11, 0, 0, 0, time.UTC)
+	at := func(minutesAgo float64, text string) string {
+		ts := end.Add(-time.Duration(minutesAgo * float64(time.Minute)))
+		return ts.Format(time.RFC3339Nano) + " " + text
+	}
+
+	// 30 分钟窗口，每段 5 分钟: 前 5 段每段 1 次 ERROR、2 次权限错误，最近一段 ERROR 突增到 8 次
+	var lines []string
+	for bucket := 0; bucket < 5; bucket++ {
+		minutesAgo := float64(29 - bucket*5)
+		lines = append(lines,
+			at(minutesAgo, "INFO handled request"),
+			at(minutesAgo, "ERROR upstream timeout"),
+			at(minutesAgo, "open /data/cache: permission denied"),
+			at(minutesAgo, "open /data/cache: permission denied"),
+		)
+	}
+	for i := 0; i < 8; i++ {
+		lines = append(lines, at(4-float64(i)*0.5, fmt.Sprintf("ERROR upstream timeout #%d", i)))
+	}
+	lines = append(lines,
+		at(1, "open /data/cache: permission denied"),
+		at(1, "open /data/cache: permission denied"),
+		at(0.5, `{"level":"error","msg":"order failed","stacktrace":"main.placeOrder\n\t/app/order.go:42"}`),
+		"\tcontinuation without timestamp",
+	)
+
+	trend := ComputeLogTrend(lines, DefaultLogAnalysisConfig(), end, 30*time.Minute)
+
+	if trend.BucketSize != 5*time.Minute || !reflect.DeepEqual(trend.Lines, []int{4, 4, 4, 4, 4, 12}) {
+		t.Fatalf("bucket = %s, lines = %v", trend.BucketSize, trend.Lines)
+	}
+	got := map[string]PatternTrend{}
+	for _, p := range trend.Patterns {
+		got[p.Pattern] = p
+	}
+
+	// JSON 的 error 行同样计入 Common Error
+	common := got["Common Error"]
+	if !reflect.DeepEqual(common.Counts, []int{1, 1, 1, 1, 1, 9}) || !common.Spike {
+		t.Errorf("Common Error = %+v, want spike", common)
+	}
+	if common.RecentRate != 1.8 || common.BaselineRate != 0.2 {
+		t.Errorf("rates = %.2f / %.2f", common.RecentRate, common.BaselineRate)
+	}
+	if denied := got["Permission Denied"]; denied.Total != 12 || denied.Spike {
+		t.Errorf("Permission Denied = %+v, want stable", denied)
+	}
+	if js := got["JSON Stacktrace"]; !reflect.DeepEqual(js.Counts, []int{0, 0, 0, 0, 0, 1}) || js.Spike {
+		t.Errorf("JSON Stacktrace = %+v", js)
+	}
+	if trend.Patterns[0].Pattern != "Common Error" {
+		t.Errorf("patterns should be sorted by total: %+v", trend.Patterns)
+	}
+	if spark := common.Sparkline(); spark != "▁▁▁▁▁█" {
+		t.Errorf("sparkline = %q", spark)
+	}
+	if spikes := trend.Spikes(); len(spikes) != 1 {
+		t.Errorf("spikes = %+v", spikes)
+	}
+}
+
+func TestComputeLogTrend_YoungContainer(t *testing.T) {
+	// 容器刚启动，窗口前段没有日志: 没有基线，不判断突增
+	end := time.Date(2024, 5, 1, 11, 0, 0, 0, time.UTC)
+	var lines []string
+	for i := 0; i < 10; i++ {
+		lines = append(lines, end.Add(-time.Minute).Format(time.RFC3339Nano)+" ERROR boom")
+	}
+	trend := ComputeLogTrend(lines, DefaultLogAnalysisConfig(), end, 30*time.Minute)
+	if len(trend.Patterns) != 1 || trend.Patterns[0].Spike || trend.Patterns[0].Total != 10 {
+		t.Errorf("patterns = %+v", trend.Patterns)
+	}
+}
+
+func TestRecentLogResult(t *testing.T) {
+	cfg := LogAnalysisConfig{Collect: LogCollectOptions{TailLines: 3}}
+	trendTail := tailTruncation(trendCollectOptions(cfg.Collect, 0).TailLines)
+
+	tests := []struct {
+		name           string
+		lines          []string
+		trendReasons   []string
+		wantLogs       []string
+		wantTruncation []string
+	}{
+		{
+			name:     "行数没有超出",
+			lines:    []string{"a", "b"},
+			wantLogs: []string{"a", "b"},
+		},
+		{
+			name:           "只分析最后 TailLines 行，不沿用趋势的行数上限",
+			lines:          []string{"a", "b", "c", "d", "ERROR e"},
+			trendReasons:   []string{trendTail},
+			wantLogs:       []string{"c", "d", "ERROR e"},
+			wantTruncation: []string{"只获取了最后 3 行"},
+		},
+		{
+			name:           "保留趋势抓取的超时原因",
+			lines:          []string{"a"},
+			trendReasons:   []string{trendTail, "抓取超时"},
+			wantLogs:       []string{"a"},
+			wantTruncation: []string{"抓取超时"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := recentLogResult(tt.lines, &LogTrend{Truncation: tt.trendReasons}, cfg)
+			if !reflect.DeepEqual(got.Logs, tt.wantLogs) {
+				t.Errorf("logs = %q, want %q", got.Logs, tt.wantLogs)
+			}
+			if !reflect.DeepEqual(got.Truncation, tt.wantTruncation) || got.Truncated != (len(tt.wantTruncation) > 0) {
+				t.Errorf("truncation = %q (%v), want %q", got.Truncation, got.Truncated, tt.wantTruncation)
+			}
+		})
+	}
+}
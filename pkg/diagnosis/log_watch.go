@@ -0,0 +1,116 @@
+package diagnosis
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// -----------------------------------------------------------
+// 实时日志的严重模式检测 (monitor 模式)
+// 按阶段 / 重启次数触发诊断时容器已经崩溃了；跟随日志流时一旦出现 panic、OOM 等
+// 必然导致崩溃的日志，就可以立即诊断，并附上命中的日志行
+// -----------------------------------------------------------
+
+// criticalContextLines 命中时附带的前文行数
+const criticalContextLines = 5
+
+// CriticalLogPattern 是一条严重日志模式
+// Threshold 为 1 时命中即触发；大于 1 时表示 Window 时间内命中 Threshold 次才触发 (例如连接被拒绝风暴)
+type CriticalLogPattern struct {
+	Name      string
+	Pattern   *regexp.Regexp
+	Threshold int
+	Window    time.Duration
+}
+
+// DefaultCriticalLogPatterns 内置的严重日志模式
+var DefaultCriticalLogPatterns = []CriticalLogPattern{
+	{Name: "Go Panic", Pattern: regexp.MustCompile(`^(?:panic: |fatal error: )`), Threshold: 1},
+	{Name: "Java OutOfMemoryError", Pattern: regexp.MustCompile(`java\.lang\.OutOfMemoryError`), Threshold: 1},
+	{Name: "Connection Refused Storm", Pattern: regexp.MustCompile(`(?i)connection refused|ECONNREFUSED`), Threshold: 10, Window: 30 * time.Second},
+}
+
+// LogAlert 是实时日志触发的告警，附在诊断结果中
+type LogAlert struct {
+	Container string    `json:"container"`
+	Pattern   string    `json:"pattern"` // 命中的严重模式
+	Lines     []string  `json:"lines"`   // 命中的日志行 (单次命中时包含前文)
+	Time      time.Time `json:"time"`
+}
+
+// CriticalLogDetector 逐行检测一个日志流中的严重模式，不能并发使用
+type CriticalLogDetector struct {
+	patterns []CriticalLogPattern
+	recent   []string               // 最近几行，作为命中时的前文
+	hits     map[string][]timedLine // 需要累计的模式在窗口内的命中
+}
+
+type timedLine struct {
+	line string
+	at   time.Time
+}
+
+// NewCriticalLogDetector 创建检测器，patterns 为空时使用内置模式
+func NewCriticalLogDetector(patterns []CriticalLogPattern) *CriticalLogDetector {
+	if len(patterns) == 0 {
+		patterns = DefaultCriticalLogPatterns
+	}
+	return &CriticalLogDetector{patterns: patterns, hits: map[string][]timedLine{}}
+}
+
+// Feed 输入一行日志，触发严重模式时返回告警 (Container 由调用方填写)
+func (d *CriticalLogDetector) Feed(line string, at time.Time) *LogAlert {
+	defer d.remember(line)
+
+	for _, p := range d.patterns {
+		if !p.Pattern.MatchString(line) {
+			continue
+		}
+		if p.Threshold <= 1 {
+			lines := append(append([]string(nil), d.recent...), line)
+			return &LogAlert{Pattern: p.Name, Lines: lines, Time: at}
+		}
+
+		// 只保留窗口内的命中
+		hits := append(d.hits[p.Name], timedLine{line: line, at: at})
+		for len(hits) > 0 && at.Sub(hits[0].at) > p.Window {
+			hits = hits[1:]
+		}
+		if len(hits) < p.Threshold {
+			d.hits[p.Name] = hits
+			continue
+		}
+		delete(d.hits, p.Name)
+		lines := make([]string, len(hits))
+		for i, h := range hits {
+			lines[i] = h.line
+		}
+		return &LogAlert{
+			Pattern: fmt.Sprintf("%s (%s 内 %d 次)", p.Name, p.Window, len(hits)),
+			Lines:   lines,
+			Time:    at,
+		}
+	}
+	return nil
+}
+
+func (d *CriticalLogDetector) remember(line string) {
+	d.recent = append(d.recent, line)
+	if len(d.recent) > criticalContextLines {
+		d.recent = d.recent[1:]
+	}
+}
+
+// AttachLogAlert 将实时日志告警附到诊断结果中，并作为第一条 Pod 级问题
+func (r *DiagnosisResult) AttachLogAlert(alert LogAlert) {
+	r.LogAlert = &alert
+	issue := Issue{
+		Type:       "Error",
+		Title:      fmt.Sprintf("实时日志命中严重模式: %s (容器 %s)", alert.Pattern, alert.Container),
+		RawError:   strings.TrimSpace(alert.Lines[len(alert.Lines)-1]),
+		Suggestion: "容器可能即将崩溃，请结合报告中附带的日志行排查",
+	}
+	r.Issues = append([]Issue{issue}, r.Issues...)
+}
@@ -0,0 +1,92 @@
+package diagnosis
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCriticalLogDetector(t *testing.T) {
+	start := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	refused := func(i int) string {
+		return fmt.Sprintf("dial tcp 10.0.0.%d:5432: connect: connection refused", i)
+	}
+
+	tests := []struct {
+		name        string
+		lines       []string
+		interval    time.Duration // 相邻两行之间的时间间隔
+		wantPattern string
+		wantLines   int
+	}{
+		{
+			name:        "Go panic 立即触发并带上前文",
+			lines:       []string{"a", "b", "c", "d", "e", "f", "panic: runtime error: invalid memory address or nil pointer dereference"},
+			wantPattern: "Go Panic",
+			wantLines:   criticalContextLines + 1,
+		},
+		{
+			name:        "Java OOM",
+			lines:       []string{`Exception in thread "main" java.lang.OutOfMemoryError: Java heap space`},
+			wantPattern: "Java OutOfMemoryError",
+			wantLines:   1,
+		},
+		{
+			name:        "连接被拒绝风暴",
+			lines:       []string{refused(1), refused(2), refused(3), refused(4), refused(5), refused(6), refused(7), refused(8), refused(9), refused(10)},
+			interval:    time.Second,
+			wantPattern: "Connection Refused Storm (30s 内 10 次)",
+			wantLines:   10,
+		},
+		{
+			name:     "窗口外的零星拒绝不触发",
+			lines:    []string{refused(1), refused(2), refused(3), refused(4), refused(5), refused(6), refused(7), refused(8), refused(9), refused(10)},
+			interval: 10 * time.Second,
+		},
+		{
+			name:  "普通日志不触发",
+			lines: []string{"panic handler installed", "recovered from panic: retrying"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := NewCriticalLogDetector(nil)
+			var alerts []*LogAlert
+			for i, line := range tt.lines {
+				if alert := d.Feed(line, start.Add(time.Duration(i)*tt.interval)); alert != nil {
+					alerts = append(alerts, alert)
+				}
+			}
+
+			if tt.wantPattern == "" {
+				if len(alerts) != 0 {
+					t.Errorf("unexpected alerts: %+v", alerts)
+				}
+				return
+			}
+			if len(alerts) != 1 {
+				t.Fatalf("alerts = %+v, want 1", alerts)
+			}
+			if alerts[0].Pattern != tt.wantPattern || len(alerts[0].Lines) != tt.wantLines {
+				t.Errorf("alert = %s (%d lines), want %s (%d lines)", alerts[0].Pattern, len(alerts[0].Lines), tt.wantPattern, tt.wantLines)
+			}
+			if last := alerts[0].Lines[len(alerts[0].Lines)-1]; last != tt.lines[len(tt.lines)-1] {
+				t.Errorf("last line = %q", last)
+			}
+		})
+	}
+}
+
+func TestDiagnosisResult_AttachLogAlert(t *testing.T) {
+	result := DiagnosisResult{Issues: []Issue{{Title: "existing"}}}
+	result.AttachLogAlert(LogAlert{Container: "app", Pattern: "Go Panic", Lines: []string{"started", "panic: boom"}})
+
+	if result.LogAlert == nil || !reflect.DeepEqual(result.LogAlert.Lines, []string{"started", "panic: boom"}) {
+		t.Fatalf("alert = %+v", result.LogAlert)
+	}
+	if len(result.Issues) != 2 || result.Issues[0].Title != "实时日志命中严重模式: Go Panic (容器 app)" || result.Issues[0].RawError != "panic: boom" {
+		t.Errorf("issues = %+v", result.Issues)
+	}
+}
@@ -0,0 +1,388 @@
+package diagnosis
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// -----------------------------------------------------------
+// NetworkPolicy 可达性分析
+// 以下函数均为纯函数，只依赖传入的对象，方便单元测试
+// -----------------------------------------------------------
+
+// EffectivePolicy 汇总了所有选中某个 Pod 的 NetworkPolicy
+type EffectivePolicy struct {
+	Policies        []string      `json:"policies"`         // 选中该 Pod 的策略名称
+	IngressIsolated bool          `json:"ingress_isolated"` // 入站是否被隔离 (存在 Ingress 类型的策略)
+	EgressIsolated  bool          `json:"egress_isolated"`  // 出站是否被隔离 (存在 Egress 类型的策略)
+	Ingress         []PolicyAllow `json:"ingress"`          // 入站放行规则 (各策略的并集)
+	Egress          []PolicyAllow `json:"egress"`           // 出站放行规则 (各策略的并集)
+}
+
+// PolicyAllow 是一条可读化的放行规则
+type PolicyAllow struct {
+	Policy string   `json:"policy"` // 来源策略
+	Peers  []string `json:"peers"`  // 对端 (from / to)
+	Ports  []string `json:"ports"`  // 端口
+}
+
+// ReachabilityResult 是 "Pod A 能否访问 Pod B 的端口 P" 的判定结果
+type ReachabilityResult struct {
+	Allowed        bool            `json:"allowed"`
+	EgressAllowed  bool            `json:"egress_allowed"`
+	EgressReason   string          `json:"egress_reason"`
+	IngressAllowed bool            `json:"ingress_allowed"`
+	IngressReason  string          `json:"ingress_reason"`
+	Source         EffectivePolicy `json:"source"`      // 源 Pod 的有效策略
+	Destination    EffectivePolicy `json:"destination"` // 目标 Pod 的有效策略
+}
+
+// PoliciesSelectingPod 返回所有选中该 Pod 的 NetworkPolicy (必须同一命名空间)
+func PoliciesSelectingPod(pod *corev1.Pod, policies []networkingv1.NetworkPolicy) []networkingv1.NetworkPolicy {
+	var result []networkingv1.NetworkPolicy
+	for _, p := range policies {
+		if p.Namespace != pod.Namespace {
+			continue
+		}
+		if selectorMatches(&p.Spec.PodSelector, pod.Labels) {
+			result = append(result, p)
+		}
+	}
+	return result
+}
+
+// ComputeEffectivePolicy 计算 Pod 的有效入站/出站放行规则
+func ComputeEffectivePolicy(pod *corev1.Pod, policies []networkingv1.NetworkPolicy) EffectivePolicy {
+	eff := EffectivePolicy{
+		Policies: []string{},
+		Ingress:  []PolicyAllow{},
+		Egress:   []PolicyAllow{},
+	}
+
+	for _, p := range PoliciesSelectingPod(pod, policies) {
+		eff.Policies = append(eff.Policies, p.Name)
+		ingress, egress := policyTypes(p)
+
+		if ingress {
+			eff.IngressIsolated = true
+			for _, rule := range p.Spec.Ingress {
+				eff.Ingress = append(eff.Ingress, PolicyAllow{
+					Policy: p.Name,
+					Peers:  describePeers(rule.From),
+					Ports:  describePorts(rule.Ports),
+				})
+			}
+		}
+		if egress {
+			eff.EgressIsolated = true
+			for _, rule := range p.Spec.Egress {
+				eff.Egress = append(eff.Egress, PolicyAllow{
+					Policy: p.Name,
+					Peers:  describePeers(rule.To),
+					Ports:  describePorts(rule.Ports),
+				})
+			}
+		}
+	}
+	return eff
+}
+
+// CanReach 判断 src Pod 能否访问 dst Pod 的 port/protocol
+// namespaces 用于解析 namespaceSelector (key 为命名空间名称)
+func CanReach(src, dst *corev1.Pod, port int32, protocol corev1.Protocol, policies []networkingv1.NetworkPolicy, namespaces map[string]*corev1.Namespace) ReachabilityResult {
+	if protocol == "" {
+		protocol = corev1.ProtocolTCP
+	}
+
+	result := ReachabilityResult{
+		Source:      ComputeEffectivePolicy(src, policies),
+		Destination: ComputeEffectivePolicy(dst, policies),
+	}
+
+	// 1. 源 Pod 的出站 (Egress) 检查
+	result.EgressAllowed, result.EgressReason = evaluateDirection(src, dst, port, protocol, policies, namespaces, networkingv1.PolicyTypeEgress)
+
+	// 2. 目标 Pod 的入站 (Ingress) 检查
+	result.IngressAllowed, result.IngressReason = evaluateDirection(dst, src, port, protocol, policies, namespaces, networkingv1.PolicyTypeIngress)
+
+	result.Allowed = result.EgressAllowed && result.IngressAllowed
+	return result
+}
+
+// evaluateDirection 判断 subject Pod 在指定方向上是否放行与 peer Pod 的流量
+// Egress: subject=源, peer=目标；Ingress: subject=目标, peer=源
+// 命名端口总是在目标 Pod 上解析
+func evaluateDirection(subject, peer *corev1.Pod, port int32, protocol corev1.Protocol, policies []networkingv1.NetworkPolicy, namespaces map[string]*corev1.Namespace, direction networkingv1.PolicyType) (bool, string) {
+	dst := peer
+	if direction == networkingv1.PolicyTypeIngress {
+		dst = subject
+	}
+
+	isolated := false
+	for _, p := range PoliciesSelectingPod(subject, policies) {
+		ingress, egress := policyTypes(p)
+		if direction == networkingv1.PolicyTypeIngress && ingress {
+			isolated = true
+			for _, rule := range p.Spec.Ingress {
+				if peersMatch(rule.From, p.Namespace, peer, namespaces) && portsMatch(rule.Ports, port, protocol, dst) {
+					return true, fmt.Sprintf("被策略 %s 的 Ingress 规则放行", p.Name)
+				}
+			}
+		}
+		if direction == networkingv1.PolicyTypeEgress && egress {
+			isolated = true
+			for _, rule := range p.Spec.Egress {
+				if peersMatch(rule.To, p.Namespace, peer, namespaces) && portsMatch(rule.Ports, port, protocol, dst) {
+					return true, fmt.Sprintf("被策略 %s 的 Egress 规则放行", p.Name)
+				}
+			}
+		}
+	}
+
+	if !isolated {
+		return true, fmt.Sprintf("没有 %s 类型的策略选中该 Pod，默认放行", direction)
+	}
+	return false, fmt.Sprintf("Pod 已被 %s 策略隔离，但没有任何规则放行该流量", direction)
+}
+
+// policyTypes 返回策略作用的方向
+// 未显式指定 policyTypes 时：总是包含 Ingress，存在 egress 规则时才包含 Egress
+func policyTypes(p networkingv1.NetworkPolicy) (ingress, egress bool) {
+	if len(p.Spec.PolicyTypes) == 0 {
+		return true, len(p.Spec.Egress) > 0
+	}
+	for _, t := range p.Spec.PolicyTypes {
+		switch t {
+		case networkingv1.PolicyTypeIngress:
+			ingress = true
+		case networkingv1.PolicyTypeEgress:
+			egress = true
+		}
+	}
+	return ingress, egress
+}
+
+// peersMatch 判断 peer Pod 是否命中规则中的任意一个对端
+// 对端列表为空表示匹配所有来源/目标
+func peersMatch(peers []networkingv1.NetworkPolicyPeer, policyNamespace string, pod *corev1.Pod, namespaces map[string]*corev1.Namespace) bool {
+	if len(peers) == 0 {
+		return true
+	}
+	for _, peer := range peers {
+		if peerMatches(peer, policyNamespace, pod, namespaces) {
+			return true
+		}
+	}
+	return false
+}
+
+func peerMatches(peer networkingv1.NetworkPolicyPeer, policyNamespace string, pod *corev1.Pod, namespaces map[string]*corev1.Namespace) bool {
+	if peer.IPBlock != nil {
+		return ipBlockContains(peer.IPBlock, pod.Status.PodIP)
+	}
+
+	// 先判断命名空间
+	if peer.NamespaceSelector != nil {
+		if !selectorMatches(peer.NamespaceSelector, namespaceLabels(pod.Namespace, namespaces)) {
+			return false
+		}
+	} else if pod.Namespace != policyNamespace {
+		// 只有 podSelector 时，只匹配策略所在命名空间的 Pod
+		return false
+	}
+
+	if peer.PodSelector != nil {
+		return selectorMatches(peer.PodSelector, pod.Labels)
+	}
+	return true
+}
+
+// portsMatch 判断端口是否命中规则中的任意一个端口
+// 端口列表为空表示匹配所有端口
+func portsMatch(ports []networkingv1.NetworkPolicyPort, port int32, protocol corev1.Protocol, dst *corev1.Pod) bool {
+	if len(ports) == 0 {
+		return true
+	}
+	for _, p := range ports {
+		pProtocol := corev1.ProtocolTCP
+		if p.Protocol != nil {
+			pProtocol = *p.Protocol
+		}
+		if pProtocol != protocol {
+			continue
+		}
+		if p.Port == nil {
+			return true
+		}
+		if p.Port.Type == intstr.String { // 命名端口，在目标 Pod 上解析
+			if resolveNamedPort(dst, p.Port.StrVal, protocol) == port {
+				return true
+			}
+			continue
+		}
+		start := p.Port.IntVal
+		end := start
+		if p.EndPort != nil {
+			end = *p.EndPort
+		}
+		if port >= start && port <= end {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveNamedPort 在 Pod 的容器端口中查找命名端口，找不到返回 0
+func resolveNamedPort(pod *corev1.Pod, name string, protocol corev1.Protocol) int32 {
+	for _, c := range pod.Spec.Containers {
+		for _, cp := range c.Ports {
+			cpProtocol := cp.Protocol
+			if cpProtocol == "" {
+				cpProtocol = corev1.ProtocolTCP
+			}
+			if cp.Name == name && cpProtocol == protocol {
+				return cp.ContainerPort
+			}
+		}
+	}
+	return 0
+}
+
+// ipBlockContains 判断 IP 是否落在 ipBlock 中 (且不在 except 中)
+func ipBlockContains(block *networkingv1.IPBlock, ip string) bool {
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return false
+	}
+	_, cidr, err := net.ParseCIDR(block.CIDR)
+	if err != nil || !cidr.Contains(addr) {
+		return false
+	}
+	for _, except := range block.Except {
+		if _, ex, err := net.ParseCIDR(except); err == nil && ex.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// selectorMatches 判断标签是否满足 LabelSelector，非法的选择器视为不匹配
+func selectorMatches(selector *metav1.LabelSelector, set map[string]string) bool {
+	s, err := metav1.LabelSelectorAsSelector(selector)
+	if err != nil {
+		return false
+	}
+	return s.Matches(labels.Set(set))
+}
+
+// namespaceLabels 返回命名空间的标签
+// 与 API Server 行为一致，总是带上 kubernetes.io/metadata.name 标签
+func namespaceLabels(name string, namespaces map[string]*corev1.Namespace) map[string]string {
+	result := map[string]string{corev1.LabelMetadataName: name}
+	if ns, ok := namespaces[name]; ok && ns != nil {
+		for k, v := range ns.Labels {
+			result[k] = v
+		}
+	}
+	return result
+}
+
+// describePeers 将对端列表格式化为可读字符串
+func describePeers(peers []networkingv1.NetworkPolicyPeer) []string {
+	if len(peers) == 0 {
+		return []string{"任意对端"}
+	}
+	var result []string
+	for _, peer := range peers {
+		if peer.IPBlock != nil {
+			desc := fmt.Sprintf("ipBlock %s", peer.IPBlock.CIDR)
+			if len(peer.IPBlock.Except) > 0 {
+				desc += fmt.Sprintf(" (except %s)", strings.Join(peer.IPBlock.Except, ","))
+			}
+			result = append(result, desc)
+			continue
+		}
+
+		var parts []string
+		if peer.PodSelector != nil {
+			parts = append(parts, fmt.Sprintf("pods{%s}", metav1.FormatLabelSelector(peer.PodSelector)))
+		}
+		if peer.NamespaceSelector != nil {
+			parts = append(parts, fmt.Sprintf("namespaces{%s}", metav1.FormatLabelSelector(peer.NamespaceSelector)))
+		} else {
+			parts = append(parts, "(同命名空间)")
+		}
+		result = append(result, strings.Join(parts, " in "))
+	}
+	return result
+}
+
+// describePorts 将端口列表格式化为可读字符串
+func describePorts(ports []networkingv1.NetworkPolicyPort) []string {
+	if len(ports) == 0 {
+		return []string{"任意端口"}
+	}
+	var result []string
+	for _, p := range ports {
+		protocol := corev1.ProtocolTCP
+		if p.Protocol != nil {
+			protocol = *p.Protocol
+		}
+		switch {
+		case p.Port == nil:
+			result = append(result, fmt.Sprintf("%s/*", protocol))
+		case p.EndPort != nil:
+			result = append(result, fmt.Sprintf("%s/%s-%d", protocol, p.Port.String(), *p.EndPort))
+		default:
+			result = append(result, fmt.Sprintf("%s/%s", protocol, p.Port.String()))
+		}
+	}
+	return result
+}
+
+// -----------------------------------------------------------
+// 集群数据获取
+// -----------------------------------------------------------
+
+// CheckReachability 从集群读取 NetworkPolicy 与 Namespace，判断 src 能否访问 dst 的端口
+func (a *Analyzer) CheckReachability(src, dst *corev1.Pod, port int32, protocol corev1.Protocol) (ReachabilityResult, error) {
+	var policies []networkingv1.NetworkPolicy
+	namespaces := map[string]*corev1.Namespace{}
+
+	for _, ns := range uniqueStrings(src.Namespace, dst.Namespace) {
+		list, err := a.client.NetworkingV1().NetworkPolicies(ns).List(a.ctx, metav1.ListOptions{})
+		if err != nil {
+			return ReachabilityResult{}, fmt.Errorf("获取 %s 的 NetworkPolicy 失败: %w", ns, err)
+		}
+		policies = append(policies, list.Items...)
+	}
+
+	// namespaceSelector 需要命名空间的标签；没有权限时退化为只使用 kubernetes.io/metadata.name
+	if nsList, err := a.client.CoreV1().Namespaces().List(a.ctx, metav1.ListOptions{}); err == nil {
+		for i := range nsList.Items {
+			namespaces[nsList.Items[i].Name] = &nsList.Items[i]
+		}
+	}
+
+	return CanReach(src, dst, port, protocol, policies, namespaces), nil
+}
+
+// uniqueStrings 去重并保持顺序
+func uniqueStrings(values ...string) []string {
+	seen := map[string]bool{}
+	var result []string
+	for _, v := range values {
+		if !seen[v] {
+			seen[v] = true
+			result = append(result, v)
+		}
+	}
+	return result
+}
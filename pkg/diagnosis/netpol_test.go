@@ -0,0 +1,121 @@
+package diagnosis
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func newNetPod(ns, name, ip string, labels map[string]string) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: ns, Labels: labels},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{
+				Name:  "app",
+				Ports: []corev1.ContainerPort{{Name: "http", ContainerPort: 8080}},
+			}},
+		},
+		Status: corev1.PodStatus{PodIP: ip},
+	}
+}
+
+func TestCanReach(t *testing.T) {
+	frontend := newNetPod("shop", "frontend", "10.0.0.10", map[string]string{"app": "frontend"})
+	api := newNetPod("shop", "api", "10.0.0.20", map[string]string{"app": "api"})
+	monitoring := newNetPod("monitoring", "prom", "10.0.1.5", map[string]string{"app": "prom"})
+
+	tcp := corev1.ProtocolTCP
+	httpPort := intstr.FromString("http")
+
+	// api 只允许 frontend 访问命名端口 http
+	allowFrontend := networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "api-allow-frontend", Namespace: "shop"},
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{MatchLabels: map[string]string{"app": "api"}},
+			Ingress: []networkingv1.NetworkPolicyIngressRule{{
+				From:  []networkingv1.NetworkPolicyPeer{{PodSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "frontend"}}}},
+				Ports: []networkingv1.NetworkPolicyPort{{Protocol: &tcp, Port: &httpPort}},
+			}},
+		},
+	}
+	// 命名空间内默认拒绝所有出站
+	denyEgress := networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "default-deny-egress", Namespace: "shop"},
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{},
+			PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeEgress},
+		},
+	}
+	// 允许来自 monitoring 命名空间的访问
+	allowMonitoring := networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "api-allow-monitoring", Namespace: "shop"},
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{MatchLabels: map[string]string{"app": "api"}},
+			Ingress: []networkingv1.NetworkPolicyIngressRule{{
+				From: []networkingv1.NetworkPolicyPeer{{NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"team": "sre"}}}},
+			}},
+		},
+	}
+
+	namespaces := map[string]*corev1.Namespace{
+		"monitoring": {ObjectMeta: metav1.ObjectMeta{Name: "monitoring", Labels: map[string]string{"team": "sre"}}},
+	}
+
+	tests := []struct {
+		name        string
+		src, dst    *corev1.Pod
+		port        int32
+		policies    []networkingv1.NetworkPolicy
+		wantAllowed bool
+		wantEgress  bool
+		wantIngress bool
+	}{
+		{"Case 1: 没有任何策略，默认放行", frontend, api, 8080, nil, true, true, true},
+		{"Case 2: 命名端口放行", frontend, api, 8080, []networkingv1.NetworkPolicy{allowFrontend}, true, true, true},
+		{"Case 3: 端口不在放行列表中", frontend, api, 9090, []networkingv1.NetworkPolicy{allowFrontend}, false, true, false},
+		{"Case 4: 来源 Pod 不匹配", api, api, 8080, []networkingv1.NetworkPolicy{allowFrontend}, false, true, false},
+		{"Case 5: 出站被默认拒绝", frontend, api, 8080, []networkingv1.NetworkPolicy{allowFrontend, denyEgress}, false, false, true},
+		{"Case 6: namespaceSelector 放行跨命名空间访问", monitoring, api, 9090, []networkingv1.NetworkPolicy{allowFrontend, allowMonitoring}, true, true, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := CanReach(tt.src, tt.dst, tt.port, corev1.ProtocolTCP, tt.policies, namespaces)
+			if res.Allowed != tt.wantAllowed || res.EgressAllowed != tt.wantEgress || res.IngressAllowed != tt.wantIngress {
+				t.Errorf("CanReach() = allowed:%v egress:%v ingress:%v, want %v/%v/%v (%s | %s)",
+					res.Allowed, res.EgressAllowed, res.IngressAllowed,
+					tt.wantAllowed, tt.wantEgress, tt.wantIngress,
+					res.EgressReason, res.IngressReason)
+			}
+		})
+	}
+}
+
+func TestAnalyzer_CheckReachability(t *testing.T) {
+	src := newNetPod("default", "client", "10.0.0.1", map[string]string{"app": "client"})
+	dst := newNetPod("default", "server", "10.0.0.2", map[string]string{"app": "server"})
+
+	// server 被一个没有任何 ingress 规则的策略隔离
+	denyAll := &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "deny-all", Namespace: "default"},
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{MatchLabels: map[string]string{"app": "server"}},
+		},
+	}
+
+	fakeClient := fake.NewSimpleClientset(src, dst, denyAll)
+	res, err := NewAnalyzer(fakeClient).CheckReachability(src, dst, 8080, corev1.ProtocolTCP)
+	if err != nil {
+		t.Fatalf("CheckReachability() error = %v", err)
+	}
+	if res.Allowed {
+		t.Error("expected traffic to be blocked by deny-all policy")
+	}
+	if len(res.Destination.Policies) != 1 || res.Destination.Policies[0] != "deny-all" {
+		t.Errorf("destination policies = %v, want [deny-all]", res.Destination.Policies)
+	}
+}
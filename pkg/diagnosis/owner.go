@@ -0,0 +1,36 @@
+package diagnosis
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// WorkloadRef 标识 Pod 所属的顶层工作负载 (例如 Deployment/web)
+type WorkloadRef struct {
+	Kind string `json:"kind"`
+	Name string `json:"name"`
+}
+
+// String 返回 "Kind/Name" 形式
+func (w WorkloadRef) String() string {
+	return w.Kind + "/" + w.Name
+}
+
+// ResolveWorkload 沿 ownerReference 找到 Pod 所属的顶层工作负载
+// ReplicaSet 会继续向上解析到 Deployment；没有控制器的 Pod 返回 nil
+func (a *Analyzer) ResolveWorkload(pod *corev1.Pod) *WorkloadRef {
+	owner := metav1.GetControllerOf(pod)
+	if owner == nil {
+		return nil
+	}
+
+	if owner.Kind == "ReplicaSet" {
+		rs, err := a.client.AppsV1().ReplicaSets(pod.Namespace).Get(a.ctx, owner.Name, metav1.GetOptions{})
+		if err == nil {
+			if depRef := metav1.GetControllerOf(rs); depRef != nil && depRef.Kind == "Deployment" {
+				return &WorkloadRef{Kind: depRef.Kind, Name: depRef.Name}
+			}
+		}
+	}
+	return &WorkloadRef{Kind: owner.Kind, Name: owner.Name}
+}
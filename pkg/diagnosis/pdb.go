@@ -0,0 +1,332 @@
+package diagnosis
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// -----------------------------------------------------------
+// PodDisruptionBudget 分析
+// 节点 drain 卡住时，找出阻止驱逐的 PDB
+// -----------------------------------------------------------
+
+// PDBStatus 是单个 PDB 的评估结果
+type PDBStatus struct {
+	Name               string `json:"name"`
+	Selector           string `json:"selector"`
+	MinAvailable       string `json:"min_available,omitempty"`
+	MaxUnavailable     string `json:"max_unavailable,omitempty"`
+	ExpectedPods       int32  `json:"expected_pods"`   // 期望的 Pod 总数 (控制器副本数或选中的 Pod 数)
+	HealthyPods        int32  `json:"healthy_pods"`    // 当前健康 (Ready) 的 Pod 数
+	DesiredHealthy     int32  `json:"desired_healthy"` // 至少需要保持健康的 Pod 数
+	DisruptionsAllowed int32  `json:"disruptions_allowed"`
+	Blocking           bool   `json:"blocking"` // 是否永久阻止驱逐 (不会自行恢复)
+}
+
+// Summary 返回单行摘要，例如 "web-pdb: 允许中断 0 (健康 2/3, 至少需要 3)"
+func (s PDBStatus) Summary() string {
+	return fmt.Sprintf("%s: 允许中断 %d (健康 %d/%d, 至少需要 %d)",
+		s.Name, s.DisruptionsAllowed, s.HealthyPods, s.ExpectedPods, s.DesiredHealthy)
+}
+
+// EvaluatePDB 计算 PDB 当前允许的中断数，并判断它是否会永久阻止驱逐
+// pods 为 PDB 选中的 Pod；controllerScale 为这些 Pod 所属控制器的副本数之和，未知时传 0
+func EvaluatePDB(pdb *policyv1.PodDisruptionBudget, pods []corev1.Pod, controllerScale int32) (PDBStatus, []Issue) {
+	status := PDBStatus{
+		Name:     pdb.Name,
+		Selector: formatLabelSelector(pdb.Spec.Selector),
+	}
+
+	for i := range pods {
+		if isPodHealthy(&pods[i]) {
+			status.HealthyPods++
+		}
+	}
+
+	// 与 disruption 控制器一致：整数 minAvailable 以选中的 Pod 数为基准，
+	// 百分比与 maxUnavailable 以控制器副本数为基准
+	status.ExpectedPods = int32(len(pods))
+	usesScale := pdb.Spec.MaxUnavailable != nil ||
+		(pdb.Spec.MinAvailable != nil && pdb.Spec.MinAvailable.Type == intstr.String)
+	if usesScale && controllerScale > 0 {
+		status.ExpectedPods = controllerScale
+	}
+
+	switch {
+	case pdb.Spec.MaxUnavailable != nil:
+		status.MaxUnavailable = pdb.Spec.MaxUnavailable.String()
+		maxUnavailable, _ := intstr.GetScaledValueFromIntOrPercent(pdb.Spec.MaxUnavailable, int(status.ExpectedPods), true)
+		status.DesiredHealthy = status.ExpectedPods - int32(maxUnavailable)
+		if status.DesiredHealthy < 0 {
+			status.DesiredHealthy = 0
+		}
+	case pdb.Spec.MinAvailable != nil:
+		status.MinAvailable = pdb.Spec.MinAvailable.String()
+		minAvailable, _ := intstr.GetScaledValueFromIntOrPercent(pdb.Spec.MinAvailable, int(status.ExpectedPods), true)
+		status.DesiredHealthy = int32(minAvailable)
+	}
+
+	status.DisruptionsAllowed = status.HealthyPods - status.DesiredHealthy
+	if status.DisruptionsAllowed < 0 {
+		status.DisruptionsAllowed = 0
+	}
+
+	// 副本数基准：用于判断 minAvailable 是否等于副本数
+	replicas := status.ExpectedPods
+	if controllerScale > 0 {
+		replicas = controllerScale
+	}
+
+	var issues []Issue
+	switch {
+	case len(pods) == 0:
+		issues = append(issues, Issue{
+			Type:       "Warning",
+			Title:      fmt.Sprintf("PDB %s 未选中任何 Pod", pdb.Name),
+			RawError:   fmt.Sprintf("selector: %s", status.Selector),
+			Suggestion: "请检查 PDB 的 selector 是否与工作负载的 Pod 标签一致",
+		})
+
+	case pdb.Spec.MaxUnavailable != nil && status.DesiredHealthy >= status.ExpectedPods:
+		status.Blocking = true
+		issues = append(issues, Issue{
+			Type:       "Error",
+			Title:      fmt.Sprintf("PDB %s 永久阻止驱逐: maxUnavailable 为 %s", pdb.Name, status.MaxUnavailable),
+			RawError:   status.Summary(),
+			Suggestion: "maxUnavailable 为 0 时任何自愿驱逐都会被拒绝，节点 drain 将一直卡住；请至少允许 1 个 Pod 不可用",
+		})
+
+	case pdb.Spec.MinAvailable != nil && replicas > 0 && status.DesiredHealthy >= replicas:
+		status.Blocking = true
+		issues = append(issues, Issue{
+			Type:       "Error",
+			Title:      fmt.Sprintf("PDB %s 永久阻止驱逐: minAvailable (%s) 不小于副本数 (%d)", pdb.Name, status.MinAvailable, replicas),
+			RawError:   status.Summary(),
+			Suggestion: "minAvailable 等于副本数意味着永远不能驱逐任何 Pod；请降低 minAvailable，或改用 maxUnavailable: 1，或增加副本数",
+		})
+
+	case status.HealthyPods == 0 && status.DesiredHealthy > 0 &&
+		!(pdb.Spec.UnhealthyPodEvictionPolicy != nil && *pdb.Spec.UnhealthyPodEvictionPolicy == policyv1.AlwaysAllow):
+		status.Blocking = true
+		issues = append(issues, Issue{
+			Type:       "Error",
+			Title:      fmt.Sprintf("PDB %s 永久阻止驱逐: 选中的 Pod 全部不健康", pdb.Name),
+			RawError:   status.Summary(),
+			Suggestion: "预算永远无法满足，不健康的 Pod 也无法被驱逐；请先修复 Pod，或设置 spec.unhealthyPodEvictionPolicy: AlwaysAllow",
+		})
+
+	case status.DisruptionsAllowed == 0:
+		issues = append(issues, Issue{
+			Type:       "Warning",
+			Title:      fmt.Sprintf("PDB %s 当前不允许中断", pdb.Name),
+			RawError:   status.Summary(),
+			Suggestion: "有 Pod 尚未就绪，待其恢复后驱逐会自动继续；如果长时间不恢复，请诊断未就绪的 Pod",
+		})
+	}
+
+	return status, issues
+}
+
+// GetPodPDBs 找到选中该 Pod 的所有 PDB 并进行评估
+func (a *Analyzer) GetPodPDBs(pod *corev1.Pod) ([]PDBStatus, []Issue) {
+	list, err := a.client.PolicyV1().PodDisruptionBudgets(pod.Namespace).List(a.ctx, metav1.ListOptions{})
+	if err != nil || len(list.Items) == 0 {
+		return nil, nil
+	}
+
+	var statuses []PDBStatus
+	var issues []Issue
+	var names []string
+	for i := range list.Items {
+		pdb := &list.Items[i]
+		if !selectorMatches(pdb.Spec.Selector, pod.Labels) {
+			continue
+		}
+		status, pdbIssues := a.evaluatePDB(pdb)
+		statuses = append(statuses, status)
+		issues = append(issues, pdbIssues...)
+		names = append(names, pdb.Name)
+	}
+
+	// Eviction API 不支持被多个 PDB 同时选中的 Pod
+	if len(names) > 1 {
+		issues = append(issues, Issue{
+			Type:       "Error",
+			Title:      fmt.Sprintf("Pod 被 %d 个 PDB 同时选中", len(names)),
+			RawError:   fmt.Sprintf("PDB: %v", names),
+			Suggestion: "Eviction API 会直接拒绝驱逐被多个 PDB 选中的 Pod；请调整 selector，确保每个 Pod 只属于一个 PDB",
+		})
+	}
+	return statuses, issues
+}
+
+// AnalyzePDB 诊断单个 PDB：预算、选中的 Pod 与是否阻止驱逐
+func (a *Analyzer) AnalyzePDB(pdb *policyv1.PodDisruptionBudget) WorkloadResult {
+	result := WorkloadResult{
+		Kind:      "PodDisruptionBudget",
+		Name:      pdb.Name,
+		Namespace: pdb.Namespace,
+		Details:   []Detail{},
+		Pods:      []PodSummary{},
+		Issues:    []Issue{},
+		Events:    a.getObjectEvents(pdb.Namespace, pdb.Name, pdb.UID),
+	}
+
+	pods, _ := a.listPDBPods(pdb)
+	for i := range pods {
+		result.Pods = append(result.Pods, SummarizePod(&pods[i]))
+	}
+
+	status, issues := EvaluatePDB(pdb, pods, a.controllerScale(pods))
+	result.Details = append(result.Details, Detail{Label: "选择器", Value: status.Selector})
+	if status.MinAvailable != "" {
+		result.Details = append(result.Details, Detail{Label: "minAvailable", Value: status.MinAvailable})
+	}
+	if status.MaxUnavailable != "" {
+		result.Details = append(result.Details, Detail{Label: "maxUnavailable", Value: status.MaxUnavailable})
+	}
+	result.Details = append(result.Details,
+		Detail{Label: "健康 Pod", Value: fmt.Sprintf("%d/%d (至少需要 %d)", status.HealthyPods, status.ExpectedPods, status.DesiredHealthy)},
+		Detail{Label: "允许中断", Value: fmt.Sprintf("%d", status.DisruptionsAllowed)},
+	)
+	result.Issues = append(result.Issues, issues...)
+	return result
+}
+
+// ScanPDBs 扫描命名空间下的所有 PDB，找出阻止驱逐的预算
+func (a *Analyzer) ScanPDBs(namespace string) (WorkloadResult, error) {
+	result := WorkloadResult{
+		Kind:      "Namespace",
+		Name:      namespace,
+		Namespace: namespace,
+		Details:   []Detail{},
+		Pods:      []PodSummary{},
+		Issues:    []Issue{},
+		Events:    []string{},
+	}
+
+	list, err := a.client.PolicyV1().PodDisruptionBudgets(namespace).List(a.ctx, metav1.ListOptions{})
+	if err != nil {
+		return result, fmt.Errorf("无法获取 PDB 列表: %w", err)
+	}
+
+	blocking := 0
+	for i := range list.Items {
+		status, issues := a.evaluatePDB(&list.Items[i])
+		if status.Blocking {
+			blocking++
+		}
+		result.Details = append(result.Details, Detail{Label: "PDB " + status.Name, Value: status.Summary()})
+		result.Issues = append(result.Issues, issues...)
+	}
+	result.Details = append([]Detail{
+		{Label: "PDB 数量", Value: fmt.Sprintf("%d (永久阻止驱逐 %d)", len(list.Items), blocking)},
+	}, result.Details...)
+	return result, nil
+}
+
+// evaluatePDB 获取 PDB 选中的 Pod 与控制器副本数后进行评估
+func (a *Analyzer) evaluatePDB(pdb *policyv1.PodDisruptionBudget) (PDBStatus, []Issue) {
+	pods, _ := a.listPDBPods(pdb)
+	return EvaluatePDB(pdb, pods, a.controllerScale(pods))
+}
+
+// listPDBPods 列出 PDB 选中的 Pod
+func (a *Analyzer) listPDBPods(pdb *policyv1.PodDisruptionBudget) ([]corev1.Pod, error) {
+	if pdb.Spec.Selector == nil {
+		return nil, nil
+	}
+	selector, err := metav1.LabelSelectorAsSelector(pdb.Spec.Selector)
+	if err != nil {
+		return nil, err
+	}
+	list, err := a.client.CoreV1().Pods(pdb.Namespace).List(a.ctx, metav1.ListOptions{LabelSelector: selector.String()})
+	if err != nil {
+		return nil, err
+	}
+	return list.Items, nil
+}
+
+// controllerScale 汇总 Pod 所属控制器的期望副本数
+// 有任意 Pod 无法确定控制器副本数时返回 0，调用方回退到按 Pod 数计算
+func (a *Analyzer) controllerScale(pods []corev1.Pod) int32 {
+	var total int32
+	seen := map[types.UID]bool{}
+	for i := range pods {
+		owner := metav1.GetControllerOf(&pods[i])
+		if owner == nil {
+			return 0
+		}
+		if seen[owner.UID] {
+			continue
+		}
+		seen[owner.UID] = true
+
+		workload := a.ResolveWorkload(&pods[i])
+		replicas, ok := a.workloadReplicas(pods[i].Namespace, workload)
+		if !ok {
+			return 0
+		}
+		total += replicas
+	}
+	return total
+}
+
+// workloadReplicas 读取工作负载的 spec.replicas
+func (a *Analyzer) workloadReplicas(namespace string, ref *WorkloadRef) (int32, bool) {
+	if ref == nil {
+		return 0, false
+	}
+	var replicas *int32
+	switch ref.Kind {
+	case "Deployment":
+		dep, err := a.client.AppsV1().Deployments(namespace).Get(a.ctx, ref.Name, metav1.GetOptions{})
+		if err != nil {
+			return 0, false
+		}
+		replicas = dep.Spec.Replicas
+	case "StatefulSet":
+		sts, err := a.client.AppsV1().StatefulSets(namespace).Get(a.ctx, ref.Name, metav1.GetOptions{})
+		if err != nil {
+			return 0, false
+		}
+		replicas = sts.Spec.Replicas
+	case "ReplicaSet":
+		rs, err := a.client.AppsV1().ReplicaSets(namespace).Get(a.ctx, ref.Name, metav1.GetOptions{})
+		if err != nil {
+			return 0, false
+		}
+		replicas = rs.Spec.Replicas
+	default:
+		return 0, false
+	}
+	if replicas == nil {
+		return 1, true
+	}
+	return *replicas, true
+}
+
+// isPodHealthy 与 disruption 控制器一致：Ready 且未处于删除中的 Pod 视为健康
+func isPodHealthy(pod *corev1.Pod) bool {
+	return pod.DeletionTimestamp == nil && SummarizePod(pod).Ready
+}
+
+// formatLabelSelector 将 LabelSelector 格式化为字符串
+func formatLabelSelector(selector *metav1.LabelSelector) string {
+	if selector == nil {
+		return "<none>"
+	}
+	s, err := metav1.LabelSelectorAsSelector(selector)
+	if err != nil {
+		return err.Error()
+	}
+	if s.Empty() {
+		return "<all>"
+	}
+	return s.String()
+}
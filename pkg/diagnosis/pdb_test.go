@@ -0,0 +1,182 @@
+package diagnosis
+
+import (
+	"strings"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func newPDB(name string, minAvailable, maxUnavailable *intstr.IntOrString) *policyv1.PodDisruptionBudget {
+	return &policyv1.PodDisruptionBudget{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+		Spec: policyv1.PodDisruptionBudgetSpec{
+			Selector:       &metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}},
+			MinAvailable:   minAvailable,
+			MaxUnavailable: maxUnavailable,
+		},
+	}
+}
+
+// PDB 测试中的 Pod 都带有 newPDB 选择的标签
+var pdbPodLabels = map[string]string{"app": "web"}
+
+func intOrStrPtr(v intstr.IntOrString) *intstr.IntOrString { return &v }
+
+func TestEvaluatePDB(t *testing.T) {
+	healthy3 := []corev1.Pod{*newServicePod("a", pdbPodLabels, true), *newServicePod("b", pdbPodLabels, true), *newServicePod("c", pdbPodLabels, true)}
+	alwaysAllow := newPDB("web", intOrStrPtr(intstr.FromInt32(1)), nil)
+	policy := policyv1.AlwaysAllow
+	alwaysAllow.Spec.UnhealthyPodEvictionPolicy = &policy
+
+	tests := []struct {
+		name         string
+		pdb          *policyv1.PodDisruptionBudget
+		pods         []corev1.Pod
+		scale        int32
+		wantAllowed  int32
+		wantBlocking bool
+		wantTitle    string
+	}{
+		{
+			name:        "healthy budget",
+			pdb:         newPDB("web", intOrStrPtr(intstr.FromInt32(2)), nil),
+			pods:        healthy3,
+			scale:       3,
+			wantAllowed: 1,
+		},
+		{
+			name:         "minAvailable equals replicas",
+			pdb:          newPDB("web", intOrStrPtr(intstr.FromInt32(3)), nil),
+			pods:         healthy3,
+			scale:        3,
+			wantBlocking: true,
+			wantTitle:    "PDB web 永久阻止驱逐: minAvailable (3) 不小于副本数 (3)",
+		},
+		{
+			name:         "minAvailable 100%",
+			pdb:          newPDB("web", intOrStrPtr(intstr.FromString("100%")), nil),
+			pods:         healthy3,
+			scale:        3,
+			wantBlocking: true,
+			wantTitle:    "PDB web 永久阻止驱逐: minAvailable (100%) 不小于副本数 (3)",
+		},
+		{
+			name:         "maxUnavailable zero",
+			pdb:          newPDB("web", nil, intOrStrPtr(intstr.FromInt32(0))),
+			pods:         healthy3,
+			scale:        3,
+			wantBlocking: true,
+			wantTitle:    "PDB web 永久阻止驱逐: maxUnavailable 为 0",
+		},
+		{
+			name:         "all selected pods unhealthy",
+			pdb:          newPDB("web", intOrStrPtr(intstr.FromInt32(1)), nil),
+			pods:         []corev1.Pod{*newServicePod("a", pdbPodLabels, false), *newServicePod("b", pdbPodLabels, false)},
+			scale:        2,
+			wantBlocking: true,
+			wantTitle:    "PDB web 永久阻止驱逐: 选中的 Pod 全部不健康",
+		},
+		{
+			name:      "all unhealthy but AlwaysAllow",
+			pdb:       alwaysAllow,
+			pods:      []corev1.Pod{*newServicePod("a", pdbPodLabels, false), *newServicePod("b", pdbPodLabels, false)},
+			scale:     2,
+			wantTitle: "PDB web 当前不允许中断",
+		},
+		{
+			name:      "temporarily exhausted",
+			pdb:       newPDB("web", nil, intOrStrPtr(intstr.FromInt32(1))),
+			pods:      []corev1.Pod{*newServicePod("a", pdbPodLabels, true), *newServicePod("b", pdbPodLabels, true), *newServicePod("c", pdbPodLabels, false)},
+			scale:     3,
+			wantTitle: "PDB web 当前不允许中断",
+		},
+		{
+			name:      "selects nothing",
+			pdb:       newPDB("web", intOrStrPtr(intstr.FromInt32(1)), nil),
+			wantTitle: "PDB web 未选中任何 Pod",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, issues := EvaluatePDB(tt.pdb, tt.pods, tt.scale)
+			if status.DisruptionsAllowed != tt.wantAllowed {
+				t.Errorf("DisruptionsAllowed = %d, want %d", status.DisruptionsAllowed, tt.wantAllowed)
+			}
+			if status.Blocking != tt.wantBlocking {
+				t.Errorf("Blocking = %v, want %v", status.Blocking, tt.wantBlocking)
+			}
+			if tt.wantTitle == "" {
+				if len(issues) != 0 {
+					t.Errorf("expected no issues, got %+v", issues)
+				}
+				return
+			}
+			if !hasIssue(issues, tt.wantTitle) {
+				t.Errorf("expected issue %q, got %+v", tt.wantTitle, issues)
+			}
+		})
+	}
+}
+
+func TestAnalyzer_GetPodPDBs(t *testing.T) {
+	controller := true
+	dep := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default", UID: "dep-uid"},
+		Spec:       appsv1.DeploymentSpec{Replicas: int32Ptr(2)},
+	}
+	rs := &appsv1.ReplicaSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "web-6d4f", Namespace: "default", UID: "rs-uid",
+			OwnerReferences: []metav1.OwnerReference{{Kind: "Deployment", Name: "web", UID: "dep-uid", Controller: &controller}},
+		},
+	}
+	pod1 := newServicePod("web-6d4f-a", pdbPodLabels, true)
+	pod2 := newServicePod("web-6d4f-b", pdbPodLabels, true)
+	for _, p := range []*corev1.Pod{pod1, pod2} {
+		p.OwnerReferences = []metav1.OwnerReference{{Kind: "ReplicaSet", Name: "web-6d4f", UID: "rs-uid", Controller: &controller}}
+	}
+
+	// 整数 minAvailable 以选中的 Pod 数为基准；百分比以 Deployment 副本数为基准
+	strict := newPDB("web-strict", intOrStrPtr(intstr.FromString("100%")), nil)
+	other := newPDB("other", intOrStrPtr(intstr.FromInt32(1)), nil)
+	other.Spec.Selector = &metav1.LabelSelector{MatchLabels: map[string]string{"app": "api"}}
+
+	fakeClient := fake.NewSimpleClientset(dep, rs, pod1, pod2, strict, other)
+	statuses, issues := NewAnalyzer(fakeClient).GetPodPDBs(pod1)
+
+	if len(statuses) != 1 || statuses[0].Name != "web-strict" {
+		t.Fatalf("expected only web-strict to select the pod, got %+v", statuses)
+	}
+	if statuses[0].ExpectedPods != 2 || !statuses[0].Blocking {
+		t.Errorf("unexpected status %+v", statuses[0])
+	}
+	if len(issues) != 1 || !strings.Contains(issues[0].Title, "永久阻止驱逐") {
+		t.Errorf("expected a blocking issue, got %+v", issues)
+	}
+}
+
+func TestAnalyzer_ScanPDBs(t *testing.T) {
+	pod := newServicePod("web-a", pdbPodLabels, true)
+	fakeClient := fake.NewSimpleClientset(pod,
+		newPDB("ok", nil, intOrStrPtr(intstr.FromInt32(1))),
+		newPDB("blocker", nil, intOrStrPtr(intstr.FromInt32(0))),
+	)
+
+	result, err := NewAnalyzer(fakeClient).ScanPDBs("default")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Details[0].Value != "2 (永久阻止驱逐 1)" {
+		t.Errorf("summary = %q", result.Details[0].Value)
+	}
+	if !hasIssue(result.Issues, "PDB blocker 永久阻止驱逐: maxUnavailable 为 0") {
+		t.Errorf("expected blocker issue, got %+v", result.Issues)
+	}
+}
@@ -0,0 +1,119 @@
+package diagnosis
+
+import (
+	"strings"
+
+	"github.com/swfoodt/kubehealer/pkg/redact"
+)
+
+// -----------------------------------------------------------
+// 诊断结果脱敏
+// 在结果交给任何报告生成器之前调用，覆盖日志、报错信息、事件与模板差异
+// -----------------------------------------------------------
+
+// Redact 对诊断结果中的所有文本做脱敏，并记录本次替换的次数
+func (r *DiagnosisResult) Redact(rd *redact.Redactor) {
+	if rd == nil {
+		return
+	}
+	before := rd.Count()
+
+	r.Events = rd.Strings(r.Events)
+	redactIssues(rd, r.Issues)
+	for i := range r.Containers {
+		redactContainer(rd, &r.Containers[i])
+	}
+	if r.HPA != nil {
+		r.HPA.Conditions = rd.Strings(r.HPA.Conditions)
+		r.HPA.MetricErrors = rd.Strings(r.HPA.MetricErrors)
+	}
+	if r.LogAlert != nil {
+		r.LogAlert.Lines = rd.Strings(r.LogAlert.Lines)
+	}
+	if r.TemplateDiff != nil {
+		for i := range r.TemplateDiff.Changes {
+			c := &r.TemplateDiff.Changes[i]
+			// 名称看起来是密码 / 令牌的环境变量，整个值都替换掉
+			if c.Category == ChangeEnv && redact.IsSensitiveName(strings.TrimPrefix(c.Field, "env.")) {
+				c.Old = rd.Value("env", c.Old)
+				c.New = rd.Value("env", c.New)
+				continue
+			}
+			c.Old = rd.String(c.Old)
+			c.New = rd.String(c.New)
+		}
+	}
+
+	r.Redactions += rd.Count() - before
+}
+
+// Redact 对工作负载诊断结果 (包括代表性 Pod) 做脱敏，并记录本次替换的次数
+func (r *WorkloadResult) Redact(rd *redact.Redactor) {
+	if rd == nil {
+		return
+	}
+	before := rd.Count()
+
+	r.Events = rd.Strings(r.Events)
+	redactIssues(rd, r.Issues)
+	for i := range r.Details {
+		r.Details[i].Value = rd.String(r.Details[i].Value)
+	}
+	for i := range r.PodResults {
+		r.PodResults[i].Redact(rd)
+	}
+
+	r.Redactions += rd.Count() - before
+}
+
+func redactIssues(rd *redact.Redactor, issues []Issue) {
+	for i := range issues {
+		issues[i].Title = rd.String(issues[i].Title)
+		issues[i].RawError = rd.String(issues[i].RawError)
+		issues[i].Suggestion = rd.String(issues[i].Suggestion)
+	}
+}
+
+func redactContainer(rd *redact.Redactor, c *ContainerDiagnosis) {
+	c.Message = rd.String(c.Message)
+	redactIssues(rd, c.Issues)
+	c.Logs = rd.Strings(c.Logs)
+	for i := range c.LogMatches {
+		m := &c.LogMatches[i]
+		m.Text = rd.String(m.Text)
+		m.Before = rd.Strings(m.Before)
+		m.After = rd.Strings(m.After)
+	}
+	for i := range c.StackTraces {
+		t := &c.StackTraces[i]
+		t.ExceptionType = rd.String(t.ExceptionType)
+		t.Message = rd.String(t.Message)
+		t.Lines = rd.Strings(t.Lines)
+	}
+	for i := range c.LogTemplates {
+		c.LogTemplates[i].Template = rd.String(c.LogTemplates[i].Template)
+		c.LogTemplates[i].Sample = rd.String(c.LogTemplates[i].Sample)
+	}
+	if cmp := c.RunComparison; cmp != nil {
+		for i := range cmp.Previous {
+			cmp.Previous[i].Text = rd.String(cmp.Previous[i].Text)
+		}
+		for i := range cmp.Current {
+			cmp.Current[i].Text = rd.String(cmp.Current[i].Text)
+		}
+		for i := range cmp.CrashOnly {
+			cmp.CrashOnly[i].Template = rd.String(cmp.CrashOnly[i].Template)
+			cmp.CrashOnly[i].Sample = rd.String(cmp.CrashOnly[i].Sample)
+		}
+		cmp.CrashPoint = rd.String(cmp.CrashPoint)
+	}
+	for i := range c.Dependencies {
+		c.Dependencies[i].Sample = rd.String(c.Dependencies[i].Sample)
+	}
+	for i := range c.JSONLogs {
+		e := &c.JSONLogs[i]
+		e.Message = rd.String(e.Message)
+		e.Error = rd.String(e.Error)
+		e.StackTrace = rd.String(e.StackTrace)
+	}
+}
@@ -0,0 +1,306 @@
+package diagnosis
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// -----------------------------------------------------------
+// 命名空间级资源浪费 / 不足分析
+// 对比每个工作负载的 requests 总量与 metrics-server 观测到的使用量
+// -----------------------------------------------------------
+
+// 资源配置状态
+const (
+	ProvisionOver    = "over"    // 申请远大于使用，存在浪费
+	ProvisionUnder   = "under"   // 使用超过申请或接近 limit
+	ProvisionOK      = "ok"      // 配置合理
+	ProvisionUnknown = "unknown" // 没有使用量数据
+)
+
+const (
+	// overProvisionRatio 使用量低于 requests 的该比例视为浪费
+	overProvisionRatio = 0.5
+	// limitPressureRatio 内存峰值超过 limit 的该比例视为不足
+	limitPressureRatio = 0.9
+	// requestHeadroom 建议的 requests 相对使用量的余量
+	requestHeadroom = 1.2
+	// cpuCoreToGiB 计算影响时 1 核 CPU 折算为多少 GiB 内存 (与常见云厂商的单价比例接近)
+	cpuCoreToGiB = 4
+	// cpuRoundStep 建议的 CPU 值向上取整的粒度 (millicores)
+	cpuRoundStep = 10
+)
+
+// ResourceReport 是命名空间级资源分析结果
+type ResourceReport struct {
+	Namespace        string              `json:"namespace"`
+	MetricsAvailable bool                `json:"metrics_available"` // metrics-server 不可用时只统计 requests
+	Workloads        []WorkloadResources `json:"workloads"`         // 按影响从大到小排序
+}
+
+// WorkloadResources 是单个工作负载的资源汇总 (所有 Pod、所有容器)
+type WorkloadResources struct {
+	Kind               string               `json:"kind"`
+	Name               string               `json:"name"`
+	Pods               int                  `json:"pods"`
+	CPURequestMilli    int64                `json:"cpu_request_millicores"`
+	CPUUsageMilli      int64                `json:"cpu_usage_millicores"`
+	MemoryRequestBytes int64                `json:"memory_request_bytes"`
+	MemoryUsageBytes   int64                `json:"memory_usage_bytes"`
+	CPUWasteMilli      int64                `json:"cpu_waste_millicores"` // 负数表示不足
+	MemoryWasteBytes   int64                `json:"memory_waste_bytes"`   // 负数表示不足
+	Impact             float64              `json:"impact"`               // 折算后的浪费 / 不足量，用于排序
+	Status             string               `json:"status"`
+	Containers         []ContainerResources `json:"containers"`
+}
+
+// ContainerResources 是单个容器 (跨副本) 的资源配置与使用量
+type ContainerResources struct {
+	Name               string              `json:"name"`
+	SampledPods        int                 `json:"sampled_pods"` // 有使用量数据的 Pod 数
+	CPURequestMilli    int64               `json:"cpu_request_millicores"`
+	CPULimitMilli      int64               `json:"cpu_limit_millicores"`
+	MemoryRequestBytes int64               `json:"memory_request_bytes"`
+	MemoryLimitBytes   int64               `json:"memory_limit_bytes"`
+	CPUUsageMilli      int64               `json:"cpu_usage_millicores"` // 各副本平均值
+	MemoryUsageBytes   int64               `json:"memory_usage_bytes"`   // 各副本平均值
+	MemoryPeakBytes    int64               `json:"memory_peak_bytes"`    // 各副本最大值
+	CPUWasteMilli      int64               `json:"cpu_waste_millicores"`
+	MemoryWasteBytes   int64               `json:"memory_waste_bytes"`
+	Status             string              `json:"status"`
+	Suggested          *SuggestedResources `json:"suggested,omitempty"`
+}
+
+// SuggestedResources 是建议的 resources 配置
+type SuggestedResources struct {
+	CPURequest    string `json:"cpu_request"`
+	CPULimit      string `json:"cpu_limit,omitempty"` // 原配置未设置 CPU limit 时不建议新增
+	MemoryRequest string `json:"memory_request"`
+	MemoryLimit   string `json:"memory_limit"`
+}
+
+// YAML 返回可以直接粘贴到容器 spec 中的 resources 块
+func (s SuggestedResources) YAML() string {
+	var sb strings.Builder
+	sb.WriteString("resources:\n")
+	sb.WriteString("  requests:\n")
+	sb.WriteString(fmt.Sprintf("    cpu: %s\n", s.CPURequest))
+	sb.WriteString(fmt.Sprintf("    memory: %s\n", s.MemoryRequest))
+	sb.WriteString("  limits:\n")
+	if s.CPULimit != "" {
+		sb.WriteString(fmt.Sprintf("    cpu: %s\n", s.CPULimit))
+	}
+	sb.WriteString(fmt.Sprintf("    memory: %s\n", s.MemoryLimit))
+	return sb.String()
+}
+
+// AnalyzeResources 统计命名空间下每个工作负载的资源浪费与不足，按影响排序
+func (a *Analyzer) AnalyzeResources(namespace string) (*ResourceReport, error) {
+	pods, err := a.client.CoreV1().Pods(namespace).List(a.ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("无法获取 Pod 列表: %w", err)
+	}
+
+	report := &ResourceReport{Namespace: namespace, Workloads: []WorkloadResources{}}
+	usage := a.listPodUsage(namespace)
+	report.MetricsAvailable = usage != nil
+
+	// 按工作负载分组 (只统计运行中的 Pod)
+	groups := map[WorkloadRef][]corev1.Pod{}
+	var order []WorkloadRef
+	cache := map[types.UID]*WorkloadRef{}
+	for _, pod := range pods.Items {
+		if pod.Status.Phase != corev1.PodRunning {
+			continue
+		}
+		ref := a.cachedWorkload(&pod, cache)
+		if _, ok := groups[ref]; !ok {
+			order = append(order, ref)
+		}
+		groups[ref] = append(groups[ref], pod)
+	}
+
+	for _, ref := range order {
+		report.Workloads = append(report.Workloads, SummarizeWorkloadResources(ref, groups[ref], usage))
+	}
+
+	sort.SliceStable(report.Workloads, func(i, j int) bool {
+		return report.Workloads[i].Impact > report.Workloads[j].Impact
+	})
+	return report, nil
+}
+
+// SummarizeWorkloadResources 汇总一个工作负载所有 Pod 的资源配置与使用量
+// usage 为 Pod 名 -> 容器名 -> 使用量，为 nil 时表示没有使用量数据
+func SummarizeWorkloadResources(ref WorkloadRef, pods []corev1.Pod, usage map[string]map[string]corev1.ResourceList) WorkloadResources {
+	result := WorkloadResources{Kind: ref.Kind, Name: ref.Name, Pods: len(pods), Status: ProvisionUnknown}
+	if len(pods) == 0 {
+		return result
+	}
+
+	// 同一工作负载的 Pod 模板相同，以第一个 Pod 的容器为准
+	for _, spec := range pods[0].Spec.Containers {
+		c := summarizeContainer(spec, pods, usage)
+		result.Containers = append(result.Containers, c)
+
+		n := int64(len(pods))
+		result.CPURequestMilli += c.CPURequestMilli * n
+		result.MemoryRequestBytes += c.MemoryRequestBytes * n
+		result.CPUUsageMilli += c.CPUUsageMilli * n
+		result.MemoryUsageBytes += c.MemoryUsageBytes * n
+		result.CPUWasteMilli += c.CPUWasteMilli
+		result.MemoryWasteBytes += c.MemoryWasteBytes
+	}
+
+	result.Status = workloadStatus(result.Containers)
+	result.Impact = math.Abs(float64(result.CPUWasteMilli))/1000 +
+		math.Abs(float64(result.MemoryWasteBytes))/float64(1<<30)/cpuCoreToGiB
+	return result
+}
+
+// summarizeContainer 统计单个容器在所有副本上的使用量，并给出状态与建议
+func summarizeContainer(spec corev1.Container, pods []corev1.Pod, usage map[string]map[string]corev1.ResourceList) ContainerResources {
+	c := ContainerResources{
+		Name:               spec.Name,
+		CPURequestMilli:    spec.Resources.Requests.Cpu().MilliValue(),
+		CPULimitMilli:      spec.Resources.Limits.Cpu().MilliValue(),
+		MemoryRequestBytes: spec.Resources.Requests.Memory().Value(),
+		MemoryLimitBytes:   spec.Resources.Limits.Memory().Value(),
+		Status:             ProvisionUnknown,
+	}
+
+	var cpuSum, memSum int64
+	for _, pod := range pods {
+		u, ok := usage[pod.Name][spec.Name]
+		if !ok {
+			continue
+		}
+		c.SampledPods++
+		cpuSum += u.Cpu().MilliValue()
+		mem := u.Memory().Value()
+		memSum += mem
+		if mem > c.MemoryPeakBytes {
+			c.MemoryPeakBytes = mem
+		}
+	}
+	if c.SampledPods == 0 {
+		return c
+	}
+
+	c.CPUUsageMilli = cpuSum / int64(c.SampledPods)
+	c.MemoryUsageBytes = memSum / int64(c.SampledPods)
+	n := int64(len(pods))
+	c.CPUWasteMilli = (c.CPURequestMilli - c.CPUUsageMilli) * n
+	c.MemoryWasteBytes = (c.MemoryRequestBytes - c.MemoryUsageBytes) * n
+	c.Status = containerStatus(c)
+	c.Suggested = suggestResources(c)
+	return c
+}
+
+// containerStatus 判断容器的资源配置状态
+func containerStatus(c ContainerResources) string {
+	switch {
+	case c.CPURequestMilli == 0 || c.MemoryRequestBytes == 0,
+		c.CPUUsageMilli > c.CPURequestMilli,
+		c.MemoryPeakBytes > c.MemoryRequestBytes,
+		c.MemoryLimitBytes > 0 && float64(c.MemoryPeakBytes) >= float64(c.MemoryLimitBytes)*limitPressureRatio:
+		return ProvisionUnder
+	case float64(c.CPUUsageMilli) < float64(c.CPURequestMilli)*overProvisionRatio,
+		float64(c.MemoryUsageBytes) < float64(c.MemoryRequestBytes)*overProvisionRatio:
+		return ProvisionOver
+	}
+	return ProvisionOK
+}
+
+// workloadStatus 汇总容器状态：任一容器不足即为不足，其次是浪费
+func workloadStatus(containers []ContainerResources) string {
+	status := ProvisionUnknown
+	for _, c := range containers {
+		switch {
+		case c.Status == ProvisionUnder:
+			return ProvisionUnder
+		case c.Status == ProvisionOver:
+			status = ProvisionOver
+		case c.Status == ProvisionOK && status == ProvisionUnknown:
+			status = ProvisionOK
+		}
+	}
+	return status
+}
+
+// suggestResources 根据观测到的使用量生成建议配置
+func suggestResources(c ContainerResources) *SuggestedResources {
+	cpuRequest := roundUp(int64(float64(c.CPUUsageMilli)*requestHeadroom), cpuRoundStep)
+	if cpuRequest < cpuRoundStep {
+		cpuRequest = cpuRoundStep
+	}
+	memRequest := roundUp(int64(float64(c.MemoryPeakBytes)*requestHeadroom), memoryRoundStep)
+	memLimit := SuggestMemoryLimit(c.MemoryPeakBytes)
+	if memLimit < memRequest {
+		memLimit = memRequest
+	}
+
+	s := &SuggestedResources{
+		CPURequest:    FormatCPU(cpuRequest),
+		MemoryRequest: FormatMemory(memRequest),
+		MemoryLimit:   FormatMemory(memLimit),
+	}
+	// 原来设置了 CPU limit 的容器，保留 2 倍 requests 的突发空间
+	if c.CPULimitMilli > 0 {
+		s.CPULimit = FormatCPU(cpuRequest * 2)
+	}
+	return s
+}
+
+// listPodUsage 获取命名空间下所有 Pod 的容器使用量，metrics-server 不可用时返回 nil
+func (a *Analyzer) listPodUsage(namespace string) map[string]map[string]corev1.ResourceList {
+	if a.metrics == nil {
+		return nil
+	}
+	list, err := a.metrics.MetricsV1beta1().PodMetricses(namespace).List(a.ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil
+	}
+
+	usage := map[string]map[string]corev1.ResourceList{}
+	for _, m := range list.Items {
+		containers := map[string]corev1.ResourceList{}
+		for _, c := range m.Containers {
+			containers[c.Name] = c.Usage
+		}
+		usage[m.Name] = containers
+	}
+	return usage
+}
+
+// cachedWorkload 解析 Pod 所属工作负载，按直接控制器缓存以减少 API 调用
+// 没有控制器的 Pod 单独作为一个工作负载
+func (a *Analyzer) cachedWorkload(pod *corev1.Pod, cache map[types.UID]*WorkloadRef) WorkloadRef {
+	owner := metav1.GetControllerOf(pod)
+	if owner == nil {
+		return WorkloadRef{Kind: "Pod", Name: pod.Name}
+	}
+	if ref, ok := cache[owner.UID]; ok {
+		return *ref
+	}
+	ref := a.ResolveWorkload(pod)
+	cache[owner.UID] = ref
+	return *ref
+}
+
+// FormatCPU 将 millicores 格式化为 Kubernetes 数量，例如 250 -> "250m"，2000 -> "2"
+func FormatCPU(milli int64) string {
+	return resource.NewMilliQuantity(milli, resource.DecimalSI).String()
+}
+
+// roundUp 将 v 向上取整到 step 的倍数
+func roundUp(v, step int64) int64 {
+	return (v + step - 1) / step * step
+}
@@ -0,0 +1,190 @@
+package diagnosis
+
+import (
+	"strings"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	metricsv1beta1 "k8s.io/metrics/pkg/apis/metrics/v1beta1"
+)
+
+// newResourcePod 创建一个带有 requests / limits 的运行中 Pod
+func newResourcePod(name string, owner *metav1.OwnerReference, cpuReq, memReq, memLimit string) *corev1.Pod {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+		Spec: corev1.PodSpec{Containers: []corev1.Container{{
+			Name: "app",
+			Resources: corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse(cpuReq), corev1.ResourceMemory: resource.MustParse(memReq)},
+				Limits:   corev1.ResourceList{corev1.ResourceMemory: resource.MustParse(memLimit)},
+			},
+		}}},
+		Status: corev1.PodStatus{Phase: corev1.PodRunning},
+	}
+	if owner != nil {
+		pod.OwnerReferences = []metav1.OwnerReference{*owner}
+	}
+	return pod
+}
+
+// newUsage 创建单容器 Pod 的 PodMetrics
+func newUsage(name, cpu, mem string) *metricsv1beta1.PodMetrics {
+	return &metricsv1beta1.PodMetrics{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+		Containers: []metricsv1beta1.ContainerMetrics{{
+			Name:  "app",
+			Usage: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse(cpu), corev1.ResourceMemory: resource.MustParse(mem)},
+		}},
+	}
+}
+
+func TestSummarizeWorkloadResources(t *testing.T) {
+	tests := []struct {
+		name       string
+		cpuReq     string
+		memReq     string
+		memLimit   string
+		usage      map[string]map[string]corev1.ResourceList
+		wantStatus string
+		wantCPU    string // 建议的 CPU request
+		wantMem    string // 建议的内存 request
+	}{
+		{
+			name:   "申请远大于使用",
+			cpuReq: "1", memReq: "1Gi", memLimit: "2Gi",
+			usage: map[string]map[string]corev1.ResourceList{
+				"p1": {"app": {corev1.ResourceCPU: resource.MustParse("100m"), corev1.ResourceMemory: resource.MustParse("200Mi")}},
+				"p2": {"app": {corev1.ResourceCPU: resource.MustParse("200m"), corev1.ResourceMemory: resource.MustParse("300Mi")}},
+			},
+			wantStatus: ProvisionOver,
+			wantCPU:    "180m",  // 平均 150m * 1.2
+			wantMem:    "384Mi", // 峰值 300Mi * 1.2 = 360Mi，按 64Mi 取整
+		},
+		{
+			name:   "内存接近 limit",
+			cpuReq: "100m", memReq: "256Mi", memLimit: "512Mi",
+			usage: map[string]map[string]corev1.ResourceList{
+				"p1": {"app": {corev1.ResourceCPU: resource.MustParse("80m"), corev1.ResourceMemory: resource.MustParse("480Mi")}},
+			},
+			wantStatus: ProvisionUnder,
+			wantCPU:    "100m", // 80m * 1.2 = 96m，按 10m 取整
+			wantMem:    "576Mi",
+		},
+		{
+			name:   "配置合理",
+			cpuReq: "100m", memReq: "256Mi", memLimit: "512Mi",
+			usage: map[string]map[string]corev1.ResourceList{
+				"p1": {"app": {corev1.ResourceCPU: resource.MustParse("70m"), corev1.ResourceMemory: resource.MustParse("200Mi")}},
+			},
+			wantStatus: ProvisionOK,
+			wantCPU:    "90m",
+			wantMem:    "256Mi",
+		},
+		{
+			name:   "没有使用量数据",
+			cpuReq: "100m", memReq: "256Mi", memLimit: "512Mi",
+			wantStatus: ProvisionUnknown,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pods := []corev1.Pod{
+				*newResourcePod("p1", nil, tt.cpuReq, tt.memReq, tt.memLimit),
+				*newResourcePod("p2", nil, tt.cpuReq, tt.memReq, tt.memLimit),
+			}
+			got := SummarizeWorkloadResources(WorkloadRef{Kind: "Deployment", Name: "web"}, pods, tt.usage)
+			if got.Status != tt.wantStatus {
+				t.Fatalf("status = %s, want %s", got.Status, tt.wantStatus)
+			}
+			c := got.Containers[0]
+			if tt.wantCPU == "" {
+				if c.Suggested != nil {
+					t.Errorf("no metrics should yield no suggestion, got %+v", c.Suggested)
+				}
+				return
+			}
+			if c.Suggested == nil || c.Suggested.CPURequest != tt.wantCPU || c.Suggested.MemoryRequest != tt.wantMem {
+				t.Errorf("suggested = %+v, want cpu %s memory %s", c.Suggested, tt.wantCPU, tt.wantMem)
+			}
+		})
+	}
+}
+
+func TestSuggestedResources_YAML(t *testing.T) {
+	s := SuggestedResources{CPURequest: "100m", MemoryRequest: "256Mi", MemoryLimit: "384Mi"}
+	want := "resources:\n  requests:\n    cpu: 100m\n    memory: 256Mi\n  limits:\n    memory: 384Mi\n"
+	if got := s.YAML(); got != want {
+		t.Errorf("YAML() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestAnalyzer_AnalyzeResources(t *testing.T) {
+	isController := true
+	rs := &appsv1.ReplicaSet{ObjectMeta: metav1.ObjectMeta{
+		Name: "web-7d9c", Namespace: "default", UID: "rs-uid",
+		OwnerReferences: []metav1.OwnerReference{{Kind: "Deployment", Name: "web", Controller: &isController}},
+	}}
+	rsOwner := &metav1.OwnerReference{Kind: "ReplicaSet", Name: "web-7d9c", UID: "rs-uid", Controller: &isController}
+
+	objects := []*corev1.Pod{
+		newResourcePod("web-1", rsOwner, "2", "4Gi", "4Gi"),
+		newResourcePod("web-2", rsOwner, "2", "4Gi", "4Gi"),
+		newResourcePod("tool", nil, "100m", "128Mi", "256Mi"),
+	}
+	pending := newResourcePod("web-3", rsOwner, "2", "4Gi", "4Gi")
+	pending.Status.Phase = corev1.PodPending
+
+	client := fake.NewSimpleClientset(rs, objects[0], objects[1], objects[2], pending)
+	metrics := newFakeMetrics(t,
+		newUsage("web-1", "100m", "512Mi"),
+		newUsage("web-2", "100m", "512Mi"),
+		newUsage("tool", "90m", "120Mi"),
+	)
+
+	report, err := NewAnalyzer(client).WithMetrics(metrics).AnalyzeResources("default")
+	if err != nil {
+		t.Fatalf("AnalyzeResources failed: %v", err)
+	}
+	if !report.MetricsAvailable {
+		t.Fatal("metrics should be available")
+	}
+	if len(report.Workloads) != 2 {
+		t.Fatalf("workloads = %d, want 2", len(report.Workloads))
+	}
+
+	web := report.Workloads[0]
+	if web.Kind != "Deployment" || web.Name != "web" || web.Pods != 2 {
+		t.Fatalf("first workload = %s/%s (%d pods), want Deployment/web with 2 pods", web.Kind, web.Name, web.Pods)
+	}
+	if web.CPUWasteMilli != 3800 || FormatMemory(web.MemoryWasteBytes) != "7Gi" {
+		t.Errorf("waste = %dm / %s, want 3800m / 7Gi", web.CPUWasteMilli, FormatMemory(web.MemoryWasteBytes))
+	}
+	if !strings.Contains(web.Containers[0].Suggested.YAML(), "memory: 768Mi") {
+		t.Errorf("unexpected suggestion:\n%s", web.Containers[0].Suggested.YAML())
+	}
+
+	tool := report.Workloads[1]
+	if tool.Kind != "Pod" || tool.Status != ProvisionOK {
+		t.Errorf("second workload = %s/%s (%s), want Pod/tool ok", tool.Kind, tool.Name, tool.Status)
+	}
+}
+
+func TestAnalyzer_AnalyzeResources_NoMetrics(t *testing.T) {
+	client := fake.NewSimpleClientset(newResourcePod("tool", nil, "100m", "128Mi", "256Mi"))
+
+	report, err := NewAnalyzer(client).AnalyzeResources("default")
+	if err != nil {
+		t.Fatalf("AnalyzeResources failed: %v", err)
+	}
+	if report.MetricsAvailable {
+		t.Error("metrics should be unavailable without a metrics client")
+	}
+	if len(report.Workloads) != 1 || report.Workloads[0].Status != ProvisionUnknown {
+		t.Errorf("workloads = %+v, want a single unknown workload", report.Workloads)
+	}
+}
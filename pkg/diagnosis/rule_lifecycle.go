@@ -0,0 +1,225 @@
+package diagnosis
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// -----------------------------------------------------------
+// LifecycleHookRule: 检测 postStart / preStop 钩子执行失败
+// postStart 失败会导致容器被杀死重启；preStop 失败会拖慢终止、跳过清理逻辑
+// -----------------------------------------------------------
+type LifecycleHookRule struct{}
+
+// hookFailureRe 解析 kubelet 记录的钩子失败消息，例如
+// Exec lifecycle hook ([/bin/sh -c exit 1]) for Container "app" in Pod "web_default(uid)" failed - error: command '/bin/sh -c exit 1' exited with 1: , message: "boom\n"
+var hookFailureRe = regexp.MustCompile(`(?s)^(Exec|HTTP|Sleep) lifecycle hook \((.*?)\) for Container "([^"]+)" in Pod "[^"]*" failed - error: (.*?)(?:, message: "(.*)")?$`)
+
+// hookFailure 是从事件中解析出的钩子失败详情
+type hookFailure struct {
+	Phase   string // postStart / preStop
+	Type    string // exec / httpGet / sleep
+	Target  string // 命令或 URL
+	Error   string // 失败原因
+	Output  string // 命令输出 (仅 exec)
+	Count   int32  // 事件累计次数
+	Message string // 原始事件消息
+}
+
+func (r *LifecycleHookRule) Name() string {
+	return "LifecycleHookRule"
+}
+
+// Check 没有事件时只能识别 PostStartHookError 等待状态
+func (r *LifecycleHookRule) Check(pod *corev1.Pod, container *corev1.Container, status corev1.ContainerStatus) CheckResult {
+	return r.CheckWithContext(&RuleContext{}, pod, container, status)
+}
+
+func (r *LifecycleHookRule) CheckWithContext(ctx *RuleContext, pod *corev1.Pod, container *corev1.Container, status corev1.ContainerStatus) CheckResult {
+	failure := findHookFailure(ctx.Events, status.Name, hookEventScope(pod, container, status))
+
+	// kubelet 在 postStart 失败时会把容器置为 PostStartHookError
+	if failure == nil && status.State.Waiting != nil && status.State.Waiting.Reason == "PostStartHookError" {
+		failure = &hookFailure{Phase: "postStart", Message: status.State.Waiting.Message}
+	}
+	if failure == nil {
+		return CheckResult{Matched: false}
+	}
+
+	// 以容器 spec 为准补全钩子类型与目标
+	if handler := lifecycleHandler(container, failure.Phase); handler != nil {
+		failure.Type, failure.Target = describeHook(pod, handler)
+	}
+
+	details := []string{}
+	if failure.Type != "" {
+		details = append(details, fmt.Sprintf("钩子: %s %s", failure.Type, failure.Target))
+	}
+	if failure.Error != "" {
+		details = append(details, "错误: "+failure.Error)
+	}
+	if failure.Output != "" {
+		details = append(details, "输出: "+failure.Output)
+	}
+	if len(details) == 0 {
+		details = append(details, failure.Message)
+	}
+	if failure.Count > 1 {
+		details = append(details, fmt.Sprintf("累计 %d 次", failure.Count))
+	}
+
+	if failure.Phase == "postStart" {
+		return CheckResult{
+			Matched: true,
+			Title:   "postStart 钩子执行失败 (FailedPostStartHook)",
+			RawError: strings.Join(details, " | ") +
+				fmt.Sprintf(" | 影响: 容器被 kubelet 杀死并重启 (当前 %s，已重启 %d 次)", containerStateName(status), status.RestartCount),
+			Suggestion: hookSuggestion(failure) + "；postStart 与 ENTRYPOINT 并发执行且不等待应用就绪，失败会导致容器被杀死，" +
+				"请在脚本中加入重试，或把初始化逻辑移到 initContainer",
+		}
+	}
+
+	return CheckResult{
+		Matched: true,
+		Title:   "preStop 钩子执行失败 (FailedPreStopHook)",
+		RawError: strings.Join(details, " | ") +
+			" | 影响: 容器仍会继续终止，但流量摘除 / 清理逻辑没有执行，且失败前的耗时计入宽限期",
+		Suggestion: hookSuggestion(failure) + "；请保证 preStop 的执行时间小于 terminationGracePeriodSeconds",
+	}
+}
+
+// hookEventScope 返回判断钩子失败事件是否属于容器当前状态的函数
+// 更早的事件 (之前的发布、之前的重启) 不能掩盖当前的崩溃循环或终止超时:
+// postStart 失败只接受最近一次启动之后的事件；preStop 失败只接受最近一次终止的宽限期内的事件
+func hookEventScope(pod *corev1.Pod, container *corev1.Container, status corev1.ContainerStatus) func(reason string, t time.Time) bool {
+	var startedAt time.Time
+	if status.State.Running != nil {
+		startedAt = status.State.Running.StartedAt.Time
+	} else if term := latestTermination(status); term != nil {
+		startedAt = term.StartedAt.Time
+	}
+
+	// 终止窗口: 容器已结束时为 [FinishedAt - 宽限期, FinishedAt]；Pod 正在删除时为删除开始之后
+	var stopFrom, stopTo time.Time
+	if term := latestTermination(status); term != nil && !term.FinishedAt.IsZero() {
+		grace := time.Duration(maxTerminationGracePeriod(pod, container)) * time.Second
+		stopFrom = term.FinishedAt.Add(-grace - terminationTimingSlack)
+		stopTo = term.FinishedAt.Add(terminationTimingSlack)
+	}
+	if status.State.Running != nil && pod.DeletionTimestamp != nil {
+		stopFrom, stopTo = pod.DeletionTimestamp.Add(-terminationTimingSlack), time.Time{}
+	}
+
+	return func(reason string, t time.Time) bool {
+		if reason == "FailedPostStartHook" {
+			return !startedAt.IsZero() && !t.Before(startedAt.Add(-terminationTimingSlack))
+		}
+		if stopFrom.IsZero() || t.Before(stopFrom) {
+			return false
+		}
+		return stopTo.IsZero() || !t.After(stopTo)
+	}
+}
+
+// findHookFailure 找到容器当前状态下 (inScope 为真) 最近一次钩子失败事件
+func findHookFailure(events []corev1.Event, containerName string, inScope func(reason string, t time.Time) bool) *hookFailure {
+	var latest *corev1.Event
+	for i := range events {
+		e := &events[i]
+		if e.Reason != "FailedPostStartHook" && e.Reason != "FailedPreStopHook" {
+			continue
+		}
+		if !eventForContainer(*e, containerName) || !inScope(e.Reason, EventTime(*e)) {
+			continue
+		}
+		if latest == nil || EventTime(*e).After(EventTime(*latest)) {
+			latest = e
+		}
+	}
+	if latest == nil {
+		return nil
+	}
+
+	failure := &hookFailure{Phase: "postStart", Count: latest.Count, Message: latest.Message}
+	if latest.Reason == "FailedPreStopHook" {
+		failure.Phase = "preStop"
+	}
+	if m := hookFailureRe.FindStringSubmatch(latest.Message); m != nil {
+		failure.Type = map[string]string{"Exec": "exec", "HTTP": "httpGet", "Sleep": "sleep"}[m[1]]
+		failure.Target = m[2]
+		failure.Error = strings.TrimSpace(m[4])
+		failure.Output = strings.TrimSpace(strings.ReplaceAll(m[5], `\n`, " "))
+	}
+	return failure
+}
+
+// lifecycleHandler 返回容器 spec 中对应阶段的钩子
+func lifecycleHandler(container *corev1.Container, phase string) *corev1.LifecycleHandler {
+	if container == nil || container.Lifecycle == nil {
+		return nil
+	}
+	if phase == "preStop" {
+		return container.Lifecycle.PreStop
+	}
+	return container.Lifecycle.PostStart
+}
+
+// describeHook 返回钩子类型与目标 (命令 / URL / 时长)
+func describeHook(pod *corev1.Pod, h *corev1.LifecycleHandler) (string, string) {
+	switch {
+	case h.Exec != nil:
+		return "exec", strings.Join(h.Exec.Command, " ")
+	case h.HTTPGet != nil:
+		scheme := strings.ToLower(string(h.HTTPGet.Scheme))
+		if scheme == "" {
+			scheme = "http"
+		}
+		host := h.HTTPGet.Host
+		if host == "" {
+			host = pod.Status.PodIP
+		}
+		if host == "" {
+			host = "<podIP>"
+		}
+		return "httpGet", fmt.Sprintf("%s://%s:%s%s", scheme, host, h.HTTPGet.Port.String(), h.HTTPGet.Path)
+	case h.Sleep != nil:
+		return "sleep", fmt.Sprintf("%ds", h.Sleep.Seconds)
+	case h.TCPSocket != nil:
+		return "tcpSocket", h.TCPSocket.Port.String()
+	}
+	return "unknown", ""
+}
+
+// hookSuggestion 根据钩子类型给出排查建议
+func hookSuggestion(f *hookFailure) string {
+	switch f.Type {
+	case "exec":
+		msg := "请确认命令在镜像中存在且可执行 (精简镜像中可能没有 sh / curl / sleep)，并检查上面的命令输出"
+		if f.Phase == "preStop" {
+			msg += "；仅为等待流量摘除时，可改用 K8s 1.30+ 的 sleep 动作"
+		}
+		return msg
+	case "httpGet":
+		return "请确认该端口与路径在钩子执行时已可访问，且返回 2xx 状态码"
+	case "sleep":
+		return "sleep 动作失败通常是时长超过了 terminationGracePeriodSeconds 或集群未开启 PodLifecycleSleepAction"
+	}
+	return "请检查容器 lifecycle 配置与 kubelet 事件详情"
+}
+
+// containerStateName 返回容器当前状态的简短描述
+func containerStateName(status corev1.ContainerStatus) string {
+	switch {
+	case status.State.Waiting != nil:
+		return "Waiting/" + status.State.Waiting.Reason
+	case status.State.Terminated != nil:
+		return "Terminated/" + status.State.Terminated.Reason
+	case status.State.Running != nil:
+		return "Running"
+	}
+	return "Unknown"
+}
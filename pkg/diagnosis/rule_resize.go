@@ -0,0 +1,210 @@
+package diagnosis
+
+import (
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// -----------------------------------------------------------
+// ResizeRule: 检测原地扩缩容 (In-Place Pod Resize) 未生效
+// 修改运行中 Pod 的 resources 后，kubelet 通过 PodResizePending / PodResizeInProgress
+// 两个 Condition 报告进度；旧版本集群使用已废弃的 status.resize 字段
+// -----------------------------------------------------------
+type ResizeRule struct{}
+
+// resizeChange 是容器 spec 与实际生效值之间的一项差异
+type resizeChange struct {
+	Resource corev1.ResourceName
+	Field    string // requests / limits
+	From     string // 实际生效值，未设置时为 "-"
+	To       string // spec 中的期望值，未设置时为 "-"
+}
+
+// resizeState 是 Pod 当前的扩缩容状态
+type resizeState struct {
+	Reason  string // Infeasible / Deferred / Error
+	Message string
+}
+
+func (r *ResizeRule) Name() string {
+	return "ResizeRule"
+}
+
+// Check 不依赖节点信息，无法给出节点容量对比
+func (r *ResizeRule) Check(pod *corev1.Pod, container *corev1.Container, status corev1.ContainerStatus) CheckResult {
+	return r.CheckWithContext(&RuleContext{}, pod, container, status)
+}
+
+func (r *ResizeRule) CheckWithContext(ctx *RuleContext, pod *corev1.Pod, container *corev1.Container, status corev1.ContainerStatus) CheckResult {
+	state := podResizeState(pod)
+	if state == nil || container == nil {
+		return CheckResult{Matched: false}
+	}
+
+	// 只报告期望值与实际值不一致的容器；kubelet 没有上报实际值时无法区分，按命中处理
+	changes := resizeChanges(container, status)
+	if status.Resources != nil && len(changes) == 0 {
+		return CheckResult{Matched: false}
+	}
+
+	details := []string{}
+	if state.Message != "" {
+		details = append(details, state.Message)
+	}
+	if len(changes) > 0 {
+		parts := make([]string, 0, len(changes))
+		for _, c := range changes {
+			parts = append(parts, fmt.Sprintf("%s %s %s -> %s", c.Resource, c.Field, c.From, c.To))
+		}
+		details = append(details, "未生效的变更: "+strings.Join(parts, ", "))
+	}
+
+	res := CheckResult{Matched: true, RawError: strings.Join(details, " | ")}
+	switch state.Reason {
+	case corev1.PodReasonInfeasible:
+		res.Title = "原地扩缩容无法执行 (Resize Infeasible)"
+		res.Suggestion = "kubelet 判定该节点永远无法满足新的资源配置 (例如 requests 超过节点可分配总量)，调整不会重试: " +
+			"1.降低期望的 requests 2.如确实需要更多资源，需重建 Pod 让调度器选择更大的节点"
+		if fit := nodeCapacityNote(ctx.Node, pod); fit != "" {
+			res.Suggestion = fit + "；" + res.Suggestion
+		}
+	case corev1.PodReasonDeferred:
+		res.Title = "原地扩缩容被推迟 (Resize Deferred)"
+		res.Suggestion = "节点容量足够，但当前空闲资源不足 (已被其他 Pod 占用)，kubelet 会在资源释放后自动重试: " +
+			"1.等待或迁走节点上的其他 Pod 2.降低本次调整幅度"
+	default:
+		res.Title = "原地扩缩容执行失败 (Resize Error)"
+		res.Suggestion = "kubelet 在应用新的资源配置时出错，请查看 kubelet 日志与容器运行时 (containerd / CRI-O) 是否支持原地扩缩容"
+	}
+
+	if note := resizePolicyNote(container, changes); note != "" {
+		res.Suggestion += "；" + note
+	}
+	return res
+}
+
+// podResizeState 读取 Pod 的扩缩容状态：优先使用 Condition，其次是废弃的 status.resize
+func podResizeState(pod *corev1.Pod) *resizeState {
+	for _, cond := range pod.Status.Conditions {
+		if cond.Status != corev1.ConditionTrue {
+			continue
+		}
+		if cond.Type == corev1.PodResizePending &&
+			(cond.Reason == corev1.PodReasonInfeasible || cond.Reason == corev1.PodReasonDeferred) {
+			return &resizeState{Reason: cond.Reason, Message: cond.Message}
+		}
+		if cond.Type == corev1.PodResizeInProgress && cond.Reason == corev1.PodReasonError {
+			return &resizeState{Reason: cond.Reason, Message: cond.Message}
+		}
+	}
+	// 兼容 1.33 之前的集群
+	switch pod.Status.Resize {
+	case corev1.PodResizeStatusInfeasible:
+		return &resizeState{Reason: corev1.PodReasonInfeasible}
+	case corev1.PodResizeStatusDeferred:
+		return &resizeState{Reason: corev1.PodReasonDeferred}
+	}
+	return nil
+}
+
+// resizeChanges 对比容器 spec 中的 resources 与 kubelet 上报的实际生效值
+func resizeChanges(container *corev1.Container, status corev1.ContainerStatus) []resizeChange {
+	actual := corev1.ResourceRequirements{Requests: status.AllocatedResources}
+	if status.Resources != nil {
+		actual = *status.Resources
+	}
+	if actual.Requests == nil && actual.Limits == nil {
+		return nil
+	}
+
+	var changes []resizeChange
+	for _, name := range []corev1.ResourceName{corev1.ResourceCPU, corev1.ResourceMemory} {
+		if c := diffQuantity(name, "requests", actual.Requests, container.Resources.Requests); c != nil {
+			changes = append(changes, *c)
+		}
+		// 只有 AllocatedResources 时无法得知实际的 limits
+		if status.Resources != nil {
+			if c := diffQuantity(name, "limits", actual.Limits, container.Resources.Limits); c != nil {
+				changes = append(changes, *c)
+			}
+		}
+	}
+	return changes
+}
+
+// diffQuantity 比较单项资源，相同时返回 nil
+func diffQuantity(name corev1.ResourceName, field string, actual, desired corev1.ResourceList) *resizeChange {
+	from, hasFrom := actual[name]
+	to, hasTo := desired[name]
+	if hasFrom == hasTo && from.Cmp(to) == 0 {
+		return nil
+	}
+	return &resizeChange{Resource: name, Field: field, From: quantityOrDash(from, hasFrom), To: quantityOrDash(to, hasTo)}
+}
+
+func quantityOrDash(q resource.Quantity, ok bool) string {
+	if !ok {
+		return "-"
+	}
+	return q.String()
+}
+
+// resizePolicyNote 根据容器的 resizePolicy 说明变更生效时是否需要重启
+func resizePolicyNote(container *corev1.Container, changes []resizeChange) string {
+	var restart, inPlace []string
+	seen := map[corev1.ResourceName]bool{}
+	for _, c := range changes {
+		if seen[c.Resource] {
+			continue
+		}
+		seen[c.Resource] = true
+		if resizeRestartPolicy(container, c.Resource) == corev1.RestartContainer {
+			restart = append(restart, string(c.Resource))
+		} else {
+			inPlace = append(inPlace, string(c.Resource))
+		}
+	}
+
+	var notes []string
+	if len(restart) > 0 {
+		notes = append(notes, fmt.Sprintf("%s 的 resizePolicy 为 RestartContainer，生效时容器会被重启", strings.Join(restart, "/")))
+	}
+	if len(inPlace) > 0 {
+		notes = append(notes, fmt.Sprintf("%s 的 resizePolicy 为 NotRequired，生效时无需重启容器", strings.Join(inPlace, "/")))
+	}
+	return strings.Join(notes, "；")
+}
+
+// resizeRestartPolicy 返回资源的 resizePolicy，未配置时默认为 NotRequired
+func resizeRestartPolicy(container *corev1.Container, name corev1.ResourceName) corev1.ResourceResizeRestartPolicy {
+	for _, p := range container.ResizePolicy {
+		if p.ResourceName == name {
+			return p.RestartPolicy
+		}
+	}
+	return corev1.NotRequired
+}
+
+// nodeCapacityNote 对比 Pod 期望的 requests 总量与节点可分配资源
+func nodeCapacityNote(node *corev1.Node, pod *corev1.Pod) string {
+	if node == nil {
+		return ""
+	}
+	var notes []string
+	for _, name := range []corev1.ResourceName{corev1.ResourceCPU, corev1.ResourceMemory} {
+		total := resource.Quantity{}
+		for _, c := range pod.Spec.Containers {
+			if q, ok := c.Resources.Requests[name]; ok {
+				total.Add(q)
+			}
+		}
+		alloc, ok := node.Status.Allocatable[name]
+		if ok && total.Cmp(alloc) > 0 {
+			notes = append(notes, fmt.Sprintf("%s requests 合计 %s 超过节点 %s 可分配的 %s", name, total.String(), node.Name, alloc.String()))
+		}
+	}
+	return strings.Join(notes, "，")
+}
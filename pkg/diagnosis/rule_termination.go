@@ -0,0 +1,187 @@
+package diagnosis
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// -----------------------------------------------------------
+// TerminationRule: 区分 "宽限期超时被 SIGKILL" / "探针失败被重启" / OOM
+// 退出码 137 只说明进程收到了 SIGKILL，并不一定是内存溢出
+// -----------------------------------------------------------
+type TerminationRule struct{}
+
+const (
+	// defaultTerminationGracePeriod 是 Pod 未设置 terminationGracePeriodSeconds 时的默认值
+	defaultTerminationGracePeriod int64 = 30
+	// terminationTimingSlack 事件与容器状态的时间戳只精确到秒，比较时留出余量
+	terminationTimingSlack = 2 * time.Second
+)
+
+// probeKillRe 匹配 kubelet 因探针失败重启容器时的 Killing 事件，例如
+// "Container app failed liveness probe, will be restarted"
+var probeKillRe = regexp.MustCompile(`failed (liveness|startup) probe`)
+
+func (r *TerminationRule) Name() string {
+	return "TerminationRule"
+}
+
+// Check 没有事件就无法判断终止原因，交给其他规则处理
+func (r *TerminationRule) Check(pod *corev1.Pod, container *corev1.Container, status corev1.ContainerStatus) CheckResult {
+	return CheckResult{Matched: false}
+}
+
+func (r *TerminationRule) CheckWithContext(ctx *RuleContext, pod *corev1.Pod, container *corev1.Container, status corev1.ContainerStatus) CheckResult {
+	termState := latestTermination(status)
+	// OOMKilled 由 OOMRule 处理；没有结束时间就无法把事件与这次终止对应起来
+	if termState == nil || termState.Reason == "OOMKilled" || termState.FinishedAt.IsZero() {
+		return CheckResult{Matched: false}
+	}
+
+	// 只有宽限期内的 Killing 事件才属于这次终止，更早的事件 (之前的发布、之前的重启) 与本次退出无关
+	window := time.Duration(maxTerminationGracePeriod(pod, container)) * time.Second
+	kill := findKillingEvent(ctx.Events, status.Name, termState.FinishedAt.Time, window)
+	if kill == nil {
+		return CheckResult{Matched: false}
+	}
+	elapsed := termState.FinishedAt.Sub(EventTime(*kill))
+
+	// 1. 探针失败：kubelet 主动重启容器
+	if m := probeKillRe.FindStringSubmatch(kill.Message); m != nil {
+		probeName, title := "liveness", "存活探针失败被重启 (Liveness Probe Kill)"
+		if m[1] == "startup" {
+			probeName, title = "startup", "启动探针失败被重启 (Startup Probe Kill)"
+		}
+		grace := terminationGracePeriod(pod, probeOf(container, probeName))
+		if elapsed > time.Duration(grace)*time.Second+terminationTimingSlack {
+			return CheckResult{Matched: false}
+		}
+
+		res := CheckResult{
+			Matched:  true,
+			Title:    title,
+			RawError: fmt.Sprintf("%s | 退出码 %d", kill.Message, termState.ExitCode),
+			Suggestion: "容器是被 kubelet 因探针失败主动重启的，并非 OOM；请检查探针的 path/port、timeoutSeconds 与 failureThreshold，" +
+				"启动较慢的应用请配置 startupProbe",
+		}
+		if termState.ExitCode == 137 {
+			res.RawError += fmt.Sprintf(" (收到 SIGTERM 后 %ds 宽限期内未退出，被 SIGKILL)", grace)
+			res.Suggestion += "；此外应用没有响应 SIGTERM，请在代码中捕获 SIGTERM 并尽快退出"
+		}
+		return res
+	}
+
+	// 2. 普通停止 (删除 / 滚动更新 / 驱逐)：只关心最终被 SIGKILL 的情况
+	if termState.ExitCode != 137 {
+		return CheckResult{Matched: false}
+	}
+	grace := terminationGracePeriod(pod, nil)
+	graceDuration := time.Duration(grace) * time.Second
+	if elapsed > graceDuration+terminationTimingSlack {
+		return CheckResult{Matched: false}
+	}
+
+	if elapsed+terminationTimingSlack < graceDuration {
+		return CheckResult{
+			Matched: true,
+			Title:   "容器被提前 SIGKILL (未等满宽限期)",
+			RawError: fmt.Sprintf("Killing 事件后 %.0fs 容器即被 SIGKILL，而 terminationGracePeriodSeconds=%d，退出码 137 并非 OOM",
+				elapsed.Seconds(), grace),
+			Suggestion: "可能使用了 kubectl delete --force / --grace-period=0，或节点资源压力导致的驱逐；请检查操作记录与节点事件",
+		}
+	}
+
+	suggestion := "应用未在宽限期内响应 SIGTERM: 1.确认业务进程以 PID 1 运行并捕获 SIGTERM " +
+		"(Dockerfile 使用 exec 形式的 ENTRYPOINT，或用 tini/dumb-init 转发信号) " +
+		"2.如需等待流量摘除，使用 preStop hook (例如 sleep 5)，并保证 preStop + 应用关闭耗时 < terminationGracePeriodSeconds " +
+		"3.确实需要更长的清理时间时，调大 terminationGracePeriodSeconds"
+	if container != nil && container.Lifecycle != nil && container.Lifecycle.PreStop != nil {
+		hookType, target := describeHook(pod, container.Lifecycle.PreStop)
+		suggestion += fmt.Sprintf("；注意已配置 preStop hook (%s: %s)，它的执行时间同样计入宽限期", hookType, target)
+	}
+
+	return CheckResult{
+		Matched: true,
+		Title:   "优雅终止超时 (SIGTERM 后被 SIGKILL)",
+		RawError: fmt.Sprintf("Killing 事件后 %.0fs 容器才结束 (terminationGracePeriodSeconds=%d)，退出码 137 并非 OOM",
+			elapsed.Seconds(), grace),
+		Suggestion: suggestion,
+	}
+}
+
+// findKillingEvent 找到容器结束前 window 内最近的一条 Killing 事件
+func findKillingEvent(events []corev1.Event, containerName string, finishedAt time.Time, window time.Duration) *corev1.Event {
+	var found *corev1.Event
+	for i := range events {
+		e := &events[i]
+		if e.Reason != "Killing" || !eventForContainer(*e, containerName) {
+			continue
+		}
+		t := EventTime(*e)
+		if t.After(finishedAt.Add(terminationTimingSlack)) {
+			continue // 这是之后的一次终止
+		}
+		if t.Before(finishedAt.Add(-window - terminationTimingSlack)) {
+			continue // 早于这次终止的宽限期，与本次退出无关
+		}
+		if found == nil || t.After(EventTime(*found)) {
+			found = e
+		}
+	}
+	return found
+}
+
+// eventForContainer 判断事件是否属于指定容器
+func eventForContainer(e corev1.Event, containerName string) bool {
+	if e.InvolvedObject.FieldPath != "" {
+		return e.InvolvedObject.FieldPath == fmt.Sprintf("spec.containers{%s}", containerName)
+	}
+	// 消息中的容器名可能带引号，例如 `for Container "app" in Pod`
+	msg := strings.ToLower(e.Message)
+	name := strings.ToLower(containerName)
+	return strings.Contains(msg, "container "+name+" ") ||
+		strings.HasSuffix(msg, "container "+name) ||
+		strings.Contains(msg, `container "`+name+`"`)
+}
+
+// terminationGracePeriod 返回实际生效的宽限期 (秒)
+// 探针级别的 terminationGracePeriodSeconds 优先于 Pod 级别
+func terminationGracePeriod(pod *corev1.Pod, probe *corev1.Probe) int64 {
+	if probe != nil && probe.TerminationGracePeriodSeconds != nil {
+		return *probe.TerminationGracePeriodSeconds
+	}
+	if pod.DeletionGracePeriodSeconds != nil {
+		return *pod.DeletionGracePeriodSeconds
+	}
+	if pod.Spec.TerminationGracePeriodSeconds != nil {
+		return *pod.Spec.TerminationGracePeriodSeconds
+	}
+	return defaultTerminationGracePeriod
+}
+
+// maxTerminationGracePeriod 返回 Pod 与存活 / 启动探针中最长的宽限期 (秒)
+// 用来界定一次终止的时间范围: 终止原因未知时，按最长的宽限期计算
+func maxTerminationGracePeriod(pod *corev1.Pod, container *corev1.Container) int64 {
+	grace := terminationGracePeriod(pod, nil)
+	for _, probeName := range []string{"liveness", "startup"} {
+		if g := terminationGracePeriod(pod, probeOf(container, probeName)); g > grace {
+			grace = g
+		}
+	}
+	return grace
+}
+
+// probeOf 返回容器对应类型的探针
+func probeOf(container *corev1.Container, probeName string) *corev1.Probe {
+	if container == nil {
+		return nil
+	}
+	if probeName == "startup" {
+		return container.StartupProbe
+	}
+	return container.LivenessProbe
+}
@@ -2,6 +2,8 @@ package diagnosis
 
 import (
 	"fmt"
+	"strings"
+	"time"
 
 	corev1 "k8s.io/api/core/v1"
 )
@@ -18,19 +20,8 @@ func (r *OOMRule) Name() string {
 func (r *OOMRule) Check(pod *corev1.Pod, container *corev1.Container, status corev1.ContainerStatus) CheckResult {
 	// 无论是 Waiting 还是 Terminated，都要检查 LastTerminationState
 
-	var termState *corev1.ContainerStateTerminated
-
-	// 检查当前是否 Terminated
-	if status.State.Terminated != nil {
-		termState = status.State.Terminated
-	}
-	// 检查上次是否 Terminated (LastTerminationState)
-	if status.LastTerminationState.Terminated != nil {
-		// 优先取最新的状态，但如果当前是 Waiting，我们就看上一次
-		if termState == nil {
-			termState = status.LastTerminationState.Terminated
-		}
-	}
+	// 优先取当前状态，如果当前是 Waiting，我们就看上一次
+	termState := latestTermination(status)
 
 	// 如果根本没有终止记录，直接跳过
 	if termState == nil {
@@ -47,12 +38,7 @@ func (r *OOMRule) Check(pod *corev1.Pod, container *corev1.Container, status cor
 
 		// 资源建议
 		if container != nil {
-			limit := container.Resources.Limits.Memory()
-			if !limit.IsZero() {
-				res.Suggestion = fmt.Sprintf("检测到内存限制 Limit=%s，建议适当调大", limit.String())
-			} else {
-				res.Suggestion = "未设置内存限制，建议设置 Limits 防止节点资源耗尽"
-			}
+			res.Suggestion = oomLimitSuggestion(container, nil)
 		}
 		return res
 	}
@@ -60,6 +46,142 @@ func (r *OOMRule) Check(pod *corev1.Pod, container *corev1.Container, status cor
 	return CheckResult{Matched: false}
 }
 
+// CheckWithContext 结合节点事件区分两种 OOM:
+// 容器超出自身 limit 的 cgroup OOM，以及节点整体内存耗尽时被内核选中的 System OOM
+func (r *OOMRule) CheckWithContext(ctx *RuleContext, pod *corev1.Pod, container *corev1.Container, status corev1.ContainerStatus) CheckResult {
+	termState := latestTermination(status)
+	if termState == nil {
+		return CheckResult{Matched: false}
+	}
+
+	sysOOM := findSystemOOM(ctx.NodeEvents, termState.FinishedAt.Time)
+	// 没有 OOMKilled 标记时，只有 137 + 节点 SystemOOM 才算 OOM
+	if termState.Reason != "OOMKilled" && (termState.ExitCode != 137 || sysOOM == nil) {
+		return CheckResult{Matched: false}
+	}
+
+	if sysOOM == nil {
+		res := r.Check(pod, container, status)
+		if res.Matched && container != nil {
+			res.Suggestion = oomLimitSuggestion(container, ContainerUsage(ctx.Metrics, status.Name))
+		}
+		if res.Matched && ctx.Node != nil {
+			res.Suggestion = "容器超出了自身内存限制 (cgroup OOM)，同期节点未发生系统级 OOM；" + res.Suggestion
+		}
+		return res
+	}
+
+	evidence := []string{fmt.Sprintf("节点 %s 发生 SystemOOM: %s", pod.Spec.NodeName, sysOOM.Message)}
+	if pressure := memoryPressureEvidence(ctx, termState.FinishedAt.Time); pressure != "" {
+		evidence = append(evidence, pressure)
+	}
+	evidence = append(evidence, fmt.Sprintf("Exit Code: %s, Reason: %s", ExplainExitCode(termState.ExitCode), termState.Reason))
+
+	limitNote := "容器未设置内存 limit，只可能是节点内存耗尽导致"
+	if container != nil {
+		if limit := container.Resources.Limits.Memory(); !limit.IsZero() {
+			limitNote = fmt.Sprintf("容器 limit=%s，被杀时节点同时发生了 SystemOOM，大概率并非超出自身限制", limit.String())
+		}
+	}
+
+	return CheckResult{
+		Matched:  true,
+		Title:    systemOOMTitle,
+		RawError: strings.Join(evidence, " | "),
+		Suggestion: limitNote + "；容器是节点整体内存耗尽时被内核选中的受害者，单纯调大该容器的 limit 无济于事: " +
+			"1.为节点上所有 Pod 设置合理的 memory requests，避免节点超卖 " +
+			"2.检查 kubelet 的 kube-reserved / system-reserved 与 evictionHard (memory.available)，让驱逐先于内核 OOM 发生 " +
+			"3.关键业务使用 Guaranteed QoS (requests = limits)，降低被 OOM Killer 选中的概率",
+	}
+}
+
+// oomLimitSuggestion 给出具体的内存 limit 建议
+// 触发 cgroup OOM 只说明使用量触及了 limit，并不知道真实峰值，因此以 limit 为基准给出建议；
+// usage 为 metrics-server 报告的重启后当前使用量 (可以为 nil)，只有超过 limit 时才作为基准
+func oomLimitSuggestion(container *corev1.Container, usage corev1.ResourceList) string {
+	limit := container.Resources.Limits.Memory()
+	var current int64
+	if usage != nil {
+		current = usage.Memory().Value()
+	}
+
+	if limit.IsZero() {
+		if current == 0 {
+			return "未设置内存限制，建议设置 Limits 防止节点资源耗尽"
+		}
+		return fmt.Sprintf("未设置内存限制，当前使用 ≈ %s，建议设置 limit 为 %s 防止节点资源耗尽",
+			FormatMemory(current), FormatMemory(SuggestMemoryLimit(current)))
+	}
+
+	if current > limit.Value() {
+		return fmt.Sprintf("当前使用 ≈ %s，已超过 limit %s，建议将 limit 调整为 %s",
+			FormatMemory(current), limit.String(), FormatMemory(SuggestMemoryLimit(current)))
+	}
+	currentNote := ""
+	if current > 0 {
+		currentNote = fmt.Sprintf(" (重启后当前使用 ≈ %s)", FormatMemory(current))
+	}
+	return fmt.Sprintf("内存使用触及 limit %s 后被杀%s，建议将 limit 调整为 %s",
+		limit.String(), currentNote, FormatMemory(SuggestMemoryLimit(limit.Value())))
+}
+
+// systemOOMTitle 是节点级 OOM 的标题 (与 "内存溢出 (OOMKilled)" 一样视为 Error)
+const systemOOMTitle = "节点内存耗尽 (System OOM) 导致容器被杀"
+
+// oomEventWindow 节点事件与容器终止时间的最大间隔
+const oomEventWindow = 2 * time.Minute
+
+// latestTermination 返回容器最近一次终止状态：优先当前状态，其次 LastTerminationState
+func latestTermination(status corev1.ContainerStatus) *corev1.ContainerStateTerminated {
+	if status.State.Terminated != nil {
+		return status.State.Terminated
+	}
+	return status.LastTerminationState.Terminated
+}
+
+// findSystemOOM 找到容器终止时间附近的 SystemOOM 节点事件
+func findSystemOOM(events []corev1.Event, finishedAt time.Time) *corev1.Event {
+	if finishedAt.IsZero() {
+		return nil
+	}
+	for i := range events {
+		if events[i].Reason == "SystemOOM" && withinWindow(EventTime(events[i]), finishedAt, oomEventWindow) {
+			return &events[i]
+		}
+	}
+	return nil
+}
+
+// memoryPressureEvidence 描述终止时间附近节点的内存压力
+func memoryPressureEvidence(ctx *RuleContext, finishedAt time.Time) string {
+	for _, e := range ctx.NodeEvents {
+		if (e.Reason == "NodeHasInsufficientMemory" || e.Reason == "EvictionThresholdMet") &&
+			withinWindow(EventTime(e), finishedAt, oomEventWindow) {
+			return fmt.Sprintf("节点内存压力: %s (%s)", e.Reason, e.Message)
+		}
+	}
+	if ctx.Node != nil {
+		for _, cond := range ctx.Node.Status.Conditions {
+			if cond.Type != corev1.NodeMemoryPressure {
+				continue
+			}
+			if cond.Status == corev1.ConditionTrue {
+				return "节点当前处于 MemoryPressure 状态"
+			}
+			if withinWindow(cond.LastTransitionTime.Time, finishedAt, oomEventWindow) {
+				return "节点在终止前后刚解除 MemoryPressure"
+			}
+		}
+	}
+	return ""
+}
+
+// withinWindow 判断两个时间的间隔是否在 window 之内
+func withinWindow(t, ref time.Time, window time.Duration) bool {
+	d := t.Sub(ref)
+	return d >= -window && d <= window
+}
+
 // -----------------------------------------------------------
 // ImagePullRule: 检测镜像拉取失败
 // -----------------------------------------------------------
@@ -1,10 +1,14 @@
 package diagnosis
 
 import (
+	"strings"
 	"testing"
+	"time"
 
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
 func TestOOMRule_Check(t *testing.T) {
@@ -73,3 +77,500 @@ func TestOOMRule_Check(t *testing.T) {
 		})
 	}
 }
+
+func TestTerminationRule_CheckWithContext(t *testing.T) {
+	rule := &TerminationRule{}
+	finished := time.Now().Add(-time.Minute)
+	grace := int64(30)
+
+	killing := func(msg string, before time.Duration) corev1.Event {
+		return corev1.Event{
+			Reason:         "Killing",
+			Message:        msg,
+			InvolvedObject: corev1.ObjectReference{FieldPath: "spec.containers{app}"},
+			LastTimestamp:  metav1.NewTime(finished.Add(-before)),
+		}
+	}
+	terminated := func(reason string, code int32) corev1.ContainerStatus {
+		return corev1.ContainerStatus{
+			Name: "app",
+			State: corev1.ContainerState{
+				Waiting: &corev1.ContainerStateWaiting{Reason: "CrashLoopBackOff"},
+			},
+			LastTerminationState: corev1.ContainerState{
+				Terminated: &corev1.ContainerStateTerminated{
+					Reason: reason, ExitCode: code, FinishedAt: metav1.NewTime(finished),
+				},
+			},
+		}
+	}
+
+	tests := []struct {
+		name      string
+		status    corev1.ContainerStatus
+		events    []corev1.Event
+		wantTitle string // 为空表示不应命中
+	}{
+		{
+			name:      "SIGTERM 被忽略，宽限期结束后 SIGKILL",
+			status:    terminated("Error", 137),
+			events:    []corev1.Event{killing("Stopping container app", 30*time.Second)},
+			wantTitle: "优雅终止超时 (SIGTERM 后被 SIGKILL)",
+		},
+		{
+			name:      "存活探针失败被重启",
+			status:    terminated("Error", 137),
+			events:    []corev1.Event{killing("Container app failed liveness probe, will be restarted", 30*time.Second)},
+			wantTitle: "存活探针失败被重启 (Liveness Probe Kill)",
+		},
+		{
+			name:      "强制删除，未等满宽限期",
+			status:    terminated("Error", 137),
+			events:    []corev1.Event{killing("Stopping container app", 0)},
+			wantTitle: "容器被提前 SIGKILL (未等满宽限期)",
+		},
+		{
+			name:   "响应 SIGTERM 正常退出",
+			status: terminated("Error", 143),
+			events: []corev1.Event{killing("Stopping container app", 3*time.Second)},
+		},
+		{
+			name:   "OOMKilled 交给 OOMRule",
+			status: terminated("OOMKilled", 137),
+			events: []corev1.Event{killing("Stopping container app", 30*time.Second)},
+		},
+		{
+			name:   "很久之前的 Killing 事件与本次退出无关",
+			status: terminated("Error", 137),
+			events: []corev1.Event{killing("Stopping container app", 3*time.Hour)},
+		},
+		{
+			name:   "很久之前的探针 Killing 事件与本次退出无关",
+			status: terminated("Error", 137),
+			events: []corev1.Event{killing("Container app failed liveness probe, will be restarted", time.Hour)},
+		},
+		{
+			name:   "没有 Killing 事件",
+			status: terminated("Error", 137),
+		},
+		{
+			name:   "其他容器的 Killing 事件",
+			status: terminated("Error", 137),
+			events: []corev1.Event{{
+				Reason: "Killing", Message: "Stopping container sidecar",
+				InvolvedObject: corev1.ObjectReference{FieldPath: "spec.containers{sidecar}"},
+				LastTimestamp:  metav1.NewTime(finished.Add(-30 * time.Second)),
+			}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pod := &corev1.Pod{Spec: corev1.PodSpec{TerminationGracePeriodSeconds: &grace}}
+			container := &corev1.Container{Name: "app"}
+
+			res := rule.CheckWithContext(&RuleContext{Events: tt.events}, pod, container, tt.status)
+			if tt.wantTitle == "" {
+				if res.Matched {
+					t.Errorf("expected no match, got %q", res.Title)
+				}
+				return
+			}
+			if !res.Matched || res.Title != tt.wantTitle {
+				t.Errorf("got matched=%v title=%q, want %q", res.Matched, res.Title, tt.wantTitle)
+			}
+		})
+	}
+}
+
+func TestTerminationRule_PreStopSuggestion(t *testing.T) {
+	finished := time.Now()
+	pod := &corev1.Pod{}
+	container := &corev1.Container{
+		Name: "app",
+		Lifecycle: &corev1.Lifecycle{
+			PreStop: &corev1.LifecycleHandler{Sleep: &corev1.SleepAction{Seconds: 20}},
+		},
+	}
+	status := corev1.ContainerStatus{
+		Name: "app",
+		State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{
+			ExitCode: 137, FinishedAt: metav1.NewTime(finished),
+		}},
+	}
+	events := []corev1.Event{{
+		Reason: "Killing", Message: "Stopping container app",
+		LastTimestamp: metav1.NewTime(finished.Add(-30 * time.Second)),
+	}}
+
+	res := (&TerminationRule{}).CheckWithContext(&RuleContext{Events: events}, pod, container, status)
+	if !res.Matched || !strings.Contains(res.Suggestion, "preStop hook (sleep: 20s)") {
+		t.Errorf("expected preStop note in suggestion, got %+v", res)
+	}
+
+	// 没有上下文时不应命中 (退回由其他规则处理)
+	if (&TerminationRule{}).Check(pod, container, status).Matched {
+		t.Error("Check without context should not match")
+	}
+}
+
+func TestLifecycleHookRule_CheckWithContext(t *testing.T) {
+	rule := &LifecycleHookRule{}
+	now := time.Now()
+
+	hookEvent := func(reason, msg string) corev1.Event {
+		return corev1.Event{
+			Reason:         reason,
+			Message:        msg,
+			Count:          3,
+			InvolvedObject: corev1.ObjectReference{FieldPath: "spec.containers{app}"},
+			LastTimestamp:  metav1.NewTime(now),
+		}
+	}
+	staleEvent := func(reason, msg string) corev1.Event {
+		e := hookEvent(reason, msg)
+		e.LastTimestamp = metav1.NewTime(now.Add(-2 * time.Hour))
+		return e
+	}
+	// 最近一次运行: 10s 前启动，5s 后结束 (事件时间 now 落在这次运行内)
+	crashing := corev1.ContainerStatus{
+		Name:         "app",
+		RestartCount: 3,
+		State:        corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "CrashLoopBackOff"}},
+		LastTerminationState: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{
+			ExitCode: 1, StartedAt: metav1.NewTime(now.Add(-10 * time.Second)), FinishedAt: metav1.NewTime(now.Add(5 * time.Second)),
+		}},
+	}
+	running := corev1.ContainerStatus{Name: "app", State: corev1.ContainerState{
+		Running: &corev1.ContainerStateRunning{StartedAt: metav1.NewTime(now.Add(-time.Hour))},
+	}}
+	deleting := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{DeletionTimestamp: &metav1.Time{Time: now.Add(-time.Second)}}}
+	preStopMsg := `HTTP lifecycle hook (/shutdown) for Container "app" in Pod "web_default(123)" failed - error: Get "http://10.0.0.5:8080/shutdown": dial tcp 10.0.0.5:8080: connect: connection refused`
+
+	tests := []struct {
+		name       string
+		pod        *corev1.Pod // 为空时使用空 Pod
+		container  *corev1.Container
+		status     corev1.ContainerStatus
+		events     []corev1.Event
+		wantTitle  string
+		wantInfo   []string // RawError 中应包含的内容
+		wantSuffix string   // Suggestion 中应包含的内容
+	}{
+		{
+			name: "postStart exec 失败",
+			container: &corev1.Container{Name: "app", Lifecycle: &corev1.Lifecycle{
+				PostStart: &corev1.LifecycleHandler{Exec: &corev1.ExecAction{Command: []string{"/bin/sh", "-c", "/init.sh"}}},
+			}},
+			status: crashing,
+			events: []corev1.Event{hookEvent("FailedPostStartHook",
+				`Exec lifecycle hook ([/bin/sh -c /init.sh]) for Container "app" in Pod "web_default(123)" failed - error: command '/bin/sh -c /init.sh' exited with 127: , message: "/bin/sh: /init.sh: not found\n"`)},
+			wantTitle: "postStart 钩子执行失败 (FailedPostStartHook)",
+			wantInfo: []string{
+				"钩子: exec /bin/sh -c /init.sh",
+				"输出: /bin/sh: /init.sh: not found",
+				"容器被 kubelet 杀死并重启 (当前 Waiting/CrashLoopBackOff，已重启 3 次)",
+			},
+			wantSuffix: "initContainer",
+		},
+		{
+			name: "preStop httpGet 失败",
+			container: &corev1.Container{Name: "app", Lifecycle: &corev1.Lifecycle{
+				PreStop: &corev1.LifecycleHandler{HTTPGet: &corev1.HTTPGetAction{Path: "/shutdown", Port: intstr.FromInt32(8080)}},
+			}},
+			pod:       deleting,
+			status:    running,
+			events:    []corev1.Event{hookEvent("FailedPreStopHook", preStopMsg)},
+			wantTitle: "preStop 钩子执行失败 (FailedPreStopHook)",
+			wantInfo: []string{
+				"钩子: httpGet http://<podIP>:8080/shutdown",
+				"connection refused",
+				"累计 3 次",
+				"容器仍会继续终止",
+			},
+			wantSuffix: "2xx",
+		},
+		{
+			name:      "preStop 失败发生在最近一次终止的宽限期内",
+			container: &corev1.Container{Name: "app"},
+			status:    crashing,
+			events:    []corev1.Event{hookEvent("FailedPreStopHook", preStopMsg)},
+			wantTitle: "preStop 钩子执行失败 (FailedPreStopHook)",
+			wantInfo:  []string{"connection refused"},
+		},
+		{
+			name:      "之前终止时的 preStop 失败不属于当前的崩溃循环",
+			container: &corev1.Container{Name: "app"},
+			status:    crashing,
+			events:    []corev1.Event{staleEvent("FailedPreStopHook", preStopMsg)},
+		},
+		{
+			name:      "运行中且未删除的容器不关心 preStop 事件",
+			container: &corev1.Container{Name: "app"},
+			status:    running,
+			events:    []corev1.Event{hookEvent("FailedPreStopHook", preStopMsg)},
+		},
+		{
+			name:      "最近一次启动之前的 postStart 失败",
+			container: &corev1.Container{Name: "app"},
+			status:    running,
+			events: []corev1.Event{staleEvent("FailedPostStartHook",
+				`Exec lifecycle hook ([/init.sh]) for Container "app" in Pod "web_default(123)" failed - error: x, message: ""`)},
+		},
+		{
+			name:      "没有事件但处于 PostStartHookError",
+			container: &corev1.Container{Name: "app"},
+			status: corev1.ContainerStatus{Name: "app", State: corev1.ContainerState{
+				Waiting: &corev1.ContainerStateWaiting{Reason: "PostStartHookError", Message: "hook failed"},
+			}},
+			wantTitle: "postStart 钩子执行失败 (FailedPostStartHook)",
+			wantInfo:  []string{"hook failed"},
+		},
+		{
+			name:      "其他容器的钩子失败",
+			container: &corev1.Container{Name: "app"},
+			status:    crashing,
+			events: []corev1.Event{{
+				Reason: "FailedPreStopHook", Message: `Exec lifecycle hook ([sleep 5]) for Container "sidecar" in Pod "web_default(123)" failed - error: x, message: ""`,
+			}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pod := tt.pod
+			if pod == nil {
+				pod = &corev1.Pod{}
+			}
+			res := rule.CheckWithContext(&RuleContext{Events: tt.events}, pod, tt.container, tt.status)
+			if tt.wantTitle == "" {
+				if res.Matched {
+					t.Errorf("expected no match, got %q", res.Title)
+				}
+				return
+			}
+			if !res.Matched || res.Title != tt.wantTitle {
+				t.Fatalf("got matched=%v title=%q, want %q", res.Matched, res.Title, tt.wantTitle)
+			}
+			for _, info := range tt.wantInfo {
+				if !strings.Contains(res.RawError, info) {
+					t.Errorf("RawError %q should contain %q", res.RawError, info)
+				}
+			}
+			if !strings.Contains(res.Suggestion, tt.wantSuffix) {
+				t.Errorf("Suggestion %q should contain %q", res.Suggestion, tt.wantSuffix)
+			}
+		})
+	}
+}
+
+func TestRuleEngine_StaleHookEventDoesNotHideCrashLoop(t *testing.T) {
+	now := time.Now()
+	status := corev1.ContainerStatus{
+		Name:         "app",
+		RestartCount: 5,
+		State:        corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "CrashLoopBackOff"}},
+		LastTerminationState: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{
+			ExitCode: 1, Reason: "Error",
+			StartedAt: metav1.NewTime(now.Add(-time.Minute)), FinishedAt: metav1.NewTime(now.Add(-50 * time.Second)),
+		}},
+	}
+	// 上一次发布时留下的 preStop 失败事件
+	events := []corev1.Event{{
+		Reason:         "FailedPreStopHook",
+		Message:        `Exec lifecycle hook ([sleep 5]) for Container "app" in Pod "web_default(123)" failed - error: x, message: ""`,
+		InvolvedObject: corev1.ObjectReference{FieldPath: "spec.containers{app}"},
+		LastTimestamp:  metav1.NewTime(now.Add(-3 * time.Hour)),
+	}}
+
+	res := NewRuleEngine().RunWithContext(&RuleContext{Events: events}, &corev1.Pod{}, &corev1.Container{Name: "app"}, status)
+	if res == nil || res.Title != "容器反复重启 (CrashLoopBackOff)" {
+		t.Errorf("expected crash loop diagnosis, got %+v", res)
+	}
+}
+
+func TestOOMRule_CheckWithContext(t *testing.T) {
+	rule := &OOMRule{}
+	finished := time.Now().Add(-5 * time.Minute)
+
+	systemOOM := corev1.Event{
+		Reason:        "SystemOOM",
+		Message:       "System OOM encountered, victim process: java, pid: 4242",
+		LastTimestamp: metav1.NewTime(finished.Add(-20 * time.Second)),
+	}
+	pressure := corev1.Event{
+		Reason:        "NodeHasInsufficientMemory",
+		Message:       "Node node-1 status is now: NodeHasInsufficientMemory",
+		LastTimestamp: metav1.NewTime(finished.Add(-time.Minute)),
+	}
+	staleOOM := systemOOM
+	staleOOM.LastTimestamp = metav1.NewTime(finished.Add(-time.Hour))
+
+	terminated := func(reason string) corev1.ContainerStatus {
+		return corev1.ContainerStatus{
+			Name: "app",
+			LastTerminationState: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{
+				Reason: reason, ExitCode: 137, FinishedAt: metav1.NewTime(finished),
+			}},
+		}
+	}
+	limited := &corev1.Container{Name: "app", Resources: corev1.ResourceRequirements{
+		Limits: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("512Mi")},
+	}}
+
+	tests := []struct {
+		name       string
+		status     corev1.ContainerStatus
+		nodeEvents []corev1.Event
+		wantTitle  string
+		wantText   string // RawError + Suggestion 中应包含的内容
+	}{
+		{
+			name:      "超出自身 limit (cgroup OOM)",
+			status:    terminated("OOMKilled"),
+			wantTitle: "内存溢出 (OOMKilled)",
+			wantText:  "cgroup OOM",
+		},
+		{
+			name:       "OOMKilled 但同期节点 SystemOOM",
+			status:     terminated("OOMKilled"),
+			nodeEvents: []corev1.Event{systemOOM, pressure},
+			wantTitle:  systemOOMTitle,
+			wantText:   "NodeHasInsufficientMemory",
+		},
+		{
+			name:       "未标记 OOMKilled 的 137，节点 SystemOOM",
+			status:     terminated("Error"),
+			nodeEvents: []corev1.Event{systemOOM},
+			wantTitle:  systemOOMTitle,
+			wantText:   "victim process: java",
+		},
+		{
+			name:       "节点 SystemOOM 发生在很久以前",
+			status:     terminated("Error"),
+			nodeEvents: []corev1.Event{staleOOM},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := &RuleContext{Node: &corev1.Node{}, NodeEvents: tt.nodeEvents}
+			res := rule.CheckWithContext(ctx, &corev1.Pod{}, limited, tt.status)
+			if tt.wantTitle == "" {
+				if res.Matched {
+					t.Errorf("expected no match, got %q", res.Title)
+				}
+				return
+			}
+			if !res.Matched || res.Title != tt.wantTitle {
+				t.Fatalf("got matched=%v title=%q, want %q", res.Matched, res.Title, tt.wantTitle)
+			}
+			if !strings.Contains(res.RawError+res.Suggestion, tt.wantText) {
+				t.Errorf("result should mention %q, got %+v", tt.wantText, res)
+			}
+		})
+	}
+}
+
+func TestResizeRule_CheckWithContext(t *testing.T) {
+	rule := &ResizeRule{}
+
+	container := &corev1.Container{
+		Name: "app",
+		Resources: corev1.ResourceRequirements{
+			Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("8"), corev1.ResourceMemory: resource.MustParse("1Gi")},
+			Limits:   corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("2Gi")},
+		},
+		ResizePolicy: []corev1.ContainerResizePolicy{
+			{ResourceName: corev1.ResourceMemory, RestartPolicy: corev1.RestartContainer},
+		},
+	}
+	applied := &corev1.ResourceRequirements{
+		Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("2"), corev1.ResourceMemory: resource.MustParse("1Gi")},
+		Limits:   corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("1Gi")},
+	}
+	node := &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "node-1"},
+		Status: corev1.NodeStatus{Allocatable: corev1.ResourceList{
+			corev1.ResourceCPU: resource.MustParse("4"), corev1.ResourceMemory: resource.MustParse("16Gi"),
+		}},
+	}
+	condition := func(condType corev1.PodConditionType, reason, message string) []corev1.PodCondition {
+		return []corev1.PodCondition{{Type: condType, Status: corev1.ConditionTrue, Reason: reason, Message: message}}
+	}
+
+	tests := []struct {
+		name       string
+		conditions []corev1.PodCondition
+		resize     corev1.PodResizeStatus
+		resources  *corev1.ResourceRequirements
+		wantTitle  string
+		wantText   []string
+	}{
+		{
+			name:       "节点容量不足 (Infeasible)",
+			conditions: condition(corev1.PodResizePending, corev1.PodReasonInfeasible, "Node didn't have enough capacity: cpu, requested: 8000, capacity: 4000"),
+			resources:  applied,
+			wantTitle:  "原地扩缩容无法执行 (Resize Infeasible)",
+			wantText: []string{
+				"cpu requests 2 -> 8", "memory limits 1Gi -> 2Gi",
+				"cpu requests 合计 8 超过节点 node-1 可分配的 4",
+				"memory 的 resizePolicy 为 RestartContainer", "cpu 的 resizePolicy 为 NotRequired",
+			},
+		},
+		{
+			name:       "节点空闲资源不足 (Deferred)",
+			conditions: condition(corev1.PodResizePending, corev1.PodReasonDeferred, "Node didn't have enough resource: cpu"),
+			resources:  applied,
+			wantTitle:  "原地扩缩容被推迟 (Resize Deferred)",
+			wantText:   []string{"自动重试", "Node didn't have enough resource: cpu"},
+		},
+		{
+			name:       "kubelet 应用失败",
+			conditions: condition(corev1.PodResizeInProgress, corev1.PodReasonError, "failed to update container resources"),
+			resources:  applied,
+			wantTitle:  "原地扩缩容执行失败 (Resize Error)",
+			wantText:   []string{"failed to update container resources"},
+		},
+		{
+			name:      "旧版本集群的 status.resize",
+			resize:    corev1.PodResizeStatusInfeasible,
+			wantTitle: "原地扩缩容无法执行 (Resize Infeasible)",
+		},
+		{
+			name:       "容器已生效，不属于本次调整",
+			conditions: condition(corev1.PodResizePending, corev1.PodReasonDeferred, ""),
+			resources:  &container.Resources,
+		},
+		{
+			name:       "调整进行中 (非错误)",
+			conditions: condition(corev1.PodResizeInProgress, "", ""),
+			resources:  applied,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pod := &corev1.Pod{
+				Spec:   corev1.PodSpec{Containers: []corev1.Container{*container}},
+				Status: corev1.PodStatus{Conditions: tt.conditions, Resize: tt.resize},
+			}
+			status := corev1.ContainerStatus{Name: "app", Resources: tt.resources}
+			res := rule.CheckWithContext(&RuleContext{Node: node}, pod, container, status)
+			if tt.wantTitle == "" {
+				if res.Matched {
+					t.Errorf("expected no match, got %q", res.Title)
+				}
+				return
+			}
+			if !res.Matched || res.Title != tt.wantTitle {
+				t.Fatalf("got matched=%v title=%q, want %q", res.Matched, res.Title, tt.wantTitle)
+			}
+			for _, text := range tt.wantText {
+				if !strings.Contains(res.RawError+res.Suggestion, text) {
+					t.Errorf("result should mention %q, got %+v", text, res)
+				}
+			}
+		})
+	}
+}
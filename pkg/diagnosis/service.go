@@ -0,0 +1,259 @@
+package diagnosis
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// AnalyzeService 对指定的 Service 进行诊断。
+// 包括：解析 Selector 匹配的 Pod、检查 targetPort 与 containerPort 是否对应、统计 EndpointSlice 中的就绪端点。
+func (a *Analyzer) AnalyzeService(svc *corev1.Service) WorkloadResult {
+	result := WorkloadResult{
+		Kind:      "Service",
+		Name:      svc.Name,
+		Namespace: svc.Namespace,
+		Pods:      []PodSummary{},
+		Issues:    []Issue{},
+		Events:    a.getObjectEvents(svc.Namespace, svc.Name, svc.UID),
+	}
+
+	result.Details = append(result.Details,
+		Detail{Label: "类型", Value: string(svc.Spec.Type)},
+		Detail{Label: "ClusterIP", Value: svc.Spec.ClusterIP},
+		Detail{Label: "Selector", Value: FormatSelector(svc.Spec.Selector)},
+		Detail{Label: "端口", Value: formatServicePorts(svc.Spec.Ports)},
+	)
+
+	// ExternalName 类型的 Service 只是一条 DNS CNAME，没有后端 Pod
+	if svc.Spec.Type == corev1.ServiceTypeExternalName {
+		result.Details = append(result.Details, Detail{Label: "ExternalName", Value: svc.Spec.ExternalName})
+		return result
+	}
+
+	// ----------------------------------------------------
+	// 1. 解析 Selector，找到匹配的 Pod
+	// ----------------------------------------------------
+	var pods []corev1.Pod
+	if len(svc.Spec.Selector) == 0 {
+		result.Issues = append(result.Issues, Issue{
+			Type:       "Warning",
+			Title:      "Service 未定义 Selector",
+			Suggestion: "没有 Selector 的 Service 不会自动生成 Endpoint，请确认是否有意手动维护 EndpointSlice",
+		})
+	} else {
+		podList, err := a.client.CoreV1().Pods(svc.Namespace).List(a.ctx, metav1.ListOptions{
+			LabelSelector: labels.SelectorFromSet(svc.Spec.Selector).String(),
+		})
+		if err != nil {
+			result.Issues = append(result.Issues, Issue{
+				Type:     "Warning",
+				Title:    "无法列出 Selector 匹配的 Pod",
+				RawError: err.Error(),
+			})
+		} else {
+			pods = podList.Items
+		}
+
+		if err == nil && len(pods) == 0 {
+			result.Issues = append(result.Issues, Issue{
+				Type:       "Error",
+				Title:      "Selector 没有匹配到任何 Pod",
+				RawError:   fmt.Sprintf("selector: %s", FormatSelector(svc.Spec.Selector)),
+				Suggestion: "请对比 Service 的 selector 与 Pod 模板的 labels，常见原因是标签拼写错误或版本标签不一致",
+			})
+		}
+	}
+
+	readyPods := 0
+	for i := range pods {
+		summary := SummarizePod(&pods[i])
+		if summary.Ready {
+			readyPods++
+		}
+		result.Pods = append(result.Pods, summary)
+	}
+	if len(pods) > 0 {
+		result.Details = append(result.Details, Detail{Label: "匹配 Pod", Value: fmt.Sprintf("%d (就绪 %d)", len(pods), readyPods)})
+	}
+
+	// ----------------------------------------------------
+	// 2. 检查 targetPort 是否能对应到容器端口
+	// ----------------------------------------------------
+	if len(pods) > 0 {
+		for _, sp := range svc.Spec.Ports {
+			if issue := checkTargetPort(sp, pods); issue != nil {
+				result.Issues = append(result.Issues, *issue)
+			}
+		}
+	}
+
+	// ----------------------------------------------------
+	// 3. 读取 EndpointSlice，统计就绪端点
+	// ----------------------------------------------------
+	slices, err := a.client.DiscoveryV1().EndpointSlices(svc.Namespace).List(a.ctx, metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("%s=%s", discoveryv1.LabelServiceName, svc.Name),
+	})
+	if err != nil {
+		result.Issues = append(result.Issues, Issue{
+			Type:     "Warning",
+			Title:    "无法读取 EndpointSlice",
+			RawError: err.Error(),
+		})
+		return result
+	}
+
+	ready, total := CountEndpoints(slices.Items)
+	result.Details = append(result.Details, Detail{Label: "Endpoints", Value: fmt.Sprintf("%d/%d 就绪", ready, total)})
+
+	if ready == 0 {
+		issue := Issue{
+			Type:  "Error",
+			Title: "Service 没有就绪的 Endpoint",
+		}
+		switch {
+		case len(svc.Spec.Selector) > 0 && len(pods) == 0:
+			issue.Suggestion = "Selector 未匹配任何 Pod，流量无处转发，请先修正 selector"
+		case len(pods) > 0 && readyPods == 0:
+			issue.Suggestion = "匹配到的 Pod 均未就绪，请使用 diagnose 逐个检查 Pod 的 readinessProbe 与容器状态"
+		default:
+			issue.Suggestion = "请检查 EndpointSlice 控制器是否正常，以及 targetPort 是否配置正确"
+		}
+		result.Issues = append(result.Issues, issue)
+	}
+
+	return result
+}
+
+// checkTargetPort 检查 Service 端口的 targetPort 能否在匹配的 Pod 中找到对应的 containerPort
+// 命名端口找不到时 Endpoint 根本不会生成，因此视为 Error；数字端口只是未声明，视为 Warning
+func checkTargetPort(sp corev1.ServicePort, pods []corev1.Pod) *Issue {
+	target := sp.TargetPort
+	// 未设置 targetPort 时默认与 port 相同
+	if target.Type == intstr.Int && target.IntVal == 0 {
+		target = intstr.FromInt32(sp.Port)
+	}
+
+	protocol := sp.Protocol
+	if protocol == "" {
+		protocol = corev1.ProtocolTCP
+	}
+
+	for _, pod := range pods {
+		for _, c := range pod.Spec.Containers {
+			for _, cp := range c.Ports {
+				cpProtocol := cp.Protocol
+				if cpProtocol == "" {
+					cpProtocol = corev1.ProtocolTCP
+				}
+				if cpProtocol != protocol {
+					continue
+				}
+				if target.Type == intstr.String && cp.Name == target.StrVal {
+					return nil
+				}
+				if target.Type == intstr.Int && cp.ContainerPort == target.IntVal {
+					return nil
+				}
+			}
+		}
+	}
+
+	if target.Type == intstr.String {
+		return &Issue{
+			Type:       "Error",
+			Title:      fmt.Sprintf("命名端口 targetPort=%s 未匹配任何 containerPort", target.StrVal),
+			RawError:   fmt.Sprintf("Service 端口 %s (%d/%s)", portDisplayName(sp), sp.Port, protocol),
+			Suggestion: "请确认 Pod 模板中存在同名的 ports[].name，命名端口无法解析时不会生成对应的 Endpoint",
+		}
+	}
+	return &Issue{
+		Type:       "Warning",
+		Title:      fmt.Sprintf("targetPort=%d 未匹配任何 containerPort", target.IntVal),
+		RawError:   fmt.Sprintf("Service 端口 %s (%d/%s)", portDisplayName(sp), sp.Port, protocol),
+		Suggestion: "请确认应用实际监听的端口，并在 Pod 模板中声明对应的 containerPort",
+	}
+}
+
+// CountEndpoints 统计 EndpointSlice 中的就绪端点数与总端点数
+// 按照 EndpointSlice 约定，Ready 为 nil 时视为就绪
+func CountEndpoints(slices []discoveryv1.EndpointSlice) (ready, total int) {
+	for _, slice := range slices {
+		for _, ep := range slice.Endpoints {
+			total++
+			if ep.Conditions.Ready == nil || *ep.Conditions.Ready {
+				ready++
+			}
+		}
+	}
+	return ready, total
+}
+
+// SummarizePod 提取 Pod 的概况，用于工作负载报告
+func SummarizePod(pod *corev1.Pod) PodSummary {
+	summary := PodSummary{
+		Name:         pod.Name,
+		Phase:        string(pod.Status.Phase),
+		NodeName:     pod.Spec.NodeName,
+		RestartCount: SumRestarts(pod),
+	}
+	for _, cond := range pod.Status.Conditions {
+		if cond.Type == corev1.PodReady {
+			summary.Ready = cond.Status == corev1.ConditionTrue
+			break
+		}
+	}
+	return summary
+}
+
+// FormatSelector 将标签选择器格式化为稳定排序的 "k=v,k=v" 字符串
+func FormatSelector(selector map[string]string) string {
+	if len(selector) == 0 {
+		return "<none>"
+	}
+	keys := make([]string, 0, len(selector))
+	for k := range selector {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		pairs = append(pairs, fmt.Sprintf("%s=%s", k, selector[k]))
+	}
+	return strings.Join(pairs, ",")
+}
+
+// formatServicePorts 格式化 Service 端口列表，例如 "http 80->8080/TCP"
+func formatServicePorts(ports []corev1.ServicePort) string {
+	if len(ports) == 0 {
+		return "<none>"
+	}
+	var parts []string
+	for _, p := range ports {
+		protocol := p.Protocol
+		if protocol == "" {
+			protocol = corev1.ProtocolTCP
+		}
+		target := p.TargetPort.String()
+		if p.TargetPort.Type == intstr.Int && p.TargetPort.IntVal == 0 {
+			target = fmt.Sprintf("%d", p.Port)
+		}
+		parts = append(parts, fmt.Sprintf("%s %d->%s/%s", portDisplayName(p), p.Port, target, protocol))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// portDisplayName 返回端口名称，未命名时返回 "<unnamed>"
+func portDisplayName(p corev1.ServicePort) string {
+	if p.Name == "" {
+		return "<unnamed>"
+	}
+	return p.Name
+}
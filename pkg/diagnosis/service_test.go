@@ -0,0 +1,131 @@
+package diagnosis
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// 构造一个带端口声明的 Pod
+func newServicePod(name string, labels map[string]string, ready bool, ports ...corev1.ContainerPort) *corev1.Pod {
+	status := corev1.ConditionFalse
+	if ready {
+		status = corev1.ConditionTrue
+	}
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", Labels: labels},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{Name: "app", Ports: ports}},
+		},
+		Status: corev1.PodStatus{
+			Phase:      corev1.PodRunning,
+			Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: status}},
+		},
+	}
+}
+
+// 构造一个属于指定 Service 的 EndpointSlice
+func newEndpointSlice(svcName string, ready ...bool) *discoveryv1.EndpointSlice {
+	slice := &discoveryv1.EndpointSlice{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      svcName + "-abc",
+			Namespace: "default",
+			Labels:    map[string]string{discoveryv1.LabelServiceName: svcName},
+		},
+	}
+	for _, r := range ready {
+		r := r
+		slice.Endpoints = append(slice.Endpoints, discoveryv1.Endpoint{
+			Addresses:  []string{"10.0.0.1"},
+			Conditions: discoveryv1.EndpointConditions{Ready: &r},
+		})
+	}
+	return slice
+}
+
+func hasIssue(issues []Issue, title string) bool {
+	for _, issue := range issues {
+		if issue.Title == title {
+			return true
+		}
+	}
+	return false
+}
+
+func TestAnalyzer_AnalyzeService(t *testing.T) {
+	selector := map[string]string{"app": "web"}
+
+	tests := []struct {
+		name       string
+		targetPort intstr.IntOrString
+		objects    []runtime.Object
+		wantIssues []string
+		noIssues   bool
+	}{
+		{
+			name:       "Case 1: 一切正常",
+			targetPort: intstr.FromString("http"),
+			objects: []runtime.Object{
+				newServicePod("web-1", selector, true, corev1.ContainerPort{Name: "http", ContainerPort: 8080}),
+				newEndpointSlice("web", true),
+			},
+			noIssues: true,
+		},
+		{
+			name:       "Case 2: Selector 没有匹配任何 Pod",
+			targetPort: intstr.FromInt32(8080),
+			objects: []runtime.Object{
+				newServicePod("other-1", map[string]string{"app": "webb"}, true, corev1.ContainerPort{ContainerPort: 8080}),
+			},
+			wantIssues: []string{"Selector 没有匹配到任何 Pod", "Service 没有就绪的 Endpoint"},
+		},
+		{
+			name:       "Case 3: 命名 targetPort 不存在",
+			targetPort: intstr.FromString("web"),
+			objects: []runtime.Object{
+				newServicePod("web-1", selector, true, corev1.ContainerPort{Name: "http", ContainerPort: 8080}),
+			},
+			wantIssues: []string{"命名端口 targetPort=web 未匹配任何 containerPort", "Service 没有就绪的 Endpoint"},
+		},
+		{
+			name:       "Case 4: 数字 targetPort 不匹配，Pod 未就绪",
+			targetPort: intstr.FromInt32(9090),
+			objects: []runtime.Object{
+				newServicePod("web-1", selector, false, corev1.ContainerPort{ContainerPort: 8080}),
+				newEndpointSlice("web", false),
+			},
+			wantIssues: []string{"targetPort=9090 未匹配任何 containerPort", "Service 没有就绪的 Endpoint"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := &corev1.Service{
+				ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+				Spec: corev1.ServiceSpec{
+					Type:     corev1.ServiceTypeClusterIP,
+					Selector: selector,
+					Ports:    []corev1.ServicePort{{Name: "http", Port: 80, TargetPort: tt.targetPort}},
+				},
+			}
+
+			fakeClient := fake.NewSimpleClientset(append(tt.objects, svc)...)
+
+			result := NewAnalyzer(fakeClient).AnalyzeService(svc)
+
+			if tt.noIssues && len(result.Issues) != 0 {
+				t.Errorf("expected no issues, got %+v", result.Issues)
+			}
+			for _, want := range tt.wantIssues {
+				if !hasIssue(result.Issues, want) {
+					t.Errorf("missing issue %q, got %+v", want, result.Issues)
+				}
+			}
+		})
+	}
+}
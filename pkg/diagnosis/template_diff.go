@@ -0,0 +1,423 @@
+package diagnosis
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// -----------------------------------------------------------
+// Pod 模板差异: "新版本到底改了什么？"
+// 对于 Deployment / StatefulSet 管理的失败 Pod，找到上一健康版本的模板并做结构化对比
+// -----------------------------------------------------------
+
+// 模板变更的分类
+const (
+	ChangeImage     = "image"
+	ChangeEnv       = "env"
+	ChangeResources = "resources"
+	ChangeProbe     = "probe"
+	ChangeVolume    = "volume"
+	ChangeCommand   = "command"
+)
+
+// TemplateDiff 是失败 Pod 所在版本与上一健康版本之间的模板差异
+type TemplateDiff struct {
+	OwnerKind   string           `json:"owner_kind"`   // Deployment / StatefulSet
+	OwnerName   string           `json:"owner_name"`   // 工作负载名称
+	OldRevision string           `json:"old_revision"` // 上一健康版本 (ReplicaSet 或 ControllerRevision 名称)
+	NewRevision string           `json:"new_revision"` // 失败 Pod 所在版本
+	Changes     []TemplateChange `json:"changes"`
+}
+
+// TemplateChange 是模板中的一处变更
+type TemplateChange struct {
+	Category  string `json:"category"`            // image / env / resources / probe / volume / command
+	Container string `json:"container,omitempty"` // 所属容器 (Volume 变更为空)
+	Field     string `json:"field"`               // 变更的字段
+	Old       string `json:"old"`                 // 旧值 (新增时为空)
+	New       string `json:"new"`                 // 新值 (删除时为空)
+}
+
+// DiffPodTemplates 对比两个 Pod 模板，返回镜像、环境变量、资源、探针、存储卷与启动命令的变更
+func DiffPodTemplates(oldTpl, newTpl corev1.PodTemplateSpec) []TemplateChange {
+	changes := []TemplateChange{}
+
+	changes = append(changes, diffContainerList(oldTpl.Spec.InitContainers, newTpl.Spec.InitContainers, "init:")...)
+	changes = append(changes, diffContainerList(oldTpl.Spec.Containers, newTpl.Spec.Containers, "")...)
+	changes = append(changes, diffVolumes(oldTpl.Spec.Volumes, newTpl.Spec.Volumes)...)
+
+	return changes
+}
+
+func diffContainerList(oldList, newList []corev1.Container, prefix string) []TemplateChange {
+	var changes []TemplateChange
+
+	oldByName := map[string]corev1.Container{}
+	for _, c := range oldList {
+		oldByName[c.Name] = c
+	}
+	newNames := map[string]bool{}
+
+	for _, nc := range newList {
+		newNames[nc.Name] = true
+		name := prefix + nc.Name
+		oc, ok := oldByName[nc.Name]
+		if !ok {
+			changes = append(changes, TemplateChange{Category: ChangeImage, Container: name, Field: "container", New: nc.Image})
+			continue
+		}
+		changes = append(changes, diffContainer(name, oc, nc)...)
+	}
+	for _, oc := range oldList {
+		if !newNames[oc.Name] {
+			changes = append(changes, TemplateChange{Category: ChangeImage, Container: prefix + oc.Name, Field: "container", Old: oc.Image})
+		}
+	}
+	return changes
+}
+
+func diffContainer(name string, oc, nc corev1.Container) []TemplateChange {
+	var changes []TemplateChange
+	add := func(category, field, oldVal, newVal string) {
+		if oldVal != newVal {
+			changes = append(changes, TemplateChange{Category: category, Container: name, Field: field, Old: oldVal, New: newVal})
+		}
+	}
+
+	// 镜像
+	add(ChangeImage, "image", oc.Image, nc.Image)
+
+	// 启动命令
+	add(ChangeCommand, "command", strings.Join(oc.Command, " "), strings.Join(nc.Command, " "))
+	add(ChangeCommand, "args", strings.Join(oc.Args, " "), strings.Join(nc.Args, " "))
+
+	// 环境变量 (按名称对比)
+	oldEnv := envMap(oc)
+	newEnv := envMap(nc)
+	for _, key := range sortedKeys(oldEnv, newEnv) {
+		add(ChangeEnv, "env."+key, oldEnv[key], newEnv[key])
+	}
+
+	// 资源
+	for _, key := range sortedKeys(resourceMap(oc.Resources), resourceMap(nc.Resources)) {
+		add(ChangeResources, key, resourceMap(oc.Resources)[key], resourceMap(nc.Resources)[key])
+	}
+
+	// 探针
+	add(ChangeProbe, "livenessProbe", describeProbe(oc.LivenessProbe), describeProbe(nc.LivenessProbe))
+	add(ChangeProbe, "readinessProbe", describeProbe(oc.ReadinessProbe), describeProbe(nc.ReadinessProbe))
+	add(ChangeProbe, "startupProbe", describeProbe(oc.StartupProbe), describeProbe(nc.StartupProbe))
+
+	// 挂载点
+	oldMounts := mountMap(oc.VolumeMounts)
+	newMounts := mountMap(nc.VolumeMounts)
+	for _, key := range sortedKeys(oldMounts, newMounts) {
+		add(ChangeVolume, "volumeMounts."+key, oldMounts[key], newMounts[key])
+	}
+
+	return changes
+}
+
+func diffVolumes(oldList, newList []corev1.Volume) []TemplateChange {
+	var changes []TemplateChange
+	oldVols := map[string]string{}
+	for _, v := range oldList {
+		oldVols[v.Name] = describeVolume(v)
+	}
+	newVols := map[string]string{}
+	for _, v := range newList {
+		newVols[v.Name] = describeVolume(v)
+	}
+	for _, key := range sortedKeys(oldVols, newVols) {
+		if oldVols[key] != newVols[key] {
+			changes = append(changes, TemplateChange{Category: ChangeVolume, Field: "volumes." + key, Old: oldVols[key], New: newVols[key]})
+		}
+	}
+	return changes
+}
+
+// envMap 将容器的 env / envFrom 展开为 name -> 描述
+func envMap(c corev1.Container) map[string]string {
+	result := map[string]string{}
+	for _, e := range c.Env {
+		switch {
+		case e.ValueFrom == nil:
+			result[e.Name] = e.Value
+		case e.ValueFrom.SecretKeyRef != nil:
+			result[e.Name] = fmt.Sprintf("secret:%s/%s", e.ValueFrom.SecretKeyRef.Name, e.ValueFrom.SecretKeyRef.Key)
+		case e.ValueFrom.ConfigMapKeyRef != nil:
+			result[e.Name] = fmt.Sprintf("configmap:%s/%s", e.ValueFrom.ConfigMapKeyRef.Name, e.ValueFrom.ConfigMapKeyRef.Key)
+		case e.ValueFrom.FieldRef != nil:
+			result[e.Name] = "field:" + e.ValueFrom.FieldRef.FieldPath
+		case e.ValueFrom.ResourceFieldRef != nil:
+			result[e.Name] = "resource:" + e.ValueFrom.ResourceFieldRef.Resource
+		}
+	}
+	for i, ef := range c.EnvFrom {
+		key := fmt.Sprintf("envFrom[%d]", i)
+		switch {
+		case ef.SecretRef != nil:
+			result[key] = ef.Prefix + "secret:" + ef.SecretRef.Name
+		case ef.ConfigMapRef != nil:
+			result[key] = ef.Prefix + "configmap:" + ef.ConfigMapRef.Name
+		}
+	}
+	return result
+}
+
+// resourceMap 将 requests / limits 展开为 "requests.cpu" -> "500m"
+func resourceMap(r corev1.ResourceRequirements) map[string]string {
+	result := map[string]string{}
+	for name, q := range r.Requests {
+		result["requests."+string(name)] = q.String()
+	}
+	for name, q := range r.Limits {
+		result["limits."+string(name)] = q.String()
+	}
+	return result
+}
+
+func mountMap(mounts []corev1.VolumeMount) map[string]string {
+	result := map[string]string{}
+	for _, m := range mounts {
+		desc := m.MountPath
+		if m.SubPath != "" {
+			desc += " (subPath " + m.SubPath + ")"
+		}
+		if m.ReadOnly {
+			desc += " ro"
+		}
+		result[m.Name] = desc
+	}
+	return result
+}
+
+// describeProbe 将探针格式化为一行文本，例如 "httpGet :8080/healthz delay=10s period=5s timeout=1s failure=3"
+func describeProbe(p *corev1.Probe) string {
+	if p == nil {
+		return ""
+	}
+	var handler string
+	switch {
+	case p.HTTPGet != nil:
+		handler = fmt.Sprintf("httpGet :%s%s", p.HTTPGet.Port.String(), p.HTTPGet.Path)
+	case p.TCPSocket != nil:
+		handler = fmt.Sprintf("tcpSocket :%s", p.TCPSocket.Port.String())
+	case p.Exec != nil:
+		handler = "exec " + strings.Join(p.Exec.Command, " ")
+	case p.GRPC != nil:
+		handler = fmt.Sprintf("grpc :%d", p.GRPC.Port)
+	default:
+		handler = "unknown"
+	}
+	return fmt.Sprintf("%s delay=%ds period=%ds timeout=%ds failure=%d",
+		handler, p.InitialDelaySeconds, p.PeriodSeconds, p.TimeoutSeconds, p.FailureThreshold)
+}
+
+// describeVolume 将存储卷来源格式化为可读文本
+func describeVolume(v corev1.Volume) string {
+	switch {
+	case v.ConfigMap != nil:
+		return "configMap:" + v.ConfigMap.Name
+	case v.Secret != nil:
+		return "secret:" + v.Secret.SecretName
+	case v.PersistentVolumeClaim != nil:
+		return "pvc:" + v.PersistentVolumeClaim.ClaimName
+	case v.EmptyDir != nil:
+		if v.EmptyDir.SizeLimit != nil {
+			return "emptyDir (sizeLimit " + v.EmptyDir.SizeLimit.String() + ")"
+		}
+		return "emptyDir"
+	case v.HostPath != nil:
+		return "hostPath:" + v.HostPath.Path
+	default:
+		// 其他类型直接序列化，保证差异可见
+		data, _ := json.Marshal(v.VolumeSource)
+		return string(data)
+	}
+}
+
+// sortedKeys 返回多个 map 的键的并集 (排序后)
+func sortedKeys(maps ...map[string]string) []string {
+	seen := map[string]bool{}
+	var keys []string
+	for _, m := range maps {
+		for k := range m {
+			if !seen[k] {
+				seen[k] = true
+				keys = append(keys, k)
+			}
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// -----------------------------------------------------------
+// 集群数据获取
+// -----------------------------------------------------------
+
+// GetTemplateDiff 查找 Pod 所在版本与上一健康版本的模板差异
+// 支持 Deployment (通过 ReplicaSet) 与 StatefulSet (通过 ControllerRevision)，无法对比时返回 nil
+func (a *Analyzer) GetTemplateDiff(pod *corev1.Pod) *TemplateDiff {
+	owner := metav1.GetControllerOf(pod)
+	if owner == nil {
+		return nil
+	}
+
+	switch owner.Kind {
+	case "ReplicaSet":
+		return a.diffDeploymentRevision(pod.Namespace, owner.Name)
+	case "StatefulSet":
+		return a.diffStatefulSetRevision(pod, owner.Name)
+	}
+	return nil
+}
+
+// diffDeploymentRevision 对比 Pod 所属 ReplicaSet 与同一 Deployment 下的上一健康 ReplicaSet
+// "健康" 指仍有就绪副本；都不健康时退化为版本号最接近的旧 ReplicaSet
+func (a *Analyzer) diffDeploymentRevision(namespace, rsName string) *TemplateDiff {
+	current, err := a.client.AppsV1().ReplicaSets(namespace).Get(a.ctx, rsName, metav1.GetOptions{})
+	if err != nil {
+		return nil
+	}
+	depRef := metav1.GetControllerOf(current)
+	if depRef == nil || depRef.Kind != "Deployment" {
+		return nil
+	}
+	dep, err := a.client.AppsV1().Deployments(namespace).Get(a.ctx, depRef.Name, metav1.GetOptions{})
+	if err != nil {
+		return nil
+	}
+	rsList, err := a.listOwnedReplicaSets(dep)
+	if err != nil {
+		return nil
+	}
+
+	currentRev := revisionNumber(current.Annotations[revisionAnnotation])
+	var previous *appsv1.ReplicaSet
+	previousHealthy := false
+	for i := range rsList {
+		rs := &rsList[i]
+		rev := revisionNumber(rs.Annotations[revisionAnnotation])
+		if rs.UID == current.UID || rev >= currentRev {
+			continue
+		}
+		healthy := rs.Status.ReadyReplicas > 0
+		prevRev := int64(-1)
+		if previous != nil {
+			prevRev = revisionNumber(previous.Annotations[revisionAnnotation])
+		}
+		// 优先选择健康的；同等条件下选择版本号更高的
+		if previous == nil || (healthy && !previousHealthy) || (healthy == previousHealthy && rev > prevRev) {
+			previous = rs
+			previousHealthy = healthy
+		}
+	}
+	if previous == nil {
+		return nil
+	}
+
+	return &TemplateDiff{
+		OwnerKind:   "Deployment",
+		OwnerName:   dep.Name,
+		OldRevision: fmt.Sprintf("%s (rev %s)", previous.Name, previous.Annotations[revisionAnnotation]),
+		NewRevision: fmt.Sprintf("%s (rev %s)", current.Name, current.Annotations[revisionAnnotation]),
+		Changes:     DiffPodTemplates(previous.Spec.Template, current.Spec.Template),
+	}
+}
+
+// diffStatefulSetRevision 对比 Pod 所在的 ControllerRevision 与上一版本
+// 滚动更新未完成时，status.currentRevision 就是上一健康版本
+func (a *Analyzer) diffStatefulSetRevision(pod *corev1.Pod, stsName string) *TemplateDiff {
+	sts, err := a.client.AppsV1().StatefulSets(pod.Namespace).Get(a.ctx, stsName, metav1.GetOptions{})
+	if err != nil {
+		return nil
+	}
+	revList, err := a.client.AppsV1().ControllerRevisions(pod.Namespace).List(a.ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil
+	}
+
+	var revisions []appsv1.ControllerRevision
+	for _, rev := range revList.Items {
+		if ref := metav1.GetControllerOf(&rev); ref != nil && ref.UID == sts.UID {
+			revisions = append(revisions, rev)
+		}
+	}
+
+	podRevName := pod.Labels[appsv1.StatefulSetRevisionLabel]
+	var current, previous *appsv1.ControllerRevision
+	for i := range revisions {
+		if revisions[i].Name == podRevName {
+			current = &revisions[i]
+		}
+	}
+	if current == nil {
+		return nil
+	}
+
+	for i := range revisions {
+		rev := &revisions[i]
+		if rev.Name == current.Name {
+			continue
+		}
+		if sts.Status.CurrentRevision != current.Name && rev.Name == sts.Status.CurrentRevision {
+			previous = rev
+			break
+		}
+		if rev.Revision < current.Revision && (previous == nil || rev.Revision > previous.Revision) {
+			previous = rev
+		}
+	}
+	if previous == nil {
+		return nil
+	}
+
+	oldTpl, err := templateFromRevision(previous)
+	if err != nil {
+		return nil
+	}
+	newTpl, err := templateFromRevision(current)
+	if err != nil {
+		return nil
+	}
+
+	return &TemplateDiff{
+		OwnerKind:   "StatefulSet",
+		OwnerName:   sts.Name,
+		OldRevision: fmt.Sprintf("%s (rev %d)", previous.Name, previous.Revision),
+		NewRevision: fmt.Sprintf("%s (rev %d)", current.Name, current.Revision),
+		Changes:     DiffPodTemplates(oldTpl, newTpl),
+	}
+}
+
+// templateFromRevision 从 ControllerRevision 中解析出 Pod 模板
+// StatefulSet 的 revision 数据形如 {"spec":{"template":{...}}}
+func templateFromRevision(rev *appsv1.ControllerRevision) (corev1.PodTemplateSpec, error) {
+	var patch struct {
+		Spec struct {
+			Template corev1.PodTemplateSpec `json:"template"`
+		} `json:"spec"`
+	}
+	if err := json.Unmarshal(rev.Data.Raw, &patch); err != nil {
+		return corev1.PodTemplateSpec{}, err
+	}
+	return patch.Spec.Template, nil
+}
+
+// revisionNumber 解析版本号注解，非法值视为 0
+func revisionNumber(s string) int64 {
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0
+	}
+	return n
+}
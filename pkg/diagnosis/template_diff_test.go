@@ -0,0 +1,128 @@
+package diagnosis
+
+import (
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func newTemplate(image, memLimit, dbHost string) corev1.PodTemplateSpec {
+	return corev1.PodTemplateSpec{
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{
+				Name:  "app",
+				Image: image,
+				Env:   []corev1.EnvVar{{Name: "DB_HOST", Value: dbHost}},
+				Resources: corev1.ResourceRequirements{
+					Limits: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse(memLimit)},
+				},
+				ReadinessProbe: &corev1.Probe{
+					ProbeHandler: corev1.ProbeHandler{
+						HTTPGet: &corev1.HTTPGetAction{Path: "/healthz", Port: intstr.FromInt32(8080)},
+					},
+					PeriodSeconds: 5,
+				},
+			}},
+		},
+	}
+}
+
+func findChange(changes []TemplateChange, field string) *TemplateChange {
+	for i := range changes {
+		if changes[i].Field == field {
+			return &changes[i]
+		}
+	}
+	return nil
+}
+
+func TestDiffPodTemplates(t *testing.T) {
+	oldTpl := newTemplate("web:1.0", "512Mi", "db-primary")
+	newTpl := newTemplate("web:1.1", "256Mi", "db-primary")
+	newTpl.Spec.Containers[0].Command = []string{"/app", "--migrate"}
+	newTpl.Spec.Volumes = []corev1.Volume{{
+		Name:         "config",
+		VolumeSource: corev1.VolumeSource{ConfigMap: &corev1.ConfigMapVolumeSource{LocalObjectReference: corev1.LocalObjectReference{Name: "web-config"}}},
+	}}
+
+	changes := DiffPodTemplates(oldTpl, newTpl)
+
+	tests := []struct {
+		field    string
+		category string
+		old, new string
+	}{
+		{"image", ChangeImage, "web:1.0", "web:1.1"},
+		{"limits.memory", ChangeResources, "512Mi", "256Mi"},
+		{"command", ChangeCommand, "", "/app --migrate"},
+		{"volumes.config", ChangeVolume, "", "configMap:web-config"},
+	}
+	for _, tt := range tests {
+		c := findChange(changes, tt.field)
+		if c == nil {
+			t.Errorf("missing change for %s, got %+v", tt.field, changes)
+			continue
+		}
+		if c.Category != tt.category || c.Old != tt.old || c.New != tt.new {
+			t.Errorf("change %s = %+v, want %s %q -> %q", tt.field, *c, tt.category, tt.old, tt.new)
+		}
+	}
+
+	// 没有变化的字段不应该出现
+	if findChange(changes, "env.DB_HOST") != nil {
+		t.Error("unchanged env var should not be reported")
+	}
+	if findChange(changes, "readinessProbe") != nil {
+		t.Error("unchanged probe should not be reported")
+	}
+}
+
+func TestAnalyzer_GetTemplateDiff_Deployment(t *testing.T) {
+	selector := &metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}}
+	dep := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default", UID: "dep-uid"},
+		Spec:       appsv1.DeploymentSpec{Selector: selector},
+	}
+	controller := true
+	depOwner := []metav1.OwnerReference{{Kind: "Deployment", Name: "web", UID: "dep-uid", Controller: &controller}}
+
+	healthyRS := &appsv1.ReplicaSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "web-v1", Namespace: "default", UID: "rs-v1", Labels: selector.MatchLabels,
+			Annotations: map[string]string{revisionAnnotation: "1"}, OwnerReferences: depOwner,
+		},
+		Spec:   appsv1.ReplicaSetSpec{Template: newTemplate("web:1.0", "512Mi", "db-primary")},
+		Status: appsv1.ReplicaSetStatus{ReadyReplicas: 2},
+	}
+	brokenRS := &appsv1.ReplicaSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "web-v2", Namespace: "default", UID: "rs-v2", Labels: selector.MatchLabels,
+			Annotations: map[string]string{revisionAnnotation: "2"}, OwnerReferences: depOwner,
+		},
+		Spec: appsv1.ReplicaSetSpec{Template: newTemplate("web:1.0", "512Mi", "db-replica")},
+	}
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "web-v2-abcde", Namespace: "default",
+			OwnerReferences: []metav1.OwnerReference{{Kind: "ReplicaSet", Name: "web-v2", UID: "rs-v2", Controller: &controller}},
+		},
+	}
+
+	fakeClient := fake.NewSimpleClientset(dep, healthyRS, brokenRS, pod)
+	diff := NewAnalyzer(fakeClient).GetTemplateDiff(pod)
+	if diff == nil {
+		t.Fatal("expected a template diff, got nil")
+	}
+	if diff.OldRevision != "web-v1 (rev 1)" || diff.NewRevision != "web-v2 (rev 2)" {
+		t.Errorf("revisions = %s -> %s", diff.OldRevision, diff.NewRevision)
+	}
+	c := findChange(diff.Changes, "env.DB_HOST")
+	if c == nil || c.Old != "db-primary" || c.New != "db-replica" {
+		t.Errorf("expected DB_HOST change, got %+v", diff.Changes)
+	}
+}
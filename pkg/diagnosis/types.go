@@ -2,6 +2,7 @@ package diagnosis
 
 import (
 	corev1 "k8s.io/api/core/v1"
+	metricsv1beta1 "k8s.io/metrics/pkg/apis/metrics/v1beta1"
 )
 
 // CheckResult 代表单条规则的检查结果
@@ -22,6 +23,24 @@ type Rule interface {
 	Check(pod *corev1.Pod, container *corev1.Container, status corev1.ContainerStatus) CheckResult
 }
 
+// RuleContext 是规则可以使用的集群上下文 (由 Analyzer 在诊断 Pod 时一次性收集)
+type RuleContext struct {
+	Events     []corev1.Event // Pod 的原始事件 (未截断)
+	Node       *corev1.Node   // Pod 所在节点 (未调度或获取失败时为 nil)
+	NodeEvents []corev1.Event // 节点的原始事件 (例如 SystemOOM)
+
+	Metrics *metricsv1beta1.PodMetrics // Pod 当前的资源使用量 (metrics-server 不可用时为 nil)
+}
+
+// ContextRule 是需要事件等集群上下文的规则，可选实现
+// 引擎在有上下文时优先调用 CheckWithContext
+type ContextRule interface {
+	Rule
+
+	// CheckWithContext 执行检查，ctx 不会为 nil
+	CheckWithContext(ctx *RuleContext, pod *corev1.Pod, container *corev1.Container, status corev1.ContainerStatus) CheckResult
+}
+
 // -----------------------------------------------------------
 // 诊断结果数据结构
 // -----------------------------------------------------------
@@ -35,20 +54,47 @@ type DiagnosisResult struct {
 	RestartCount int32                `json:"restart_count"`
 	Containers   []ContainerDiagnosis `json:"containers"` // 容器级诊断列表
 	Events       []string             `json:"events"`     // 最近的事件列表
+
+	// TemplateDiff 是与上一健康版本的 Pod 模板差异 (仅在 Pod 异常且能找到历史版本时存在)
+	TemplateDiff *TemplateDiff `json:"template_diff,omitempty"`
+
+	// HPA 是作用于 Pod 所属工作负载的 HPA 状态 (没有 HPA 时为 nil)
+	HPA *HPAStatus `json:"hpa,omitempty"`
+
+	// PDBs 是选中该 Pod 的 PodDisruptionBudget 评估结果
+	PDBs []PDBStatus `json:"pdbs,omitempty"`
+
+	// Issues 是不属于某个具体容器的 Pod 级问题 (例如 HPA 饱和)
+	Issues []Issue `json:"issues,omitempty"`
+
+	// LogAlert 是 monitor 模式下触发本次诊断的实时日志告警 (其他情况下为 nil)
+	LogAlert *LogAlert `json:"log_alert,omitempty"`
+
+	// Redactions 是生成报告前被脱敏替换的敏感信息数量
+	Redactions int `json:"redactions,omitempty"`
 }
 
 // ContainerDiagnosis 单个容器的诊断详情
 type ContainerDiagnosis struct {
-	Name         string   `json:"name"`
-	State        string   `json:"state"`         // Waiting, Running, Terminated
-	Reason       string   `json:"reason"`        // CrashLoopBackOff, OOMKilled ...
-	Message      string   `json:"message"`       // 详细信息
-	ExitCode     int32    `json:"exit_code"`     // 退出码
-	Ready        bool     `json:"ready"`         // 是否就绪
-	ResourceInfo string   `json:"resource_info"` // CPU/Mem 配置字符串
-	Issues       []Issue  `json:"issues"`        // 发现的问题 (由规则引擎产出)
-	Logs         []string `json:"logs"`          // 抓取的最后几行日志
-	LogKeywords  []string `json:"log_keywords"`  // 从日志中提取的关键词
+	Name          string              `json:"name"`
+	State         string              `json:"state"`                    // Waiting, Running, Terminated
+	Reason        string              `json:"reason"`                   // CrashLoopBackOff, OOMKilled ...
+	Message       string              `json:"message"`                  // 详细信息
+	ExitCode      int32               `json:"exit_code"`                // 退出码
+	Ready         bool                `json:"ready"`                    // 是否就绪
+	ResourceInfo  string              `json:"resource_info"`            // CPU/Mem 配置字符串
+	Usage         *ResourceUsage      `json:"usage,omitempty"`          // 当前资源使用量 (需要 metrics-server)
+	Issues        []Issue             `json:"issues"`                   // 发现的问题 (由规则引擎产出)
+	Logs          []string            `json:"logs"`                     // 抓取的最后几行日志
+	LogKeywords   []string            `json:"log_keywords"`             // 从日志中提取的关键词
+	LogMatches    []LogMatch          `json:"log_matches,omitempty"`    // 每一处命中的行号、模式与上下文
+	StackTraces   []StackTrace        `json:"stack_traces,omitempty"`   // 从日志中提取的堆栈 (异常类型、应用代码帧)
+	LogTemplates  []LogTemplate       `json:"log_templates,omitempty"`  // 日志模板聚类 (重复日志折叠后的视图)
+	JSONLogs      []JSONLogEntry      `json:"json_logs,omitempty"`      // warn 及以上级别的 JSON 日志 (已解析出消息、错误与堆栈)
+	LogTruncation []string            `json:"log_truncation,omitempty"` // 日志不完整的原因 (行数 / 字节 / 时间窗口限制、读取超时)
+	RunComparison *LogRunComparison   `json:"run_comparison,omitempty"` // 重启过的容器: 上一次运行与当前运行的日志对比
+	LogTrend      *LogTrend           `json:"log_trend,omitempty"`      // 深度模式: 时间窗口内各模式的命中趋势
+	Dependencies  []DependencyFailure `json:"dependencies,omitempty"`   // 日志中识别出的依赖故障及目标 Service 的状态
 }
 
 // Issue 代表发现的一个具体问题
@@ -58,3 +104,40 @@ type Issue struct {
 	RawError   string `json:"raw_error"`  // 原始报错
 	Suggestion string `json:"suggestion"` // 修复建议
 }
+
+// -----------------------------------------------------------
+// 工作负载级诊断结果 (Service / Deployment ...)
+// -----------------------------------------------------------
+
+// WorkloadResult 包含一个工作负载级对象的完整诊断信息
+// 与 DiagnosisResult 不同，它关注的是对象本身以及它所关联的一组 Pod
+type WorkloadResult struct {
+	Kind      string       `json:"kind"`      // Service, Deployment ...
+	Name      string       `json:"name"`      // 对象名称
+	Namespace string       `json:"namespace"` // 命名空间
+	Details   []Detail     `json:"details"`   // 基础信息 (按顺序展示)
+	Pods      []PodSummary `json:"pods"`      // 关联的 Pod 概况
+	Issues    []Issue      `json:"issues"`    // 发现的问题
+	Events    []string     `json:"events"`    // 最近的事件列表
+
+	// PodResults 是代表性 Pod 的完整诊断 (例如滚动更新中失败的新 Pod)
+	PodResults []DiagnosisResult `json:"pod_results,omitempty"`
+
+	// Redactions 是生成报告前被脱敏替换的敏感信息数量 (包含代表性 Pod)
+	Redactions int `json:"redactions,omitempty"`
+}
+
+// Detail 是报告中展示的一条 "指标: 值"
+type Detail struct {
+	Label string `json:"label"`
+	Value string `json:"value"`
+}
+
+// PodSummary 是工作负载报告中单个 Pod 的概况
+type PodSummary struct {
+	Name         string `json:"name"`
+	Phase        string `json:"phase"`
+	Ready        bool   `json:"ready"`
+	NodeName     string `json:"node_name"`
+	RestartCount int32  `json:"restart_count"`
+}
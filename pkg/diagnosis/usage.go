@@ -0,0 +1,136 @@
+package diagnosis
+
+import (
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	metricsv1beta1 "k8s.io/metrics/pkg/apis/metrics/v1beta1"
+	metricsclient "k8s.io/metrics/pkg/client/clientset/versioned"
+)
+
+// -----------------------------------------------------------
+// 资源使用量 (metrics.k8s.io)
+// metrics-server 是可选组件：没有安装时所有功能自动降级，不影响诊断
+// -----------------------------------------------------------
+
+const (
+	// memoryHeadroom 建议的内存 limit 相对峰值的余量
+	memoryHeadroom = 1.5
+	// memoryRoundStep 建议值向上取整的粒度
+	memoryRoundStep = 64 * 1024 * 1024
+)
+
+// ResourceUsage 是容器当前的资源使用量及其占 requests / limits 的比例
+type ResourceUsage struct {
+	CPU             string `json:"cpu"`    // 例如 120m
+	Memory          string `json:"memory"` // 例如 480Mi
+	CPUOfRequest    string `json:"cpu_of_request,omitempty"`
+	CPUOfLimit      string `json:"cpu_of_limit,omitempty"`
+	MemoryOfRequest string `json:"memory_of_request,omitempty"`
+	MemoryOfLimit   string `json:"memory_of_limit,omitempty"`
+}
+
+// String 返回单行描述，例如 "CPU 120m (请求 60%, 限制 30%) | 内存 480Mi (请求 94%, 限制 94%)"
+func (u ResourceUsage) String() string {
+	return fmt.Sprintf("CPU %s%s | 内存 %s%s",
+		u.CPU, formatRatios(u.CPUOfRequest, u.CPUOfLimit),
+		u.Memory, formatRatios(u.MemoryOfRequest, u.MemoryOfLimit))
+}
+
+// WithMetrics 为分析器注入 metrics.k8s.io 客户端 (可以为 nil，此时不采集使用量)
+func (a *Analyzer) WithMetrics(client metricsclient.Interface) *Analyzer {
+	a.metrics = client
+	return a
+}
+
+// GetPodMetrics 获取 Pod 当前的资源使用量
+// 没有注入客户端或集群未安装 metrics-server 时返回 nil
+func (a *Analyzer) GetPodMetrics(pod *corev1.Pod) *metricsv1beta1.PodMetrics {
+	if a.metrics == nil {
+		return nil
+	}
+	m, err := a.metrics.MetricsV1beta1().PodMetricses(pod.Namespace).Get(a.ctx, pod.Name, metav1.GetOptions{})
+	if err != nil {
+		return nil
+	}
+	return m
+}
+
+// ContainerUsage 从 PodMetrics 中取出指定容器的使用量
+func ContainerUsage(m *metricsv1beta1.PodMetrics, name string) corev1.ResourceList {
+	if m == nil {
+		return nil
+	}
+	for _, c := range m.Containers {
+		if c.Name == name {
+			return c.Usage
+		}
+	}
+	return nil
+}
+
+// BuildResourceUsage 计算容器使用量占 requests / limits 的比例
+func BuildResourceUsage(usage corev1.ResourceList, container *corev1.Container) *ResourceUsage {
+	if usage == nil {
+		return nil
+	}
+	cpu := usage.Cpu()
+	mem := usage.Memory()
+	result := &ResourceUsage{
+		CPU:    cpu.String(),
+		Memory: FormatMemory(mem.Value()),
+	}
+	if container != nil {
+		result.CPUOfRequest = percentOf(cpu.MilliValue(), container.Resources.Requests.Cpu().MilliValue())
+		result.CPUOfLimit = percentOf(cpu.MilliValue(), container.Resources.Limits.Cpu().MilliValue())
+		result.MemoryOfRequest = percentOf(mem.Value(), container.Resources.Requests.Memory().Value())
+		result.MemoryOfLimit = percentOf(mem.Value(), container.Resources.Limits.Memory().Value())
+	}
+	return result
+}
+
+// SuggestMemoryLimit 根据峰值给出建议的内存 limit (峰值 * 1.5，按 64Mi 向上取整)
+func SuggestMemoryLimit(peak int64) int64 {
+	target := int64(float64(peak) * memoryHeadroom)
+	return (target + memoryRoundStep - 1) / memoryRoundStep * memoryRoundStep
+}
+
+// FormatMemory 将字节数格式化为 Mi / Gi，例如 805306368 -> "768Mi"
+func FormatMemory(bytes int64) string {
+	const mi = 1024 * 1024
+	const gi = 1024 * mi
+	switch {
+	case bytes >= gi && bytes%gi == 0:
+		return fmt.Sprintf("%dGi", bytes/gi)
+	case bytes >= mi:
+		return fmt.Sprintf("%dMi", (bytes+mi-1)/mi)
+	default:
+		return resource.NewQuantity(bytes, resource.BinarySI).String()
+	}
+}
+
+// percentOf 计算百分比，分母为 0 (未设置) 时返回空字符串
+func percentOf(value, total int64) string {
+	if total == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%d%%", value*100/total)
+}
+
+// formatRatios 格式化 "(请求 60%, 限制 30%)"，都没有时返回空字符串
+func formatRatios(ofRequest, ofLimit string) string {
+	var parts []string
+	if ofRequest != "" {
+		parts = append(parts, "请求 "+ofRequest)
+	}
+	if ofLimit != "" {
+		parts = append(parts, "限制 "+ofLimit)
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return " (" + strings.Join(parts, ", ") + ")"
+}
@@ -0,0 +1,186 @@
+package diagnosis
+
+import (
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	metricsv1beta1 "k8s.io/metrics/pkg/apis/metrics/v1beta1"
+	metricsfake "k8s.io/metrics/pkg/client/clientset/versioned/fake"
+)
+
+// newFakeMetrics 创建带有 PodMetrics 的 fake metrics 客户端
+// fake tracker 会把 PodMetrics 推断为 "podmetricses" 资源，而客户端实际访问的是 "pods"，因此需要显式指定 GVR
+func newFakeMetrics(t *testing.T, metrics ...*metricsv1beta1.PodMetrics) *metricsfake.Clientset {
+	client := metricsfake.NewSimpleClientset()
+	gvr := metricsv1beta1.SchemeGroupVersion.WithResource("pods")
+	for _, m := range metrics {
+		if err := client.Tracker().Create(gvr, m, m.Namespace); err != nil {
+			t.Fatalf("failed to seed pod metrics: %v", err)
+		}
+	}
+	return client
+}
+
+func TestSuggestMemoryLimit(t *testing.T) {
+	tests := []struct {
+		peak string
+		want string
+	}{
+		{"512Mi", "768Mi"},
+		{"480Mi", "768Mi"}, // 720Mi 向上取整到 64Mi
+		{"1Gi", "1536Mi"},
+		{"2Gi", "3Gi"},
+	}
+	for _, tt := range tests {
+		peak := resource.MustParse(tt.peak)
+		if got := FormatMemory(SuggestMemoryLimit(peak.Value())); got != tt.want {
+			t.Errorf("SuggestMemoryLimit(%s) = %s, want %s", tt.peak, got, tt.want)
+		}
+	}
+}
+
+func TestOOMLimitSuggestion(t *testing.T) {
+	withLimit := func(limit string) *corev1.Container {
+		c := &corev1.Container{Name: "app"}
+		if limit != "" {
+			c.Resources.Limits = corev1.ResourceList{corev1.ResourceMemory: resource.MustParse(limit)}
+		}
+		return c
+	}
+	memory := func(v string) corev1.ResourceList {
+		return corev1.ResourceList{corev1.ResourceMemory: resource.MustParse(v)}
+	}
+
+	tests := []struct {
+		name      string
+		container *corev1.Container
+		usage     corev1.ResourceList
+		want      string
+	}{
+		{
+			name:      "当前使用低于 limit 时以 limit 为基准，不把 limit 当作实测峰值",
+			container: withLimit("512Mi"),
+			usage:     memory("480Mi"),
+			want:      "内存使用触及 limit 512Mi 后被杀 (重启后当前使用 ≈ 480Mi)，建议将 limit 调整为 768Mi",
+		},
+		{
+			name:      "当前使用超过 limit (limit 已被调小)",
+			container: withLimit("512Mi"),
+			usage:     memory("1Gi"),
+			want:      "当前使用 ≈ 1Gi，已超过 limit 512Mi，建议将 limit 调整为 1536Mi",
+		},
+		{
+			name:      "没有使用量",
+			container: withLimit("1Gi"),
+			want:      "内存使用触及 limit 1Gi 后被杀，建议将 limit 调整为 1536Mi",
+		},
+		{
+			name:      "未设置 limit",
+			container: withLimit(""),
+			usage:     memory("300Mi"),
+			want:      "未设置内存限制，当前使用 ≈ 300Mi，建议设置 limit 为 512Mi 防止节点资源耗尽",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := oomLimitSuggestion(tt.container, tt.usage); got != tt.want {
+				t.Errorf("oomLimitSuggestion() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildResourceUsage(t *testing.T) {
+	container := &corev1.Container{
+		Name: "app",
+		Resources: corev1.ResourceRequirements{
+			Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("200m"), corev1.ResourceMemory: resource.MustParse("256Mi")},
+			Limits:   corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("512Mi")},
+		},
+	}
+	usage := corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("120m"), corev1.ResourceMemory: resource.MustParse("480Mi")}
+
+	got := BuildResourceUsage(usage, container)
+	want := "CPU 120m (请求 60%) | 内存 480Mi (请求 187%, 限制 93%)"
+	if got.String() != want {
+		t.Errorf("usage = %q, want %q", got.String(), want)
+	}
+
+	if BuildResourceUsage(nil, container) != nil {
+		t.Error("missing metrics should yield nil usage")
+	}
+}
+
+func TestAnalyzer_AnalyzePod_WithMetrics(t *testing.T) {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default", UID: "pod-uid"},
+		Spec: corev1.PodSpec{Containers: []corev1.Container{{
+			Name: "app",
+			Resources: corev1.ResourceRequirements{
+				Limits: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("512Mi")},
+			},
+		}}},
+		Status: corev1.PodStatus{
+			Phase: corev1.PodRunning,
+			ContainerStatuses: []corev1.ContainerStatus{{
+				Name:         "app",
+				RestartCount: 2,
+				State:        corev1.ContainerState{Running: &corev1.ContainerStateRunning{}},
+				LastTerminationState: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{
+					Reason: "OOMKilled", ExitCode: 137,
+				}},
+			}},
+		},
+	}
+	podMetrics := &metricsv1beta1.PodMetrics{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+		Containers: []metricsv1beta1.ContainerMetrics{{
+			Name:  "app",
+			Usage: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("50m"), corev1.ResourceMemory: resource.MustParse("300Mi")},
+		}},
+	}
+
+	tests := []struct {
+		name       string
+		analyzer   *Analyzer
+		wantUsage  bool
+		suggestion string
+	}{
+		{
+			name:       "metrics-server 可用",
+			analyzer:   NewAnalyzer(fake.NewSimpleClientset(pod)).WithMetrics(newFakeMetrics(t, podMetrics)),
+			wantUsage:  true,
+			suggestion: "内存使用触及 limit 512Mi 后被杀 (重启后当前使用 ≈ 300Mi)，建议将 limit 调整为 768Mi",
+		},
+		{
+			name:       "metrics-server 不可用时降级",
+			analyzer:   NewAnalyzer(fake.NewSimpleClientset(pod)).WithMetrics(newFakeMetrics(t)),
+			suggestion: "内存使用触及 limit 512Mi 后被杀，建议将 limit 调整为 768Mi",
+		},
+		{
+			name:       "未注入 metrics 客户端",
+			analyzer:   NewAnalyzer(fake.NewSimpleClientset(pod)),
+			suggestion: "建议将 limit 调整为 768Mi",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diag := tt.analyzer.AnalyzePod(pod).Containers[0]
+			if (diag.Usage != nil) != tt.wantUsage {
+				t.Fatalf("usage = %+v, want present=%v", diag.Usage, tt.wantUsage)
+			}
+			if tt.wantUsage && diag.Usage.Memory != "300Mi" {
+				t.Errorf("memory usage = %s, want 300Mi", diag.Usage.Memory)
+			}
+			if len(diag.Issues) == 0 || !strings.Contains(diag.Issues[0].Suggestion, tt.suggestion) {
+				t.Errorf("suggestion should contain %q, got %+v", tt.suggestion, diag.Issues)
+			}
+		})
+	}
+}
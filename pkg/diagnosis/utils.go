@@ -17,7 +17,7 @@ func ExplainExitCode(code int32) string {
 		127: "Command Not Found (命令未找到)",
 		128: "Invalid Exit Argument (无效的退出参数)",
 		130: "Script Terminated by Control-C (被Ctrl+C终止)",
-		137: "SIGKILL (强制终止/OOMKilled - 内存溢出)",
+		137: "SIGKILL (被强制终止: OOM、宽限期超时或探针失败重启)",
 		143: "SIGTERM (优雅终止)",
 	}
 
@@ -55,3 +55,18 @@ func TranslateTimestamp(t time.Time) string {
 	}
 	return fmt.Sprintf("%.0f小时前", duration.Hours())
 }
+
+// EventTime 获取事件发生的最佳时间 (依次尝试 LastTimestamp / EventTime / FirstTimestamp)
+func EventTime(e corev1.Event) time.Time {
+	if !e.LastTimestamp.IsZero() {
+		return e.LastTimestamp.Time
+	}
+	if !e.EventTime.IsZero() {
+		return e.EventTime.Time
+	}
+	// 如果都没有，尝试 FirstTimestamp
+	if !e.FirstTimestamp.IsZero() {
+		return e.FirstTimestamp.Time
+	}
+	return time.Time{} // 真的一无所有
+}
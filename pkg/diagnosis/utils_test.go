@@ -13,7 +13,7 @@ func TestExplainExitCode(t *testing.T) {
 	}{
 		// 修改期望值，加上 "数字 (...) " 的格式
 		{0, "0 (Completed (正常退出))"},
-		{137, "137 (SIGKILL (强制终止/OOMKilled - 内存溢出))"},
+		{137, "137 (SIGKILL (被强制终止: OOM、宽限期超时或探针失败重启))"},
 		// 修改测试用例：用一个小于 128 且不在 map 里的数来测“未知错误”
 		{50, "50 (未知错误码)"},
 		// 999 会命中 Signal 逻辑
@@ -0,0 +1,148 @@
+package diagnosis
+
+import (
+	"fmt"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// -----------------------------------------------------------
+// 控制器级诊断 (ReplicaSet / StatefulSet / Job)
+// -----------------------------------------------------------
+
+// AnalyzeReplicaSet 诊断 ReplicaSet：副本数、所属 Pod 以及准入拒绝事件
+func (a *Analyzer) AnalyzeReplicaSet(rs *appsv1.ReplicaSet) WorkloadResult {
+	var desired int32 = 1
+	if rs.Spec.Replicas != nil {
+		desired = *rs.Spec.Replicas
+	}
+
+	result := a.newControllerResult("ReplicaSet", rs.ObjectMeta, rs.Spec.Selector)
+	result.Details = append(result.Details,
+		Detail{Label: "副本数", Value: fmt.Sprintf("期望 %d / 当前 %d / 就绪 %d", desired, rs.Status.Replicas, rs.Status.ReadyReplicas)},
+	)
+	a.appendControllerIssues(&result, rs.ObjectMeta, desired)
+	return result
+}
+
+// AnalyzeStatefulSet 诊断 StatefulSet：副本数、所属 Pod 以及准入拒绝事件
+func (a *Analyzer) AnalyzeStatefulSet(sts *appsv1.StatefulSet) WorkloadResult {
+	var desired int32 = 1
+	if sts.Spec.Replicas != nil {
+		desired = *sts.Spec.Replicas
+	}
+
+	result := a.newControllerResult("StatefulSet", sts.ObjectMeta, sts.Spec.Selector)
+	result.Details = append(result.Details,
+		Detail{Label: "副本数", Value: fmt.Sprintf("期望 %d / 当前 %d / 就绪 %d", desired, sts.Status.Replicas, sts.Status.ReadyReplicas)},
+		Detail{Label: "当前版本", Value: sts.Status.CurrentRevision},
+		Detail{Label: "更新版本", Value: sts.Status.UpdateRevision},
+	)
+	a.appendControllerIssues(&result, sts.ObjectMeta, desired)
+	return result
+}
+
+// AnalyzeJob 诊断 Job：完成情况、所属 Pod 以及准入拒绝事件
+func (a *Analyzer) AnalyzeJob(job *batchv1.Job) WorkloadResult {
+	var parallelism int32 = 1
+	if job.Spec.Parallelism != nil {
+		parallelism = *job.Spec.Parallelism
+	}
+
+	result := a.newControllerResult("Job", job.ObjectMeta, job.Spec.Selector)
+	result.Details = append(result.Details,
+		Detail{Label: "运行状态", Value: fmt.Sprintf("活跃 %d / 成功 %d / 失败 %d", job.Status.Active, job.Status.Succeeded, job.Status.Failed)},
+	)
+	for _, cond := range job.Status.Conditions {
+		if cond.Type == batchv1.JobFailed && cond.Status == corev1.ConditionTrue {
+			result.Issues = append(result.Issues, Issue{
+				Type:       "Error",
+				Title:      fmt.Sprintf("Job 执行失败 (%s)", cond.Reason),
+				RawError:   cond.Message,
+				Suggestion: "请诊断失败的 Pod 查看具体原因，必要时调整 backoffLimit 或 activeDeadlineSeconds",
+			})
+		}
+	}
+
+	// 已经完成的 Job 不再期望有活跃 Pod
+	expected := parallelism
+	if job.Status.CompletionTime != nil {
+		expected = 0
+	}
+	a.appendControllerIssues(&result, job.ObjectMeta, expected)
+	return result
+}
+
+// newControllerResult 构造控制器级诊断结果的公共部分 (关联 Pod 与事件)
+func (a *Analyzer) newControllerResult(kind string, meta metav1.ObjectMeta, selector *metav1.LabelSelector) WorkloadResult {
+	result := WorkloadResult{
+		Kind:      kind,
+		Name:      meta.Name,
+		Namespace: meta.Namespace,
+		Pods:      []PodSummary{},
+		Issues:    []Issue{},
+		Events:    a.getObjectEvents(meta.Namespace, meta.Name, meta.UID),
+	}
+
+	pods, err := a.listOwnedPods(meta.Namespace, selector, meta.UID)
+	if err != nil {
+		result.Issues = append(result.Issues, Issue{
+			Type:     "Warning",
+			Title:    "无法列出控制器管理的 Pod",
+			RawError: err.Error(),
+		})
+		return result
+	}
+	for i := range pods {
+		result.Pods = append(result.Pods, SummarizePod(&pods[i]))
+	}
+	result.Issues = append(result.Issues, CheckImageConsistency(pods)...)
+	return result
+}
+
+// appendControllerIssues 追加 FailedCreate 准入拒绝的问题
+// 当期望有 Pod 但一个都没有、又找不到具体原因时，给出兜底提示
+func (a *Analyzer) appendControllerIssues(result *WorkloadResult, meta metav1.ObjectMeta, expected int32) {
+	admissionIssues := a.GetAdmissionIssues(meta.Namespace, meta.Name, meta.UID)
+	result.Issues = append(result.Issues, admissionIssues...)
+
+	if expected > 0 && len(result.Pods) == 0 && len(admissionIssues) == 0 {
+		result.Issues = append(result.Issues, Issue{
+			Type:       "Warning",
+			Title:      "控制器期望创建 Pod，但当前没有任何 Pod",
+			Suggestion: "未找到 FailedCreate 事件 (事件可能已过期)，请检查控制器的 status.conditions 与 kube-controller-manager 日志",
+		})
+	}
+}
+
+// listOwnedPods 列出由指定控制器直接管理的 Pod (selector 匹配且 ownerReference 指向该控制器)
+func (a *Analyzer) listOwnedPods(namespace string, selector *metav1.LabelSelector, ownerUID types.UID) ([]corev1.Pod, error) {
+	opts := metav1.ListOptions{}
+	if selector != nil {
+		s, err := metav1.LabelSelectorAsSelector(selector)
+		if err != nil {
+			return nil, err
+		}
+		opts.LabelSelector = s.String()
+	}
+
+	podList, err := a.client.CoreV1().Pods(namespace).List(a.ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var pods []corev1.Pod
+	for _, pod := range podList.Items {
+		for _, ref := range pod.OwnerReferences {
+			if ref.UID == ownerUID {
+				pods = append(pods, pod)
+				break
+			}
+		}
+	}
+	return pods, nil
+}
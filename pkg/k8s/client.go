@@ -7,11 +7,14 @@ import (
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/clientcmd"
 	"k8s.io/client-go/util/homedir"
+	metricsclient "k8s.io/metrics/pkg/client/clientset/versioned"
 )
 
 // Client 封装了 Kubernetes 客户端集
 type Client struct {
 	Clientset *kubernetes.Clientset
+	// Metrics 是 metrics.k8s.io 客户端，集群未安装 metrics-server 时调用会返回错误，由调用方降级处理
+	Metrics metricsclient.Interface
 }
 
 // NewClient 初始化并返回一个 K8s 客户端
@@ -42,7 +45,11 @@ func NewClient() (*Client, error) {
 		return nil, err
 	}
 
-	return &Client{
-		Clientset: clientset,
-	}, nil
+	client := &Client{Clientset: clientset}
+
+	// metrics 客户端只是可选增强，创建失败不影响主流程
+	if metrics, err := metricsclient.NewForConfig(config); err == nil {
+		client.Metrics = metrics
+	}
+	return client, nil
 }
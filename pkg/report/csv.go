@@ -0,0 +1,48 @@
+package report
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+
+	"github.com/swfoodt/kubehealer/pkg/diagnosis"
+)
+
+// resourceCSVHeader 每个容器一行，数值列使用原始单位 (millicores / bytes) 方便在表格软件中计算
+var resourceCSVHeader = []string{
+	"namespace", "kind", "workload", "pods", "container", "status",
+	"cpu_request_m", "cpu_limit_m", "cpu_usage_m", "cpu_waste_m",
+	"memory_request_bytes", "memory_limit_bytes", "memory_usage_bytes", "memory_peak_bytes", "memory_waste_bytes",
+	"suggested_cpu_request", "suggested_cpu_limit", "suggested_memory_request", "suggested_memory_limit",
+}
+
+// WriteResourceCSV 将命名空间资源分析结果写为 CSV
+func WriteResourceCSV(w io.Writer, result *diagnosis.ResourceReport) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(resourceCSVHeader); err != nil {
+		return err
+	}
+
+	for _, wl := range result.Workloads {
+		for _, c := range wl.Containers {
+			var s diagnosis.SuggestedResources
+			if c.Suggested != nil {
+				s = *c.Suggested
+			}
+			row := []string{
+				result.Namespace, wl.Kind, wl.Name, fmt.Sprintf("%d", wl.Pods), c.Name, c.Status,
+				fmt.Sprintf("%d", c.CPURequestMilli), fmt.Sprintf("%d", c.CPULimitMilli),
+				fmt.Sprintf("%d", c.CPUUsageMilli), fmt.Sprintf("%d", c.CPUWasteMilli),
+				fmt.Sprintf("%d", c.MemoryRequestBytes), fmt.Sprintf("%d", c.MemoryLimitBytes),
+				fmt.Sprintf("%d", c.MemoryUsageBytes), fmt.Sprintf("%d", c.MemoryPeakBytes), fmt.Sprintf("%d", c.MemoryWasteBytes),
+				s.CPURequest, s.CPULimit, s.MemoryRequest, s.MemoryLimit,
+			}
+			if err := cw.Write(row); err != nil {
+				return err
+			}
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
@@ -24,7 +24,7 @@ func GenerateHTML(result diagnosis.DiagnosisResult, filename string) error {
 	}
 
 	// 2. 解析模板 (从 templates.go 中的常量读取)
-	tmpl, err := template.New("report").Parse(HTMLTemplate)
+	tmpl, err := parseHTMLTemplate("report", HTMLTemplate)
 	if err != nil {
 		return err
 	}
@@ -39,3 +39,58 @@ func GenerateHTML(result diagnosis.DiagnosisResult, filename string) error {
 	// 4. 渲染
 	return tmpl.Execute(f, data)
 }
+
+// containerView 是传给 "container" 子模板的数据，ID 用于生成页面内唯一的锚点
+// 工作负载报告中多个 Pod 可能有同名容器，因此 ID 带上 Pod 名称前缀
+type containerView struct {
+	diagnosis.ContainerDiagnosis
+	ID string
+}
+
+// containerViews 为容器列表生成子模板数据，prefix 为空时 ID 就是容器名
+func containerViews(prefix string, containers []diagnosis.ContainerDiagnosis) []containerView {
+	views := make([]containerView, len(containers))
+	for i, c := range containers {
+		views[i] = containerView{ContainerDiagnosis: c, ID: c.Name}
+		if prefix != "" {
+			views[i].ID = prefix + "-" + c.Name
+		}
+	}
+	return views
+}
+
+// parseHTMLTemplate 解析报告模板，并加载 Pod 报告与工作负载报告共用的子模板
+func parseHTMLTemplate(name, text string) (*template.Template, error) {
+	tmpl, err := template.New(name).Funcs(template.FuncMap{"containers": containerViews}).Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	return tmpl.Parse(sharedHTMLTemplates)
+}
+
+// WorkloadHTMLData 传给工作负载模板的数据结构
+type WorkloadHTMLData struct {
+	diagnosis.WorkloadResult
+	GenerateTime string
+}
+
+// GenerateWorkloadHTML 生成工作负载级 (Service / Deployment ...) 的 HTML 文件
+func GenerateWorkloadHTML(result diagnosis.WorkloadResult, filename string) error {
+	data := WorkloadHTMLData{
+		WorkloadResult: result,
+		GenerateTime:   time.Now().Format("2006-01-02 15:04:05"),
+	}
+
+	tmpl, err := parseHTMLTemplate("workload", WorkloadHTMLTemplate)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return tmpl.Execute(f, data)
+}
@@ -2,6 +2,7 @@ package report
 
 import (
 	"fmt"
+	"html"
 	"strings"
 	"time"
 
@@ -15,6 +16,7 @@ func GenerateMarkdown(result diagnosis.DiagnosisResult) string {
 	// 标题与元数据
 	sb.WriteString(fmt.Sprintf("# 🚑 KubeHealer 诊断报告: %s\n\n", result.PodName))
 	sb.WriteString(fmt.Sprintf("> 生成时间: %s\n\n", time.Now().Format("2006-01-02 15:04:05")))
+	writeRedactionNote(&sb, result.Redactions)
 
 	// 基础信息表格
 	sb.WriteString("## 1. 基础信息\n\n")
@@ -24,11 +26,145 @@ func GenerateMarkdown(result diagnosis.DiagnosisResult) string {
 	sb.WriteString(fmt.Sprintf("| **命名空间** | `%s` |\n", result.Namespace))
 	sb.WriteString(fmt.Sprintf("| **所在节点** | `%s` |\n", result.NodeName))
 	sb.WriteString(fmt.Sprintf("| **当前状态** | **%s** |\n", result.Phase))
-	sb.WriteString(fmt.Sprintf("| **重启次数** | %d |\n\n", result.RestartCount))
+	sb.WriteString(fmt.Sprintf("| **重启次数** | %d |\n", result.RestartCount))
+	if result.HPA != nil {
+		sb.WriteString(fmt.Sprintf("| **HPA** | %s |\n", formatHPA(result.HPA)))
+	}
+	for _, pdb := range result.PDBs {
+		sb.WriteString(fmt.Sprintf("| **PDB** | %s |\n", pdb.Summary()))
+	}
+	sb.WriteString("\n")
+
+	// Pod 级问题 (不属于某个具体容器)
+	if len(result.Issues) > 0 {
+		sb.WriteString("**🔍 Pod 级诊断发现:**\n\n")
+		writeIssueList(&sb, result.Issues)
+		sb.WriteString("\n")
+	}
+
+	// monitor 模式下触发本次诊断的日志
+	if alert := result.LogAlert; alert != nil {
+		sb.WriteString(fmt.Sprintf("**🔥 触发日志** (容器 `%s`，%s，%s):\n\n", alert.Container, alert.Pattern, alert.Time.Format("2006-01-02 15:04:05")))
+		sb.WriteString("```text\n" + strings.Join(alert.Lines, "\n") + "\n```\n\n")
+	}
 
 	// 容器分析
 	sb.WriteString("## 2. 容器深度分析\n\n")
-	for _, c := range result.Containers {
+	writeContainerSections(&sb, result.Containers, "###")
+
+	// 后续章节是否出现取决于结果内容，因此动态编号
+	section := 3
+
+	// 模板差异 (可能原因)
+	if result.TemplateDiff != nil && len(result.TemplateDiff.Changes) > 0 {
+		sb.WriteString(fmt.Sprintf("## %d. 模板变更 (可能原因)\n\n", section))
+		section++
+		writeTemplateDiff(&sb, result.TemplateDiff)
+	}
+
+	// 事件列表
+	sb.WriteString(fmt.Sprintf("## %d. 最近事件 (Events)\n\n", section))
+	if len(result.Events) == 0 {
+		sb.WriteString("*暂无事件记录*\n")
+	} else {
+		for _, e := range result.Events {
+			sb.WriteString(fmt.Sprintf("- %s\n", e))
+		}
+	}
+
+	return sb.String()
+}
+
+// GenerateWorkloadMarkdown 生成工作负载级 (Service / Deployment ...) 的 Markdown 诊断报告
+func GenerateWorkloadMarkdown(result diagnosis.WorkloadResult) string {
+	var sb strings.Builder
+
+	// 标题与元数据
+	sb.WriteString(fmt.Sprintf("# 🚑 KubeHealer 诊断报告: %s/%s\n\n", result.Kind, result.Name))
+	sb.WriteString(fmt.Sprintf("> 生成时间: %s\n\n", time.Now().Format("2006-01-02 15:04:05")))
+	writeRedactionNote(&sb, result.Redactions)
+
+	// 基础信息表格
+	sb.WriteString("## 1. 基础信息\n\n")
+	sb.WriteString("| 指标 | 值 |\n")
+	sb.WriteString("| :--- | :--- |\n")
+	sb.WriteString(fmt.Sprintf("| **类型** | `%s` |\n", result.Kind))
+	sb.WriteString(fmt.Sprintf("| **名称** | `%s` |\n", result.Name))
+	sb.WriteString(fmt.Sprintf("| **命名空间** | `%s` |\n", result.Namespace))
+	for _, d := range result.Details {
+		sb.WriteString(fmt.Sprintf("| **%s** | `%s` |\n", d.Label, d.Value))
+	}
+	sb.WriteString("\n")
+
+	// 诊断发现
+	sb.WriteString("## 2. 诊断发现\n\n")
+	if len(result.Issues) == 0 {
+		sb.WriteString("*未发现问题*\n\n")
+	}
+	writeIssueList(&sb, result.Issues)
+	sb.WriteString("\n")
+
+	// 关联 Pod
+	sb.WriteString("## 3. 关联 Pod\n\n")
+	if len(result.Pods) == 0 {
+		sb.WriteString("*无关联 Pod*\n\n")
+	} else {
+		sb.WriteString("| Pod | 状态 | 就绪 | 节点 | 重启 |\n")
+		sb.WriteString("| :--- | :--- | :--- | :--- | :--- |\n")
+		for _, p := range result.Pods {
+			ready := "❌"
+			if p.Ready {
+				ready = "✅"
+			}
+			sb.WriteString(fmt.Sprintf("| `%s` | %s | %s | `%s` | %d |\n", p.Name, p.Phase, ready, p.NodeName, p.RestartCount))
+		}
+		sb.WriteString("\n")
+	}
+
+	// 后续章节是否出现取决于结果内容，因此动态编号
+	section := 4
+
+	// 代表性 Pod 的详细诊断
+	if len(result.PodResults) > 0 {
+		sb.WriteString(fmt.Sprintf("## %d. 代表性 Pod 诊断\n\n", section))
+		section++
+		for _, pr := range result.PodResults {
+			sb.WriteString(fmt.Sprintf("### Pod: `%s` (%s, 重启 %d 次)\n\n", pr.PodName, pr.Phase, pr.RestartCount))
+			if len(pr.Issues) > 0 {
+				writeIssueList(&sb, pr.Issues)
+				sb.WriteString("\n")
+			}
+			writeContainerSections(&sb, pr.Containers, "####")
+			if pr.TemplateDiff != nil && len(pr.TemplateDiff.Changes) > 0 {
+				sb.WriteString("#### 🧬 模板变更 (可能原因)\n\n")
+				writeTemplateDiff(&sb, pr.TemplateDiff)
+			}
+		}
+	}
+
+	// 事件列表
+	sb.WriteString(fmt.Sprintf("## %d. 最近事件 (Events)\n\n", section))
+	if len(result.Events) == 0 {
+		sb.WriteString("*暂无事件记录*\n")
+	} else {
+		for _, e := range result.Events {
+			sb.WriteString(fmt.Sprintf("- %s\n", e))
+		}
+	}
+
+	return sb.String()
+}
+
+// writeRedactionNote 写入脱敏提示
+func writeRedactionNote(sb *strings.Builder, count int) {
+	if count > 0 {
+		sb.WriteString(fmt.Sprintf("> 🔒 已脱敏 %d 处敏感信息 (令牌、密码、连接串等)\n\n", count))
+	}
+}
+
+// writeContainerSections 写入容器深度分析部分，heading 为容器标题的 Markdown 级别 (例如 "###")
+func writeContainerSections(sb *strings.Builder, containers []diagnosis.ContainerDiagnosis, heading string) {
+	for _, c := range containers {
 		icon := "✅"
 		if c.State != "Running" {
 			icon = "⚠️"
@@ -41,9 +177,12 @@ func GenerateMarkdown(result diagnosis.DiagnosisResult) string {
 			}
 		}
 
-		sb.WriteString(fmt.Sprintf("### %s 容器: %s\n\n", icon, c.Name))
+		sb.WriteString(fmt.Sprintf("%s %s 容器: %s\n\n", heading, icon, c.Name))
 		sb.WriteString(fmt.Sprintf("- **状态**: %s\n", c.State))
 		sb.WriteString(fmt.Sprintf("- **资源配置**: `%s`\n", strings.ReplaceAll(c.ResourceInfo, "\n", " ")))
+		if c.Usage != nil {
+			sb.WriteString(fmt.Sprintf("- **资源使用**: `%s`\n", c.Usage.String()))
+		}
 
 		if c.Reason != "" {
 			sb.WriteString(fmt.Sprintf("- **原因**: %s\n", c.Reason))
@@ -73,18 +212,185 @@ func GenerateMarkdown(result diagnosis.DiagnosisResult) string {
 				sb.WriteString(">\n") // 空行分隔
 			}
 		}
+
+		// 日志不完整时提示，避免把截断后的日志当成全部
+		if len(c.LogTruncation) > 0 {
+			sb.WriteString(fmt.Sprintf("\n> ✂️ **日志不完整**: %s\n", strings.Join(c.LogTruncation, "；")))
+		}
+
+		// 最值得关注的几处命中及其上下文
+		if len(c.LogMatches) > 0 {
+			top := diagnosis.TopLogMatches(c.LogMatches, topLogMatches)
+			sb.WriteString(fmt.Sprintf("\n**🎯 日志命中** (共 %d 处，展示 %d 处，▶ 为命中行):\n\n", len(c.LogMatches), len(top)))
+			for _, m := range top {
+				sb.WriteString(fmt.Sprintf("- 第 %d 行 `%s`:\n\n```text\n", m.LineNo(), m.Pattern))
+				for i, line := range m.Before {
+					sb.WriteString(fmt.Sprintf("  %5d │ %s\n", m.Line-len(m.Before)+i+1, line))
+				}
+				sb.WriteString(fmt.Sprintf("▶ %5d │ %s\n", m.LineNo(), m.Text))
+				for i, line := range m.After {
+					sb.WriteString(fmt.Sprintf("  %5d │ %s\n", m.LineNo()+i+1, line))
+				}
+				sb.WriteString("```\n\n")
+			}
+		}
+
+		// 日志中提取的堆栈，完整内容折叠显示
+		if len(c.StackTraces) > 0 {
+			sb.WriteString("\n**🧵 日志堆栈:**\n\n")
+			for _, trace := range c.StackTraces {
+				sb.WriteString(fmt.Sprintf("<details><summary>%s</summary>\n\n", html.EscapeString(trace.Summary())))
+				sb.WriteString("```text\n")
+				sb.WriteString(strings.Join(trace.Lines, "\n"))
+				sb.WriteString("\n```\n\n</details>\n\n")
+			}
+		}
+
+		// 结构化 (JSON) 日志
+		if len(c.JSONLogs) > 0 {
+			sb.WriteString("\n**🧾 结构化日志:**\n\n")
+			for _, e := range c.JSONLogs {
+				sb.WriteString(fmt.Sprintf("- 第 %d 行 **%s** %s", e.LineNo(), strings.ToUpper(e.Level), e.Message))
+				if e.Error != "" {
+					sb.WriteString(fmt.Sprintf(" — `%s`", strings.ReplaceAll(e.Error, "`", "'")))
+				}
+				sb.WriteString("\n")
+				if e.StackTrace != "" {
+					sb.WriteString(fmt.Sprintf("  <details><summary>stacktrace</summary>\n\n  ```text\n%s\n  ```\n\n  </details>\n", e.StackTrace))
+				}
+			}
+		}
+
+		// 依赖故障及目标 Service 的状态
+		if len(c.Dependencies) > 0 {
+			sb.WriteString("\n**🔌 依赖故障**:\n\n")
+			sb.WriteString("| 类别 | 目标 | 原因 | 次数 | 集群内 Service |\n| :--- | :--- | :--- | :--- | :--- |\n")
+			for _, d := range c.Dependencies {
+				status := d.Service.Status()
+				if status == "" {
+					status = "-"
+				} else if !d.Service.Healthy() {
+					status = "❌ " + status
+				}
+				sb.WriteString(fmt.Sprintf("| %s | `%s` | %s | %d | %s |\n", d.Kind.Label(), d.Target(), escapeTableCell(d.Detail), d.Count, escapeTableCell(status)))
+			}
+		}
+
+		// 日志模板聚类，原始日志折叠显示
+		if len(c.LogTemplates) > 0 {
+			sb.WriteString(fmt.Sprintf("\n**📜 日志模板** (%d 行 → %d 个模板，🔎 为崩溃前出现的罕见日志):\n\n", len(c.Logs), len(c.LogTemplates)))
+			sb.WriteString("| | 次数 | 行号 | 模板 |\n| :--- | :--- | :--- | :--- |\n")
+			for _, t := range c.LogTemplates {
+				mark := ""
+				if t.Suspicious {
+					mark = "🔎"
+				}
+				sb.WriteString(fmt.Sprintf("| %s | %d | %s | `%s` |\n", mark, t.Count, t.Lines(), escapeTableCell(t.Template)))
+			}
+			sb.WriteString(fmt.Sprintf("\n<details><summary>原始日志 (%d 行)</summary>\n\n```text\n%s\n```\n\n</details>\n", len(c.Logs), strings.Join(c.Logs, "\n")))
+		}
+
+		// 深度模式的错误趋势
+		if trend := c.LogTrend; trend != nil {
+			sb.WriteString(fmt.Sprintf("\n**📈 日志趋势** (最近 %s，每段 %s，🔺 为最近一段突增):\n\n", trend.End.Sub(trend.Start), trend.BucketSize))
+			if len(trend.Truncation) > 0 {
+				sb.WriteString(fmt.Sprintf("> ✂️ %s\n\n", strings.Join(trend.Truncation, "；")))
+			}
+			if len(trend.Patterns) == 0 {
+				sb.WriteString("✅ 未发现错误模式\n")
+			} else {
+				sb.WriteString("| | 模式 | 趋势 | 次数 | 最近 / 之前 (次/分钟) |\n| :--- | :--- | :--- | :--- | :--- |\n")
+				for _, p := range trend.Patterns {
+					mark := ""
+					if p.Spike {
+						mark = "🔺"
+					}
+					sb.WriteString(fmt.Sprintf("| %s | %s | `%s` | %d | %.2f / %.2f |\n", mark, p.Pattern, p.Sparkline(), p.Total, p.RecentRate, p.BaselineRate))
+				}
+			}
+		}
+
+		// 上一次运行与当前运行的对比，并排的日志折叠显示
+		if cmp := c.RunComparison; cmp != nil {
+			sb.WriteString(fmt.Sprintf("\n**🔁 两次运行对比**: %s\n\n", cmp.ProgressDetail))
+			for _, t := range cmp.CrashOnly {
+				sb.WriteString(fmt.Sprintf("- ❗ 只在崩溃那次出现 (第 %s 行): `%s`\n", t.Lines(), escapeTableCell(t.Template)))
+			}
+			sb.WriteString(fmt.Sprintf("\n<details><summary>并排查看日志 (上次 %d 行 / 当前 %d 行，💥 为上次崩溃前的最后一条日志，📍 为当前运行中的同一位置)</summary>\n\n", len(cmp.Previous), len(cmp.Current)))
+			sb.WriteString("| 上次运行 (崩溃) | 当前运行 |\n| :--- | :--- |\n")
+			for i := 0; i < len(cmp.Previous) || i < len(cmp.Current); i++ {
+				var prev, cur string
+				if i < len(cmp.Previous) {
+					prev = markdownComparedCell(cmp.Previous[i], "💥")
+				}
+				if i < len(cmp.Current) {
+					cur = markdownComparedCell(cmp.Current[i], "📍")
+				}
+				sb.WriteString(fmt.Sprintf("| %s | %s |\n", prev, cur))
+			}
+			sb.WriteString("\n</details>\n")
+		}
 		sb.WriteString("\n---\n\n")
 	}
+}
 
-	// 事件列表
-	sb.WriteString("## 3. 最近事件 (Events)\n\n")
-	if len(result.Events) == 0 {
-		sb.WriteString("*暂无事件记录*\n")
-	} else {
-		for _, e := range result.Events {
-			sb.WriteString(fmt.Sprintf("- %s\n", e))
+// markdownComparedCell 对比表格中的一行日志，崩溃点与只在崩溃那次出现的错误加粗
+func markdownComparedCell(line diagnosis.ComparedLogLine, crashMark string) string {
+	if strings.TrimSpace(line.Text) == "" {
+		return ""
+	}
+	text := "`" + escapeTableCell(line.Text) + "`"
+	switch {
+	case line.CrashPoint:
+		return crashMark + " **" + text + "**"
+	case line.CrashOnly:
+		return "❗ **" + text + "**"
+	default:
+		return text
+	}
+}
+
+// escapeTableCell 转义表格单元格中的竖线，反引号替换为单引号 (单元格内容放在行内代码中)
+func escapeTableCell(s string) string {
+	return strings.NewReplacer("|", "\\|", "`", "'").Replace(s)
+}
+
+// writeTemplateDiff 以 diff 代码块的形式写入模板差异，便于在 Markdown 中高亮显示
+func writeTemplateDiff(sb *strings.Builder, diff *diagnosis.TemplateDiff) {
+	sb.WriteString(fmt.Sprintf("> ⚠️ **%s/%s** 当前版本 `%s` 相较上一健康版本 `%s` 共有 **%d** 处变更，请优先排查：\n\n",
+		diff.OwnerKind, diff.OwnerName, diff.NewRevision, diff.OldRevision, len(diff.Changes)))
+
+	sb.WriteString("```diff\n")
+	for _, c := range diff.Changes {
+		target := c.Field
+		if c.Container != "" {
+			target = fmt.Sprintf("[%s] %s", c.Container, c.Field)
+		}
+		sb.WriteString(fmt.Sprintf("# (%s) %s\n", c.Category, target))
+		if c.Old != "" {
+			sb.WriteString(fmt.Sprintf("- %s\n", c.Old))
+		}
+		if c.New != "" {
+			sb.WriteString(fmt.Sprintf("+ %s\n", c.New))
 		}
 	}
+	sb.WriteString("```\n\n")
+}
 
-	return sb.String()
+// writeIssueList 以引用块的形式写入问题列表
+func writeIssueList(sb *strings.Builder, issues []diagnosis.Issue) {
+	for _, issue := range issues {
+		prefix := "⚠️"
+		if issue.Type == "Error" {
+			prefix = "🛑"
+		}
+		sb.WriteString(fmt.Sprintf("> %s **%s**\n", prefix, issue.Title))
+		if issue.RawError != "" {
+			sb.WriteString(fmt.Sprintf("> *原始报错: %s*\n", issue.RawError))
+		}
+		if issue.Suggestion != "" {
+			sb.WriteString(fmt.Sprintf("> **💡 修复建议**: %s\n", issue.Suggestion))
+		}
+		sb.WriteString(">\n") // 空行分隔
+	}
 }
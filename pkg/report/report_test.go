@@ -0,0 +1,141 @@
+package report
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/swfoodt/kubehealer/pkg/diagnosis"
+)
+
+// newRolloutResult 构造一个滚动更新失败的 Deployment 诊断结果，代表性 Pod 带有模板差异
+func newRolloutResult() diagnosis.WorkloadResult {
+	return diagnosis.WorkloadResult{
+		Kind: "Deployment", Name: "web", Namespace: "default",
+		PodResults: []diagnosis.DiagnosisResult{{
+			PodName: "web-7c9d-x2x", Namespace: "default", Phase: "Running", RestartCount: 4,
+			Containers: []diagnosis.ContainerDiagnosis{{Name: "app", State: "Waiting", Reason: "CrashLoopBackOff"}},
+			TemplateDiff: &diagnosis.TemplateDiff{
+				OwnerKind: "Deployment", OwnerName: "web", OldRevision: "web-5f6b", NewRevision: "web-7c9d",
+				Changes: []diagnosis.TemplateChange{
+					{Category: "image", Container: "app", Field: "image", Old: "web:1.4.2", New: "web:1.5.0"},
+				},
+			},
+		}},
+	}
+}
+
+// renderHTML 把 generate 生成的 HTML 文件读回为字符串
+func renderHTML(t *testing.T, generate func(filename string) error) string {
+	t.Helper()
+	filename := filepath.Join(t.TempDir(), "report.html")
+	if err := generate(filename); err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+	out, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(out)
+}
+
+// captureStdout 返回 fn 执行期间写到标准输出的内容
+func captureStdout(t *testing.T, fn func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	fn()
+	w.Close()
+	out, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(out)
+}
+
+func TestWorkloadReports_TemplateDiff(t *testing.T) {
+	result := newRolloutResult()
+
+	tests := []struct {
+		name   string
+		render func(t *testing.T) string
+		want   []string
+	}{
+		{
+			name:   "table",
+			render: func(t *testing.T) string { return captureStdout(t, func() { PrintWorkloadTable(result) }) },
+			want:   []string{"模板变更 (可能原因): Deployment/web web-5f6b -> web-7c9d", "web:1.5.0"},
+		},
+		{
+			name:   "markdown",
+			render: func(t *testing.T) string { return GenerateWorkloadMarkdown(result) },
+			want:   []string{"**Deployment/web** 当前版本 `web-7c9d` 相较上一健康版本 `web-5f6b`", "- web:1.4.2\n+ web:1.5.0"},
+		},
+		{
+			name: "html",
+			render: func(t *testing.T) string {
+				return renderHTML(t, func(filename string) error { return GenerateWorkloadHTML(result, filename) })
+			},
+			want: []string{`<code class="text-white">web-5f6b</code>`, `<td class="diff-old">web:1.4.2</td>`, `<td class="diff-new">web:1.5.0</td>`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := tt.render(t)
+			for _, want := range tt.want {
+				if !strings.Contains(out, want) {
+					t.Errorf("report should contain %q, got:\n%s", want, out)
+				}
+			}
+		})
+	}
+}
+
+func TestWorkloadHTML_PodEvidence(t *testing.T) {
+	pod := diagnosis.DiagnosisResult{
+		PodName: "web-7c9d-x2x", Namespace: "default", Phase: "Running", RestartCount: 4,
+		Issues: []diagnosis.Issue{{Type: "Warning", Title: "镜像拉取缓慢"}},
+		Containers: []diagnosis.ContainerDiagnosis{{
+			Name: "app", State: "Waiting", Reason: "CrashLoopBackOff",
+			Issues:     []diagnosis.Issue{{Type: "Error", Title: "容器反复重启 (CrashLoopBackOff)"}},
+			Logs:       []string{"starting", "panic: nil map"},
+			LogMatches: []diagnosis.LogMatch{{Pattern: "go-panic", Line: 1, Text: "panic: nil map", Before: []string{"starting"}}},
+			StackTraces: []diagnosis.StackTrace{{
+				Pattern: "go-panic", ExceptionType: "panic", Message: "nil map", TopFrame: "main.go:42",
+				Lines: []string{"panic: nil map", "main.main()"},
+			}},
+		}},
+	}
+
+	// 工作负载报告中代表性 Pod 的证据与单 Pod 报告一致
+	want := []string{"镜像拉取缓慢", "容器反复重启 (CrashLoopBackOff)", "<mark>panic: nil map</mark>", "抛出位置: <code>main.go:42</code>", "main.main()"}
+	podHTML := renderHTML(t, func(filename string) error { return GenerateHTML(pod, filename) })
+	workloadHTML := renderHTML(t, func(filename string) error {
+		return GenerateWorkloadHTML(diagnosis.WorkloadResult{Kind: "Deployment", Name: "web", PodResults: []diagnosis.DiagnosisResult{pod}}, filename)
+	})
+	for _, w := range want {
+		if !strings.Contains(podHTML, w) {
+			t.Errorf("pod report should contain %q", w)
+		}
+		if !strings.Contains(workloadHTML, w) {
+			t.Errorf("workload report should contain %q", w)
+		}
+	}
+
+	// 单 Pod 报告的锚点保持为容器名；工作负载报告带上 Pod 名称，避免多个 Pod 的同名容器冲突
+	if !strings.Contains(podHTML, `id="log-app-2"`) || !strings.Contains(podHTML, `id="logs-app"`) {
+		t.Error("pod report anchors should use the container name")
+	}
+	if !strings.Contains(workloadHTML, `id="log-web-7c9d-x2x-app-2"`) || !strings.Contains(workloadHTML, `href="#log-web-7c9d-x2x-app-2"`) {
+		t.Error("workload report anchors should be prefixed with the pod name")
+	}
+}
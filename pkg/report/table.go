@@ -14,9 +14,22 @@ func PrintTable(result diagnosis.DiagnosisResult) {
 	fmt.Println()
 	printBasicInfo(result)
 	fmt.Println()
+	if len(result.Issues) > 0 {
+		fmt.Println("🔍 Pod 级诊断发现:")
+		printIssueList(result.Issues)
+		fmt.Println()
+	}
+	if alert := result.LogAlert; alert != nil {
+		fmt.Printf("🔥 触发日志 (容器 %s，%s):\n", alert.Container, alert.Pattern)
+		for _, line := range alert.Lines {
+			fmt.Printf("  %s\n", line)
+		}
+		fmt.Println()
+	}
 	printContainerInfo(result)
 	fmt.Println()
-	printEvents(result)
+	printTemplateDiff(result.TemplateDiff)
+	printEvents(result.Events)
 	fmt.Println()
 }
 
@@ -28,6 +41,15 @@ func printBasicInfo(result diagnosis.DiagnosisResult) {
 		{"当前状态", result.Phase},
 		{"重启总数", fmt.Sprintf("%d 次", result.RestartCount)},
 	}
+	if result.HPA != nil {
+		data = append(data, []string{"HPA", formatHPA(result.HPA)})
+	}
+	for _, pdb := range result.PDBs {
+		data = append(data, []string{"PDB", pdb.Summary()})
+	}
+	if result.Redactions > 0 {
+		data = append(data, []string{"脱敏", fmt.Sprintf("🔒 %d 处敏感信息已替换", result.Redactions)})
+	}
 
 	table := tablewriter.NewWriter(os.Stdout)
 	table.SetHeader([]string{"基础信息", "值"})
@@ -73,8 +95,21 @@ func printContainerInfo(result diagnosis.DiagnosisResult) {
 			}
 		}
 
-		// 3. 资源信息简化显示
+		// 3. 日志中提取的堆栈 (只显示异常类型与应用代码位置)
+		for _, trace := range c.StackTraces {
+			details = append(details, fmt.Sprintf("🧵 %s", trace.Summary()))
+		}
+
+		// 4. 日志不完整的原因
+		if len(c.LogTruncation) > 0 {
+			details = append(details, fmt.Sprintf("✂️ 日志不完整: %s", strings.Join(c.LogTruncation, "；")))
+		}
+
+		// 5. 资源信息简化显示
 		resInfo := strings.ReplaceAll(c.ResourceInfo, " | ", "\n")
+		if c.Usage != nil {
+			resInfo += "\n使用: " + strings.ReplaceAll(c.Usage.String(), " | ", "\n使用: ")
+		}
 
 		table.Append([]string{
 			c.Name,
@@ -86,16 +121,379 @@ func printContainerInfo(result diagnosis.DiagnosisResult) {
 
 	fmt.Println("📋 容器分析:")
 	table.Render()
+
+	for _, c := range result.Containers {
+		printLogMatches(c)
+		printDependencies(c)
+		printLogTemplates(c)
+		printRunComparison(c)
+		printLogTrend(c)
+		printJSONLogs(c)
+	}
+}
+
+// printDependencies 打印日志中识别出的依赖故障，以及目标在集群内对应的 Service 状态
+func printDependencies(c diagnosis.ContainerDiagnosis) {
+	if len(c.Dependencies) == 0 {
+		return
+	}
+	fmt.Printf("\n🔌 容器 %s 依赖故障:\n", c.Name)
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"类别", "目标", "原因", "次数", "集群内 Service"})
+	table.SetBorder(false)
+	table.SetAutoWrapText(false)
+	for _, d := range c.Dependencies {
+		status := d.Service.Status()
+		if status == "" {
+			status = "-"
+		} else if !d.Service.Healthy() {
+			status = "❌ " + status
+		}
+		table.Append([]string{d.Kind.Label(), d.Target(), d.Detail, fmt.Sprintf("%d", d.Count), status})
+	}
+	table.Render()
+}
+
+// printLogTrend 打印深度模式下各错误模式的趋势，🔺 为最近一段突增
+func printLogTrend(c diagnosis.ContainerDiagnosis) {
+	trend := c.LogTrend
+	if trend == nil {
+		return
+	}
+	total := 0
+	for _, n := range trend.Lines {
+		total += n
+	}
+	fmt.Printf("\n📈 容器 %s 日志趋势 (最近 %s 共 %d 行，每段 %s):\n", c.Name, trend.End.Sub(trend.Start), total, trend.BucketSize)
+	if len(trend.Truncation) > 0 {
+		fmt.Printf("  ✂️ %s\n", strings.Join(trend.Truncation, "；"))
+	}
+	if len(trend.Patterns) == 0 {
+		fmt.Println("  ✅ 未发现错误模式")
+		return
+	}
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"", "模式", "趋势", "次数", "最近 / 之前 (次/分钟)"})
+	table.SetBorder(false)
+	table.SetAutoWrapText(false)
+	for _, p := range trend.Patterns {
+		mark := ""
+		if p.Spike {
+			mark = "🔺"
+		}
+		table.Append([]string{mark, p.Pattern, p.Sparkline(), fmt.Sprintf("%d", p.Total), fmt.Sprintf("%.2f / %.2f", p.RecentRate, p.BaselineRate)})
+	}
+	table.Render()
+}
+
+// topLogMatches 终端与 Markdown 报告中展示的命中行数量
+const topLogMatches = 5
+
+// printLogMatches 打印最值得关注的几处命中及其上下文，▶ 为命中行
+func printLogMatches(c diagnosis.ContainerDiagnosis) {
+	if len(c.LogMatches) == 0 {
+		return
+	}
+	top := diagnosis.TopLogMatches(c.LogMatches, topLogMatches)
+	fmt.Printf("\n🎯 容器 %s 日志命中 (共 %d 处，展示 %d 处):\n", c.Name, len(c.LogMatches), len(top))
+	for _, m := range top {
+		fmt.Printf("  [%s] 第 %d 行\n", m.Pattern, m.LineNo())
+		for i, line := range m.Before {
+			fmt.Printf("      %5d │ %s\n", m.Line-len(m.Before)+i+1, line)
+		}
+		fmt.Printf("    ▶ %5d │ %s\n", m.LineNo(), m.Text)
+		for i, line := range m.After {
+			fmt.Printf("      %5d │ %s\n", m.LineNo()+i+1, line)
+		}
+	}
+}
+
+// comparisonTailLines 终端并排对比时每边显示的行数
+const comparisonTailLines = 15
+
+// printRunComparison 并排打印上一次 (崩溃的) 运行与当前运行的最后几行日志
+// 💥 为上次崩溃前的最后一条日志 (当前运行中用 📍 标出同一位置)，❗ 为只在崩溃那次出现的错误
+func printRunComparison(c diagnosis.ContainerDiagnosis) {
+	cmp := c.RunComparison
+	if cmp == nil {
+		return
+	}
+	fmt.Printf("\n🔁 容器 %s 两次运行对比: %s\n", c.Name, cmp.ProgressDetail)
+	for _, t := range cmp.CrashOnly {
+		fmt.Printf("  ❗ 只在崩溃那次出现 (第 %s 行): %s\n", t.Lines(), t.Template)
+	}
+
+	prev, cur := tailCompared(cmp.Previous), tailCompared(cmp.Current)
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{fmt.Sprintf("上次运行 (%d 行)", len(cmp.Previous)), fmt.Sprintf("当前运行 (%d 行)", len(cmp.Current))})
+	table.SetBorder(false)
+	table.SetAutoWrapText(false)
+	for i := 0; i < len(prev) || i < len(cur); i++ {
+		row := []string{"", ""}
+		if i < len(prev) {
+			row[0] = comparedCell(prev[i], "💥 ")
+		}
+		if i < len(cur) {
+			row[1] = comparedCell(cur[i], "📍 ")
+		}
+		table.Append(row)
+	}
+	table.Render()
+}
+
+func tailCompared(lines []diagnosis.ComparedLogLine) []diagnosis.ComparedLogLine {
+	if len(lines) > comparisonTailLines {
+		return lines[len(lines)-comparisonTailLines:]
+	}
+	return lines
+}
+
+// comparedCell 截断过长的日志行并加上标记
+func comparedCell(line diagnosis.ComparedLogLine, crashMark string) string {
+	text := []rune(line.Text)
+	if len(text) > 60 {
+		text = append(text[:59], '…')
+	}
+	switch {
+	case line.CrashPoint:
+		return crashMark + string(text)
+	case line.CrashOnly:
+		return "❗ " + string(text)
+	default:
+		return string(text)
+	}
+}
+
+// printJSONLogs 打印 warn 及以上级别的 JSON 日志 (级别、消息、错误，堆栈只显示第一帧)
+func printJSONLogs(c diagnosis.ContainerDiagnosis) {
+	if len(c.JSONLogs) == 0 {
+		return
+	}
+	fmt.Printf("\n🧾 容器 %s 结构化日志:\n", c.Name)
+	for _, e := range c.JSONLogs {
+		fmt.Printf("  #%-4d %s\n", e.LineNo(), e.Text())
+		if e.StackTrace != "" {
+			first, _, _ := strings.Cut(strings.TrimSpace(e.StackTrace), "\n")
+			fmt.Printf("        ↳ %s\n", strings.TrimSpace(first))
+		}
+	}
+}
+
+// printLogTemplates 打印日志模板聚类结果，可疑模板 (崩溃前的罕见日志) 用 🔎 标记
+func printLogTemplates(c diagnosis.ContainerDiagnosis) {
+	if len(c.LogTemplates) == 0 {
+		return
+	}
+	fmt.Printf("\n📜 容器 %s 日志模板 (%d 行 → %d 个模板):\n", c.Name, len(c.Logs), len(c.LogTemplates))
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"", "次数", "行号", "模板"})
+	table.SetBorder(false)
+	table.SetAutoWrapText(false)
+	for _, t := range c.LogTemplates {
+		mark := ""
+		if t.Suspicious {
+			mark = "🔎"
+		}
+		table.Append([]string{mark, fmt.Sprintf("%d", t.Count), t.Lines(), t.Template})
+	}
+	table.Render()
+}
+
+func printTemplateDiff(diff *diagnosis.TemplateDiff) {
+	if diff == nil || len(diff.Changes) == 0 {
+		return
+	}
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"类别", "容器", "字段", "旧值", "新值"})
+	table.SetRowLine(true)
+	for _, c := range diff.Changes {
+		table.Append([]string{c.Category, c.Container, c.Field, c.Old, c.New})
+	}
+
+	fmt.Printf("🧬 模板变更 (可能原因): %s/%s %s -> %s\n", diff.OwnerKind, diff.OwnerName, diff.OldRevision, diff.NewRevision)
+	table.Render()
+	fmt.Println()
 }
 
-func printEvents(result diagnosis.DiagnosisResult) {
-	if len(result.Events) == 0 {
+func printEvents(events []string) {
+	if len(events) == 0 {
 		return
 	}
 
 	// 直接打印文本列表
 	fmt.Println("🕒 最近事件 (Events):")
-	for _, e := range result.Events {
+	for _, e := range events {
 		fmt.Println("  " + e)
 	}
 }
+
+// PrintWorkloadTable 将工作负载级诊断结果渲染为终端表格
+func PrintWorkloadTable(result diagnosis.WorkloadResult) {
+	fmt.Println()
+	printWorkloadInfo(result)
+	fmt.Println()
+	printWorkloadPods(result)
+	fmt.Println()
+	printWorkloadIssues(result)
+	fmt.Println()
+	for _, pr := range result.PodResults {
+		fmt.Printf("🔬 代表性 Pod: %s (%s, 重启 %d 次)\n", pr.PodName, pr.Phase, pr.RestartCount)
+		if len(pr.Issues) > 0 {
+			printIssueList(pr.Issues)
+		}
+		printContainerInfo(pr)
+		fmt.Println()
+		printTemplateDiff(pr.TemplateDiff)
+	}
+	printEvents(result.Events)
+	fmt.Println()
+}
+
+func printWorkloadInfo(result diagnosis.WorkloadResult) {
+	data := [][]string{
+		{"类型", result.Kind},
+		{"名称", result.Name},
+		{"命名空间", result.Namespace},
+	}
+	for _, d := range result.Details {
+		data = append(data, []string{d.Label, d.Value})
+	}
+	if result.Redactions > 0 {
+		data = append(data, []string{"脱敏", fmt.Sprintf("🔒 %d 处敏感信息已替换", result.Redactions)})
+	}
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"基础信息", "值"})
+	table.SetBorder(false)
+	table.SetColumnColor(
+		tablewriter.Colors{tablewriter.Bold, tablewriter.FgHiCyanColor},
+		tablewriter.Colors{tablewriter.Normal},
+	)
+	table.AppendBulk(data)
+	table.Render()
+}
+
+func printWorkloadPods(result diagnosis.WorkloadResult) {
+	if len(result.Pods) == 0 {
+		return
+	}
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"Pod", "状态", "就绪", "节点", "重启"})
+	for _, p := range result.Pods {
+		ready := "❌"
+		if p.Ready {
+			ready = "✅"
+		}
+		table.Append([]string{p.Name, p.Phase, ready, p.NodeName, fmt.Sprintf("%d", p.RestartCount)})
+	}
+
+	fmt.Println("📦 关联 Pod:")
+	table.Render()
+}
+
+func printWorkloadIssues(result diagnosis.WorkloadResult) {
+	if len(result.Issues) == 0 {
+		fmt.Println("✅ 未发现问题")
+		return
+	}
+
+	fmt.Println("🔍 诊断发现:")
+	printIssueList(result.Issues)
+}
+
+func printIssueList(issues []diagnosis.Issue) {
+	for _, issue := range issues {
+		prefix := "⚠️"
+		if issue.Type == "Error" {
+			prefix = "🛑"
+		}
+		fmt.Printf("  %s %s\n", prefix, issue.Title)
+		if issue.RawError != "" {
+			fmt.Printf("     原始信息: %s\n", issue.RawError)
+		}
+		if issue.Suggestion != "" {
+			fmt.Printf("     💡 %s\n", issue.Suggestion)
+		}
+	}
+}
+
+// formatHPA 将 HPA 状态压缩为一行，例如 "web: 10/10 (min 2, max 10)"
+func formatHPA(hpa *diagnosis.HPAStatus) string {
+	return fmt.Sprintf("%s: %d/%d (min %d, max %d)",
+		hpa.Name, hpa.CurrentReplicas, hpa.DesiredReplicas, hpa.MinReplicas, hpa.MaxReplicas)
+}
+
+// PrintResourceTable 将命名空间资源分析结果渲染为终端表格，并输出每个容器的建议配置
+func PrintResourceTable(result *diagnosis.ResourceReport) {
+	fmt.Println()
+	fmt.Printf("📊 命名空间 %s 资源使用分析 (按影响排序)\n", result.Namespace)
+	if !result.MetricsAvailable {
+		fmt.Println("⚠️  metrics-server 不可用，仅统计 requests，无法计算浪费与不足")
+	}
+	fmt.Println()
+	if len(result.Workloads) == 0 {
+		fmt.Println("✅ 没有运行中的工作负载")
+		return
+	}
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"工作负载", "Pod", "CPU 请求/使用", "内存 请求/使用", "CPU 浪费", "内存 浪费", "状态"})
+	for _, w := range result.Workloads {
+		table.Append([]string{
+			w.Kind + "/" + w.Name,
+			fmt.Sprintf("%d", w.Pods),
+			diagnosis.FormatCPU(w.CPURequestMilli) + " / " + diagnosis.FormatCPU(w.CPUUsageMilli),
+			diagnosis.FormatMemory(w.MemoryRequestBytes) + " / " + diagnosis.FormatMemory(w.MemoryUsageBytes),
+			formatSignedCPU(w.CPUWasteMilli),
+			formatSignedMemory(w.MemoryWasteBytes),
+			provisionLabel(w.Status),
+		})
+	}
+	table.Render()
+	fmt.Println()
+
+	for _, w := range result.Workloads {
+		for _, c := range w.Containers {
+			if c.Suggested == nil || c.Status == diagnosis.ProvisionOK {
+				continue
+			}
+			fmt.Printf("💡 %s/%s 容器 %s (%s): CPU 平均 %s, 内存平均 %s / 峰值 %s\n",
+				w.Kind, w.Name, c.Name, provisionLabel(c.Status),
+				diagnosis.FormatCPU(c.CPUUsageMilli), diagnosis.FormatMemory(c.MemoryUsageBytes), diagnosis.FormatMemory(c.MemoryPeakBytes))
+			for _, line := range strings.Split(strings.TrimRight(c.Suggested.YAML(), "\n"), "\n") {
+				fmt.Println("   " + line)
+			}
+			fmt.Println()
+		}
+	}
+}
+
+// provisionLabel 返回资源配置状态的中文标签
+func provisionLabel(status string) string {
+	switch status {
+	case diagnosis.ProvisionOver:
+		return "⚠️ 浪费"
+	case diagnosis.ProvisionUnder:
+		return "🛑 不足"
+	case diagnosis.ProvisionOK:
+		return "✅ 合理"
+	}
+	return "❔ 无数据"
+}
+
+// formatSignedCPU 格式化浪费量，负数 (不足) 带 "-" 前缀
+func formatSignedCPU(milli int64) string {
+	if milli < 0 {
+		return "-" + diagnosis.FormatCPU(-milli)
+	}
+	return diagnosis.FormatCPU(milli)
+}
+
+func formatSignedMemory(bytes int64) string {
+	if bytes < 0 {
+		return "-" + diagnosis.FormatMemory(-bytes)
+	}
+	return diagnosis.FormatMemory(bytes)
+}
@@ -19,6 +19,9 @@ const HTMLTemplate = `
         .issue-error { border-left: 5px solid #dc3545; background-color: #fff5f5; }
         .issue-warning { border-left: 5px solid #ffc107; background-color: #fff3cd; }
         .event-icon { width: 20px; display: inline-block; text-align: center; }
+        .log-line-no { display: inline-block; min-width: 3.5em; color: #6c757d; user-select: none; }
+        .log-hit { background-color: rgba(220, 53, 69, 0.45); }
+        .log-hit:target { outline: 2px solid #ffc107; }
 		/* 时间轴样式 */
         .timeline { border-left: 2px solid #dee2e6; padding: 10px 0; margin-left: 20px; }
         .timeline-item { position: relative; padding-left: 30px; margin-bottom: 15px; }
@@ -28,6 +31,9 @@ const HTMLTemplate = `
         }
         .timeline-item.warning::before { background-color: #ffc107; }
         .timeline-date { font-size: 0.85em; color: #6c757d; margin-bottom: 2px; }
+        /* 模板差异高亮 */
+        .diff-old { background-color: #ffebe9; color: #82071e; text-decoration: line-through; font-family: monospace; }
+        .diff-new { background-color: #e6ffec; color: #116329; font-family: monospace; }
     </style>
 	<script src="https://cdn.jsdelivr.net/npm/bootstrap@5.3.0/dist/js/bootstrap.bundle.min.js"></script>
 </head>
@@ -36,6 +42,7 @@ const HTMLTemplate = `
         <div class="text-center mb-4">
             <h1>🚑 KubeHealer 诊断报告</h1>
             <p class="text-muted">生成时间: {{ .GenerateTime }}</p>
+            {{ if .Redactions }}<p class="text-muted">🔒 已脱敏 {{ .Redactions }} 处敏感信息 (令牌、密码、连接串等)</p>{{ end }}
         </div>
 
         <div class="card">
@@ -50,11 +57,240 @@ const HTMLTemplate = `
                 <div class="mt-2">
                     <strong>当前状态:</strong> <span class="badge bg-info text-dark">{{ .Phase }}</span>
                 </div>
+                {{ with .HPA }}
+                <div class="mt-2">
+                    <strong>HPA:</strong> {{ .Name }} &rarr; {{ .Target }}
+                    <span class="badge bg-secondary">当前 {{ .CurrentReplicas }} / 期望 {{ .DesiredReplicas }}</span>
+                    <span class="badge bg-light text-dark">min {{ .MinReplicas }}, max {{ .MaxReplicas }}</span>
+                    {{ if .RecentScales }}<small class="text-muted ms-2">最近扩缩容: {{ range $i, $s := .RecentScales }}{{ if $i }} &rarr; {{ end }}{{ $s }}{{ end }}</small>{{ end }}
+                </div>
+                {{ end }}
+                {{ range .PDBs }}
+                <div class="mt-2">
+                    <strong>PDB:</strong> {{ .Summary }}
+                    {{ if .Blocking }}<span class="badge bg-danger">永久阻止驱逐</span>{{ end }}
+                </div>
+                {{ end }}
             </div>
         </div>
 
+        {{ if .Issues }}
+        <h3>Pod 级诊断发现</h3>
+        {{ template "issues" .Issues }}
+        {{ end }}
+
+        {{ with .LogAlert }}
+        <h3>触发日志</h3>
+        <p class="text-muted">🔥 容器 <code>{{ .Container }}</code> 在 {{ .Time.Format "2006-01-02 15:04:05" }} 命中严重模式 <strong>{{ .Pattern }}</strong>:</p>
+        <pre class="bg-dark text-white p-2" style="font-size: 0.85em; max-height: 300px; overflow-y: auto;">{{ range .Lines }}{{ . }}
+{{ end }}</pre>
+        {{ end }}
+
         <h3>容器深度分析</h3>
-        {{ range .Containers }}
+        {{ range containers "" .Containers }}{{ template "container" . }}{{ end }}
+
+        {{ with .TemplateDiff }}{{ if .Changes }}
+        <h3>模板变更 (可能原因)</h3>
+        {{ template "templateDiff" . }}
+        {{ end }}{{ end }}
+
+        <h3>最近事件 (Timeline)</h3>
+        <div class="card">
+            <div class="card-body">
+                {{ if not .Events }}
+                    <p class="text-muted">暂无事件记录</p>
+                {{ else }}
+                    <div class="timeline">
+                    {{ range .Events }}
+                        <div class="timeline-item">
+                            <div class="timeline-content">{{ . }}</div>
+                        </div>
+                    {{ end }}
+                    </div>
+                {{ end }}
+            </div>
+        </div>
+        
+        <footer class="text-center mt-4 mb-4 text-muted">
+            <small>Generated by KubeHealer</small>
+        </footer>
+    </div>
+    <script>
+        // 点击命中行号时先展开折叠的原始日志，再跳转到对应的行
+        document.querySelectorAll('a.log-jump').forEach(function (a) {
+            a.addEventListener('click', function () {
+                var target = document.querySelector(a.getAttribute('href'));
+                var box = target && target.closest('.collapse');
+                if (box) { box.classList.add('show'); }
+            });
+        });
+    </script>
+</body>
+</html>
+`
+
+// WorkloadHTMLTemplate 定义了工作负载级 (Service / Deployment ...) 诊断报告的网页结构
+// 样式与 HTMLTemplate 保持一致
+const WorkloadHTMLTemplate = `
+<!DOCTYPE html>
+<html lang="zh-CN">
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <title>KubeHealer 诊断报告 - {{ .Kind }}/{{ .Name }}</title>
+    <link href="https://cdn.jsdelivr.net/npm/bootstrap@5.3.0/dist/css/bootstrap.min.css" rel="stylesheet">
+    <style>
+        body { background-color: #f8f9fa; padding-top: 20px; }
+        .card { margin-bottom: 20px; box-shadow: 0 2px 4px rgba(0,0,0,0.1); }
+        .issue-error { border-left: 5px solid #dc3545; background-color: #fff5f5; }
+        .issue-warning { border-left: 5px solid #ffc107; background-color: #fff3cd; }
+        .log-line-no { display: inline-block; min-width: 3.5em; color: #6c757d; user-select: none; }
+        .log-hit { background-color: rgba(220, 53, 69, 0.45); }
+        .log-hit:target { outline: 2px solid #ffc107; }
+        .timeline { border-left: 2px solid #dee2e6; padding: 10px 0; margin-left: 20px; }
+        .timeline-item { position: relative; padding-left: 30px; margin-bottom: 15px; }
+        .timeline-item::before { 
+            content: ''; position: absolute; left: -6px; top: 5px; width: 10px; height: 10px; 
+            border-radius: 50%; background-color: #0d6efd; border: 2px solid #fff; 
+        }
+        .diff-old { background-color: #ffebe9; color: #82071e; text-decoration: line-through; font-family: monospace; }
+        .diff-new { background-color: #e6ffec; color: #116329; font-family: monospace; }
+    </style>
+	<script src="https://cdn.jsdelivr.net/npm/bootstrap@5.3.0/dist/js/bootstrap.bundle.min.js"></script>
+</head>
+<body>
+    <div class="container">
+        <div class="text-center mb-4">
+            <h1>🚑 KubeHealer 诊断报告</h1>
+            <p class="text-muted">生成时间: {{ .GenerateTime }}</p>
+            {{ if .Redactions }}<p class="text-muted">🔒 已脱敏 {{ .Redactions }} 处敏感信息 (令牌、密码、连接串等)</p>{{ end }}
+        </div>
+
+        <div class="card">
+            <div class="card-header bg-primary text-white">基础信息</div>
+            <div class="card-body">
+                <div class="row">
+                    <div class="col-md-4"><strong>类型:</strong> {{ .Kind }}</div>
+                    <div class="col-md-4"><strong>名称:</strong> {{ .Name }}</div>
+                    <div class="col-md-4"><strong>命名空间:</strong> {{ .Namespace }}</div>
+                </div>
+                {{ if .Details }}
+                <table class="table table-sm mt-3 mb-0">
+                    <tbody>
+                    {{ range .Details }}
+                        <tr><th style="width: 25%">{{ .Label }}</th><td><code>{{ .Value }}</code></td></tr>
+                    {{ end }}
+                    </tbody>
+                </table>
+                {{ end }}
+            </div>
+        </div>
+
+        <h3>诊断发现</h3>
+        {{ if not .Issues }}
+        <div class="alert alert-success">✅ 未发现问题</div>
+        {{ end }}
+        {{ template "issues" .Issues }}
+
+        <h3>关联 Pod</h3>
+        <div class="card">
+            <div class="card-body">
+                {{ if not .Pods }}
+                    <p class="text-muted">无关联 Pod</p>
+                {{ else }}
+                <table class="table table-striped mb-0">
+                    <thead><tr><th>Pod</th><th>状态</th><th>就绪</th><th>节点</th><th>重启</th></tr></thead>
+                    <tbody>
+                    {{ range .Pods }}
+                        <tr>
+                            <td>{{ .Name }}</td>
+                            <td>{{ .Phase }}</td>
+                            <td>{{ if .Ready }}<span class="badge bg-success">Ready</span>{{ else }}<span class="badge bg-danger">NotReady</span>{{ end }}</td>
+                            <td>{{ .NodeName }}</td>
+                            <td>{{ .RestartCount }}</td>
+                        </tr>
+                    {{ end }}
+                    </tbody>
+                </table>
+                {{ end }}
+            </div>
+        </div>
+
+        {{ if .PodResults }}
+        <h3>代表性 Pod 诊断</h3>
+        {{ range .PodResults }}
+        <div class="card">
+            <div class="card-header">
+                🔬 Pod: <strong>{{ .PodName }}</strong>
+                <span class="badge bg-info text-dark ms-2">{{ .Phase }}</span>
+                <span class="badge bg-secondary ms-1">重启 {{ .RestartCount }} 次</span>
+            </div>
+            <div class="card-body">
+                {{ template "issues" .Issues }}
+                {{ range containers .PodName .Containers }}{{ template "container" . }}{{ end }}
+                {{ with .TemplateDiff }}{{ if .Changes }}
+                {{ template "templateDiff" . }}
+                {{ end }}{{ end }}
+            </div>
+        </div>
+        {{ end }}
+        {{ end }}
+
+        <h3>最近事件 (Timeline)</h3>
+        <div class="card">
+            <div class="card-body">
+                {{ if not .Events }}
+                    <p class="text-muted">暂无事件记录</p>
+                {{ else }}
+                    <div class="timeline">
+                    {{ range .Events }}
+                        <div class="timeline-item">
+                            <div class="timeline-content">{{ . }}</div>
+                        </div>
+                    {{ end }}
+                    </div>
+                {{ end }}
+            </div>
+        </div>
+
+        <footer class="text-center mt-4 mb-4 text-muted">
+            <small>Generated by KubeHealer</small>
+        </footer>
+    </div>
+    <script>
+        // 点击命中行号时先展开折叠的原始日志，再跳转到对应的行
+        document.querySelectorAll('a.log-jump').forEach(function (a) {
+            a.addEventListener('click', function () {
+                var target = document.querySelector(a.getAttribute('href'));
+                var box = target && target.closest('.collapse');
+                if (box) { box.classList.add('show'); }
+            });
+        });
+    </script>
+</body>
+</html>
+`
+
+// sharedHTMLTemplates 是 HTMLTemplate 与 WorkloadHTMLTemplate 共用的子模板
+const sharedHTMLTemplates = `
+{{ define "issues" }}
+        {{ range . }}
+        <div class="alert {{ if eq .Type "Error" }}issue-error{{ else }}issue-warning{{ end }}">
+            <h5 class="alert-heading">
+                {{ if eq .Type "Error" }}🛑{{ else }}⚠️{{ end }} {{ .Title }}
+            </h5>
+            {{ if .RawError }}
+            <p class="mb-1 text-muted"><small>原始报错: {{ .RawError }}</small></p>
+            {{ end }}
+            {{ if .Suggestion }}
+            <hr>
+            <p class="mb-0"><strong>💡 修复建议:</strong> {{ .Suggestion }}</p>
+            {{ end }}
+        </div>
+        {{ end }}
+{{ end }}
+
+{{ define "container" }}{{ $c := . }}
         <div class="card">
             <div class="card-header d-flex justify-content-between align-items-center">
                 <span>📦 容器: <strong>{{ .Name }}</strong></span>
@@ -62,6 +298,7 @@ const HTMLTemplate = `
             </div>
             <div class="card-body">
                 <p><strong>资源配置:</strong> <code>{{ .ResourceInfo }}</code></p>
+                {{ with .Usage }}<p><strong>资源使用:</strong> <code>{{ .String }}</code></p>{{ end }}
                 
                 {{ if .Reason }}
                 <p><strong>原因:</strong> {{ .Reason }}</p>
@@ -73,34 +310,137 @@ const HTMLTemplate = `
                 </div>
                 {{ end }}
 
-                {{ range .Issues }}
-                <div class="alert {{ if eq .Type "Error" }}issue-error{{ else }}issue-warning{{ end }}">
-                    <h5 class="alert-heading">
-                        {{ if eq .Type "Error" }}🛑{{ else }}⚠️{{ end }} {{ .Title }}
-                    </h5>
-                    {{ if .RawError }}
-                    <p class="mb-1 text-muted"><small>原始报错: {{ .RawError }}</small></p>
-                    {{ end }}
-                    {{ if .Suggestion }}
-                    <hr>
-                    <p class="mb-0"><strong>💡 修复建议:</strong> {{ .Suggestion }}</p>
-                    {{ end }}
-                </div>
-                {{ end }}
+                {{ template "issues" .Issues }}
 
 				{{ if .Logs }}
                 <div class="mt-3">
-                    <button class="btn btn-outline-secondary btn-sm" type="button" data-bs-toggle="collapse" data-bs-target="#logs-{{ .Name }}">
+                    <button class="btn btn-outline-secondary btn-sm" type="button" data-bs-toggle="collapse" data-bs-target="#logs-{{ .ID }}">
                         📄 查看容器日志 (最后 {{ len .Logs }} 行)
                     </button>
                     {{ if .LogKeywords }}
                     <span class="badge bg-danger ms-2">发现关键词: {{ range .LogKeywords }}{{ . }} {{ end }}</span>
                     {{ end }}
+                    {{ if .LogMatches }}
+                    <ul class="list-group mt-2" style="font-size: 0.85em;">
+                        {{ range .LogMatches }}
+                        <li class="list-group-item py-1">
+                            <span class="badge bg-danger">{{ .Pattern }}</span>
+                            <a class="log-jump ms-1" href="#log-{{ $c.ID }}-{{ .LineNo }}">第 {{ .LineNo }} 行</a>
+                            <pre class="bg-light p-1 mt-1 mb-0">{{ range .Before }}{{ . }}
+{{ end }}<mark>{{ .Text }}</mark>{{ range .After }}
+{{ . }}{{ end }}</pre>
+                        </li>
+                        {{ end }}
+                    </ul>
+                    {{ end }}
+                    {{ if .Dependencies }}
+                    <table class="table table-sm mt-2 mb-1" style="font-size: 0.85em;">
+                        <thead><tr><th>🔌 依赖故障</th><th>目标</th><th>次数</th><th>集群内 Service</th></tr></thead>
+                        <tbody>
+                        {{ range .Dependencies }}
+                        <tr{{ if and .Service (not .Service.Healthy) }} class="table-danger"{{ end }}>
+                            <td>{{ .Kind.Label }}{{ if .Detail }} <span class="text-muted">({{ .Detail }})</span>{{ end }}</td>
+                            <td class="font-monospace" title="{{ .Sample }}">{{ .Target }} <a class="log-jump" href="#log-{{ $c.ID }}-{{ .LineNo }}">第 {{ .LineNo }} 行</a></td>
+                            <td>{{ .Count }}</td>
+                            <td>{{ with .Service }}{{ .Status }}{{ else }}-{{ end }}</td>
+                        </tr>
+                        {{ end }}
+                        </tbody>
+                    </table>
+                    {{ end }}
+                    {{ if .LogTruncation }}
+                    <div class="text-muted small mt-1">✂️ 日志不完整: {{ range $i, $r := .LogTruncation }}{{ if $i }}；{{ end }}{{ $r }}{{ end }}</div>
+                    {{ end }}
                     
-                    <div class="collapse mt-2" id="logs-{{ .Name }}">
+                    {{ if .JSONLogs }}
+                    <ul class="list-group mt-2" style="font-size: 0.85em;">
+                        {{ range .JSONLogs }}
+                        <li class="list-group-item">
+                            <span class="badge {{ if or (eq .Level "error") (eq .Level "fatal") }}bg-danger{{ else if eq .Level "warn" }}bg-warning text-dark{{ else }}bg-secondary{{ end }}">{{ if .Level }}{{ .Level }}{{ else }}json{{ end }}</span>
+                            <small class="text-muted">#{{ .LineNo }}</small>
+                            {{ .Message }}
+                            {{ if .Error }}<br><code>{{ .Error }}</code>{{ end }}
+                            {{ if .StackTrace }}<pre class="bg-light p-2 mt-1 mb-0" style="max-height: 200px; overflow-y: auto;">{{ .StackTrace }}</pre>{{ end }}
+                        </li>
+                        {{ end }}
+                    </ul>
+                    {{ end }}
+
+                    {{ if .LogTemplates }}
+                    <table class="table table-sm table-hover mt-2 mb-1" style="font-size: 0.85em;">
+                        <thead><tr><th style="width: 4em;">次数</th><th style="width: 6em;">行号</th><th>日志模板 ({{ len .Logs }} 行 → {{ len .LogTemplates }} 个模板)</th></tr></thead>
+                        <tbody>
+                        {{ range .LogTemplates }}
+                        <tr{{ if .Suspicious }} class="table-warning"{{ end }}>
+                            <td>{{ .Count }}</td>
+                            <td>{{ .Lines }}</td>
+                            <td class="font-monospace" title="{{ .Sample }}">{{ if .Suspicious }}<span class="badge bg-warning text-dark me-1">🔎 崩溃前罕见</span>{{ end }}{{ .Template }}</td>
+                        </tr>
+                        {{ end }}
+                        </tbody>
+                    </table>
+                    {{ end }}
+
+                    {{ with .LogTrend }}
+                    <table class="table table-sm mt-2 mb-1" style="font-size: 0.85em;">
+                        <thead><tr><th colspan="4">📈 日志趋势 (每段 {{ .BucketSize }}，🔺 为最近一段突增){{ range .Truncation }} <span class="text-muted fw-normal">✂️ {{ . }}</span>{{ end }}</th></tr></thead>
+                        <tbody>
+                        {{ range .Patterns }}
+                        <tr{{ if .Spike }} class="table-danger"{{ end }}>
+                            <td>{{ if .Spike }}🔺 {{ end }}{{ .Pattern }}</td>
+                            <td class="font-monospace">{{ .Sparkline }}</td>
+                            <td>{{ .Total }} 次</td>
+                            <td>最近 {{ printf "%.2f" .RecentRate }} / 之前 {{ printf "%.2f" .BaselineRate }} 次/分钟</td>
+                        </tr>
+                        {{ else }}
+                        <tr><td colspan="4">✅ 未发现错误模式</td></tr>
+                        {{ end }}
+                        </tbody>
+                    </table>
+                    {{ end }}
+
+                    {{ with .RunComparison }}
+                    <div class="card mt-2">
+                        <div class="card-header py-1" style="font-size: 0.9em;">
+                            🔁 两次运行对比:
+                            <span class="badge {{ if eq .Progress "passed" }}bg-success{{ else if eq .Progress "at_crash_point" }}bg-danger{{ else }}bg-secondary{{ end }}">{{ .ProgressDetail }}</span>
+                        </div>
+                        <div class="card-body py-2">
+                            {{ range .CrashOnly }}
+                            <div class="small text-danger">❗ 只在崩溃那次出现 (第 {{ .Lines }} 行): <code>{{ .Template }}</code></div>
+                            {{ end }}
+                            <div class="row g-2 mt-1">
+                                <div class="col-md-6">
+                                    <div class="small text-muted">上次运行 (崩溃，{{ len .Previous }} 行)</div>
+                                    <div class="bg-dark text-white font-monospace p-2" style="font-size: 0.8em; max-height: 300px; overflow-y: auto;">
+                                        {{ range .Previous }}<div class="{{ if .CrashPoint }}bg-danger{{ else if .CrashOnly }}text-warning fw-bold{{ end }}">{{ if .CrashPoint }}💥 {{ end }}{{ .Text }}</div>{{ end }}
+                                    </div>
+                                </div>
+                                <div class="col-md-6">
+                                    <div class="small text-muted">当前运行 ({{ len .Current }} 行)</div>
+                                    <div class="bg-dark text-white font-monospace p-2" style="font-size: 0.8em; max-height: 300px; overflow-y: auto;">
+                                        {{ range .Current }}<div class="{{ if .CrashPoint }}bg-primary{{ end }}">{{ if .CrashPoint }}📍 {{ end }}{{ .Text }}</div>{{ end }}
+                                    </div>
+                                </div>
+                            </div>
+                        </div>
+                    </div>
+                    {{ end }}
+
+                    {{ range $i, $t := .StackTraces }}
+                    <div class="alert alert-warning mt-2 mb-0 py-2">
+                        🧵 <strong>{{ if $t.ExceptionType }}{{ $t.ExceptionType }}{{ else }}{{ $t.Pattern }}{{ end }}</strong>{{ if $t.Message }}: {{ $t.Message }}{{ end }}
+                        {{ if $t.TopFrame }}<br><small>抛出位置: <code>{{ $t.TopFrame }}</code></small>{{ end }}
+                        <button class="btn btn-link btn-sm p-0 ms-2" type="button" data-bs-toggle="collapse" data-bs-target="#trace-{{ $c.ID }}-{{ $i }}">完整堆栈 ({{ len $t.Lines }} 行)</button>
+                        <pre class="collapse mt-2 mb-0 bg-dark text-white p-2" style="font-size: 0.85em; max-height: 300px; overflow-y: auto;" id="trace-{{ $c.ID }}-{{ $i }}">{{ range $t.Lines }}{{ . }}
+{{ end }}</pre>
+                    </div>
+                    {{ end }}
+
+                    <div class="collapse mt-2" id="logs-{{ .ID }}">
                         <div class="card card-body bg-dark text-white font-monospace" style="font-size: 0.85em; max-height: 300px; overflow-y: auto;">
-                            {{ range .Logs }}
-                            <div>{{ . }}</div>
+                            {{ range .LogLines }}
+                            <div id="log-{{ $c.ID }}-{{ .No }}"{{ if .Patterns }} class="log-hit" title="{{ range $i, $p := .Patterns }}{{ if $i }}, {{ end }}{{ $p }}{{ end }}"{{ end }}><span class="log-line-no">{{ .No }}</span>{{ .Text }}</div>
                             {{ end }}
                         </div>
                     </div>
@@ -108,29 +448,29 @@ const HTMLTemplate = `
                 {{ end }}
             </div>
         </div>
-        {{ end }}
-
-        <h3>最近事件 (Timeline)</h3>
-        <div class="card">
+{{ end }}
+{{ define "templateDiff" }}
+        <div class="card border-danger">
+            <div class="card-header bg-danger text-white">
+                🧬 {{ .OwnerKind }}/{{ .OwnerName }}: <code class="text-white">{{ .OldRevision }}</code> → <code class="text-white">{{ .NewRevision }}</code>
+            </div>
             <div class="card-body">
-                {{ if not .Events }}
-                    <p class="text-muted">暂无事件记录</p>
-                {{ else }}
-                    <div class="timeline">
-                    {{ range .Events }}
-                        <div class="timeline-item">
-                            <div class="timeline-content">{{ . }}</div>
-                        </div>
+                <p class="mb-2">当前失败版本相较上一健康版本共有 <strong>{{ len .Changes }}</strong> 处变更，请优先排查：</p>
+                <table class="table table-sm mb-0">
+                    <thead><tr><th>类别</th><th>容器</th><th>字段</th><th>旧值</th><th>新值</th></tr></thead>
+                    <tbody>
+                    {{ range .Changes }}
+                        <tr>
+                            <td><span class="badge bg-secondary">{{ .Category }}</span></td>
+                            <td>{{ .Container }}</td>
+                            <td><code>{{ .Field }}</code></td>
+                            <td class="diff-old">{{ .Old }}</td>
+                            <td class="diff-new">{{ .New }}</td>
+                        </tr>
                     {{ end }}
-                    </div>
-                {{ end }}
+                    </tbody>
+                </table>
             </div>
         </div>
-        
-        <footer class="text-center mt-4 mb-4 text-muted">
-            <small>Generated by KubeHealer</small>
-        </footer>
-    </div>
-</body>
-</html>
+{{ end }}
 `